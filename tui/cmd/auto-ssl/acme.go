@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/acme"
+	"github.com/Brightblade42/auto-ssl/internal/agent"
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// acmeAccountKeyName is the default account key file in the config dir.
+const acmeAccountKeyName = "acme-account.key"
+
+func runACME(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools acme obtain|renew ...")
+	}
+
+	switch args[0] {
+	case "obtain":
+		return runACMEObtain(args[1:])
+	case "renew":
+		return runACMERenew(args[1:])
+	default:
+		return fmt.Errorf("unknown acme command: %s", args[0])
+	}
+}
+
+// runACMEObtain requests a certificate with the settings given as flags.
+// `server enroll --acme` uses it before saving the settings to the config.
+func runACMEObtain(args []string) error {
+	req := acme.Request{
+		Challenge:  acme.HTTP01,
+		AccountKey: filepath.Join(config.ConfigDir(), acmeAccountKeyName),
+		CertPath:   filepath.Join(config.CertDir(), "server.crt"),
+		KeyPath:    filepath.Join(config.CertDir(), "server.key"),
+	}
+	root := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--directory", "--san", "--challenge", "--webroot", "--port", "--email", "--root", "--account-key", "--cert-path", "--key-path":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--directory":
+				req.Directory = value
+			case "--san":
+				req.Names = append(req.Names, value)
+			case "--challenge":
+				req.Challenge = value
+			case "--webroot":
+				req.Webroot = value
+			case "--port":
+				port, err := strconv.Atoi(value)
+				if err != nil || port < 1 || port > 65535 {
+					return fmt.Errorf("--port must be a TCP port, got %q", value)
+				}
+				req.Port = port
+			case "--email":
+				req.Email = value
+			case "--root":
+				root = value
+			case "--account-key":
+				req.AccountKey = value
+			case "--cert-path":
+				req.CertPath = value
+			case "--key-path":
+				req.KeyPath = value
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+	if req.Directory == "" || len(req.Names) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools acme obtain --directory URL --san NAME... [--challenge http-01|tls-alpn-01] [--webroot DIR] [--port N] [--email ADDR] [--root FILE] [--account-key FILE] [--cert-path FILE] [--key-path FILE]")
+	}
+	roots, err := loadRoots(root)
+	if err != nil {
+		return err
+	}
+	req.RootCAs = roots
+	return obtainCertificate(req)
+}
+
+// runACMERenew renews the certificates enrolled with --acme (or the one
+// named with --name) with the settings `server enroll` saved. Without
+// --force a certificate is only renewed once it is due (renew_at of its
+// lifetime, 2/3 by default).
+func runACMERenew(args []string) error {
+	force := false
+	name := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--force":
+			force = true
+		case "--name":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--name requires a value")
+			}
+			name = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Server.Suspended && !force {
+		fmt.Println("Renewal is suspended; not renewing")
+		return nil
+	}
+
+	var certs []config.Certificate
+	if name != "" {
+		cert, err := cfg.Server.FindCertificate(name)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	} else {
+		for _, cert := range cfg.Server.CertificateList() {
+			if cert.Enrollment == "acme" {
+				certs = append(certs, cert)
+			}
+		}
+		if len(certs) == 0 {
+			return fmt.Errorf("this server was not enrolled with --acme")
+		}
+	}
+
+	var errs []error
+	for _, cert := range certs {
+		if err := acmeRenew(cert, force); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cert.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func acmeRenew(cert config.Certificate, force bool) error {
+	req, err := acmeRequest(cert)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		renewAt := agent.DefaultRenewAt
+		if cert.RenewAt != "" {
+			if renewAt, err = config.ParseFraction(cert.RenewAt); err != nil {
+				return fmt.Errorf("renew_at: %w", err)
+			}
+		}
+		if chain, err := certinfo.LoadFile(req.CertPath); err == nil {
+			current := chain[0]
+			lifetime := current.NotAfter.Sub(current.NotBefore)
+			due := current.NotBefore.Add(time.Duration(float64(lifetime) * renewAt))
+			if time.Now().Before(due) {
+				fmt.Printf("%s is valid until %s; not due for renewal until %s\n", req.CertPath,
+					current.NotAfter.Local().Format(time.RFC1123), due.Local().Format(time.RFC1123))
+				return nil
+			}
+		}
+	}
+	return obtainCertificate(req)
+}
+
+// acmeRequest builds the request for the ACME settings that `server enroll
+// --acme` saved for cert.
+func acmeRequest(cert config.Certificate) (acme.Request, error) {
+	if cert.Enrollment != "acme" {
+		return acme.Request{}, fmt.Errorf("certificate %s was not enrolled with --acme", cert.Name)
+	}
+	req := acme.Request{
+		Directory:  cert.ACME.Directory,
+		Challenge:  cert.ACME.Challenge,
+		Webroot:    cert.ACME.Webroot,
+		Port:       cert.ACME.Port,
+		Email:      cert.ACME.Email,
+		AccountKey: cert.ACME.AccountKey,
+		CertPath:   cert.CertPath,
+		KeyPath:    cert.KeyPath,
+	}
+	if req.Challenge == "" {
+		req.Challenge = acme.HTTP01
+	}
+	if req.AccountKey == "" {
+		req.AccountKey = filepath.Join(config.ConfigDir(), acmeAccountKeyName)
+	}
+	for _, name := range strings.Split(cert.SANs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Names = append(req.Names, name)
+		}
+	}
+	roots, err := loadRoots(cert.ACME.Root)
+	if err != nil {
+		return acme.Request{}, err
+	}
+	req.RootCAs = roots
+	return req, nil
+}
+
+// loadRoots reads the CA certificates in file; "" means the system roots.
+func loadRoots(file string) (*x509.CertPool, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", file)
+	}
+	return pool, nil
+}
+
+func obtainCertificate(req acme.Request) error {
+	req.Log = os.Stdout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cert, err := acme.Obtain(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Certificate for %s issued, valid until %s\n", strings.Join(req.Names, ", "), cert.NotAfter.Local().Format(time.RFC1123))
+	return nil
+}
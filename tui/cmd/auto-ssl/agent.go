@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/acme"
+	"github.com/Brightblade42/auto-ssl/internal/agent"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/hooks"
+)
+
+func runAgent(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return runAgentRun(args[1:])
+		case "status":
+			return runAgentStatus(args[1:])
+		case "--help", "-h", "help":
+			printAgentUsage()
+			return nil
+		}
+	}
+	return runAgentRun(args)
+}
+
+func printAgentUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  auto-ssl agent [run] [--name NAME] [--renew-at FRACTION] [--jitter DUR] [--backoff DUR]")
+	fmt.Println("      [--max-backoff DUR] [--exec COMMAND]... [--status-dir DIR]")
+	fmt.Println("  auto-ssl agent status [--name NAME] [--json] [--status-dir DIR]")
+}
+
+// runAgentRun keeps the enrolled server certificates renewed until it is
+// interrupted, each on its own schedule. It runs in the foreground;
+// `server enroll` installs it as the auto-ssl-agent systemd service.
+func runAgentRun(args []string) error {
+	name := ""
+	renewAt := ""
+	jitter := time.Duration(0)
+	minBackoff := agent.DefaultMinBackoff
+	maxBackoff := agent.DefaultMaxBackoff
+	statusDir := config.DataDir()
+	var extra []hooks.Hook
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--name", "--renew-at", "--jitter", "--backoff", "--max-backoff", "--exec", "--status-dir":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--name":
+				name = value
+			case "--renew-at":
+				renewAt = value
+			case "--jitter", "--backoff", "--max-backoff":
+				// 0 would mean the default; an explicit --jitter 0 turns jitter off
+				if d, err := time.ParseDuration(value); err == nil && d == 0 && args[i] == "--jitter" {
+					jitter = -1
+					break
+				}
+				d, err := config.ParseDuration(value)
+				if err != nil {
+					return fmt.Errorf("%s must be a duration such as 30s or 1h, got %q", args[i], value)
+				}
+				switch args[i] {
+				case "--jitter":
+					jitter = d
+				case "--backoff":
+					minBackoff = d
+				case "--max-backoff":
+					maxBackoff = d
+				}
+			case "--exec":
+				extra = append(extra, hooks.Hook{Command: value})
+			case "--status-dir":
+				statusDir = value
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Server.Suspended {
+		fmt.Println("Renewal is suspended; resume it with: auto-ssl server resume")
+		return nil
+	}
+	certs, err := agentCertificates(cfg.Server, name)
+	if err != nil {
+		return err
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	var agents []*agent.Agent
+	for _, cert := range certs {
+		cert := cert
+		certRenewAt := renewAt
+		if certRenewAt == "" {
+			certRenewAt = cert.RenewAt
+		}
+		fraction := agent.DefaultRenewAt
+		if certRenewAt != "" {
+			if fraction, err = config.ParseFraction(certRenewAt); err != nil {
+				return fmt.Errorf("%s: renew_at: %w", cert.Name, err)
+			}
+		}
+
+		a := &agent.Agent{
+			Name:       cert.Name,
+			CertPath:   cert.CertPath,
+			RenewAt:    fraction,
+			Jitter:     jitter,
+			MinBackoff: minBackoff,
+			MaxBackoff: maxBackoff,
+			StatusFile: statusPath(statusDir, cert.Name),
+			Log:        os.Stdout,
+		}
+		if a.Renew, err = renewer(cert); err != nil {
+			return fmt.Errorf("%s: %w", cert.Name, err)
+		}
+		a.Reload = func(ctx context.Context, renewed *x509.Certificate) error {
+			err := reloadServices(ctx, cert, extra, renewed, os.Stdout)
+			if err != nil {
+				problem := fmt.Sprintf("%s was renewed, but: %v", cert.CertPath, err)
+				if alertErr := hooks.Alert(ctx, cert.AlertCommand, problem, os.Stdout); alertErr != nil {
+					fmt.Println(alertErr)
+				}
+			}
+			return err
+		}
+		agents = append(agents, a)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("auto-ssl agent %s started (pid %d)\n", Version, os.Getpid())
+
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	for i, a := range agents {
+		wg.Add(1)
+		go func(i int, a *agent.Agent) {
+			defer wg.Done()
+			if err := a.Run(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", a.Name, err)
+			}
+		}(i, a)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// agentCertificates returns the certificate called name, or every enrolled
+// certificate when name is "".
+func agentCertificates(server config.ServerConfig, name string) ([]config.Certificate, error) {
+	if name != "" {
+		cert, err := server.FindCertificate(name)
+		if err != nil {
+			return nil, err
+		}
+		return []config.Certificate{cert}, nil
+	}
+	certs := server.CertificateList()
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("this server is not enrolled (see: auto-ssl server enroll)")
+	}
+	return certs, nil
+}
+
+// statusPath returns the status file of the certificate called name:
+// agent.json for the default certificate, agent-NAME.json for the others.
+func statusPath(dir, name string) string {
+	if name == config.DefaultCertificate {
+		return filepath.Join(dir, agent.StatusFileName)
+	}
+	return filepath.Join(dir, "agent-"+name+".json")
+}
+
+// renewer returns how the agent renews cert: a new ACME order for
+// certificates enrolled with --acme, step ca renew (authenticated by the
+// current certificate) otherwise.
+func renewer(cert config.Certificate) (func(context.Context) error, error) {
+	if cert.Enrollment == "acme" {
+		req, err := acmeRequest(cert)
+		if err != nil {
+			return nil, err
+		}
+		req.Log = os.Stdout
+		return func(ctx context.Context) error {
+			_, err := acme.Obtain(ctx, req)
+			return err
+		}, nil
+	}
+
+	return func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, "step", "ca", "renew", "--force", cert.CertPath, cert.KeyPath)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stdout
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("step ca renew: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+func runAgentStatus(args []string) error {
+	name := ""
+	jsonOutput := false
+	statusDir := config.DataDir()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			jsonOutput = true
+		case "--name", "--status-dir":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--name" {
+				name = args[i+1]
+			} else {
+				statusDir = args[i+1]
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	certs, err := agentCertificates(cfg.Server, name)
+	if err != nil {
+		return err
+	}
+
+	type certStatus struct {
+		Running bool `json:"running"`
+		*agent.Status
+	}
+	var statuses []certStatus
+	var missing []string // certificates enrolled since the agent last started
+	for _, cert := range certs {
+		file := statusPath(statusDir, cert.Name)
+		st, running, err := agent.ReadStatus(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, file)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		st.Name = cert.Name
+		statuses = append(statuses, certStatus{running, st})
+	}
+	if len(statuses) == 0 {
+		return fmt.Errorf("no agent status at %s (is the agent running? see: systemctl status auto-ssl-agent)", strings.Join(missing, ", "))
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	formatTime := func(t *time.Time, none string) string {
+		if t == nil {
+			return none
+		}
+		return t.Local().Format(time.RFC1123)
+	}
+	for i, cs := range statuses {
+		st := cs.Status
+		if i > 0 {
+			fmt.Println()
+		}
+		if len(statuses) > 1 || st.Name != config.DefaultCertificate {
+			fmt.Printf("Name:         %s\n", st.Name)
+		}
+		if cs.Running {
+			fmt.Printf("Agent:        running (pid %d)\n", st.PID)
+		} else {
+			fmt.Printf("Agent:        not running (last seen %s)\n", st.Updated.Local().Format(time.RFC1123))
+		}
+		fmt.Printf("State:        %s\n", st.State)
+		fmt.Printf("Certificate:  %s\n", st.CertPath)
+		fmt.Printf("Expires:      %s\n", formatTime(st.NotAfter, "unknown"))
+		if cs.Running {
+			fmt.Printf("Next renewal: %s\n", formatTime(st.NextRenewal, "unknown"))
+		}
+		fmt.Printf("Last renewal: %s\n", formatTime(st.LastRenewal, "never"))
+		if st.LastError != "" {
+			fmt.Printf("Last error:   %s (%d failed attempts, last at %s)\n", st.LastError, st.Failures, formatTime(st.LastAttempt, "unknown"))
+		}
+		if st.LastReloadError != "" {
+			fmt.Printf("Reload error: %s\n", st.LastReloadError)
+		}
+	}
+	for _, file := range missing {
+		fmt.Printf("\nNo agent status at %s yet (restart the agent: systemctl restart auto-ssl-agent)\n", file)
+	}
+	return nil
+}
@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/backup"
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+const backupUsage = "usage: auto-ssl tools backup create|extract|verify|upload|list|prune ..."
+
+func runBackup(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(backupUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		return runBackupCreate(args[1:])
+	case "extract":
+		return runBackupExtract(args[1:])
+	case "verify":
+		return runBackupVerify(args[1:])
+	case "upload":
+		return runBackupUpload(args[1:])
+	case "list":
+		return runBackupList(args[1:])
+	case "prune":
+		return runBackupPrune(args[1:])
+	default:
+		return fmt.Errorf("unknown backup command: %s", args[0])
+	}
+}
+
+// backupFlags parses the options of the backup commands. The passphrase
+// comes from a file or an inherited file descriptor, never the command
+// line, where other users could read it.
+type backupFlags struct {
+	output         string
+	passphraseFile string
+	passphraseFD   int
+	caPasswordFile string
+	name           string
+	force          bool
+	json           bool
+	dryRun         bool
+	all            bool
+	dest           config.BackupDestination
+	positional     []string
+}
+
+func parseBackupFlags(args []string) (*backupFlags, error) {
+	f := &backupFlags{passphraseFD: -1}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--force":
+			f.force = true
+		case "--json":
+			f.json = true
+		case "--dry-run":
+			f.dryRun = true
+		case "--all":
+			f.all = true
+		case "--output", "--passphrase-file", "--passphrase-fd", "--ca-password-file", "--name",
+			"--dest-type", "--path", "--rsync-target", "--s3-bucket", "--s3-endpoint", "--s3-prefix", "--s3-region":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--output":
+				f.output = value
+			case "--passphrase-file":
+				f.passphraseFile = value
+			case "--ca-password-file":
+				f.caPasswordFile = value
+			case "--name":
+				f.name = value
+			case "--dest-type":
+				f.dest.Type = value
+			case "--path":
+				f.dest.Path = value
+			case "--rsync-target":
+				f.dest.Target = value
+			case "--s3-bucket":
+				f.dest.Bucket = value
+			case "--s3-endpoint":
+				f.dest.Endpoint = value
+			case "--s3-prefix":
+				f.dest.Prefix = value
+			case "--s3-region":
+				f.dest.Region = value
+			case "--passphrase-fd":
+				fd, err := strconv.Atoi(value)
+				if err != nil || fd < 0 {
+					return nil, fmt.Errorf("--passphrase-fd must be a file descriptor number, got %q", value)
+				}
+				f.passphraseFD = fd
+			}
+			i++
+		default:
+			if len(args[i]) > 1 && args[i][0] == '-' {
+				return nil, fmt.Errorf("unknown option: %s", args[i])
+			}
+			f.positional = append(f.positional, args[i])
+		}
+	}
+	return f, nil
+}
+
+// passphrase reads the passphrase, without its trailing newline.
+func (f *backupFlags) passphrase() ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case f.passphraseFile != "" && f.passphraseFD >= 0:
+		return nil, fmt.Errorf("use --passphrase-file or --passphrase-fd, not both")
+	case f.passphraseFile != "":
+		data, err = os.ReadFile(f.passphraseFile)
+	case f.passphraseFD >= 0:
+		file := os.NewFile(uintptr(f.passphraseFD), "passphrase")
+		if file == nil {
+			return nil, fmt.Errorf("--passphrase-fd %d is not open", f.passphraseFD)
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+	default:
+		return nil, fmt.Errorf("the passphrase is required: use --passphrase-file FILE or --passphrase-fd N")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the passphrase: %w", err)
+	}
+	data = bytes.TrimRight(data, "\r\n")
+	if len(data) == 0 {
+		return nil, fmt.Errorf("the passphrase is empty")
+	}
+	return data, nil
+}
+
+// runBackupCreate writes an encrypted backup of the step-ca directory and
+// the config directory. `ca backup` stops step-ca around it.
+func runBackupCreate(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if flags.output == "" || len(flags.positional) > 0 {
+		return fmt.Errorf("usage: auto-ssl tools backup create --output FILE (--passphrase-file FILE | --passphrase-fd N) [--force]")
+	}
+	if _, err := os.Stat(flags.output); err == nil && !flags.force {
+		return fmt.Errorf("%s already exists (use --force)", flags.output)
+	}
+	passphrase, err := flags.passphrase()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	_, ca, err := cfg.ActiveContext()
+	if err != nil {
+		return err
+	}
+	stepPath := ca.StepPath
+	if stepPath == "" {
+		stepPath = config.StepCAPath()
+	}
+	if info, err := os.Stat(stepPath); err != nil || !info.IsDir() {
+		return fmt.Errorf("no CA directory at %s", stepPath)
+	}
+
+	meta := backup.Metadata{
+		Version:     Version,
+		Timestamp:   time.Now().UTC().Truncate(time.Second),
+		CAURL:       ca.URL,
+		CAName:      ca.Name,
+		Fingerprint: ca.Fingerprint,
+		StepPath:    stepPath,
+	}
+	if fp, err := rootFingerprint(filepath.Join(stepPath, "certs", "root_ca.crt")); err == nil {
+		meta.Fingerprint = fp
+	}
+	meta.Hostname, _ = os.Hostname()
+
+	// The backup holds the CA keys, so it is written private and renamed
+	// into place only once complete.
+	if dir := filepath.Dir(flags.output); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	partial := flags.output + ".tmp"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+	manifest, err := backup.Create(f, passphrase, meta, []backup.Source{
+		{Name: "step-ca", Dir: stepPath},
+		{Name: "config", Dir: config.ConfigDir()},
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, flags.output); err != nil {
+		return err
+	}
+
+	files, size := 0, int64(0)
+	for _, file := range manifest.Files {
+		if file.Type == backup.TypeFile {
+			files++
+			size += file.Size
+		}
+	}
+	fmt.Printf("Backup written to %s\n", flags.output)
+	fmt.Printf("Contents:    %d files, %d bytes from %s and %s\n", files, size, stepPath, config.ConfigDir())
+	if meta.Fingerprint != "" {
+		fmt.Printf("Fingerprint: %s\n", meta.Fingerprint)
+	}
+	return nil
+}
+
+// rootFingerprint returns the SHA-256 fingerprint of the certificate in the
+// PEM file at path, as step prints it.
+func rootFingerprint(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("%s: no PEM certificate", path)
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// runBackupExtract decrypts and verifies a backup into a new directory,
+// from which `ca restore` moves step-ca/ and config/ into place.
+func runBackupExtract(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if flags.output == "" || len(flags.positional) != 1 {
+		return fmt.Errorf("usage: auto-ssl tools backup extract FILE --output DIR (--passphrase-file FILE | --passphrase-fd N)")
+	}
+	file := flags.positional[0]
+	entries, err := os.ReadDir(flags.output)
+	existed := err == nil
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", flags.output)
+	}
+	passphrase, err := flags.passphrase()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(flags.output, 0700); err != nil {
+		return err
+	}
+	contents, err := backup.Extract(in, passphrase, flags.output)
+	if err != nil {
+		// Nothing unverified is left behind.
+		if existed {
+			entries, _ := os.ReadDir(flags.output)
+			for _, entry := range entries {
+				os.RemoveAll(filepath.Join(flags.output, entry.Name()))
+			}
+		} else {
+			os.RemoveAll(flags.output)
+		}
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	meta := contents.Metadata
+	fmt.Printf("Backup of %s verified and extracted to %s\n", file, flags.output)
+	fmt.Printf("Created:     %s on %s\n", meta.Timestamp.Local().Format(time.RFC1123), meta.Hostname)
+	fmt.Printf("CA:          %s (%s)\n", meta.CAName, meta.CAURL)
+	if meta.Fingerprint != "" {
+		fmt.Printf("Fingerprint: %s\n", meta.Fingerprint)
+	}
+	return nil
+}
+
+// runBackupVerify checks that a backup could be restored, reading it in
+// memory: nothing is written to disk and the installed CA is not touched.
+func runBackupVerify(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(flags.positional) != 1 {
+		return fmt.Errorf("usage: auto-ssl tools backup verify FILE (--passphrase-file FILE | --passphrase-fd N) [--ca-password-file FILE] [--name NAME] [--json]")
+	}
+	file := flags.positional[0]
+	passphrase, err := flags.passphrase()
+	if err != nil {
+		return err
+	}
+	// Without a CA password, Verify uses the one in the backup.
+	var caPassword []byte
+	if flags.caPasswordFile != "" {
+		data, err := os.ReadFile(flags.caPasswordFile)
+		if err != nil {
+			return err
+		}
+		caPassword = bytes.TrimRight(data, "\r\n")
+	}
+
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	report, err := backup.Verify(in, passphrase, caPassword)
+
+	// The result is kept for `backup list`, under --name when FILE is a
+	// copy about to be stored under another name. A wrong passphrase says
+	// nothing about the backup. Recording is best effort: verify also runs
+	// where the data directory is not writable.
+	if !errors.Is(err, backup.ErrPassphrase) {
+		result := backup.Verification{Name: flags.name, Size: info.Size(), Time: time.Now().UTC().Truncate(time.Second), OK: err == nil}
+		if result.Name == "" {
+			result.Name = filepath.Base(file)
+		}
+		if err != nil {
+			result.Detail = err.Error()
+		} else if failed := report.Failed(); failed > 0 {
+			result.OK = false
+			result.Detail = fmt.Sprintf("%d check(s) failed", failed)
+		}
+		backup.RecordVerification(filepath.Join(config.DataDir(), backup.VerificationsFileName), result)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	if flags.json {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		meta := report.Metadata
+		fmt.Printf("Backup:      %s\n", file)
+		fmt.Printf("Created:     %s on %s\n", meta.Timestamp.Local().Format(time.RFC1123), meta.Hostname)
+		fmt.Printf("CA:          %s (%s)\n", meta.CAName, meta.CAURL)
+		if meta.Fingerprint != "" {
+			fmt.Printf("Fingerprint: %s\n", meta.Fingerprint)
+		}
+		fmt.Printf("Contents:    %d files, %d bytes\n", report.Files, report.Bytes)
+		fmt.Println()
+		for _, check := range report.Checks {
+			fmt.Printf("%-16s  %-4s  %s\n", check.Name, check.Status, check.Detail)
+		}
+	}
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// runBackupUpload copies a backup file to one destination, described by
+// the same options as `ca backup`, or with --all to every destination in
+// backup.destinations. S3 uploads are signed here, so the aws CLI is not
+// needed.
+func runBackupUpload(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(flags.positional) != 1 || (flags.all && flags.dest != config.BackupDestination{}) {
+		return fmt.Errorf("usage: auto-ssl tools backup upload FILE (--all [--json] | --dest-type local|rsync|s3 [--path DIR | --rsync-target TARGET | --s3-bucket BUCKET [--s3-endpoint URL] [--s3-prefix PREFIX] [--s3-region REGION]]) [--name NAME]")
+	}
+	file := flags.positional[0]
+	name := flags.name
+	if name == "" {
+		name = filepath.Base(file)
+	}
+	if flags.all {
+		return uploadAll(file, name, flags.json)
+	}
+	dest := flags.dest
+	switch dest.Type {
+	case "local":
+		if dest.Path == "" {
+			return fmt.Errorf("--path is required for local destinations")
+		}
+	case "rsync":
+		if dest.Target == "" {
+			return fmt.Errorf("--rsync-target is required for rsync destinations")
+		}
+	case "s3":
+		if dest.Bucket == "" {
+			return fmt.Errorf("--s3-bucket is required for s3 destinations")
+		}
+		if dest.Endpoint != "" {
+			if err := config.ValidateS3Endpoint(dest.Endpoint); err != nil {
+				return fmt.Errorf("--s3-endpoint: %w", err)
+			}
+		}
+	case "":
+		return fmt.Errorf("--dest-type is required (local, rsync or s3)")
+	}
+	store, err := destinationStore(dest)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := store.Put(ctx, name, file); err != nil {
+		return fmt.Errorf("%s: %w", store, err)
+	}
+	fmt.Printf("Uploaded %s (%s) to %s as %s\n", file, humanSize(info.Size()), store, name)
+	return nil
+}
+
+// uploadAll stores file at every destination in backup.destinations at
+// once, records each result for `backup list` and reports them. A
+// destination that fails does not stop the others, but the command then
+// exits non-zero.
+func uploadAll(file, name string, asJSON bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	var stores []backup.Store
+	for i, dest := range cfg.Backup.Destinations {
+		store, err := destinationStore(dest)
+		if err != nil {
+			return fmt.Errorf("backup.destinations.%d: %w", i, err)
+		}
+		stores = append(stores, store)
+	}
+	if len(stores) == 0 && !asJSON {
+		fmt.Println("No destinations in backup.destinations; nothing to upload.")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := backup.PutAll(ctx, stores, name, file)
+	if err != nil {
+		return err
+	}
+	// Best effort, as for verify.
+	backup.RecordUploads(filepath.Join(config.DataDir(), backup.UploadsFileName), results)
+
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Uploading %s (%s) to %d destination(s) as %s\n", file, humanSize(results[0].Size), len(results), name)
+		for _, result := range results {
+			if result.OK {
+				fmt.Printf("  ok      %-40s  %s\n", result.Destination, result.Duration)
+			} else {
+				fmt.Printf("  FAILED  %-40s  %s\n", result.Destination, result.Error)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("the backup was not stored at %d of %d destination(s)", failed, len(results))
+	}
+	return nil
+}
+
+// backupStores returns the destinations of the backup config: the
+// scheduled backups' output directory, then each of backup.destinations.
+func backupStores(cfg *config.Config) ([]backup.Store, error) {
+	var stores []backup.Store
+	seen := map[string]bool{}
+	add := func(s backup.Store) {
+		if !seen[s.String()] {
+			seen[s.String()] = true
+			stores = append(stores, s)
+		}
+	}
+	if cfg.Backup.OutputDir != "" {
+		add(&backup.LocalStore{Dir: cfg.Backup.OutputDir})
+	}
+	for i, dest := range cfg.Backup.Destinations {
+		store, err := destinationStore(dest)
+		if err != nil {
+			return nil, fmt.Errorf("backup.destinations.%d: %w", i, err)
+		}
+		add(store)
+	}
+	if len(stores) == 0 {
+		return nil, fmt.Errorf("no backup destinations are configured (see: auto-ssl ca backup-schedule)")
+	}
+	return stores, nil
+}
+
+// destinationStore returns the store for one backup destination.
+func destinationStore(dest config.BackupDestination) (backup.Store, error) {
+	switch dest.Type {
+	case "local":
+		return &backup.LocalStore{Dir: dest.Path}, nil
+	case "rsync":
+		return &backup.RsyncStore{Target: dest.Target}, nil
+	case "s3":
+		return &backup.S3Store{Bucket: dest.Bucket, Endpoint: dest.Endpoint, Region: dest.Region, Prefix: dest.Prefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown destination type %q", dest.Type)
+	}
+}
+
+func backupPolicy(cfg *config.Config) backup.Policy {
+	return backup.Policy{
+		Last:    cfg.Backup.Retention,
+		Daily:   cfg.Backup.KeepDaily,
+		Weekly:  cfg.Backup.KeepWeekly,
+		Monthly: cfg.Backup.KeepMonthly,
+	}
+}
+
+// runBackupList shows the last upload to every destination and the
+// backups there with their age, size, last verification and what the
+// retention policy does with them.
+func runBackupList(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(flags.positional) > 0 {
+		return fmt.Errorf("usage: auto-ssl tools backup list [--json]")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	stores, err := backupStores(cfg)
+	if err != nil {
+		return err
+	}
+	verifications, err := backup.ReadVerifications(filepath.Join(config.DataDir(), backup.VerificationsFileName))
+	if err != nil {
+		return err
+	}
+	uploads, err := backup.ReadUploads(filepath.Join(config.DataDir(), backup.UploadsFileName))
+	if err != nil {
+		return err
+	}
+	policy := backupPolicy(cfg)
+
+	type listedBackup struct {
+		backup.Decision
+		Verification *backup.Verification `json:"verification,omitempty"`
+	}
+	type destination struct {
+		Destination string         `json:"destination"`
+		Error       string         `json:"error,omitempty"`
+		LastUpload  *backup.Upload `json:"last_upload,omitempty"`
+		Backups     []listedBackup `json:"backups"`
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var listed []destination
+	failed := 0
+	for _, store := range stores {
+		d := destination{Destination: store.String(), Backups: []listedBackup{}}
+		if u, ok := uploads[d.Destination]; ok {
+			d.LastUpload = &u
+		}
+		objects, err := store.List(ctx)
+		if err != nil {
+			d.Error = err.Error()
+			failed++
+		}
+		for _, decision := range policy.Apply(objects) {
+			b := listedBackup{Decision: decision}
+			if v, ok := verifications.Lookup(decision.Object); ok {
+				b.Verification = &v
+			}
+			d.Backups = append(d.Backups, b)
+		}
+		listed = append(listed, d)
+	}
+
+	if flags.json {
+		data, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		now := time.Now()
+		fmt.Printf("Retention: %s\n", policy)
+		for _, d := range listed {
+			fmt.Printf("\n%s\n", d.Destination)
+			if u := d.LastUpload; u != nil && u.OK {
+				fmt.Printf("  last upload: %s, ok, %s ago\n", u.Name, certinfo.HumanDuration(now.Sub(u.Time)))
+			} else if u != nil {
+				lastOK := "never"
+				if !u.LastOK.IsZero() {
+					lastOK = certinfo.HumanDuration(now.Sub(u.LastOK)) + " ago"
+				}
+				fmt.Printf("  last upload: %s, FAILED %s ago: %s (last success: %s)\n", u.Name, certinfo.HumanDuration(now.Sub(u.Time)), u.Error, lastOK)
+			}
+			if d.Error != "" {
+				fmt.Printf("  error: %s\n", d.Error)
+				continue
+			}
+			if len(d.Backups) == 0 {
+				fmt.Println("  no backups")
+				continue
+			}
+			fmt.Printf("  %-32s  %-8s  %-10s  %-25s  %s\n", "NAME", "AGE", "SIZE", "KEPT BY", "VERIFIED")
+			for _, b := range d.Backups {
+				keep := "- (pruned next)"
+				if b.Keep {
+					keep = strings.Join(b.Reasons, ",")
+				}
+				verified := "never"
+				if v := b.Verification; v != nil && v.OK {
+					verified = "ok, " + certinfo.HumanDuration(now.Sub(v.Time)) + " ago"
+				} else if v != nil {
+					verified = "FAILED: " + v.Detail
+				}
+				fmt.Printf("  %-32s  %-8s  %-10s  %-25s  %s\n", b.Name, certinfo.HumanDuration(now.Sub(b.Time)), humanSize(b.Size), keep, verified)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d destination(s) could not be listed", failed)
+	}
+	return nil
+}
+
+// runBackupPrune deletes the backups the retention policy does not keep,
+// at every destination. A destination that fails does not stop the others.
+func runBackupPrune(args []string) error {
+	flags, err := parseBackupFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(flags.positional) > 0 {
+		return fmt.Errorf("usage: auto-ssl tools backup prune [--dry-run]")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	stores, err := backupStores(cfg)
+	if err != nil {
+		return err
+	}
+	policy := backupPolicy(cfg)
+	if policy.IsZero() {
+		fmt.Println("No retention policy is set (backup.retention, backup.keep_*); nothing to prune.")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Printf("Retention: %s\n", policy)
+	verb := "deleted"
+	if flags.dryRun {
+		verb = "would delete"
+	}
+	failed := 0
+	for _, store := range stores {
+		decisions, err := backup.Prune(ctx, store, policy, flags.dryRun)
+		kept, pruned := 0, 0
+		for _, d := range decisions {
+			if d.Keep {
+				kept++
+				continue
+			}
+			pruned++
+			if flags.dryRun {
+				fmt.Printf("%s: would delete %s\n", store, d.Name)
+			}
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", store, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: kept %d, %s %d\n", store, kept, verb, pruned)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d destination(s) could not be pruned", failed)
+	}
+	return nil
+}
+
+// humanSize renders n bytes in binary units, e.g. "300.0 KiB".
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/bundle"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/runtime"
+)
+
+// defaultBundleTTL gives an operator a working day to carry a bundle to an
+// isolated server. step CLI versions that cannot mint a token that long
+// make bundle create fail rather than write a bundle that expires early.
+const defaultBundleTTL = 24 * time.Hour
+
+func runBundle(manager *runtime.Manager, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools bundle create|inspect ...")
+	}
+
+	switch args[0] {
+	case "create":
+		return runBundleCreate(manager, args[1:])
+	case "inspect":
+		return runBundleInspect(args[1:])
+	default:
+		return fmt.Errorf("unknown bundle command: %s", args[0])
+	}
+}
+
+func runBundleCreate(manager *runtime.Manager, args []string) error {
+	host := ""
+	var sans []string
+	output := ""
+	ttl := defaultBundleTTL
+	provisioner := "admin"
+	force := false
+	stepBinaries := map[string]string{}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--force":
+			force = true
+		case "--host", "--san", "--output", "--ttl", "--provisioner", "--step-binary":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--host":
+				host = value
+			case "--san":
+				sans = append(sans, value)
+			case "--output":
+				output = value
+			case "--ttl":
+				d, err := config.ParseDuration(value)
+				if err != nil {
+					return fmt.Errorf("--ttl: %w", err)
+				}
+				ttl = d
+			case "--provisioner":
+				provisioner = value
+			case "--step-binary":
+				arch, err := bundle.StepArch(value)
+				if err != nil {
+					return err
+				}
+				if prev, ok := stepBinaries[arch]; ok {
+					return fmt.Errorf("--step-binary %s and %s are both %s", prev, value, arch)
+				}
+				stepBinaries[arch] = value
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+	if host == "" {
+		return fmt.Errorf("usage: auto-ssl tools bundle create --host NAME [--san NAME]... [--output FILE] [--ttl DUR] [--step-binary FILE]... [--provisioner NAME] [--force]")
+	}
+	if len(sans) == 0 {
+		sans = []string{host}
+	}
+	if output == "" {
+		output = "auto-ssl-bundle-" + host + ".tgz"
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists (use --force)", output)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	_, ca, err := cfg.ActiveContext()
+	if err != nil {
+		return err
+	}
+	if ca.URL == "" || ca.Fingerprint == "" {
+		return fmt.Errorf("CA URL and fingerprint are not configured; run this on the CA server")
+	}
+	stepPath := ca.StepPath
+	if stepPath == "" {
+		stepPath = config.StepCAPath()
+	}
+	rootPath := filepath.Join(stepPath, "certs", "root_ca.crt")
+	root, err := os.ReadFile(rootPath)
+	if err != nil {
+		return fmt.Errorf("reading the root certificate: %w", err)
+	}
+
+	created := time.Now().UTC().Truncate(time.Second)
+	token, err := mintToken(manager, provisioner, sans, ttl)
+	if err != nil {
+		return err
+	}
+	// The bundle states the token's real lifetime, not the one asked for.
+	tokenExpires, err := bundle.TokenExpiry(token)
+	if err != nil {
+		return fmt.Errorf("the enrollment token: %w", err)
+	}
+	if tokenExpires.Before(created.Add(ttl - time.Minute)) {
+		return fmt.Errorf("step minted a token that expires at %s, sooner than --ttl %s; upgrade step or use a shorter --ttl", tokenExpires.Local().Format(time.RFC1123), ttl)
+	}
+
+	tmp, err := os.MkdirTemp("", "auto-ssl-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	runtimeDir, err := manager.DumpBash(filepath.Join(tmp, bundle.RuntimeDir), true)
+	if err != nil {
+		return err
+	}
+
+	// The bundle holds a live token, so it is written private and renamed
+	// into place only once complete.
+	partial := output + ".tmp"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+	err = bundle.Write(f, bundle.Contents{
+		Manifest: bundle.Manifest{
+			Host:         host,
+			SANs:         sans,
+			CAURL:        ca.URL,
+			Fingerprint:  ca.Fingerprint,
+			Created:      created,
+			TokenExpires: tokenExpires,
+		},
+		RuntimeDir:   runtimeDir,
+		RootCA:       root,
+		Token:        token,
+		StepBinaries: stepBinaries,
+	})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Bundle for %s written to %s\n", host, output)
+	fmt.Printf("SANs:          %s\n", strings.Join(sans, ", "))
+	fmt.Printf("Token expires: %s\n", tokenExpires.Local().Format(time.RFC1123))
+	if len(stepBinaries) == 0 {
+		fmt.Println("step CLI:      not included; the server must already have step installed")
+	}
+	fmt.Println("")
+	fmt.Println("On the server:")
+	fmt.Printf("  tar -xzf %s\n", filepath.Base(output))
+	fmt.Printf("  sudo ./%s/%s/auto-ssl server enroll --bundle %s\n", bundle.Dir, bundle.RuntimeDir, filepath.Base(output))
+	return nil
+}
+
+// mintToken runs `auto-ssl ca token` for sans, so the bundle carries a
+// one-time token rather than the provisioner password.
+func mintToken(manager *runtime.Manager, provisioner string, sans []string, ttl time.Duration) (string, error) {
+	args := []string{"ca", "token", "--ttl", ttl.String(), "--provisioner", provisioner}
+	for _, san := range sans {
+		args = append(args, "--san", san)
+	}
+	cmd, err := manager.Command(args...)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("creating the enrollment token: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func runBundleInspect(args []string) error {
+	asJSON := false
+	file := ""
+	for _, arg := range args {
+		switch {
+		case arg == "--json":
+			asJSON = true
+		case strings.HasPrefix(arg, "--") || file != "":
+			return fmt.Errorf("unknown option: %s", arg)
+		default:
+			file = arg
+		}
+	}
+	if file == "" {
+		return fmt.Errorf("usage: auto-ssl tools bundle inspect [--json] FILE")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	manifest, err := bundle.Read(f)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	expires := manifest.TokenExpires.Local().Format(time.RFC1123)
+	if time.Now().After(manifest.TokenExpires) {
+		expires += " (expired)"
+	}
+	step := strings.Join(manifest.StepArches, ", ")
+	if step == "" {
+		step = "not included"
+	}
+	fmt.Printf("Host:          %s\n", manifest.Host)
+	fmt.Printf("SANs:          %s\n", strings.Join(manifest.SANs, ", "))
+	fmt.Printf("CA URL:        %s\n", manifest.CAURL)
+	fmt.Printf("Fingerprint:   %s\n", manifest.Fingerprint)
+	fmt.Printf("Created:       %s\n", manifest.Created.Local().Format(time.RFC1123))
+	fmt.Printf("Token expires: %s\n", expires)
+	fmt.Printf("step CLI:      %s\n", step)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+)
+
+func runCert(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools cert inspect [--json] [--chain] <file|host:port|->")
+	}
+
+	switch args[0] {
+	case "inspect":
+		return runCertInspect(args[1:])
+	default:
+		return fmt.Errorf("unknown cert command: %s", args[0])
+	}
+}
+
+func runCertInspect(args []string) error {
+	asJSON := false
+	chain := false
+	serverName := ""
+	timeout := 5 * time.Second
+	target := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--chain":
+			chain = true
+		case "--server-name":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--server-name requires a value")
+			}
+			serverName = args[i+1]
+			i++
+		case "--timeout":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--timeout requires a duration")
+			}
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid --timeout: %w", err)
+			}
+			timeout = d
+			i++
+		default:
+			if strings.HasPrefix(args[i], "--") || target != "" {
+				return fmt.Errorf("unknown option: %s", args[i])
+			}
+			target = args[i]
+		}
+	}
+
+	if target == "" {
+		return fmt.Errorf("usage: auto-ssl tools cert inspect [--json] [--chain] <file|host:port|->")
+	}
+
+	certs, err := certinfo.Load(target, serverName, timeout)
+	if err != nil {
+		return err
+	}
+	if !chain {
+		certs = certs[:1]
+	}
+
+	now := time.Now()
+	infos := make([]certinfo.Info, 0, len(certs))
+	for _, cert := range certs {
+		infos = append(infos, certinfo.Describe(cert, target, now))
+	}
+
+	if asJSON {
+		var value any = infos[0]
+		if chain {
+			value = infos
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Println("")
+		}
+		printCertInfo(info)
+	}
+	return nil
+}
+
+func printCertInfo(info certinfo.Info) {
+	remaining := info.Remaining
+	if info.Expired {
+		remaining = "EXPIRED " + strings.TrimPrefix(info.Remaining, "-") + " ago"
+	}
+	sans := strings.Join(info.SANs, ", ")
+	if sans == "" {
+		sans = "(none)"
+	}
+
+	fmt.Printf("Subject:      %s\n", info.Subject)
+	fmt.Printf("SANs:         %s\n", sans)
+	fmt.Printf("Issuer:       %s\n", info.Issuer)
+	fmt.Printf("Serial:       %s\n", info.Serial)
+	fmt.Printf("Key type:     %s\n", info.KeyType)
+	fmt.Printf("Not before:   %s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Printf("Not after:    %s\n", info.NotAfter.Format(time.RFC3339))
+	fmt.Printf("Lifetime:     %s\n", info.Lifetime)
+	fmt.Printf("Remaining:    %s\n", remaining)
+	fmt.Printf("SHA-256:      %s\n", info.FingerprintSHA256)
+}
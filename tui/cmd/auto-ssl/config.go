@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "usage: auto-ssl tools config get|set|unset|show|validate [--config FILE] [--strict] ..."
+
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(configUsage)
+	}
+
+	sub := args[0]
+	path := ""
+	asJSON := false
+	strict := false
+	var positional []string
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--config":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--config requires a path")
+			}
+			path = args[i+1]
+			i++
+		case "--json":
+			asJSON = true
+		case "--strict":
+			strict = true
+		case "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return fmt.Errorf("unknown option: %s", args[i])
+			}
+			positional = append(positional, args[i])
+		}
+	}
+
+	if path == "" {
+		path = config.ConfigFile()
+	}
+	load := config.LoadFile
+	if strict {
+		load = config.LoadFileStrict
+	}
+	// Never fall through to defaults here: saving them would overwrite a
+	// file the operator only mistyped.
+	cfg, loadErr := load(path)
+	if loadErr != nil && sub != "validate" {
+		return loadErr
+	}
+
+	switch sub {
+	case "get":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: auto-ssl tools config get KEY")
+		}
+		value, ok, err := cfg.Get(positional[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Like `git config`, an unset key exits 1 without output so
+			// scripts can fall back to their own default.
+			os.Exit(1)
+		}
+		fmt.Println(value)
+		return nil
+	case "set":
+		if len(positional) != 2 {
+			return fmt.Errorf("usage: auto-ssl tools config set KEY VALUE")
+		}
+		return updateConfig(cfg, positional[0], func() error {
+			return cfg.Set(positional[0], positional[1])
+		})
+	case "unset":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: auto-ssl tools config unset KEY")
+		}
+		return updateConfig(cfg, positional[0], func() error {
+			return cfg.Unset(positional[0])
+		})
+	case "show":
+		if len(positional) != 0 {
+			return fmt.Errorf("usage: auto-ssl tools config show [--json]")
+		}
+		return printConfig(cfg, asJSON)
+	case "validate":
+		if len(positional) != 0 {
+			return fmt.Errorf("usage: auto-ssl tools config validate [--json] [--strict]")
+		}
+		return validateConfig(cfg, loadErr, asJSON)
+	default:
+		return fmt.Errorf("unknown config command: %s", sub)
+	}
+}
+
+// updateConfig applies change to key and saves the result. It refuses a
+// value that makes key itself invalid; problems it creates elsewhere, such
+// as a new backup destination that still needs its bucket, are reported as
+// warnings so multi-key entries can be filled in one key at a time.
+func updateConfig(cfg *config.Config, key string, change func() error) error {
+	before := map[string]bool{}
+	for _, fieldErr := range cfg.Validate() {
+		before[fieldErr.Error()] = true
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	var rejected []string
+	for _, fieldErr := range cfg.Validate() {
+		if before[fieldErr.Error()] {
+			continue
+		}
+		if fieldErr.Key == key {
+			rejected = append(rejected, fieldErr.Error())
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s\n", fieldErr.Error())
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("invalid value: %s", strings.Join(rejected, "; "))
+	}
+
+	return cfg.Save()
+}
+
+func printConfig(cfg *config.Config, asJSON bool) error {
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		out, err := yamlAsJSON(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("# %s\n%s", cfg.Path(), data)
+	return nil
+}
+
+// yamlAsJSON renders v as indented JSON by way of its YAML form, so JSON
+// keys and omitted fields match the files on disk.
+func yamlAsJSON(v any) ([]byte, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func validateConfig(cfg *config.Config, loadErr error, asJSON bool) error {
+	var errs []config.FieldError
+	if loadErr == nil {
+		errs = cfg.Validate()
+	}
+
+	if asJSON {
+		if errs == nil {
+			errs = []config.FieldError{}
+		}
+		report := map[string]any{
+			"file":   cfg.Path(),
+			"valid":  loadErr == nil && len(errs) == 0,
+			"errors": errs,
+		}
+		var structured *config.LoadError
+		if errors.As(loadErr, &structured) {
+			report["load_error"] = map[string]any{
+				"file":    structured.File,
+				"line":    structured.Line,
+				"column":  structured.Column,
+				"key":     structured.Key,
+				"message": structured.Err.Error(),
+			}
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		if loadErr != nil {
+			fmt.Printf("invalid  %v\n", loadErr)
+		}
+		for _, fieldErr := range errs {
+			fmt.Printf("invalid  %s\n", fieldErr.Error())
+		}
+		if loadErr == nil && len(errs) == 0 {
+			fmt.Printf("%s: ok\n", cfg.Path())
+		}
+	}
+
+	if loadErr != nil {
+		return fmt.Errorf("%s: cannot be loaded", cfg.Path())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %d schema error(s)", cfg.Path(), len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/runtime"
+)
+
+func runContext(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools context list|current|env|use|add|remove")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runContextList(cfg, args[1:])
+	case "current":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: auto-ssl tools context current")
+		}
+		name, _, err := cfg.ActiveContext()
+		if err != nil {
+			return err
+		}
+		fmt.Println(name)
+		return nil
+	case "env":
+		// For the Bash runtime when it is run without the Go wrapper.
+		if len(args) != 1 {
+			return fmt.Errorf("usage: auto-ssl tools context env")
+		}
+		env, err := runtime.ContextEnv()
+		if err != nil {
+			return err
+		}
+		for _, kv := range env {
+			fmt.Println(kv)
+		}
+		return nil
+	case "use":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: auto-ssl tools context use NAME")
+		}
+		if err := cfg.UseContext(args[1]); err != nil {
+			return err
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to context %s\n", args[1])
+		return nil
+	case "add":
+		return runContextAdd(cfg, args[1:])
+	case "remove", "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: auto-ssl tools context remove NAME")
+		}
+		if err := cfg.RemoveContext(args[1]); err != nil {
+			return err
+		}
+		return cfg.Save()
+	default:
+		return fmt.Errorf("unknown context command: %s", args[0])
+	}
+}
+
+type contextRow struct {
+	Name        string `json:"name"`
+	Current     bool   `json:"current"`
+	URL         string `json:"url"`
+	Fingerprint string `json:"fingerprint"`
+	CAName      string `json:"ca_name"`
+	StepPath    string `json:"steppath,omitempty"`
+}
+
+func runContextList(cfg *config.Config, args []string) error {
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+			continue
+		}
+		return fmt.Errorf("unknown option: %s", arg)
+	}
+
+	active, _, err := cfg.ActiveContext()
+	if err != nil {
+		return err
+	}
+
+	rows := []contextRow{contextRowFor(config.DefaultContext, cfg.CA, active)}
+	for _, ctx := range cfg.Contexts {
+		rows = append(rows, contextRowFor(ctx.Name, ctx.CA, active))
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tCA URL\tFINGERPRINT\tCA NAME")
+	for _, row := range rows {
+		marker := ""
+		if row.Current {
+			marker = "*"
+		}
+		fingerprint := row.Fingerprint
+		if len(fingerprint) > 16 {
+			fingerprint = fingerprint[:16] + "…"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, row.Name, dash(row.URL), dash(fingerprint), dash(row.CAName))
+	}
+	return w.Flush()
+}
+
+func contextRowFor(name string, ca config.CAConfig, active string) contextRow {
+	return contextRow{
+		Name:        name,
+		Current:     name == active,
+		URL:         ca.URL,
+		Fingerprint: ca.Fingerprint,
+		CAName:      ca.Name,
+		StepPath:    ca.StepPath,
+	}
+}
+
+func runContextAdd(cfg *config.Config, args []string) error {
+	ctx := config.Context{}
+	use := false
+	force := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--ca-url", "--fingerprint", "--name", "--steppath":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--ca-url":
+				ctx.CA.URL = value
+			case "--fingerprint":
+				ctx.CA.Fingerprint = value
+			case "--name":
+				ctx.CA.Name = value
+			case "--steppath":
+				ctx.CA.StepPath = value
+			}
+			i++
+		case "--use":
+			use = true
+		case "--force":
+			force = true
+		default:
+			if strings.HasPrefix(args[i], "--") || ctx.Name != "" {
+				return fmt.Errorf("unknown option: %s", args[i])
+			}
+			ctx.Name = args[i]
+		}
+	}
+
+	if ctx.Name == "" || ctx.CA.URL == "" {
+		return fmt.Errorf("usage: auto-ssl tools context add NAME --ca-url URL [--fingerprint FP] [--name NAME] [--steppath DIR] [--use] [--force]")
+	}
+
+	if err := cfg.AddContext(ctx, force); err != nil {
+		return err
+	}
+	for _, fieldErr := range cfg.Validate() {
+		if strings.HasPrefix(fieldErr.Key, "contexts."+ctx.Name+".") {
+			return fieldErr
+		}
+	}
+	if use {
+		if err := cfg.UseContext(ctx.Name); err != nil {
+			return err
+		}
+	}
+	return cfg.Save()
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/fleet"
+	"github.com/Brightblade42/auto-ssl/internal/runtime"
+)
+
+// exitUnreachable is the runtime's "network error" exit code, which the
+// single-host remote commands use when SSH cannot connect.
+const exitUnreachable = 5
+
+// fleetOperations are the remote subcommands the fleet runner can fan out.
+var fleetOperations = []string{"status", "update-ca-url", "renew", "enroll"}
+
+// rollbackOperations can undo a host's change with `--rollback`.
+var rollbackOperations = map[string]bool{"update-ca-url": true}
+
+// runFleet runs `auto-ssl remote OPERATION --host HOST` for every selected
+// server. Options it does not recognise are passed to each host's command.
+func runFleet(manager *runtime.Manager, args []string) error {
+	if len(args) == 0 || args[0] == "--help" || args[0] == "-h" {
+		return fmt.Errorf("usage: auto-ssl tools fleet %s [--all|--group GROUP|--selector SELECTOR] [--parallel N] [--timeout DUR] [--log-dir DIR] [--json] [--canary N] [--batch-size N] [--max-failures N] [--rollback] [OPTIONS...]", strings.Join(fleetOperations, "|"))
+	}
+	operation := args[0]
+	known := false
+	for _, op := range fleetOperations {
+		known = known || op == operation
+	}
+	if !known {
+		return fmt.Errorf("unknown fleet operation: %s (%s)", operation, strings.Join(fleetOperations, ", "))
+	}
+
+	all := false
+	selector := ""
+	group := ""
+	logDir := ""
+	asJSON := false
+	runner := fleet.Runner{Parallel: fleet.DefaultParallel, Timeout: fleet.DefaultTimeout}
+	var passthrough []string
+
+	// update-ca-url can break renewal everywhere at once, so it always rolls
+	// out behind a canary. Other operations roll out only when asked to.
+	rolling := operation == "update-ca-url"
+	rollout := fleet.Rollout{Canary: 1}
+	rollback := false
+
+	rest := args[1:]
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "--all":
+			all = true
+		case "--json":
+			asJSON = true
+		case "--rollback":
+			if !rollbackOperations[operation] {
+				return fmt.Errorf("--rollback is not supported for %s", operation)
+			}
+			rollback = true
+		case "--canary", "--batch-size", "--max-failures":
+			if i+1 >= len(rest) {
+				return fmt.Errorf("%s requires a value", rest[i])
+			}
+			n, err := strconv.Atoi(rest[i+1])
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s must be a number, got %q", rest[i], rest[i+1])
+			}
+			switch rest[i] {
+			case "--canary":
+				rollout.Canary = n
+			case "--batch-size":
+				rollout.BatchSize = n
+			case "--max-failures":
+				rollout.MaxFailures = n
+			}
+			rolling = true
+			i++
+		case "--group", "--selector", "--parallel", "--timeout", "--log-dir":
+			if i+1 >= len(rest) {
+				return fmt.Errorf("%s requires a value", rest[i])
+			}
+			value := rest[i+1]
+			switch rest[i] {
+			case "--group":
+				group = value
+			case "--selector":
+				selector = value
+			case "--parallel":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 1 {
+					return fmt.Errorf("--parallel must be a positive number, got %q", value)
+				}
+				runner.Parallel = n
+			case "--timeout":
+				d, err := config.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("--timeout must be a duration such as 90s or 5m, got %q", value)
+				}
+				runner.Timeout = d
+			case "--log-dir":
+				logDir = value
+			}
+			i++
+		case "--":
+			passthrough = append(passthrough, rest[i+1:]...)
+			i = len(rest)
+		case "--host":
+			return fmt.Errorf("--host selects a single server; use auto-ssl remote %s --host instead", operation)
+		default:
+			passthrough = append(passthrough, rest[i])
+		}
+	}
+
+	if !all && selector == "" && group == "" {
+		return fmt.Errorf("select servers with --all, --group or --selector")
+	}
+	sel, err := config.ParseSelector(selector)
+	if err != nil {
+		return err
+	}
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return err
+	}
+	servers := inv.Select(sel, group)
+	if len(servers) == 0 {
+		return fmt.Errorf("no servers match")
+	}
+	if logDir != "" {
+		if err := os.MkdirAll(logDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	if rollback && !rolling {
+		return fmt.Errorf("--rollback needs a rollout (--canary, --batch-size or --max-failures)")
+	}
+
+	// hostTask runs `auto-ssl remote OPERATION --host HOST EXTRA...`.
+	hostTask := func(logSuffix string, extra ...string) fleet.Task {
+		return func(ctx context.Context, server config.Server) (string, error) {
+			return runFleetHost(ctx, manager, server, logDir, logSuffix, append([]string{"remote", operation, "--host", server.Host}, extra...))
+		}
+	}
+
+	if !asJSON {
+		runner.Reporter = fleet.NewProgress(os.Stdout, isTerminal(os.Stdout))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	started := time.Now()
+
+	var results []fleet.Result
+	stopped := ""
+	if rolling {
+		results, stopped = runner.RunRollout(ctx, servers, hostTask("", passthrough...), rollout)
+	} else {
+		results = runner.Run(ctx, servers, hostTask("", passthrough...))
+	}
+
+	var rollbackResults []fleet.Result
+	if stopped != "" && rollback {
+		var updated []config.Server
+		index := map[string]int{}
+		for idx, result := range results {
+			if result.Status == fleet.StatusOK {
+				updated = append(updated, servers[idx])
+				index[result.Host] = idx
+			}
+		}
+		if len(updated) > 0 {
+			if !asJSON {
+				fmt.Printf("\nRolling back %d updated server(s)\n", len(updated))
+			}
+			// The rollback must run even after an interrupt stopped the rollout.
+			rollbackResults = runner.Run(context.Background(), updated, hostTask(".rollback", append([]string{"--rollback"}, passthrough...)...))
+			for _, result := range rollbackResults {
+				if result.Status == fleet.StatusOK {
+					results[index[result.Host]].RolledBack = true
+				}
+			}
+		}
+	}
+	summary := fleet.Summarize(results)
+
+	if asJSON {
+		report := map[string]any{
+			"operation": operation,
+			"summary":   summary,
+			"results":   results,
+		}
+		if stopped != "" {
+			report["stopped"] = stopped
+		}
+		if rollbackResults != nil {
+			report["rollback"] = rollbackResults
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Println("")
+		if stopped != "" {
+			fmt.Printf("Rollout stopped: %s\n", stopped)
+		}
+		fmt.Printf("%s: %d ok, %d failed, %d unreachable of %d server(s) in %s\n",
+			operation, summary.OK, summary.Failed, summary.Unreachable, summary.Total, time.Since(started).Round(time.Second))
+		if summary.Skipped > 0 {
+			fmt.Printf("Not attempted: %d server(s)\n", summary.Skipped)
+		}
+		if rollbackResults != nil {
+			rolledBack := fleet.Summarize(rollbackResults)
+			fmt.Printf("Rolled back: %d of %d updated server(s)\n", rolledBack.OK, rolledBack.Total)
+		}
+		if logDir != "" {
+			fmt.Printf("Per-host output: %s\n", logDir)
+		}
+	}
+	return summary.Err()
+}
+
+// runFleetHost runs one host's command and turns its exit status into a
+// fleet outcome. The output is saved to HOST+SUFFIX.log under logDir.
+func runFleetHost(ctx context.Context, manager *runtime.Manager, server config.Server, logDir, logSuffix string, hostArgs []string) (string, error) {
+	cmd, err := manager.CommandContext(ctx, hostArgs...)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	runErr := cmd.Run()
+
+	output := out.String()
+	if logDir != "" {
+		_ = os.WriteFile(filepath.Join(logDir, server.Host+logSuffix+".log"), out.Bytes(), 0644)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		detail := strings.TrimLeft(fleet.LastLine(output), "✗⚠ ")
+		if detail == "" {
+			detail = runErr.Error()
+		}
+		if exitErr.ExitCode() == exitUnreachable {
+			return output, fleet.Unreachable(errors.New(detail))
+		}
+		return output, errors.New(detail)
+	}
+	return output, runErr
+}
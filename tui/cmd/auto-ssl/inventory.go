@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/remote"
+)
+
+func runInventory(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools inventory add|remove|set|label|rekey|list|show|import|export")
+	}
+
+	switch args[0] {
+	case "add":
+		return runInventoryAdd(args[1:])
+	case "remove", "rm":
+		return runInventoryRemove(args[1:])
+	case "set":
+		return runInventorySet(args[1:])
+	case "label":
+		return runInventoryLabel(args[1:])
+	case "rekey":
+		return runInventoryRekey(args[1:])
+	case "list", "ls":
+		return runInventoryList(args[1:])
+	case "show":
+		return runInventoryShow(args[1:])
+	case "import":
+		return runInventoryImport(args[1:])
+	case "export":
+		return runInventoryExport(args[1:])
+	default:
+		return fmt.Errorf("unknown inventory command: %s", args[0])
+	}
+}
+
+// serverOptions are the inventory fields that add and set accept as flags.
+// Only flags that were given are applied, so set can clear a value with "".
+type serverOptions struct {
+	given     map[string]bool
+	name      string
+	user      string
+	port      int
+	identity  string
+	proxyJump string
+	privilege string
+	hostKey   string
+	group     string
+	labels    []string
+}
+
+const serverOptionsUsage = "[--name NAME] [--user USER] [--port PORT] [--identity FILE] [--proxy-jump HOST] [--privilege sudo|root] [--host-key FP] [--group GROUP] [--label KEY=VALUE]..."
+
+func parseServerOptions(args []string) (string, serverOptions, error) {
+	host := ""
+	opts := serverOptions{given: map[string]bool{}}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--name", "--user", "--port", "--identity", "--proxy-jump", "--privilege", "--host-key", "--group", "--label":
+			if i+1 >= len(args) {
+				return "", opts, fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			opts.given[args[i]] = true
+			switch args[i] {
+			case "--name":
+				opts.name = value
+			case "--user":
+				opts.user = value
+			case "--port":
+				if value != "" {
+					port, err := strconv.Atoi(value)
+					if err != nil || port < 1 || port > 65535 {
+						return "", opts, fmt.Errorf("invalid --port %q", value)
+					}
+					opts.port = port
+				}
+			case "--identity":
+				opts.identity = value
+			case "--proxy-jump":
+				opts.proxyJump = value
+			case "--privilege":
+				if err := config.ValidatePrivilege(value); err != nil {
+					return "", opts, fmt.Errorf("--privilege %v", err)
+				}
+				opts.privilege = value
+			case "--host-key":
+				if err := config.ValidateHostKey(value); err != nil {
+					return "", opts, fmt.Errorf("--host-key %v", err)
+				}
+				opts.hostKey = value
+			case "--group":
+				opts.group = value
+			case "--label":
+				opts.labels = append(opts.labels, value)
+			}
+			i++
+		default:
+			if strings.HasPrefix(args[i], "--") || host != "" {
+				return "", opts, fmt.Errorf("unknown option: %s", args[i])
+			}
+			host = args[i]
+		}
+	}
+	return host, opts, nil
+}
+
+func (o serverOptions) apply(server *config.Server) error {
+	labels, err := config.ParseLabels(o.labels)
+	if err != nil {
+		return err
+	}
+	if o.given["--name"] {
+		server.Name = o.name
+	}
+	if o.given["--user"] {
+		server.User = o.user
+	}
+	if o.given["--port"] {
+		server.Port = o.port
+	}
+	if o.given["--identity"] {
+		server.Identity = o.identity
+	}
+	if o.given["--proxy-jump"] {
+		server.ProxyJump = o.proxyJump
+	}
+	if o.given["--privilege"] {
+		server.Privilege = o.privilege
+	}
+	if o.given["--host-key"] {
+		server.HostKey = o.hostKey
+	}
+	if o.given["--group"] {
+		server.Group = o.group
+	}
+	mergeLabels(server, labels, nil)
+	return nil
+}
+
+func runInventoryAdd(args []string) error {
+	host, opts, err := parseServerOptions(args)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("usage: auto-ssl tools inventory add HOST %s", serverOptionsUsage)
+	}
+
+	return config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		server := config.Server{Host: host}
+		if existing := inv.GetServer(host); existing != nil {
+			server = *existing
+		}
+		if err := opts.apply(&server); err != nil {
+			return err
+		}
+		if server.Name == "" {
+			server.Name = host
+		}
+		server.Enrolled = true
+		server.EnrolledAt = time.Now().UTC().Truncate(time.Second)
+		inv.AddServer(server)
+		return nil
+	})
+}
+
+// runInventorySet changes fields of an existing server without touching its
+// enrollment state.
+func runInventorySet(args []string) error {
+	host, opts, err := parseServerOptions(args)
+	if err != nil {
+		return err
+	}
+	if host == "" || len(opts.given) == 0 {
+		return fmt.Errorf("usage: auto-ssl tools inventory set HOST %s", serverOptionsUsage)
+	}
+
+	return config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		server := inv.GetServer(host)
+		if server == nil {
+			return fmt.Errorf("%s is not in the inventory", host)
+		}
+		return opts.apply(server)
+	})
+}
+
+// runInventoryLabel changes labels on an existing server, kubectl-style:
+// KEY=VALUE sets a label and KEY- removes it. --group sets the group; an
+// empty value clears it.
+func runInventoryLabel(args []string) error {
+	host := ""
+	group := ""
+	setGroup := false
+	var set []string
+	var remove []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--group":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--group requires a value")
+			}
+			group = args[i+1]
+			setGroup = true
+			i++
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("unknown option: %s", arg)
+		case host == "":
+			host = arg
+		case strings.Contains(arg, "="):
+			set = append(set, arg)
+		case strings.HasSuffix(arg, "-"):
+			remove = append(remove, strings.TrimSuffix(arg, "-"))
+		default:
+			return fmt.Errorf("invalid label change %q (expected KEY=VALUE or KEY-)", arg)
+		}
+	}
+
+	if host == "" || (len(set) == 0 && len(remove) == 0 && !setGroup) {
+		return fmt.Errorf("usage: auto-ssl tools inventory label HOST [KEY=VALUE|KEY-]... [--group GROUP]")
+	}
+	newLabels, err := config.ParseLabels(set)
+	if err != nil {
+		return err
+	}
+
+	return config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		server := inv.GetServer(host)
+		if server == nil {
+			return fmt.Errorf("%s is not in the inventory", host)
+		}
+		if setGroup {
+			server.Group = group
+		}
+		mergeLabels(server, newLabels, remove)
+		return nil
+	})
+}
+
+func mergeLabels(server *config.Server, set map[string]string, remove []string) {
+	for _, key := range remove {
+		delete(server.Labels, key)
+	}
+	if len(set) > 0 && server.Labels == nil {
+		server.Labels = map[string]string{}
+	}
+	for key, value := range set {
+		server.Labels[key] = value
+	}
+	if len(server.Labels) == 0 {
+		server.Labels = nil
+	}
+}
+
+func runInventoryRemove(args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "--") {
+		return fmt.Errorf("usage: auto-ssl tools inventory remove HOST")
+	}
+	host := args[0]
+
+	return config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		if inv.GetServer(host) == nil {
+			return fmt.Errorf("%s is not in the inventory", host)
+		}
+		inv.RemoveServer(host)
+		return nil
+	})
+}
+
+// runInventoryRekey replaces a server's pinned host key with the key it
+// presents now, for hosts that were legitimately rebuilt. --host-key makes
+// it refuse any key but the one the operator verified out of band.
+func runInventoryRekey(args []string) error {
+	host := ""
+	expected := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--host-key":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--host-key requires a value")
+			}
+			if err := config.ValidateHostKey(args[i+1]); err != nil {
+				return fmt.Errorf("--host-key %v", err)
+			}
+			expected = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--") || host != "":
+			return fmt.Errorf("unknown option: %s", args[i])
+		default:
+			host = args[i]
+		}
+	}
+	if host == "" {
+		return fmt.Errorf("usage: auto-ssl tools inventory rekey HOST [--host-key FP]")
+	}
+
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return err
+	}
+	server := inv.GetServer(host)
+	if server == nil {
+		return fmt.Errorf("%s is not in the inventory", host)
+	}
+	target := remote.TargetFor(*server)
+	key, err := remote.ScanHostKey(context.Background(), target, remote.Options{})
+	if err != nil {
+		return err
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+	if expected != "" && fingerprint != expected {
+		return fmt.Errorf("%s presented %s, not the expected %s; not updating", host, fingerprint, expected)
+	}
+
+	old := ""
+	err = config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		server := inv.GetServer(host)
+		if server == nil {
+			return fmt.Errorf("%s is not in the inventory", host)
+		}
+		old = server.HostKey
+		server.HostKey = fingerprint
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := remote.TrustHostKey("", target, key); err != nil {
+		return err
+	}
+
+	if old == fingerprint {
+		fmt.Printf("Host key for %s is unchanged: %s\n", host, fingerprint)
+		return nil
+	}
+	fmt.Printf("Host key for %s updated\n", host)
+	fmt.Printf("  Old: %s\n", dash(old))
+	fmt.Printf("  New: %s\n", fingerprint)
+	return nil
+}
+
+func runInventoryList(args []string) error {
+	format := "table"
+	selector := ""
+	group := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--format requires table, tsv, json or yaml")
+			}
+			format = args[i+1]
+			i++
+		case "--selector", "-l", "--group":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--group" {
+				group = args[i+1]
+			} else {
+				selector = args[i+1]
+			}
+			i++
+		case "--json":
+			format = "json"
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	sel, err := config.ParseSelector(selector)
+	if err != nil {
+		return err
+	}
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return err
+	}
+	filtered := !sel.Empty() || group != ""
+	inv.Servers = inv.Select(sel, group)
+
+	switch format {
+	case "table":
+		if len(inv.Servers) == 0 {
+			if filtered {
+				fmt.Println("No servers match")
+			} else {
+				fmt.Println("No servers enrolled")
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tNAME\tUSER\tGROUP\tLABELS\tENROLLED\tSUSPENDED")
+		for _, server := range inv.Servers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", server.Host, dash(server.Name), dash(server.User), dash(server.Group), dash(config.FormatLabels(server.Labels)), formatTime(server.EnrolledAt), server.Suspended)
+		}
+		return w.Flush()
+	case "tsv":
+		// Stable, header-less columns for the Bash runtime: host, user, name,
+		// port, identity, proxy jump, privilege, host key. Empty values are
+		// "-" because `read` collapses consecutive tabs.
+		for _, server := range inv.Servers {
+			fmt.Printf("%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", server.Host, dash(server.User), dash(server.Name),
+				server.SSHPort(), dash(server.Identity), dash(server.ProxyJump), server.PrivilegeMode(), dash(server.HostKey))
+		}
+		return nil
+	case "json":
+		if len(inv.Servers) == 0 {
+			fmt.Println("[]")
+			return nil
+		}
+		data, err := yamlAsJSON(inv.Servers)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(inv)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	default:
+		return fmt.Errorf("unknown format: %s (table, tsv, json or yaml)", format)
+	}
+}
+
+func runInventoryShow(args []string) error {
+	host := ""
+	asJSON := false
+	for _, arg := range args {
+		switch {
+		case arg == "--json":
+			asJSON = true
+		case strings.HasPrefix(arg, "--") || host != "":
+			return fmt.Errorf("unknown option: %s", arg)
+		default:
+			host = arg
+		}
+	}
+	if host == "" {
+		return fmt.Errorf("usage: auto-ssl tools inventory show HOST [--json]")
+	}
+
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return err
+	}
+	server := inv.GetServer(host)
+	if server == nil {
+		return fmt.Errorf("%s is not in the inventory", host)
+	}
+
+	if asJSON {
+		data, err := yamlAsJSON(server)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	data, err := yaml.Marshal(server)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// runInventoryImport merges servers from another inventory format. With
+// --dry-run it only prints what would change.
+func runInventoryImport(args []string) error {
+	source := ""
+	format := ""
+	dryRun := false
+	asJSON := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--format requires %s", strings.Join(config.InventoryFormats, ", "))
+			}
+			format = args[i+1]
+			i++
+		case "--dry-run":
+			dryRun = true
+		case "--json":
+			asJSON = true
+		default:
+			if (strings.HasPrefix(args[i], "--") && args[i] != "-") || source != "" {
+				return fmt.Errorf("unknown option: %s", args[i])
+			}
+			source = args[i]
+		}
+	}
+
+	if source == "" {
+		return fmt.Errorf("usage: auto-ssl tools inventory import FILE|- [--format %s] [--dry-run] [--json]", strings.Join(config.InventoryFormats, "|"))
+	}
+	if format == "" {
+		detected, ok := config.DetectInventoryFormat(source)
+		if !ok {
+			return fmt.Errorf("cannot tell the format of %s; use --format %s", source, strings.Join(config.InventoryFormats, "|"))
+		}
+		format = detected
+	}
+
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return err
+	}
+
+	servers, warnings, err := config.ParseServers(format, data)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+
+	var changes []config.ServerChange
+	if dryRun {
+		inv, err := config.LoadInventory()
+		if err != nil {
+			return err
+		}
+		changes = inv.MergeServers(servers)
+	} else {
+		err = config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+			changes = inv.MergeServers(servers)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(map[string]any{"dry_run": dryRun, "changes": changes}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printImportChanges(changes, dryRun)
+	return nil
+}
+
+func printImportChanges(changes []config.ServerChange, dryRun bool) {
+	counts := map[string]int{}
+	for _, change := range changes {
+		counts[change.Action]++
+		switch change.Action {
+		case config.ChangeAdd:
+			fmt.Printf("+ %s\n", change.Host)
+		case config.ChangeUpdate:
+			fmt.Printf("~ %s\n", change.Host)
+		default:
+			continue
+		}
+		for _, field := range change.Fields {
+			if change.Action == config.ChangeAdd {
+				fmt.Printf("    %s: %s\n", field.Key, field.New)
+			} else {
+				fmt.Printf("    %s: %s -> %s\n", field.Key, dash(field.Old), dash(field.New))
+			}
+		}
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d server(s): %d added, %d updated, %d unchanged\n", verb, len(changes),
+		counts[config.ChangeAdd], counts[config.ChangeUpdate], counts[config.ChangeUnchanged])
+}
+
+func runInventoryExport(args []string) error {
+	format := ""
+	output := ""
+	selector := ""
+	group := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format", "--output", "-o", "--selector", "-l", "--group":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--format":
+				format = args[i+1]
+			case "--output", "-o":
+				output = args[i+1]
+			case "--selector", "-l":
+				selector = args[i+1]
+			case "--group":
+				group = args[i+1]
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	if format == "" {
+		format = config.FormatJSON
+		if detected, ok := config.DetectInventoryFormat(output); ok && output != "" {
+			format = detected
+		}
+	}
+	sel, err := config.ParseSelector(selector)
+	if err != nil {
+		return err
+	}
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return err
+	}
+
+	data, err := config.FormatServers(format, inv.Select(sel, group))
+	if err != nil {
+		return err
+	}
+	if output == "" || output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(output, data, 0644)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,7 +12,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Brightblade42/auto-ssl/internal/config"
 	"github.com/Brightblade42/auto-ssl/internal/runtime"
+	"github.com/Brightblade42/auto-ssl/internal/tui"
 )
 
 var (
@@ -23,7 +26,21 @@ func main() {
 	manager := runtime.NewManager(Version)
 	prog := path.Base(os.Args[0])
 
+	args, err := applyGlobalOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", prog, err)
+		os.Exit(2)
+	}
+	os.Args = append(os.Args[:1], args...)
+
 	if prog == "auto-ssl" {
+		if len(os.Args) > 1 && os.Args[1] == "tui" {
+			if err := runTUI(manager); err != nil {
+				fmt.Fprintf(os.Stderr, "tui failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 		if len(os.Args) > 1 && os.Args[1] == "tools" {
 			if err := runTools(manager, os.Args[2:]); err != nil {
 				fmt.Fprintf(os.Stderr, "tools failed: %v\n", err)
@@ -31,16 +48,25 @@ func main() {
 			}
 			return
 		}
+		if len(os.Args) > 1 && os.Args[1] == "agent" {
+			if err := runAgent(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "agent failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 		if err := runAutoSSL(manager, os.Args[1:]); err != nil {
-			fmt.Fprintf(os.Stderr, "auto-ssl failed: %v\n", err)
-			os.Exit(1)
+			exitOnError("auto-ssl failed", err)
 		}
 		return
 	}
 
 	if len(os.Args) <= 1 {
-		printUsage()
-		os.Exit(2)
+		if err := runTUI(manager); err != nil {
+			fmt.Fprintf(os.Stderr, "tui failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	if os.Args[1] == "tools" {
@@ -58,6 +84,12 @@ func main() {
 	case "--help", "-h", "help":
 		printUsage()
 		return
+	case "tui":
+		if err := runTUI(manager); err != nil {
+			fmt.Fprintf(os.Stderr, "tui failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	case "dump-bash":
 		if err := runDumpBash(manager, os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "dump-bash failed: %v\n", err)
@@ -82,10 +114,15 @@ func main() {
 			os.Exit(1)
 		}
 		return
+	case "agent":
+		if err := runAgent(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "agent failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	case "exec":
 		if err := runExec(manager, os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "exec failed: %v\n", err)
-			os.Exit(1)
+			exitOnError("exec failed", err)
 		}
 		return
 	default:
@@ -96,6 +133,46 @@ func main() {
 
 }
 
+// applyGlobalOptions consumes leading --config/--config-dir/--context
+// options and exports them as the environment overrides that both the
+// config package and the Bash runtime read. Paths are made absolute because
+// the runtime changes directory in some workflows.
+func applyGlobalOptions(args []string) ([]string, error) {
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		var env string
+		switch name {
+		case "--config":
+			env = config.EnvConfigFile
+		case "--config-dir":
+			env = config.EnvConfigDir
+		case "--context":
+			env = config.EnvContext
+		default:
+			return args, nil
+		}
+
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if env != config.EnvContext {
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return nil, err
+			}
+			value = abs
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
 func runTools(manager *runtime.Manager, args []string) error {
 	if len(args) == 0 {
 		printToolsUsage()
@@ -120,39 +197,128 @@ func runTools(manager *runtime.Manager, args []string) error {
 		return runtime.InstallDependencies(autoYes)
 	case "dump-bash":
 		return runDumpBash(manager, args[1:])
+	case "cert":
+		return runCert(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "context":
+		return runContext(args[1:])
+	case "inventory":
+		return runInventory(args[1:])
+	case "fleet":
+		return runFleet(manager, args[1:])
+	case "ssh":
+		return runSSH(args[1:])
+	case "bundle":
+		return runBundle(manager, args[1:])
+	case "acme":
+		return runACME(args[1:])
+	case "reload":
+		return runReload(args[1:])
+	case "backup":
+		return runBackup(args[1:])
 	default:
 		return fmt.Errorf("unknown tools command: %s", args[0])
 	}
 }
 
+// exitOnError exits with the Bash runtime's own exit code when it failed (it
+// has already reported why), so scripts can tell the documented codes apart.
+// Other errors are printed and exit 1.
+func exitOnError(prefix string, err error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		os.Exit(exitErr.ExitCode())
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", prefix, err)
+	os.Exit(1)
+}
+
 func runAutoSSL(manager *runtime.Manager, args []string) error {
-	path, err := manager.AutoSSLPath()
+	cmd, err := manager.Command(args...)
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command(path, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	return cmd.Run()
 }
 
+func runTUI(manager *runtime.Manager) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return fmt.Errorf("the console needs an interactive terminal; use auto-ssl <command> for scripted use")
+	}
+	return tui.Run(manager, Version)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 func printUsage() {
-	fmt.Println("auto-ssl-tui - compatibility alias for auto-ssl tools")
+	fmt.Println("auto-ssl-tui - operator console and compatibility alias for auto-ssl tools")
 	fmt.Println("")
 	fmt.Println("Usage:")
+	fmt.Println("  auto-ssl-tui [--config FILE] [--config-dir DIR] [--context NAME] <command> ...")
+	fmt.Println("  auto-ssl-tui                (open the interactive console)")
 	fmt.Println("  auto-ssl-tui --version")
 	fmt.Println("  auto-ssl-tui tools doctor [--json]")
 	fmt.Println("  auto-ssl-tui tools install-deps [--yes]")
 	fmt.Println("  auto-ssl-tui tools dump-bash [--output DIR] [--force] [--print-path] [--checksum]")
+	fmt.Println("  auto-ssl-tui tools cert inspect [--json] [--chain] <file|host:port|->")
+	fmt.Println("  auto-ssl-tui tools config get|set|unset|show|validate [--config FILE] [--strict] ...")
+	fmt.Println("  auto-ssl-tui tools context list|current|use|add|remove ...")
+	fmt.Println("  auto-ssl-tui tools inventory add|remove|set|label|list|show|import|export ...")
+	fmt.Println("  auto-ssl-tui tools fleet status|update-ca-url|renew|enroll ...")
+	fmt.Println("  auto-ssl-tui tools ssh check|exec|upload ...")
+	fmt.Println("  auto-ssl-tui tools bundle create|inspect ...")
+	fmt.Println("  auto-ssl-tui tools acme obtain|renew ...")
+	fmt.Println("  auto-ssl-tui tools reload [--name NAME] [--no-verify | --check] [--exec COMMAND]...")
+	fmt.Println("  auto-ssl-tui tools backup create|extract|verify|upload|list|prune ...")
+	fmt.Println("  auto-ssl-tui agent [run|status] ...")
 	fmt.Println("  auto-ssl-tui exec -- <auto-ssl args>")
 }
 
 func printToolsUsage() {
 	fmt.Println("Usage:")
+	fmt.Println("  auto-ssl [--config FILE] [--config-dir DIR] [--context NAME] tools <command> ...")
 	fmt.Println("  auto-ssl tools doctor [--json]")
 	fmt.Println("  auto-ssl tools install-deps [--yes]")
 	fmt.Println("  auto-ssl tools dump-bash [--output DIR] [--force] [--print-path] [--checksum]")
+	fmt.Println("  auto-ssl tools cert inspect [--json] [--chain] [--server-name NAME] [--timeout DUR] <file|host:port|->")
+	fmt.Println("  auto-ssl tools config get KEY | set KEY VALUE | unset KEY [--config FILE] [--strict]")
+	fmt.Println("  auto-ssl tools config show|validate [--json] [--config FILE] [--strict]")
+	fmt.Println("  auto-ssl tools context list [--json] | current | env | use NAME | remove NAME")
+	fmt.Println("  auto-ssl tools context add NAME --ca-url URL [--fingerprint FP] [--name NAME] [--steppath DIR] [--use] [--force]")
+	fmt.Println("  auto-ssl tools inventory add|set HOST [--name NAME] [--user USER] [--port PORT] [--identity FILE]")
+	fmt.Println("      [--proxy-jump HOST] [--privilege sudo|root] [--group GROUP] [--label KEY=VALUE]...")
+	fmt.Println("  auto-ssl tools inventory remove HOST")
+	fmt.Println("  auto-ssl tools inventory label HOST [KEY=VALUE|KEY-]... [--group GROUP]")
+	fmt.Println("  auto-ssl tools inventory list [--format table|tsv|json|yaml] [--group GROUP] [--selector SELECTOR] | show HOST [--json]")
+	fmt.Println("  auto-ssl tools inventory import FILE|- [--format csv|json|ansible-ini|ansible-yaml] [--dry-run] [--json]")
+	fmt.Println("  auto-ssl tools inventory export [--format csv|json|ansible-ini|ansible-yaml] [--output FILE] [--group GROUP] [--selector SELECTOR]")
+	fmt.Println("  auto-ssl tools fleet status|update-ca-url|renew|enroll [--all|--group GROUP|--selector SELECTOR]")
+	fmt.Println("      [--parallel N] [--timeout DUR] [--log-dir DIR] [--json] [remote command options...]")
+	fmt.Println("  auto-ssl tools ssh check|exec|upload [--user USER] [--port PORT] [--identity FILE] [--proxy-jump HOST]")
+	fmt.Println("      [--connect-timeout DUR] [USER@]HOST [--stdin -- COMMAND... | [--mode MODE] LOCAL|- REMOTE]")
+	fmt.Println("  auto-ssl tools bundle create --host NAME [--san NAME]... [--output FILE] [--ttl DUR]")
+	fmt.Println("      [--step-binary FILE]... [--provisioner NAME] [--force]")
+	fmt.Println("  auto-ssl tools bundle inspect [--json] FILE")
+	fmt.Println("  auto-ssl tools acme obtain --directory URL --san NAME... [--challenge http-01|tls-alpn-01] [--webroot DIR] [--port N]")
+	fmt.Println("      [--email ADDR] [--root FILE] [--account-key FILE] [--cert-path FILE] [--key-path FILE]")
+	fmt.Println("  auto-ssl tools acme renew [--force] [--name NAME]")
+	fmt.Println("  auto-ssl tools reload [--name NAME] [--no-verify | --check] [--exec COMMAND]...")
+	fmt.Println("  auto-ssl tools backup create --output FILE (--passphrase-file FILE | --passphrase-fd N) [--force]")
+	fmt.Println("  auto-ssl tools backup extract FILE --output DIR (--passphrase-file FILE | --passphrase-fd N)")
+	fmt.Println("  auto-ssl tools backup verify FILE (--passphrase-file FILE | --passphrase-fd N) [--ca-password-file FILE] [--name NAME] [--json]")
+	fmt.Println("  auto-ssl tools backup upload FILE (--all [--json] | --dest-type local|rsync|s3 ...) [--name NAME]")
+	fmt.Println("  auto-ssl tools backup list [--json]")
+	fmt.Println("  auto-ssl tools backup prune [--dry-run]")
 }
 
 func runDumpBash(manager *runtime.Manager, args []string) error {
@@ -255,7 +421,7 @@ func runDoctor(args []string) error {
 			return err
 		}
 		fmt.Println(out)
-		return nil
+		return doctorResult(runtime.Checks())
 	}
 
 	deps := runtime.Doctor()
@@ -274,6 +440,19 @@ func runDoctor(args []string) error {
 			fmt.Printf("%-10s  %-8s  %-8s  %s\n", dep.Name, status, required, dep.Purpose)
 		}
 	}
+
+	checks := runtime.Checks()
+	fmt.Println("")
+	for _, check := range checks {
+		fmt.Printf("%-10s  %-8s  %s\n", check.Name, check.Status, check.Detail)
+	}
+	return doctorResult(checks)
+}
+
+func doctorResult(checks []runtime.CheckStatus) error {
+	if failed := runtime.FailedChecks(checks); len(failed) > 0 {
+		return fmt.Errorf("%d check(s) failed", len(failed))
+	}
 	return nil
 }
 
@@ -284,11 +463,10 @@ func runExec(manager *runtime.Manager, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("usage: auto-ssl-tui exec -- <args>")
 	}
-	path, err := manager.AutoSSLPath()
+	cmd, err := manager.Command(args...)
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command(path, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
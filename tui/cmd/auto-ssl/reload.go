@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/hooks"
+)
+
+// runReload runs the reload hooks of every certificate (or the one named
+// with --name) and checks that it is served. `server renew` runs it after
+// a manual renewal; `server status` runs it with --check, which only does
+// the check.
+func runReload(args []string) error {
+	verify := true
+	check := false
+	name := ""
+	var extra []hooks.Hook
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--no-verify":
+			verify = false
+		case "--check":
+			check = true
+		case "--name", "--exec":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--name" {
+				name = args[i+1]
+			} else {
+				extra = append(extra, hooks.Hook{Command: args[i+1]})
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	certs := cfg.Server.CertificateList()
+	if name != "" {
+		cert, err := cfg.Server.FindCertificate(name)
+		if err != nil {
+			return err
+		}
+		certs = []config.Certificate{cert}
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("this server is not enrolled")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var errs []error
+	for _, cert := range certs {
+		if len(certs) > 1 {
+			fmt.Printf("%s:\n", cert.Name)
+		}
+		if !verify {
+			cert.VerifyPort = 0
+		}
+		chain, err := certinfo.LoadFile(cert.CertPath)
+		if err == nil {
+			if check {
+				err = checkServed(ctx, cert, chain[0], name != "")
+			} else {
+				err = reloadServices(ctx, cert, extra, chain[0], os.Stdout)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cert.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkServed checks once that cert.verify_port serves current. Without a
+// port that is an error only when the certificate was asked for by name.
+func checkServed(ctx context.Context, cert config.Certificate, current *x509.Certificate, named bool) error {
+	if cert.VerifyPort == 0 {
+		if named {
+			return fmt.Errorf("verify_port is not set")
+		}
+		return nil
+	}
+	if err := hooks.Verify(ctx, current, cert.VerifyPort, 0); err != nil {
+		return err
+	}
+	fmt.Printf("Port %d serves the current certificate\n", cert.VerifyPort)
+	return nil
+}
+
+// reloadServices runs the reload hooks of cert, then extra, and checks that
+// renewed is served on its verify_port.
+func reloadServices(ctx context.Context, cert config.Certificate, extra []hooks.Hook, renewed *x509.Certificate, log io.Writer) error {
+	var all []hooks.Hook
+	for _, hook := range cert.Reload {
+		all = append(all, hooks.Hook{Service: hook.Service, Command: hook.Command})
+	}
+	all = append(all, extra...)
+
+	err := hooks.Run(ctx, all, log)
+	if cert.VerifyPort == 0 {
+		return err
+	}
+	if verifyErr := hooks.Verify(ctx, renewed, cert.VerifyPort, hooks.DefaultVerifyTimeout); verifyErr != nil {
+		return errors.Join(err, fmt.Errorf("the renewed certificate is not being served: %w", verifyErr))
+	}
+	fmt.Fprintf(log, "Port %d serves the renewed certificate\n", cert.VerifyPort)
+	return err
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+	"github.com/Brightblade42/auto-ssl/internal/remote"
+)
+
+const sshOptionsUsage = "[--user USER] [--port PORT] [--identity FILE] [--proxy-jump HOST] [--host-key FP] [--accept-new-hostkey] [--connect-timeout DUR]"
+
+// runSSH is the Bash runtime's SSH transport. Connection settings and the
+// pinned host key come from the inventory entry for HOST unless overridden
+// by flags. It exits 5 when the server cannot be reached or its host key
+// does not match, and with the remote command's own status when that
+// fails, like ssh does.
+func runSSH(args []string) error {
+	if len(args) == 0 || args[0] == "--help" || args[0] == "-h" {
+		return fmt.Errorf("usage: auto-ssl tools ssh check|keyscan|exec|upload %s [USER@]HOST ...", sshOptionsUsage)
+	}
+
+	var run func(ctx context.Context, client *remote.Client, rest []string) error
+	scan := false
+	stdin := false
+	mode := os.FileMode(0600)
+	switch args[0] {
+	case "check":
+		run = func(ctx context.Context, client *remote.Client, rest []string) error {
+			if len(rest) != 0 {
+				return fmt.Errorf("unexpected argument: %s", rest[0])
+			}
+			if err := client.Run(ctx, "true", nil, io.Discard, io.Discard); err != nil {
+				return err
+			}
+			fmt.Println(ssh.FingerprintSHA256(client.HostKey()))
+			return nil
+		}
+	case "keyscan":
+		scan = true
+	case "exec":
+		run = func(ctx context.Context, client *remote.Client, rest []string) error {
+			if len(rest) == 0 {
+				return fmt.Errorf("usage: auto-ssl tools ssh exec [OPTIONS] [--stdin] [USER@]HOST -- COMMAND...")
+			}
+			var in io.Reader
+			if stdin {
+				in = os.Stdin
+			}
+			return client.Run(ctx, strings.Join(rest, " "), in, os.Stdout, os.Stderr)
+		}
+	case "upload":
+		run = func(ctx context.Context, client *remote.Client, rest []string) error {
+			if len(rest) != 2 {
+				return fmt.Errorf("usage: auto-ssl tools ssh upload [OPTIONS] [--mode MODE] [USER@]HOST LOCAL|- REMOTE")
+			}
+			in := io.Reader(os.Stdin)
+			if rest[0] != "-" {
+				f, err := os.Open(rest[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			return client.Upload(ctx, in, rest[1], mode)
+		}
+	default:
+		return fmt.Errorf("unknown ssh command: %s", args[0])
+	}
+
+	var target remote.Target
+	opts := remote.Options{ConnectTimeout: remote.DefaultConnectTimeout}
+	given := map[string]bool{}
+	var rest []string
+
+	flags := args[1:]
+	for i := 0; i < len(flags); i++ {
+		switch flags[i] {
+		case "--stdin":
+			stdin = true
+		case "--accept-new-hostkey":
+			opts.AcceptNewHostKey = true
+		case "--user", "--port", "--identity", "--proxy-jump", "--host-key", "--connect-timeout", "--mode":
+			if i+1 >= len(flags) {
+				return fmt.Errorf("%s requires a value", flags[i])
+			}
+			value := flags[i+1]
+			given[flags[i]] = true
+			switch flags[i] {
+			case "--user":
+				target.User = value
+			case "--port":
+				if value != "" {
+					port, err := strconv.Atoi(value)
+					if err != nil || port < 1 || port > 65535 {
+						return fmt.Errorf("invalid --port %q", value)
+					}
+					target.Port = port
+				}
+			case "--identity":
+				target.Identity = value
+			case "--proxy-jump":
+				target.ProxyJump = value
+			case "--host-key":
+				if err := config.ValidateHostKey(value); err != nil {
+					return fmt.Errorf("--host-key %v", err)
+				}
+				opts.HostKey = value
+			case "--connect-timeout":
+				d, err := config.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("--connect-timeout must be a duration such as 10s, got %q", value)
+				}
+				opts.ConnectTimeout = d
+			case "--mode":
+				m, err := strconv.ParseUint(value, 8, 32)
+				if err != nil || m > 0777 {
+					return fmt.Errorf("--mode must be octal permissions such as 0644, got %q", value)
+				}
+				mode = os.FileMode(m)
+			}
+			i++
+		case "--":
+			rest = append(rest, flags[i+1:]...)
+			i = len(flags)
+		default:
+			if strings.HasPrefix(flags[i], "--") {
+				return fmt.Errorf("unknown option: %s", flags[i])
+			}
+			if target.Host == "" {
+				target.Host = flags[i]
+				continue
+			}
+			rest = append(rest, flags[i])
+		}
+	}
+	if target.Host == "" {
+		return fmt.Errorf("host required")
+	}
+	if at := strings.LastIndex(target.Host, "@"); at >= 0 {
+		if !given["--user"] {
+			target.User = target.Host[:at]
+			given["--user"] = true
+		}
+		target.Host = target.Host[at+1:]
+	}
+	pinned, err := fillFromInventory(&target, &opts, given)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if scan {
+		if len(rest) != 0 {
+			return fmt.Errorf("unexpected argument: %s", rest[0])
+		}
+		key, err := remote.ScanHostKey(ctx, target, opts)
+		if err != nil {
+			reportConnectError(target.Host, err)
+			os.Exit(exitUnreachable)
+		}
+		fmt.Println(ssh.FingerprintSHA256(key))
+		return nil
+	}
+
+	// --host-key checks the pinned key; it does not replace it
+	if pinned != "" && opts.HostKey != pinned && !opts.AcceptNewHostKey {
+		return fmt.Errorf("--host-key %s is not the key pinned for %s (%s); if it was rebuilt, run `auto-ssl tools inventory rekey %s --host-key %s`, or pass --accept-new-hostkey",
+			opts.HostKey, target.Host, pinned, target.Host, opts.HostKey)
+	}
+
+	client, err := remote.Dial(ctx, target, opts)
+	if err != nil {
+		reportConnectError(target.Host, err)
+		os.Exit(exitUnreachable)
+	}
+	defer client.Close()
+
+	if fingerprint := ssh.FingerprintSHA256(client.HostKey()); opts.AcceptNewHostKey && pinned != "" && fingerprint != pinned {
+		if err := pinHostKey(target.Host, fingerprint); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "auto-ssl: accepted new host key for %s: %s (was %s)\n", target.Host, fingerprint, pinned)
+	}
+
+	err = run(ctx, client, rest)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		client.Close()
+		os.Exit(exitErr.ExitStatus())
+	}
+	return err
+}
+
+// fillFromInventory uses the inventory's settings for target.Host for each
+// connection option not given on the command line, and returns the host key
+// pinned in the inventory.
+func fillFromInventory(target *remote.Target, opts *remote.Options, given map[string]bool) (string, error) {
+	inv, err := config.LoadInventory()
+	if err != nil {
+		return "", err
+	}
+	server := inv.GetServer(target.Host)
+	if server == nil {
+		return "", nil
+	}
+	if !given["--user"] {
+		target.User = server.User
+	}
+	if !given["--port"] {
+		target.Port = server.Port
+	}
+	if !given["--identity"] {
+		target.Identity = server.Identity
+	}
+	if !given["--proxy-jump"] {
+		target.ProxyJump = server.ProxyJump
+	}
+	if !given["--host-key"] {
+		opts.HostKey = server.HostKey
+	}
+	return server.HostKey, nil
+}
+
+// pinHostKey records fingerprint as host's pinned host key.
+func pinHostKey(host, fingerprint string) error {
+	return config.UpdateInventory(config.InventoryFile(), func(inv *config.Inventory) error {
+		server := inv.GetServer(host)
+		if server == nil {
+			return fmt.Errorf("server not found: %s", host)
+		}
+		server.HostKey = fingerprint
+		return nil
+	})
+}
+
+// reportConnectError prints err last, so it is the line the fleet runner
+// shows for the host.
+func reportConnectError(host string, err error) {
+	var keyErr *remote.HostKeyError
+	if errors.As(err, &keyErr) {
+		fmt.Fprintf(os.Stderr, "auto-ssl: if %s was rebuilt, verify its new key and run `auto-ssl tools inventory rekey %s`, or pass --accept-new-hostkey\n", host, host)
+	}
+	fmt.Fprintf(os.Stderr, "auto-ssl: %v\n", err)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// TestMain lets a test run runSSH in a child process, to see its exit code.
+func TestMain(m *testing.M) {
+	if args := os.Getenv("AUTO_SSL_TEST_SSH_ARGS"); args != "" {
+		if err := runSSH(strings.Fields(args)); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, priv
+}
+
+// startHandshakeServer serves SSH handshakes with hostKey and nothing more.
+func startHandshakeServer(t *testing.T, hostKey ssh.Signer) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(hostKey)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if sconn, _, _, err := ssh.NewServerConn(conn, cfg); err == nil {
+					sconn.Close()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func runSSHChild(t *testing.T, configDir string, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(),
+		"AUTO_SSL_TEST_SSH_ARGS="+strings.Join(args, " "),
+		config.EnvConfigDir+"="+configDir,
+		"SSH_AUTH_SOCK=",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), stderr.String()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0, stderr.String()
+}
+
+func TestSSHHostKeyMismatchExitsUnreachable(t *testing.T) {
+	hostKey, _ := newSigner(t)
+	port := startHandshakeServer(t, hostKey)
+	other, _ := newSigner(t)
+
+	dir := t.TempDir()
+	_, clientKey := newSigner(t)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stderr := runSSHChild(t, dir, "check",
+		"--port", strconv.Itoa(port),
+		"--identity", identity,
+		"--host-key", ssh.FingerprintSHA256(other.PublicKey()),
+		"--connect-timeout", "5s",
+		"127.0.0.1")
+	if code != exitUnreachable {
+		t.Fatalf("exit code = %d, want %d; stderr:\n%s", code, exitUnreachable, stderr)
+	}
+	if !strings.Contains(stderr, "host key for") || !strings.Contains(stderr, ssh.FingerprintSHA256(hostKey.PublicKey())) {
+		t.Errorf("stderr = %q, want the host key mismatch and the key the server sent", stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, config.KnownHostsFileName)); len(data) != 0 {
+		t.Errorf("known_hosts = %q, want nothing recorded on a mismatch", data)
+	}
+}
+
+// A --host-key other than the inventory's pin is refused; only
+// --accept-new-hostkey replaces the pin.
+func TestSSHHostKeyDoesNotRepin(t *testing.T) {
+	hostKey, _ := newSigner(t)
+	port := startHandshakeServer(t, hostKey)
+	old, _ := newSigner(t)
+	presented := ssh.FingerprintSHA256(hostKey.PublicKey())
+	was := ssh.FingerprintSHA256(old.PublicKey())
+
+	dir := t.TempDir()
+	_, clientKey := newSigner(t)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inventory := filepath.Join(dir, config.InventoryFileName)
+	err = config.UpdateInventory(inventory, func(inv *config.Inventory) error {
+		inv.AddServer(config.Server{Host: "127.0.0.1", Port: port, Identity: identity, HostKey: was})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin := func() string {
+		inv, err := config.LoadInventoryFile(inventory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inv.GetServer("127.0.0.1").HostKey
+	}
+
+	code, stderr := runSSHChild(t, dir, "check", "--host-key", presented, "--connect-timeout", "5s", "127.0.0.1")
+	if code == 0 || !strings.Contains(stderr, "inventory rekey") {
+		t.Errorf("exit code = %d, stderr = %q; want --host-key refused", code, stderr)
+	}
+	if got := pin(); got != was {
+		t.Fatalf("pin = %s after --host-key, want %s kept", got, was)
+	}
+
+	// The handshake server closes before the command runs; the pin is
+	// replaced once the connection is up.
+	_, stderr = runSSHChild(t, dir, "check", "--accept-new-hostkey", "--connect-timeout", "5s", "127.0.0.1")
+	if got := pin(); got != presented {
+		t.Errorf("pin = %s after --accept-new-hostkey, want %s; stderr:\n%s", got, presented, stderr)
+	}
+}
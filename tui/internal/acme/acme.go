@@ -0,0 +1,368 @@
+// Package acme obtains server certificates over ACME (RFC 8555), from
+// step-ca's ACME provisioner or any other ACME server such as pebble. The
+// server proves control of its names with http-01, answered by a standalone
+// listener or through an existing web server's document root, or with
+// tls-alpn-01. No provisioner credentials are involved, for issuance or
+// renewal.
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	xacme "golang.org/x/crypto/acme"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+)
+
+// Challenge types.
+const (
+	HTTP01    = "http-01"
+	TLSALPN01 = "tls-alpn-01"
+)
+
+// DefaultPort returns the port the ACME server connects to when it
+// validates a challenge.
+func DefaultPort(challenge string) int {
+	if challenge == TLSALPN01 {
+		return 443
+	}
+	return 80
+}
+
+// Request describes the certificate to obtain and how to prove control of
+// its names.
+type Request struct {
+	Directory string   // ACME directory URL
+	Names     []string // DNS names and IP addresses; the first is the subject
+	Challenge string   // HTTP01 or TLSALPN01
+	Webroot   string   // http-01: write responses under this document root instead of listening
+	Port      int      // standalone listener port; 0 means DefaultPort
+	Email     string   // account contact, optional
+
+	// RootCAs verifies the directory's TLS certificate; nil uses the
+	// system roots.
+	RootCAs *x509.CertPool
+
+	AccountKey string // PEM file holding the account key; created when missing
+	CertPath   string // the issued chain is written here
+	KeyPath    string // and its new private key here
+
+	Log io.Writer // progress messages; nil discards them
+}
+
+// Obtain registers (or reuses) the ACME account, answers a challenge for
+// every name and writes the issued certificate and key. It returns the
+// issued leaf certificate.
+func Obtain(ctx context.Context, req Request) (*x509.Certificate, error) {
+	if len(req.Names) == 0 {
+		return nil, errors.New("no names to request a certificate for")
+	}
+	if req.Challenge != HTTP01 && req.Challenge != TLSALPN01 {
+		return nil, fmt.Errorf("unsupported challenge %q (%s or %s)", req.Challenge, HTTP01, TLSALPN01)
+	}
+	if req.Webroot != "" && req.Challenge != HTTP01 {
+		return nil, fmt.Errorf("a webroot can only answer %s", HTTP01)
+	}
+	logf := func(format string, args ...any) {
+		if req.Log != nil {
+			fmt.Fprintf(req.Log, format+"\n", args...)
+		}
+	}
+
+	var ids []xacme.AuthzID
+	for _, name := range req.Names {
+		if net.ParseIP(name) == nil {
+			ids = append(ids, xacme.DomainIDs(name)...)
+			continue
+		}
+		if req.Challenge == TLSALPN01 {
+			return nil, fmt.Errorf("%s is an IP address; use %s for IP addresses", name, HTTP01)
+		}
+		ids = append(ids, xacme.IPIDs(name)...)
+	}
+
+	accountKey, err := loadAccountKey(req.AccountKey)
+	if err != nil {
+		return nil, err
+	}
+	client := &xacme.Client{
+		Key:          accountKey,
+		DirectoryURL: req.Directory,
+		UserAgent:    "auto-ssl",
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: req.RootCAs},
+			},
+		},
+	}
+
+	account := &xacme.Account{}
+	if req.Email != "" {
+		account.Contact = []string{"mailto:" + req.Email}
+	}
+	if _, err := client.Register(ctx, account, xacme.AcceptTOS); err != nil && !errors.Is(err, xacme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("registering the ACME account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("creating the order: %w", err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := authorize(ctx, client, url, req, logf); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("waiting for the order: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := certificateRequest(key, req.Names)
+	if err != nil {
+		return nil, err
+	}
+	logf("Finalizing the order")
+	chain, err := finalize(ctx, client, order, csr)
+	if err != nil {
+		return nil, fmt.Errorf("finalizing the order: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing the issued certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := savePair(req.CertPath, certPEM, req.KeyPath, keyPEM); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// savePair writes a certificate and its key. The certificate goes first;
+// if the key cannot be written after it, the previous certificate is put
+// back, so the files never hold a certificate for a key they do not.
+func savePair(certPath string, certPEM []byte, keyPath string, keyPEM []byte) error {
+	previous, err := os.ReadFile(certPath)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := atomicfile.Write(certPath, certPEM, 0644); err != nil {
+		return err
+	}
+	if err := atomicfile.Write(keyPath, keyPEM, 0600); err != nil {
+		if existed {
+			atomicfile.Write(certPath, previous, 0644)
+		} else {
+			os.Remove(certPath)
+		}
+		return err
+	}
+	return nil
+}
+
+// finalize submits the CSR and returns the issued chain. Servers that issue
+// asynchronously, such as pebble, may answer without the order's Location,
+// which CreateOrderCert needs to poll; the order's own URL is polled then.
+func finalize(ctx context.Context, client *xacme.Client, order *xacme.Order, csr []byte) ([][]byte, error) {
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err == nil {
+		return chain, nil
+	}
+	done, waitErr := client.WaitOrder(ctx, order.URI)
+	if waitErr != nil || done.Status != xacme.StatusValid {
+		return nil, err
+	}
+	return client.FetchCert(ctx, done.CertURL, true)
+}
+
+// authorize answers the requested challenge for one authorization and
+// waits for the ACME server to validate it.
+func authorize(ctx context.Context, client *xacme.Client, url string, req Request, logf func(string, ...any)) error {
+	authz, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return err
+	}
+	name := authz.Identifier.Value
+	if authz.Status == xacme.StatusValid {
+		logf("%s: already authorized", name)
+		return nil
+	}
+
+	var chal *xacme.Challenge
+	var offered []string
+	for _, c := range authz.Challenges {
+		offered = append(offered, c.Type)
+		if c.Type == req.Challenge {
+			chal = c
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("%s: the ACME server does not offer %s (offered: %s)", name, req.Challenge, strings.Join(offered, ", "))
+	}
+
+	logf("%s: answering %s", name, req.Challenge)
+	stop, err := respond(client, chal, name, req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer stop()
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("%s: accepting the challenge: %w", name, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("%s: %s validation failed: %w", name, req.Challenge, err)
+	}
+	logf("%s: validated", name)
+	return nil
+}
+
+// respond makes the challenge response available and returns a function
+// that withdraws it.
+func respond(client *xacme.Client, chal *xacme.Challenge, name string, req Request) (stop func(), err error) {
+	port := req.Port
+	if port == 0 {
+		port = DefaultPort(req.Challenge)
+	}
+	addr := net.JoinHostPort("", strconv.Itoa(port))
+
+	switch req.Challenge {
+	case HTTP01:
+		path := client.HTTP01ChallengePath(chal.Token)
+		body, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+		if req.Webroot != "" {
+			file := filepath.Join(req.Webroot, filepath.FromSlash(path))
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(file, []byte(body), 0644); err != nil {
+				return nil, err
+			}
+			return func() { os.Remove(file) }, nil
+		}
+		return serve(addr, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != path {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, body)
+		}))
+
+	default:
+		cert, err := client.TLSALPN01ChallengeCert(chal.Token, name)
+		if err != nil {
+			return nil, err
+		}
+		return serve(addr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"acme-tls/1"},
+		}, http.NotFoundHandler())
+	}
+}
+
+// serve listens on addr, over TLS when tlsConfig is set, until stop is
+// called.
+func serve(addr string, tlsConfig *tls.Config, handler http.Handler) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for the challenge (is a web server using the port? answer through its webroot instead): %w", err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return func() { srv.Close() }, nil
+}
+
+func certificateRequest(key crypto.Signer, names []string) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{Subject: pkix.Name{CommonName: names[0]}}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
+
+// loadAccountKey reads the account key at path, creating a new P-256 key
+// there when the file does not exist yet.
+func loadAccountKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		if err := atomicfile.Write(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not an account key: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+	return signer, nil
+}
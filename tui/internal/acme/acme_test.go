@@ -0,0 +1,187 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCertificateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		wantDNS []string
+		wantIPs []string
+	}{
+		{
+			name:    "dns only",
+			names:   []string{"web.internal", "www.internal"},
+			wantDNS: []string{"web.internal", "www.internal"},
+		},
+		{
+			name:    "ip only",
+			names:   []string{"192.168.1.50", "fd00::50"},
+			wantIPs: []string{"192.168.1.50", "fd00::50"},
+		},
+		{
+			name:    "ip subject with a dns name",
+			names:   []string{"192.168.1.50", "web.internal"},
+			wantDNS: []string{"web.internal"},
+			wantIPs: []string{"192.168.1.50"},
+		},
+		{
+			// Not an IP address, so it can only be a name.
+			name:    "dotted name that is not an ip",
+			names:   []string{"1.2.3.4.internal"},
+			wantDNS: []string{"1.2.3.4.internal"},
+		},
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := certificateRequest(key, tt.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := csr.CheckSignature(); err != nil {
+				t.Errorf("signature: %v", err)
+			}
+			if csr.Subject.CommonName != tt.names[0] {
+				t.Errorf("subject %q, want %q", csr.Subject.CommonName, tt.names[0])
+			}
+			if !reflect.DeepEqual(csr.DNSNames, tt.wantDNS) {
+				t.Errorf("DNS SANs %v, want %v", csr.DNSNames, tt.wantDNS)
+			}
+			var ips []string
+			for _, ip := range csr.IPAddresses {
+				ips = append(ips, ip.String())
+			}
+			if !reflect.DeepEqual(ips, tt.wantIPs) {
+				t.Errorf("IP SANs %v, want %v", ips, tt.wantIPs)
+			}
+		})
+	}
+}
+
+func TestLoadAccountKeyCreatesAndReuses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acme", "account.key")
+
+	created, err := loadAccountKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("account key mode %o, want 600", perm)
+	}
+
+	reused, err := loadAccountKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Public().(*ecdsa.PublicKey).Equal(reused.Public()) {
+		t.Error("a second load made a new account key")
+	}
+}
+
+func TestLoadAccountKeyPKCS8(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "account.key")
+			if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadAccountKey(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Public(), key.Public()) {
+				t.Errorf("loaded %T, not the key in the file", got)
+			}
+		})
+	}
+}
+
+func TestLoadAccountKeyRejectsOtherFiles(t *testing.T) {
+	tests := map[string][]byte{
+		"not pem":     []byte("hello\n"),
+		"certificate": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0x30, 0x00}}),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "account.key")
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadAccountKey(path); err == nil {
+				t.Error("loaded an account key from a file without one")
+			}
+			if got, _ := os.ReadFile(path); string(got) != string(data) {
+				t.Error("the file was replaced")
+			}
+		})
+	}
+}
+
+func TestSavePair(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server.crt")
+	keyPath := filepath.Join(dir, "server.key")
+
+	if err := savePair(certPath, []byte("cert 1"), keyPath, []byte("key 1")); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{certPath: "cert 1", keyPath: "key 1"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+
+	// The key cannot be written: the certificate written before it is
+	// rolled back, to the previous one or to nothing.
+	missing := filepath.Join(dir, "missing", "server.key")
+	if err := savePair(certPath, []byte("cert 2"), missing, []byte("key 2")); err == nil {
+		t.Fatal("saved a key into a missing directory")
+	}
+	if got, _ := os.ReadFile(certPath); string(got) != "cert 1" {
+		t.Errorf("certificate %q after the key failed, want the previous one", got)
+	}
+
+	newCert := filepath.Join(dir, "db.crt")
+	if err := savePair(newCert, []byte("cert 3"), missing, []byte("key 3")); err == nil {
+		t.Fatal("saved a key into a missing directory")
+	}
+	if _, err := os.Stat(newCert); !os.IsNotExist(err) {
+		t.Errorf("certificate left without its key: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package agent keeps a server certificate renewed. Instead of renewing on a
+// fixed calendar, it schedules each renewal from the certificate's own
+// lifetime, retries with exponential backoff while the CA is unreachable,
+// reloads the services using the certificate after every renewal and
+// reports its state in a status file that `auto-ssl agent status` reads.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+)
+
+// StatusFileName is the status file's name in the data directory.
+const StatusFileName = "agent.json"
+
+// Defaults for an Agent's zero fields.
+const (
+	DefaultRenewAt    = 2.0 / 3
+	DefaultMinBackoff = 30 * time.Second
+	DefaultMaxBackoff = 30 * time.Minute
+
+	// maxJitter caps the default jitter of lifetime/20.
+	maxJitter = time.Hour
+	// recheck bounds every wait, so a certificate replaced by hand (or a
+	// clock jump after suspend) is noticed within the hour.
+	recheck = time.Hour
+)
+
+// States reported in the status file.
+const (
+	StateWaiting  = "waiting"
+	StateRenewing = "renewing"
+	StateRetrying = "retrying"
+	StateStopped  = "stopped"
+)
+
+// Status is the agent's state as written to the status file.
+type Status struct {
+	Name            string     `json:"name,omitempty"`
+	PID             int        `json:"pid"`
+	State           string     `json:"state"`
+	CertPath        string     `json:"cert_path"`
+	NotAfter        *time.Time `json:"not_after,omitempty"`
+	NextRenewal     *time.Time `json:"next_renewal,omitempty"`
+	LastAttempt     *time.Time `json:"last_attempt,omitempty"`
+	LastRenewal     *time.Time `json:"last_renewal,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	Failures        int        `json:"failures"` // consecutive failed attempts
+	LastReloadError string     `json:"last_reload_error,omitempty"`
+	Updated         time.Time  `json:"updated"`
+}
+
+// Agent renews the certificate at CertPath.
+type Agent struct {
+	Name     string // reported in the status; several agents can run at once
+	CertPath string
+
+	// RenewAt is the fraction of the lifetime after which to renew;
+	// 0 means DefaultRenewAt.
+	RenewAt float64
+	// Jitter is the most that is added at random to each renewal time, so
+	// a fleet enrolled together does not renew together. 0 means a
+	// twentieth of the lifetime, at most an hour; negative disables it.
+	Jitter time.Duration
+
+	MinBackoff time.Duration // first retry delay; 0 means DefaultMinBackoff
+	MaxBackoff time.Duration // retry delay cap; 0 means DefaultMaxBackoff
+
+	// Renew replaces the certificate and key at CertPath.
+	Renew func(ctx context.Context) error
+	// Reload, if set, runs after each renewal so the services using the
+	// certificate serve cert, the renewed one.
+	Reload func(ctx context.Context, cert *x509.Certificate) error
+
+	StatusFile string    // "" keeps the status in memory only
+	Log        io.Writer // renewal and hook output; nil discards it
+}
+
+// Run renews the certificate whenever it is due until ctx is done.
+func (a *Agent) Run(ctx context.Context) error {
+	if a.Renew == nil {
+		return errors.New("agent: no renewal method")
+	}
+	st := &Status{Name: a.Name, PID: os.Getpid(), CertPath: a.CertPath}
+	defer func() {
+		st.State = StateStopped
+		a.save(st)
+	}()
+
+	backoff := a.minBackoff()
+	var scheduled []byte // the certificate renewAt belongs to
+	var renewAt time.Time
+
+	for {
+		certs, err := certinfo.LoadFile(a.CertPath)
+		if err != nil {
+			// step ca renew needs the current certificate, so there is
+			// nothing to renew until it is back.
+			a.fail(st, err, backoff)
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			backoff = a.nextBackoff(backoff)
+			continue
+		}
+		cert := certs[0]
+		if !bytes.Equal(cert.Raw, scheduled) {
+			scheduled = cert.Raw
+			renewAt = a.schedule(cert)
+			st.NotAfter = timePtr(cert.NotAfter)
+			st.NextRenewal = timePtr(renewAt)
+			st.Failures = 0
+			st.LastError = ""
+			backoff = a.minBackoff()
+			a.logf("%s expires %s; renewing at %s", a.CertPath, cert.NotAfter.Local().Format(time.RFC1123), renewAt.Local().Format(time.RFC1123))
+		}
+
+		if wait := time.Until(renewAt); wait > 0 {
+			st.State = StateWaiting
+			a.save(st)
+			if !sleep(ctx, min(wait, recheck)) {
+				return nil
+			}
+			continue
+		}
+
+		st.State = StateRenewing
+		st.LastAttempt = timePtr(time.Now())
+		a.save(st)
+		a.logf("Renewing %s", a.CertPath)
+		err = a.Renew(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var renewed []*x509.Certificate
+		if err == nil {
+			if renewed, err = certinfo.LoadFile(a.CertPath); err == nil && bytes.Equal(renewed[0].Raw, cert.Raw) {
+				err = errors.New("renewal did not replace the certificate")
+			}
+		}
+		if err != nil {
+			a.fail(st, err, backoff)
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			backoff = a.nextBackoff(backoff)
+			continue
+		}
+
+		st.LastRenewal = timePtr(time.Now())
+		st.LastReloadError = ""
+		a.logf("Renewed %s", a.CertPath)
+		if a.Reload != nil {
+			if err := a.Reload(ctx, renewed[0]); err != nil {
+				st.LastReloadError = err.Error()
+			}
+		}
+	}
+}
+
+// schedule returns when cert is due: RenewAt of the way through its
+// lifetime plus a random jitter, which never uses more than half of the
+// time left after that point.
+func (a *Agent) schedule(cert *x509.Certificate) time.Time {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	fraction := a.RenewAt
+	if fraction <= 0 || fraction >= 1 {
+		fraction = DefaultRenewAt
+	}
+	at := cert.NotBefore.Add(time.Duration(float64(lifetime) * fraction))
+
+	jitter := a.Jitter
+	if jitter == 0 {
+		jitter = min(lifetime/20, maxJitter)
+	}
+	jitter = min(jitter, cert.NotAfter.Sub(at)/2)
+	if jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return at
+}
+
+func (a *Agent) fail(st *Status, err error, backoff time.Duration) {
+	st.State = StateRetrying
+	st.Failures++
+	st.LastError = err.Error()
+	st.NextRenewal = timePtr(time.Now().Add(backoff))
+	a.save(st)
+	a.logf("Renewal failed (attempt %d): %v; retrying in %s", st.Failures, err, backoff)
+}
+
+func (a *Agent) minBackoff() time.Duration {
+	if a.MinBackoff > 0 {
+		return a.MinBackoff
+	}
+	return DefaultMinBackoff
+}
+
+func (a *Agent) nextBackoff(d time.Duration) time.Duration {
+	limit := a.MaxBackoff
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+	return min(2*d, limit)
+}
+
+// save writes st to the status file. A status file that cannot be written
+// is logged but does not stop renewals.
+func (a *Agent) save(st *Status) {
+	st.Updated = time.Now()
+	if a.StatusFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(a.StatusFile), 0755)
+	}
+	if err == nil {
+		err = atomicfile.Write(a.StatusFile, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		a.logf("Cannot write the status file: %v", err)
+	}
+}
+
+func (a *Agent) logf(format string, args ...any) {
+	if a.Log != nil {
+		fmt.Fprintf(a.Log, format+"\n", args...)
+	}
+}
+
+// ReadStatus reads the status file at path. running reports whether the
+// agent that wrote it is still alive.
+func ReadStatus(path string) (st *Status, running bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	st = &Status{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	running = st.State != StateStopped && processAlive(st.PID)
+	return st, running, nil
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,298 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newCert returns a self-signed certificate valid from notBefore to notAfter.
+func newCert(t *testing.T, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "server.internal"},
+		DNSNames:     []string{"server.internal"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeCert(t *testing.T, path string, cert *x509.Certificate) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduleRenewAt(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cert := newCert(t, start, start.Add(90*time.Hour))
+	tests := []struct {
+		renewAt float64
+		want    time.Duration // after NotBefore
+	}{
+		{renewAt: 0, want: 60 * time.Hour}, // DefaultRenewAt
+		{renewAt: 0.5, want: 45 * time.Hour},
+		{renewAt: 0.9, want: 81 * time.Hour},
+		{renewAt: 1, want: 60 * time.Hour},
+		{renewAt: 1.5, want: 60 * time.Hour},
+		{renewAt: -0.25, want: 60 * time.Hour},
+	}
+	for _, tt := range tests {
+		a := &Agent{RenewAt: tt.renewAt, Jitter: -1}
+		if got := a.schedule(cert).Sub(start); got != tt.want {
+			t.Errorf("RenewAt %v: renews %v after issue, want %v", tt.renewAt, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		lifetime  time.Duration
+		renewAt   float64
+		jitter    time.Duration
+		maxJitter time.Duration // the latest renewal, after the unjittered time
+	}{
+		// The default is a twentieth of the lifetime, at most an hour.
+		{name: "default", lifetime: 10 * time.Hour, maxJitter: 30 * time.Minute},
+		{name: "default capped", lifetime: 90 * 24 * time.Hour, maxJitter: time.Hour},
+		{name: "explicit", lifetime: 24 * time.Hour, jitter: 2 * time.Hour, maxJitter: 2 * time.Hour},
+		// Never more than half of the time left after the renewal point.
+		{name: "half the time left", lifetime: 10 * time.Hour, renewAt: 0.9, jitter: 5 * time.Hour, maxJitter: 30 * time.Minute},
+		{name: "short certificate", lifetime: 5 * time.Minute, renewAt: 0.8, jitter: time.Hour, maxJitter: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newCert(t, start, start.Add(tt.lifetime))
+			a := &Agent{RenewAt: tt.renewAt, Jitter: tt.jitter}
+			fraction := tt.renewAt
+			if fraction == 0 {
+				fraction = DefaultRenewAt
+			}
+			base := start.Add(time.Duration(float64(tt.lifetime) * fraction))
+
+			var latest time.Duration
+			for i := 0; i < 500; i++ {
+				at := a.schedule(cert)
+				offset := at.Sub(base)
+				if offset < 0 || offset >= tt.maxJitter {
+					t.Fatalf("renews %v after %v, want within [0, %v)", offset, base, tt.maxJitter)
+				}
+				if at.After(cert.NotAfter) {
+					t.Fatalf("renews at %v, after the certificate expires", at)
+				}
+				latest = max(latest, offset)
+			}
+			// 500 draws land in the top half of the window all but never.
+			if latest < tt.maxJitter/2 {
+				t.Errorf("latest jitter %v of %v: jitter not applied", latest, tt.maxJitter)
+			}
+		})
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		max  time.Duration
+		from time.Duration
+		want time.Duration
+	}{
+		{max: time.Minute, from: time.Second, want: 2 * time.Second},
+		{max: time.Minute, from: 20 * time.Second, want: 40 * time.Second},
+		{max: time.Minute, from: 40 * time.Second, want: time.Minute},
+		{max: time.Minute, from: time.Minute, want: time.Minute},
+		{max: 0, from: DefaultMinBackoff, want: 2 * DefaultMinBackoff},
+		{max: 0, from: DefaultMaxBackoff, want: DefaultMaxBackoff},
+	}
+	for _, tt := range tests {
+		a := &Agent{MaxBackoff: tt.max}
+		if got := a.nextBackoff(tt.from); got != tt.want {
+			t.Errorf("MaxBackoff %v: after %v, got %v, want %v", tt.max, tt.from, got, tt.want)
+		}
+	}
+	if got := (&Agent{}).minBackoff(); got != DefaultMinBackoff {
+		t.Errorf("default first retry %v, want %v", got, DefaultMinBackoff)
+	}
+}
+
+// stopAfter is the agent's log; it cancels the run once the renewal is done.
+type stopAfter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (s *stopAfter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bytes.HasPrefix(p, []byte("Renewed ")) {
+		s.cancel()
+	}
+	return s.buf.Write(p)
+}
+
+func (s *stopAfter) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+var retryDelay = regexp.MustCompile(`(?m)^Renewal failed \(attempt (\d+)\): (.*); retrying in (\S+)$`)
+
+func TestRunRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures is what each attempt does before the one that renews:
+		// "error" fails, "same" succeeds without replacing the certificate.
+		failures  []string
+		wantDelay []string
+	}{
+		{
+			name:      "renews first time",
+			failures:  nil,
+			wantDelay: nil,
+		},
+		{
+			name:      "backoff doubles and caps",
+			failures:  []string{"error", "error", "error", "error", "error"},
+			wantDelay: []string{"1ms", "2ms", "4ms", "8ms", "8ms"},
+		},
+		{
+			name:      "unchanged certificate is a failure",
+			failures:  []string{"same", "error", "same"},
+			wantDelay: []string{"1ms", "2ms", "4ms"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certPath := filepath.Join(dir, "server.crt")
+			now := time.Now()
+			// Two thirds of the way through its lifetime an hour ago: due.
+			writeCert(t, certPath, newCert(t, now.Add(-3*time.Hour), now.Add(time.Hour/2)))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			log := &stopAfter{cancel: cancel}
+			attempts := 0
+			a := &Agent{
+				CertPath:   certPath,
+				Jitter:     -1,
+				MinBackoff: time.Millisecond,
+				MaxBackoff: 8 * time.Millisecond,
+				StatusFile: filepath.Join(dir, "status", StatusFileName),
+				Log:        log,
+				Renew: func(ctx context.Context) error {
+					attempts++
+					if attempts <= len(tt.failures) {
+						if tt.failures[attempts-1] == "error" {
+							return errors.New("connection refused")
+						}
+						return nil
+					}
+					writeCert(t, certPath, newCert(t, time.Now(), time.Now().Add(24*time.Hour)))
+					return nil
+				},
+			}
+			if err := a.Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Fatalf("the agent never renewed; log:\n%s", log)
+			}
+
+			if attempts != len(tt.failures)+1 {
+				t.Errorf("%d renewal attempts, want %d", attempts, len(tt.failures)+1)
+			}
+			var delays []string
+			for i, m := range retryDelay.FindAllStringSubmatch(log.String(), -1) {
+				delays = append(delays, m[3])
+				want := "connection refused"
+				if tt.failures[i] == "same" {
+					want = "renewal did not replace the certificate"
+				}
+				if m[2] != want {
+					t.Errorf("attempt %s failed with %q, want %q", m[1], m[2], want)
+				}
+			}
+			if strings.Join(delays, ",") != strings.Join(tt.wantDelay, ",") {
+				t.Errorf("retry delays %v, want %v", delays, tt.wantDelay)
+			}
+
+			st, running, err := ReadStatus(a.StatusFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if running || st.State != StateStopped {
+				t.Errorf("status %s (running %v) after Run returned", st.State, running)
+			}
+			if st.LastRenewal == nil || st.Failures != 0 || st.LastError != "" {
+				t.Errorf("status after the renewal: last renewal %v, %d failures, error %q", st.LastRenewal, st.Failures, st.LastError)
+			}
+			if st.NextRenewal == nil || time.Until(*st.NextRenewal) < 12*time.Hour {
+				t.Errorf("next renewal %v, want two thirds into the new certificate", st.NextRenewal)
+			}
+		})
+	}
+}
+
+// A certificate that is not due is left alone until it is.
+func TestRunWaitsUntilDue(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server.crt")
+	now := time.Now()
+	writeCert(t, certPath, newCert(t, now.Add(-time.Hour), now.Add(23*time.Hour)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	renewed := false
+	a := &Agent{
+		CertPath: certPath,
+		Jitter:   -1,
+		Renew: func(ctx context.Context) error {
+			renewed = true
+			return nil
+		},
+	}
+	if err := a.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if renewed {
+		t.Error("renewed a certificate that is not due")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package agent
+
+// processAlive cannot check other processes here, so an agent that has not
+// recorded a clean stop is assumed to be running.
+func processAlive(pid int) bool {
+	return pid > 0
+}
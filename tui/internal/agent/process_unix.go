@@ -0,0 +1,10 @@
+//go:build unix
+
+package agent
+
+import "syscall"
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
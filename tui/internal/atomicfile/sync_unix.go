@@ -0,0 +1,15 @@
+//go:build unix
+
+package atomicfile
+
+import "os"
+
+// syncDir flushes a directory's entries, making a rename in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
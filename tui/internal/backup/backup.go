@@ -0,0 +1,410 @@
+// Package backup writes and reads encrypted CA backups.
+//
+// A backup is a gzipped tarball, encrypted as described in crypt.go, that
+// unpacks to:
+//
+//	metadata.json   what was backed up, where and when (Metadata)
+//	step-ca/        the step-ca directory
+//	config/         the auto-ssl config directory
+//	manifest.json   every entry above with its size and SHA-256 (Manifest)
+//	manifest.sig    HMAC-SHA256 of manifest.json, keyed from the passphrase
+//
+// Read checks each entry against the signed manifest, so a backup that
+// decrypts but is missing a file, or holds an extra one, is rejected too.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entries that are not part of a source directory.
+const (
+	MetadataName  = "metadata.json"
+	ManifestName  = "manifest.json"
+	SignatureName = "manifest.sig"
+)
+
+// Format is the archive format version written to the metadata and
+// manifest.
+const Format = 1
+
+// Metadata describes a backup. The Bash runtime reads ca_url from it.
+type Metadata struct {
+	Format      int       `json:"format"`
+	Version     string    `json:"version"` // of the auto-ssl that wrote it
+	Timestamp   time.Time `json:"timestamp"`
+	CAURL       string    `json:"ca_url"`
+	CAName      string    `json:"ca_name"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // of the root certificate
+	Hostname    string    `json:"hostname"`
+	StepPath    string    `json:"step_path,omitempty"` // where step-ca/ was taken from
+}
+
+// Entry types in a manifest.
+const (
+	TypeFile    = "file"
+	TypeDir     = "dir"
+	TypeSymlink = "symlink"
+)
+
+// File is one manifest entry.
+type File struct {
+	Path   string      `json:"path"`
+	Type   string      `json:"type"`
+	Mode   fs.FileMode `json:"mode"` // permission bits
+	Size   int64       `json:"size,omitempty"`
+	SHA256 string      `json:"sha256,omitempty"`
+	Link   string      `json:"link,omitempty"`
+}
+
+// Manifest lists every entry of a backup but itself and its signature.
+type Manifest struct {
+	Format  int       `json:"format"`
+	Created time.Time `json:"created"`
+	Files   []File    `json:"files"`
+}
+
+// Source is a directory to back up.
+type Source struct {
+	Name string // top-level directory in the archive, e.g. step-ca
+	Dir  string // directory on disk
+}
+
+// Contents is what Read found in a backup.
+type Contents struct {
+	Metadata Metadata
+	Manifest Manifest
+}
+
+// Create writes an encrypted backup of meta and sources to w. Entries other
+// than directories, regular files and symlinks (sockets, devices) are
+// skipped.
+func Create(w io.Writer, passphrase []byte, meta Metadata, sources []Source) (*Manifest, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("the passphrase is empty")
+	}
+	ew, err := newEncryptWriter(w, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(ew)
+	tw := tar.NewWriter(gz)
+
+	meta.Format = Format
+	manifest := &Manifest{Format: Format, Created: meta.Timestamp}
+	add := func(hdr *tar.Header, body io.Reader) error {
+		f := File{Path: strings.TrimSuffix(hdr.Name, "/"), Mode: fs.FileMode(hdr.Mode) & fs.ModePerm}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			f.Type = TypeDir
+		case tar.TypeSymlink:
+			f.Type, f.Link = TypeSymlink, hdr.Linkname
+		default:
+			f.Type, f.Size = TypeFile, hdr.Size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if body != nil {
+			h := sha256.New()
+			if n, err := io.Copy(tw, io.TeeReader(io.LimitReader(body, hdr.Size), h)); err != nil {
+				return err
+			} else if n != hdr.Size {
+				return fmt.Errorf("%s changed while it was backed up", hdr.Name)
+			}
+			f.SHA256 = hex.EncodeToString(h.Sum(nil))
+		}
+		manifest.Files = append(manifest.Files, f)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	hdr := &tar.Header{Name: MetadataName, Mode: 0644, Size: int64(len(data)), ModTime: meta.Timestamp}
+	if err := add(hdr, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	for _, src := range sources {
+		if err := addTree(add, src); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sig := hex.EncodeToString(sign(ew.keys.macKey, data)) + "\n"
+	for _, entry := range []struct{ name, data string }{{ManifestName, string(data) + "\n"}, {SignatureName, sig}} {
+		hdr := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data)), ModTime: meta.Timestamp}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, entry.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, ew.Close()
+}
+
+func addTree(add func(*tar.Header, io.Reader) error, src Source) error {
+	return filepath.WalkDir(src.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src.Dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(src.Name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if !info.Mode().IsRegular() {
+			return add(hdr, nil)
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return add(hdr, f)
+	})
+}
+
+func sign(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Read decrypts the backup in r and calls fn for every entry but the
+// manifest and its signature, in archive order. fn may leave body unread.
+// Entries are only checked against the manifest once the archive has been
+// read to the end, so fn must not trust what it got until Read returns nil.
+func Read(r io.Reader, passphrase []byte, fn func(hdr *tar.Header, body io.Reader) error) (*Contents, error) {
+	dr, err := newDecryptReader(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(dr)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	found := map[string]File{}
+	var metaData, manifestData, sigData []byte
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("the backup holds an unsafe path: %s", hdr.Name)
+		}
+		switch name {
+		case ManifestName:
+			manifestData, err = io.ReadAll(tr)
+		case SignatureName:
+			sigData, err = io.ReadAll(tr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name == ManifestName || name == SignatureName {
+			continue
+		}
+		if _, dup := found[name]; dup {
+			return nil, fmt.Errorf("the backup holds %s twice", name)
+		}
+
+		f := File{Path: name, Mode: fs.FileMode(hdr.Mode) & fs.ModePerm}
+		var body io.Reader
+		h := sha256.New()
+		hashed := io.TeeReader(tr, h)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			f.Type = TypeDir
+		case tar.TypeSymlink:
+			f.Type, f.Link = TypeSymlink, hdr.Linkname
+		case tar.TypeReg:
+			f.Type, f.Size = TypeFile, hdr.Size
+			body = hashed
+			if name == MetadataName {
+				if metaData, err = io.ReadAll(body); err != nil {
+					return nil, err
+				}
+				body = bytes.NewReader(metaData)
+			}
+		default:
+			return nil, fmt.Errorf("the backup holds %s of unsupported type %q", name, hdr.Typeflag)
+		}
+		if err := fn(hdr, body); err != nil {
+			return nil, err
+		}
+		if f.Type == TypeFile {
+			if _, err := io.Copy(io.Discard, hashed); err != nil {
+				return nil, err
+			}
+			f.SHA256 = hex.EncodeToString(h.Sum(nil))
+		}
+		found[name] = f
+	}
+	// The gzip stream, and the encryption under it, must end here too.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, err
+	}
+
+	if manifestData == nil || sigData == nil || metaData == nil {
+		return nil, fmt.Errorf("the backup has no %s, %s or %s", MetadataName, ManifestName, SignatureName)
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || !hmac.Equal(sig, sign(dr.keys.macKey, []byte(strings.TrimSuffix(string(manifestData), "\n")))) {
+		return nil, errors.New("the manifest signature does not match")
+	}
+	c := &Contents{}
+	if err := json.Unmarshal(manifestData, &c.Manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestName, err)
+	}
+	if err := json.Unmarshal(metaData, &c.Metadata); err != nil {
+		return nil, fmt.Errorf("%s: %w", MetadataName, err)
+	}
+	if c.Manifest.Format != Format {
+		return nil, fmt.Errorf("unsupported backup format %d", c.Manifest.Format)
+	}
+
+	for _, want := range c.Manifest.Files {
+		got, ok := found[want.Path]
+		if !ok {
+			return nil, fmt.Errorf("the backup is missing %s", want.Path)
+		}
+		if got != want {
+			return nil, fmt.Errorf("%s does not match the manifest", want.Path)
+		}
+		delete(found, want.Path)
+	}
+	if len(found) > 0 {
+		extra := make([]string, 0, len(found))
+		for name := range found {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return nil, fmt.Errorf("the backup holds files that are not in the manifest: %s", strings.Join(extra, ", "))
+	}
+	return c, nil
+}
+
+// Extract reads the backup in r into dir, which should be new and empty:
+// when Extract fails, what it wrote there has not been verified and should
+// be removed. Ownership is restored when running as root.
+func Extract(r io.Reader, passphrase []byte, dir string) (*Contents, error) {
+	type dirMode struct {
+		path string
+		mode fs.FileMode
+	}
+	var dirs []dirMode
+	links := map[string]bool{}
+
+	c, err := Read(r, passphrase, func(hdr *tar.Header, body io.Reader) error {
+		name := strings.TrimSuffix(hdr.Name, "/")
+		// Never write through a symlink from the archive itself.
+		for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+			if links[parent] {
+				return fmt.Errorf("the backup writes %s through a symlink", name)
+			}
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		mode := fs.FileMode(hdr.Mode) & fs.ModePerm
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			dirs = append(dirs, dirMode{target, mode})
+		case tar.TypeSymlink:
+			links[name] = true
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, body)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+		if os.Geteuid() == 0 {
+			return os.Lchown(target, hdr.Uid, hdr.Gid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Directories were created writable so they could be filled.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
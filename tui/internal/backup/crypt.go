@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted backup starts with a header,
+//
+//	magic "auto-ssl-backup/v1\n"
+//	scrypt parameters: log2(N), r, p (one byte each)
+//	salt (16 bytes)
+//
+// followed by the archive in chunks of chunkSize bytes, each sealed with
+// AES-256-GCM. The nonce is the chunk's index plus a flag marking the last
+// chunk, and the header is the additional data of every chunk, so chunks
+// cannot be reordered, dropped or cut off and the header cannot be edited.
+const (
+	Magic      = "auto-ssl-backup/v1\n"
+	saltSize   = 16
+	headerSize = len(Magic) + 3 + saltSize
+	chunkSize  = 64 * 1024
+
+	// scrypt cost for new backups: 32 MiB and about a tenth of a second.
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+	// maxLogN keeps a doctored header from asking for more than 1 GiB.
+	maxLogN = 20
+)
+
+// ErrPassphrase is returned when the first chunk of a backup does not
+// decrypt. A wrong passphrase is far more likely than damage there.
+var ErrPassphrase = errors.New("cannot decrypt the backup: wrong passphrase, or the file is damaged")
+
+// keys holds what the passphrase and header derive: the chunk cipher and
+// the key that signs the manifest.
+type keys struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func deriveKeys(passphrase, header []byte) (*keys, error) {
+	logN, r, p := int(header[len(Magic)]), int(header[len(Magic)+1]), int(header[len(Magic)+2])
+	if logN < 10 || logN > maxLogN || r < 1 || r > 32 || p < 1 || p > 16 {
+		return nil, fmt.Errorf("unsupported scrypt parameters N=2^%d r=%d p=%d", logN, r, p)
+	}
+	salt := header[len(Magic)+3:]
+	key, err := scrypt.Key(passphrase, salt, 1<<logN, r, p, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keys{aead: aead, macKey: key[32:]}, nil
+}
+
+func nonce(counter uint64, last bool) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[3:11], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+// encryptWriter seals what is written to it chunk by chunk. Close seals
+// the last chunk, which may be empty, but does not close w.
+type encryptWriter struct {
+	w       io.Writer
+	keys    *keys
+	header  []byte
+	buf     []byte
+	counter uint64
+}
+
+func newEncryptWriter(w io.Writer, passphrase []byte) (*encryptWriter, error) {
+	header := make([]byte, headerSize)
+	copy(header, Magic)
+	header[len(Magic)] = scryptLogN
+	header[len(Magic)+1] = scryptR
+	header[len(Magic)+2] = scryptP
+	if _, err := rand.Read(header[len(Magic)+3:]); err != nil {
+		return nil, err
+	}
+	k, err := deriveKeys(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, keys: k, header: header, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is sealed only once more data follows, so that
+		// Close can always mark the final one.
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.keys.aead.Seal(nil, nonce(e.counter, last), e.buf, e.header)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader opens the chunks written by an encryptWriter. It returns
+// io.EOF only after the chunk marked last.
+type decryptReader struct {
+	r       *bufio.Reader
+	keys    *keys
+	header  []byte
+	sealed  []byte
+	buf     []byte
+	counter uint64
+	done    bool
+}
+
+func newDecryptReader(r io.Reader, passphrase []byte) (*decryptReader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(Magic)]) != Magic {
+		return nil, errors.New("not an auto-ssl backup")
+	}
+	k, err := deriveKeys(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, chunkSize+k.aead.Overhead()),
+		keys:   k,
+		header: header,
+		sealed: make([]byte, chunkSize+k.aead.Overhead()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	last := false
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("the backup is truncated")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := d.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plain, err := d.keys.aead.Open(nil, nonce(d.counter, last), d.sealed[:n], d.header)
+	if err != nil {
+		// A file cut off right after a chunk ends on one not marked last.
+		if _, openErr := d.keys.aead.Open(nil, nonce(d.counter, false), d.sealed[:n], d.header); last && openErr == nil {
+			return errors.New("the backup is truncated")
+		}
+		if d.counter == 0 {
+			return ErrPassphrase
+		}
+		return fmt.Errorf("the backup is damaged: chunk %d fails authentication", d.counter)
+	}
+	d.counter++
+	d.buf = plain
+	d.done = last
+	return nil
+}
@@ -0,0 +1,201 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var passphrase = []byte("correct horse battery staple")
+
+// sealedSize is the size of a full chunk once sealed.
+const sealedSize = chunkSize + 16
+
+func encrypt(t *testing.T, plain []byte, writeSize int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newEncryptWriter(&out, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := plain; len(p) > 0; {
+		n := writeSize
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(data, passphrase []byte) ([]byte, error) {
+	r, err := newDecryptReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func plaintext(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		writeSize int
+		chunks    int
+	}{
+		{"empty", 0, 1, 1},
+		{"one byte", 1, 1, 1},
+		{"under a chunk", chunkSize - 1, chunkSize, 1},
+		{"exactly a chunk", chunkSize, chunkSize, 1},
+		{"just over a chunk", chunkSize + 1, chunkSize, 2},
+		{"whole chunks", 3 * chunkSize, 7000, 3},
+		{"small writes", 2*chunkSize + chunkSize/2, 1000, 3},
+		{"one large write", 4*chunkSize + 17, 1 << 20, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := plaintext(tt.size)
+			sealed := encrypt(t, plain, tt.writeSize)
+
+			if want := headerSize + tt.size + 16*tt.chunks; len(sealed) != want {
+				t.Errorf("encrypted size = %d, want %d (%d chunks)", len(sealed), want, tt.chunks)
+			}
+			if !bytes.HasPrefix(sealed, []byte(Magic)) {
+				t.Errorf("encrypted backup does not start with %q", Magic)
+			}
+			got, err := decrypt(sealed, passphrase)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes differ from the %d encrypted", len(got), len(plain))
+			}
+		})
+	}
+}
+
+// Each backup gets its own salt, so the same archive encrypts differently.
+func TestEncryptSalted(t *testing.T) {
+	plain := plaintext(100)
+	a, b := encrypt(t, plain, 100), encrypt(t, plain, 100)
+	if bytes.Equal(a[:headerSize], b[:headerSize]) || bytes.Equal(a[headerSize:], b[headerSize:]) {
+		t.Error("two encryptions of the same archive are identical")
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	sealed := encrypt(t, plaintext(chunkSize+10), chunkSize)
+	_, err := decrypt(sealed, []byte("wrong"))
+	if !errors.Is(err, ErrPassphrase) {
+		t.Errorf("err = %v, want ErrPassphrase", err)
+	}
+}
+
+func TestDecryptDamaged(t *testing.T) {
+	// Three full chunks and a partial last one.
+	sealed := encrypt(t, plaintext(3*chunkSize+100), chunkSize)
+	chunk := func(i int) []byte {
+		start := headerSize + i*sealedSize
+		end := start + sealedSize
+		if end > len(sealed) {
+			end = len(sealed)
+		}
+		return sealed[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(append([][]byte{sealed[:headerSize]}, parts...), nil)
+	}
+	flip := func(offset int) []byte {
+		data := append([]byte(nil), sealed...)
+		data[offset] ^= 0x01
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string // in the error; "" means ErrPassphrase
+	}{
+		{"last chunk dropped", join(chunk(0), chunk(1), chunk(2)), "truncated"},
+		{"cut off after the first chunk", join(chunk(0)), "truncated"},
+		{"cut off mid-chunk", sealed[:headerSize+sealedSize+1000], "chunk 1 fails authentication"},
+		{"cut off after the header", sealed[:headerSize], "truncated"},
+		{"cut off in the header", sealed[:headerSize-1], "not an auto-ssl backup"},
+		{"chunks reordered", join(chunk(0), chunk(2), chunk(1), chunk(3)), "chunk 1 fails authentication"},
+		{"chunk dropped", join(chunk(0), chunk(2), chunk(3)), "chunk 1 fails authentication"},
+		{"chunk repeated", join(chunk(0), chunk(1), chunk(1), chunk(2), chunk(3)), "chunk 2 fails authentication"},
+		{"data appended", append(append([]byte(nil), sealed...), chunk(3)...), "chunk 3 fails authentication"},
+		{"second chunk tampered", flip(headerSize + sealedSize + 5), "chunk 1 fails authentication"},
+		{"last chunk tampered", flip(len(sealed) - 1), "chunk 3 fails authentication"},
+		{"first chunk tampered", flip(headerSize + 5), ""},
+		{"salt changed", flip(headerSize - 1), ""},
+		{"scrypt parameters changed", flip(len(Magic) + 1), ""},
+		{"magic changed", flip(0), "not an auto-ssl backup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decrypt(tt.data, passphrase)
+			if err == nil {
+				t.Fatalf("decrypted %d bytes, want an error", len(got))
+			}
+			if tt.want == "" {
+				if !errors.Is(err, ErrPassphrase) {
+					t.Errorf("err = %v, want ErrPassphrase", err)
+				}
+				return
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// The header is the additional data of every chunk: chunks do not open
+// under another header even with the right key.
+func TestDecryptHeaderIsAuthenticated(t *testing.T) {
+	sealed := encrypt(t, plaintext(100), 100)
+	header := sealed[:headerSize]
+	k, err := deriveKeys(passphrase, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := append([]byte(nil), header...)
+	other[len(other)-1] ^= 0x01
+	d := &decryptReader{
+		r:      bufio.NewReader(bytes.NewReader(sealed[headerSize:])),
+		keys:   k,
+		header: other,
+		sealed: make([]byte, sealedSize),
+	}
+	if _, err := io.ReadAll(d); err == nil {
+		t.Error("a chunk opened under a modified header")
+	}
+}
+
+func TestDecryptUnsupportedParameters(t *testing.T) {
+	sealed := encrypt(t, nil, 1)
+	sealed[len(Magic)] = maxLogN + 1
+	_, err := decrypt(sealed, passphrase)
+	if err == nil || !strings.Contains(err.Error(), "unsupported scrypt parameters") {
+		t.Errorf("err = %v, want the parameters refused", err)
+	}
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Policy says which backups a destination keeps, in the style of
+// grandfather-father-son rotation: the newest Last backups, plus the newest
+// backup of each of the last Daily days, Weekly ISO weeks and Monthly
+// months that have one. Days, weeks and months are in local time.
+type Policy struct {
+	Last    int `json:"keep_last"`
+	Daily   int `json:"keep_daily"`
+	Weekly  int `json:"keep_weekly"`
+	Monthly int `json:"keep_monthly"`
+}
+
+// IsZero reports whether p keeps everything, which is what a destination
+// without a policy does.
+func (p Policy) IsZero() bool {
+	return p.Last <= 0 && p.Daily <= 0 && p.Weekly <= 0 && p.Monthly <= 0
+}
+
+func (p Policy) String() string {
+	if p.IsZero() {
+		return "keep everything"
+	}
+	return fmt.Sprintf("keep last %d, daily %d, weekly %d, monthly %d", p.Last, p.Daily, p.Weekly, p.Monthly)
+}
+
+// Decision is what a policy does with one backup.
+type Decision struct {
+	Object
+	Keep    bool     `json:"keep"`
+	Reasons []string `json:"reasons,omitempty"` // last, daily, weekly, monthly
+}
+
+// Apply decides which of backups p keeps, and returns the decisions
+// newest first.
+func (p Policy) Apply(backups []Object) []Decision {
+	decisions := make([]Decision, len(backups))
+	for i, obj := range backups {
+		decisions[i] = Decision{Object: obj}
+	}
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].Time.After(decisions[j].Time)
+	})
+	if p.IsZero() {
+		for i := range decisions {
+			decisions[i].Keep = true
+		}
+		return decisions
+	}
+
+	tiers := []struct {
+		reason string
+		keep   int
+		period func(o Object) string
+	}{
+		{"last", p.Last, func(o Object) string { return o.Name }},
+		{"daily", p.Daily, func(o Object) string { return o.Time.Local().Format("2006-01-02") }},
+		{"weekly", p.Weekly, func(o Object) string {
+			year, week := o.Time.Local().ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.Monthly, func(o Object) string { return o.Time.Local().Format("2006-01") }},
+	}
+	for _, tier := range tiers {
+		seen := map[string]bool{}
+		for i := range decisions {
+			if len(seen) >= tier.keep {
+				break
+			}
+			period := tier.period(decisions[i].Object)
+			if seen[period] {
+				continue
+			}
+			seen[period] = true
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, tier.reason)
+		}
+	}
+	return decisions
+}
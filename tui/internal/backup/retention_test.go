@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// at parses "2006-01-02 15:04" in local time, the zone Apply works in.
+func at(t *testing.T, stamp string) time.Time {
+	t.Helper()
+	ts, err := time.ParseInLocation("2006-01-02 15:04", stamp, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestPolicyApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		backups []string
+		// want maps each kept backup to its reasons; the rest are pruned.
+		want map[string]string
+	}{
+		{
+			name:    "no policy keeps everything",
+			policy:  Policy{},
+			backups: []string{"2024-05-01 02:00", "2024-05-02 02:00"},
+			want:    map[string]string{"2024-05-01 02:00": "", "2024-05-02 02:00": ""},
+		},
+		{
+			name:    "keep last",
+			policy:  Policy{Last: 2},
+			backups: []string{"2024-05-01 02:00", "2024-05-03 02:00", "2024-05-02 02:00", "2024-05-04 02:00"},
+			want:    map[string]string{"2024-05-04 02:00": "last", "2024-05-03 02:00": "last"},
+		},
+		{
+			name:    "daily keeps the newest of each day",
+			policy:  Policy{Daily: 2},
+			backups: []string{"2024-05-01 23:59", "2024-05-02 00:00", "2024-05-02 12:00", "2024-05-03 08:00"},
+			want:    map[string]string{"2024-05-03 08:00": "daily", "2024-05-02 12:00": "daily"},
+		},
+		{
+			name:    "daily skips days without backups",
+			policy:  Policy{Daily: 3},
+			backups: []string{"2024-04-20 02:00", "2024-04-25 02:00", "2024-05-01 02:00", "2024-05-01 14:00"},
+			want:    map[string]string{"2024-05-01 14:00": "daily", "2024-04-25 02:00": "daily", "2024-04-20 02:00": "daily"},
+		},
+		{
+			name:    "tiers overlap on the newest backup",
+			policy:  Policy{Last: 1, Daily: 2, Weekly: 1, Monthly: 1},
+			backups: []string{"2024-05-06 02:00", "2024-05-07 02:00", "2024-05-07 14:00"},
+			want: map[string]string{
+				"2024-05-07 14:00": "last,daily,weekly,monthly",
+				"2024-05-06 02:00": "daily",
+			},
+		},
+		{
+			name:   "grandfather-father-son",
+			policy: Policy{Last: 2, Daily: 3, Weekly: 2, Monthly: 3},
+			backups: []string{
+				"2024-03-15 02:00",
+				"2024-04-10 02:00", "2024-04-30 02:00",
+				"2024-05-20 02:00", // Monday of week 21
+				"2024-05-26 02:00", // Sunday of week 21
+				"2024-05-27 02:00", "2024-05-28 02:00", "2024-05-28 14:00", "2024-05-29 02:00",
+			},
+			want: map[string]string{
+				"2024-05-29 02:00": "last,daily,weekly,monthly",
+				"2024-05-28 14:00": "last,daily",
+				"2024-05-27 02:00": "daily",
+				"2024-05-26 02:00": "weekly",
+				"2024-04-30 02:00": "monthly",
+				"2024-03-15 02:00": "monthly",
+			},
+		},
+		{
+			name:   "ISO weeks start on Monday",
+			policy: Policy{Weekly: 2},
+			// 2022-12-31 and 2023-01-01 are in 2022-W52, 2023-01-02 in 2023-W01.
+			backups: []string{"2022-12-31 02:00", "2023-01-01 02:00", "2023-01-02 02:00"},
+			want:    map[string]string{"2023-01-02 02:00": "weekly", "2023-01-01 02:00": "weekly"},
+		},
+		{
+			name:   "ISO week years",
+			policy: Policy{Weekly: 2},
+			// 2024-12-30 to 2025-01-05 are all in 2025-W01; 2024-12-29 is 2024-W52.
+			backups: []string{"2024-12-29 02:00", "2024-12-30 02:00", "2025-01-05 02:00"},
+			want:    map[string]string{"2025-01-05 02:00": "weekly", "2024-12-29 02:00": "weekly"},
+		},
+		{
+			name:   "53-week years",
+			policy: Policy{Weekly: 3},
+			// 2020-W53 runs from 2020-12-28 to 2021-01-03.
+			backups: []string{"2020-12-27 02:00", "2020-12-28 02:00", "2021-01-03 02:00", "2021-01-04 02:00"},
+			want:    map[string]string{"2021-01-04 02:00": "weekly", "2021-01-03 02:00": "weekly", "2020-12-27 02:00": "weekly"},
+		},
+		{
+			name:    "months end at midnight",
+			policy:  Policy{Monthly: 2},
+			backups: []string{"2024-01-31 23:30", "2024-02-01 00:30", "2024-02-29 23:59", "2024-03-01 00:00"},
+			want:    map[string]string{"2024-03-01 00:00": "monthly", "2024-02-29 23:59": "monthly"},
+		},
+		{
+			name:    "months across a year",
+			policy:  Policy{Monthly: 3},
+			backups: []string{"2023-11-30 02:00", "2023-12-01 02:00", "2023-12-31 02:00", "2024-01-01 02:00"},
+			want:    map[string]string{"2024-01-01 02:00": "monthly", "2023-12-31 02:00": "monthly", "2023-11-30 02:00": "monthly"},
+		},
+		{
+			name:    "policy larger than the backups",
+			policy:  Policy{Last: 10, Monthly: 12},
+			backups: []string{"2024-05-01 02:00", "2024-05-02 02:00"},
+			want:    map[string]string{"2024-05-02 02:00": "last,monthly", "2024-05-01 02:00": "last"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backups []Object
+			for _, stamp := range tt.backups {
+				backups = append(backups, Object{Name: stamp, Time: at(t, stamp)})
+			}
+			decisions := tt.policy.Apply(backups)
+
+			if len(decisions) != len(backups) {
+				t.Fatalf("%d decisions for %d backups", len(decisions), len(backups))
+			}
+			for i := 1; i < len(decisions); i++ {
+				if decisions[i].Time.After(decisions[i-1].Time) {
+					t.Errorf("decisions not newest first: %s before %s", decisions[i-1].Name, decisions[i].Name)
+				}
+			}
+			got := map[string]string{}
+			for _, d := range decisions {
+				if d.Keep {
+					got[d.Name] = strings.Join(d.Reasons, ",")
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyString(t *testing.T) {
+	if got := (Policy{}).String(); got != "keep everything" {
+		t.Errorf("zero policy = %q", got)
+	}
+	if got := (Policy{Last: 3, Monthly: 6}).String(); got != "keep last 3, daily 0, weekly 0, monthly 6" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RsyncStore keeps backups in a directory that rsync reaches, usually over
+// SSH (user@host:/backups). It runs the rsync binary, so the destination
+// uses the same SSH configuration as the upload.
+type RsyncStore struct {
+	Target string
+}
+
+func (s *RsyncStore) String() string {
+	return "rsync:" + s.Target
+}
+
+func (s *RsyncStore) dir() string {
+	return strings.TrimSuffix(s.Target, "/") + "/"
+}
+
+// rsyncListLine matches a line of `rsync --list-only`:
+//
+//	-rw-------        307200 2026/10/16 02:00:41 ca-backup-20261016-020041.enc
+var rsyncListLine = regexp.MustCompile(`^(\S+)\s+([\d,.]+)\s+(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})\s(.+)$`)
+
+func (s *RsyncStore) List(ctx context.Context) ([]Object, error) {
+	out, err := runRsync(ctx, "--list-only", "--no-h", s.dir())
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := rsyncListLine.FindStringSubmatch(scanner.Text())
+		if m == nil || !strings.HasPrefix(m[1], "-") || !IsBackupName(m[4]) {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(m[2]), 10, 64)
+		if err != nil {
+			continue
+		}
+		// rsync prints times in the local time zone.
+		modified, _ := time.ParseInLocation("2006/01/02 15:04:05", m[3], time.Local)
+		objects = append(objects, newObject(m[4], size, modified))
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+func (s *RsyncStore) Put(ctx context.Context, name, file string) error {
+	if err := checkPutName(name); err != nil {
+		return err
+	}
+	_, err := runRsync(ctx, "--times", "--perms", file, s.dir()+name)
+	return err
+}
+
+// Delete has rsync delete the file, by syncing an empty directory over the
+// destination with a filter that only lets name through. No shell runs on
+// the remote end.
+func (s *RsyncStore) Delete(ctx context.Context, name string) error {
+	if !IsBackupName(name) || strings.ContainsAny(name, "*?[") {
+		return fmt.Errorf("%q is not a backup name", name)
+	}
+	empty, err := os.MkdirTemp("", "auto-ssl-rsync-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(empty)
+	_, err = runRsync(ctx, "--dirs", "--delete", "--include=/"+name, "--exclude=*", empty+"/", s.dir())
+	return err
+}
+
+func runRsync(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// rsync and ssh name themselves in their messages.
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(strings.ReplaceAll(msg, "\n", "; "))
+		}
+		return nil, fmt.Errorf("rsync: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Brightblade42/auto-ssl/internal/s3"
+)
+
+// S3Store keeps backups under a prefix of an S3 bucket, at AWS or, with
+// Endpoint, at an S3-compatible service such as Wasabi, MinIO or Backblaze
+// B2. Credentials come from the environment or ~/.aws/credentials; the
+// aws CLI is not needed.
+type S3Store struct {
+	Bucket   string
+	Endpoint string
+	Region   string
+	Prefix   string
+
+	client *s3.Client
+}
+
+func (s *S3Store) String() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+func (s *S3Store) connect() (*s3.Client, error) {
+	if s.client == nil {
+		client, err := s3.New(s.Bucket, s.Endpoint, s.Region)
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+	return s.client, nil
+}
+
+func (s *S3Store) List(ctx context.Context) ([]Object, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	listed, err := client.List(ctx, s.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, obj := range listed {
+		name := strings.TrimPrefix(obj.Key, s.Prefix)
+		if !IsBackupName(name) {
+			continue
+		}
+		objects = append(objects, newObject(name, obj.Size, obj.LastModified))
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, name, file string) error {
+	if err := checkPutName(name); err != nil {
+		return err
+	}
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return client.Put(ctx, s.Prefix+name, f, info.Size())
+}
+
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	if !IsBackupName(name) {
+		return fmt.Errorf("%q is not a backup name", name)
+	}
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+	return client.Delete(ctx, s.Prefix+name)
+}
@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+)
+
+// Scheduled backups are named ca-backup-YYYYMMDD-HHMMSS.enc, in local time.
+// Stores list, and retention deletes, only files named like this, so
+// anything else at a destination is left alone.
+const (
+	NamePrefix     = "ca-backup-"
+	NameSuffix     = ".enc"
+	nameTimeLayout = "20060102-150405"
+)
+
+// IsBackupName reports whether name is a backup a store manages.
+func IsBackupName(name string) bool {
+	return strings.HasPrefix(name, NamePrefix) && strings.HasSuffix(name, NameSuffix) &&
+		!strings.ContainsAny(name, "/\\") && len(name) > len(NamePrefix)+len(NameSuffix)
+}
+
+// BackupName returns the name of a backup taken at t.
+func BackupName(t time.Time) string {
+	return NamePrefix + t.Local().Format(nameTimeLayout) + NameSuffix
+}
+
+// Object is a backup at a destination.
+type Object struct {
+	Name string    `json:"name"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"` // when it was taken, from its name if it has one
+}
+
+func newObject(name string, size int64, modified time.Time) Object {
+	t := modified
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, NamePrefix), NameSuffix)
+	if parsed, err := time.ParseInLocation(nameTimeLayout, stamp, time.Local); err == nil {
+		t = parsed
+	}
+	return Object{Name: name, Size: size, Time: t}
+}
+
+func sortObjects(objects []Object) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Time.After(objects[j].Time) })
+}
+
+// Store is a backup destination.
+type Store interface {
+	// String names the destination, e.g. local:/var/backups/auto-ssl.
+	String() string
+	// List returns the backups at the destination, newest first.
+	List(ctx context.Context) ([]Object, error)
+	// Put uploads the local file as name, replacing any backup called
+	// that.
+	Put(ctx context.Context, name, file string) error
+	// Delete removes the backup called name.
+	Delete(ctx context.Context, name string) error
+}
+
+// checkPutName rejects names that would land outside the destination. Put
+// takes any file name, not only a backup name, since `ca backup --output`
+// lets the user choose it.
+func checkPutName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("%q is not a file name", name)
+	}
+	return nil
+}
+
+// Prune deletes the backups at s that p does not keep, or with dryRun only
+// decides which. It stops at the first failed deletion; the decisions are
+// returned either way.
+func Prune(ctx context.Context, s Store, p Policy, dryRun bool) ([]Decision, error) {
+	objects, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	decisions := p.Apply(objects)
+	if dryRun {
+		return decisions, nil
+	}
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		if err := s.Delete(ctx, d.Name); err != nil {
+			return decisions, fmt.Errorf("deleting %s: %w", d.Name, err)
+		}
+	}
+	return decisions, nil
+}
+
+// LocalStore keeps backups in a directory.
+type LocalStore struct {
+	Dir string
+}
+
+func (s *LocalStore) String() string {
+	return "local:" + s.Dir
+}
+
+// List returns no backups, rather than an error, when the directory has
+// not been created yet.
+func (s *LocalStore) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !IsBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, newObject(entry.Name(), info.Size(), info.ModTime()))
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+// Put copies file into the directory under a temporary name and renames
+// it, so a half-written backup never carries a backup's name.
+func (s *LocalStore) Put(ctx context.Context, name, file string) error {
+	if err := checkPutName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return atomicfile.WriteFrom(filepath.Join(s.Dir, name), src, 0o600)
+}
+
+func (s *LocalStore) Delete(ctx context.Context, name string) error {
+	if !IsBackupName(name) {
+		return fmt.Errorf("%q is not a backup name", name)
+	}
+	return os.Remove(filepath.Join(s.Dir, name))
+}
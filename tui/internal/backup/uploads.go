@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+)
+
+// UploadsFileName is the file in the data directory where upload records
+// the last result at each destination, for `backup list`.
+const UploadsFileName = "backup-uploads.json"
+
+// Upload is the result of storing a backup at one destination.
+type Upload struct {
+	Destination string        `json:"destination"`
+	Name        string        `json:"name"`
+	Size        int64         `json:"size"`
+	Time        time.Time     `json:"time"`
+	Duration    time.Duration `json:"duration"`
+	OK          bool          `json:"ok"`
+	Error       string        `json:"error,omitempty"`
+	// LastOK is when a backup last reached the destination, kept across
+	// failed uploads.
+	LastOK time.Time `json:"last_ok"`
+}
+
+// PutAll stores file as name at every store at once and returns one result
+// per store, in the order given. A store that fails does not stop the
+// others.
+func PutAll(ctx context.Context, stores []Store, name, file string) ([]Upload, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Upload, len(stores))
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store Store) {
+			defer wg.Done()
+			start := time.Now()
+			err := store.Put(ctx, name, file)
+			results[i] = Upload{
+				Destination: store.String(),
+				Name:        name,
+				Size:        info.Size(),
+				Time:        start.UTC().Truncate(time.Second),
+				Duration:    time.Since(start).Round(time.Millisecond),
+				OK:          err == nil,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].LastOK = results[i].Time
+			}
+		}(i, store)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Uploads maps destinations to their last upload.
+type Uploads map[string]Upload
+
+// ReadUploads reads the results at path. A missing file holds none.
+func ReadUploads(path string) (Uploads, error) {
+	u := Uploads{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return u, nil
+}
+
+// RecordUploads saves results at path, replacing the previous result for
+// each of their destinations.
+func RecordUploads(path string, results []Upload) error {
+	u, err := ReadUploads(path)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if !result.OK {
+			result.LastOK = u[result.Destination].LastOK
+		}
+		u[result.Destination] = result
+	}
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+)
+
+// VerificationsFileName is the file in the data directory where verify
+// records its results, for `backup list`.
+const VerificationsFileName = "backup-verifications.json"
+
+// Verification is the last result of verifying a backup. Copies of a
+// backup at other destinations share its name and size, and so its result.
+type Verification struct {
+	Name   string    `json:"name"`
+	Size   int64     `json:"size"`
+	Time   time.Time `json:"time"`
+	OK     bool      `json:"ok"`
+	Detail string    `json:"detail,omitempty"` // why it failed
+}
+
+// Verifications maps backup names to their last verification.
+type Verifications map[string]Verification
+
+// ReadVerifications reads the results at path. A missing file holds none.
+func ReadVerifications(path string) (Verifications, error) {
+	v := Verifications{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// Lookup returns the verification of obj, if it was verified as it is now.
+func (v Verifications) Lookup(obj Object) (Verification, bool) {
+	result, ok := v[obj.Name]
+	if !ok || result.Size != obj.Size {
+		return Verification{}, false
+	}
+	return result, true
+}
+
+// RecordVerification saves result at path, replacing the previous result
+// for the same name.
+func RecordVerification(path string, result Verification) error {
+	v, err := ReadVerifications(path)
+	if err != nil {
+		return err
+	}
+	v[result.Name] = result
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, append(data, '\n'), 0644)
+}
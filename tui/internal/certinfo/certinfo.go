@@ -0,0 +1,234 @@
+// Package certinfo reads X.509 certificates from files or live TLS endpoints
+// and reports the facts the auto-ssl workflows care about.
+package certinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info describes a single certificate.
+type Info struct {
+	Source            string    `json:"source"`
+	Subject           string    `json:"subject"`
+	CommonName        string    `json:"common_name"`
+	SANs              []string  `json:"sans"`
+	Issuer            string    `json:"issuer"`
+	Serial            string    `json:"serial"`
+	KeyType           string    `json:"key_type"`
+	IsCA              bool      `json:"is_ca"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	Lifetime          string    `json:"lifetime"`
+	Remaining         string    `json:"remaining"`
+	RemainingSeconds  int64     `json:"remaining_seconds"`
+	Expired           bool      `json:"expired"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+}
+
+// Describe summarizes cert relative to now.
+func Describe(cert *x509.Certificate, source string, now time.Time) Info {
+	remaining := cert.NotAfter.Sub(now)
+
+	return Info{
+		Source:            source,
+		Subject:           cert.Subject.String(),
+		CommonName:        cert.Subject.CommonName,
+		SANs:              SANs(cert),
+		Issuer:            cert.Issuer.String(),
+		Serial:            formatSerial(cert),
+		KeyType:           KeyType(cert.PublicKey),
+		IsCA:              cert.IsCA,
+		NotBefore:         cert.NotBefore.UTC(),
+		NotAfter:          cert.NotAfter.UTC(),
+		Lifetime:          HumanDuration(cert.NotAfter.Sub(cert.NotBefore)),
+		Remaining:         HumanDuration(remaining),
+		RemainingSeconds:  int64(remaining / time.Second),
+		Expired:           remaining <= 0,
+		FingerprintSHA256: Fingerprint(cert),
+	}
+}
+
+// Fingerprint returns the lowercase hex SHA-256 fingerprint of cert, in the
+// same form `step certificate fingerprint` prints.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// SANs lists every subject alternative name on cert.
+func SANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// KeyType names the algorithm and size of a public key.
+func KeyType(pub any) string {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// HumanDuration renders d as days/hours/minutes, e.g. "6d 23h" or "-2h 5m".
+func HumanDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%s%dd %dh", sign, days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%s%dh %dm", sign, hours, minutes)
+	default:
+		return fmt.Sprintf("%s%dm", sign, minutes)
+	}
+}
+
+func formatSerial(cert *x509.Certificate) string {
+	if cert.SerialNumber == nil {
+		return ""
+	}
+	return cert.SerialNumber.Text(16)
+}
+
+// Load resolves target as a certificate file, "-" for stdin, or a host:port
+// TLS endpoint, and returns the certificates it holds leaf first.
+func Load(target string, serverName string, timeout time.Duration) ([]*x509.Certificate, error) {
+	if target == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(data)
+	}
+	if _, err := os.Stat(target); err == nil {
+		return LoadFile(target)
+	}
+	if IsEndpoint(target) {
+		return FetchChain(target, serverName, timeout)
+	}
+	return nil, fmt.Errorf("%s: no such file and not a host:port endpoint", target)
+}
+
+// IsEndpoint reports whether target looks like host:port.
+func IsEndpoint(target string) bool {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+// LoadFile reads PEM or DER certificates from path.
+func LoadFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return certs, nil
+}
+
+// Parse decodes every CERTIFICATE block in data, falling back to raw DER.
+func Parse(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, errors.New("no certificate data")
+	}
+	der, err := x509.ParseCertificates(data)
+	if err != nil {
+		return nil, errors.New("no PEM certificate found and data is not DER")
+	}
+	return der, nil
+}
+
+// FetchChain connects to a TLS endpoint and returns the chain it presents.
+// The chain is not verified: inspection must work before trust is set up.
+func FetchChain(addr string, serverName string, timeout time.Duration) ([]*x509.Certificate, error) {
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			serverName = host
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // inspection only, nothing is trusted
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s presented no certificates", addr)
+	}
+	return certs, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultCertificate names the certificate described by the server: block
+// itself.
+const DefaultCertificate = "default"
+
+// Certificate is one certificate the server keeps renewed, with its own
+// schedule and reload hooks. See ServerConfig for the fields.
+type Certificate struct {
+	Name         string       `yaml:"name"`
+	CertPath     string       `yaml:"cert_path"`
+	KeyPath      string       `yaml:"key_path"`
+	SANs         string       `yaml:"sans"`
+	RenewAt      string       `yaml:"renew_at,omitempty"`
+	Reload       []ReloadHook `yaml:"reload,omitempty"`
+	VerifyPort   int          `yaml:"verify_port,omitempty"`
+	AlertCommand string       `yaml:"alert_command,omitempty"`
+	Enrollment   string       `yaml:"enrollment,omitempty"`
+	ACME         ACMEConfig   `yaml:"acme,omitempty"`
+}
+
+var certificateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Default returns the default certificate. Its paths fall back to
+// server.crt and server.key in CertDir().
+func (s ServerConfig) Default() Certificate {
+	cert := Certificate{
+		Name:         DefaultCertificate,
+		CertPath:     s.CertPath,
+		KeyPath:      s.KeyPath,
+		SANs:         s.SANs,
+		RenewAt:      s.RenewAt,
+		Reload:       s.Reload,
+		VerifyPort:   s.VerifyPort,
+		AlertCommand: s.AlertCommand,
+		Enrollment:   s.Enrollment,
+		ACME:         s.ACME,
+	}
+	return cert.withDefaults("server")
+}
+
+// CertificateList returns the certificates the server keeps renewed: the
+// default one once it has been enrolled (it has SANs), then the named ones.
+func (s ServerConfig) CertificateList() []Certificate {
+	var certs []Certificate
+	if s.SANs != "" {
+		certs = append(certs, s.Default())
+	}
+	for _, cert := range s.Certificates {
+		certs = append(certs, cert.withDefaults(cert.Name))
+	}
+	return certs
+}
+
+// FindCertificate returns the certificate called name; "" is the default.
+func (s ServerConfig) FindCertificate(name string) (Certificate, error) {
+	if name == "" || name == DefaultCertificate {
+		return s.Default(), nil
+	}
+	for _, cert := range s.Certificates {
+		if cert.Name == name {
+			return cert.withDefaults(cert.Name), nil
+		}
+	}
+	return Certificate{}, fmt.Errorf("unknown certificate: %s", name)
+}
+
+// withDefaults fills in NAME.crt and NAME.key in CertDir() for unset paths.
+func (c Certificate) withDefaults(base string) Certificate {
+	if c.CertPath == "" {
+		c.CertPath = filepath.Join(CertDir(), base+".crt")
+	}
+	if c.KeyPath == "" {
+		c.KeyPath = filepath.Join(CertDir(), base+".key")
+	}
+	return c
+}
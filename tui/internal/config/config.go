@@ -1,18 +1,25 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
 )
 
+// Default locations, used when no environment override is set (see paths.go).
 const (
-	DefaultConfigDir   = "/etc/auto-ssl"
-	DefaultConfigFile  = "config.yaml"
-	DefaultCertDir     = "/etc/ssl/auto-ssl"
-	DefaultStepCAPath  = "/opt/step-ca"
+	DefaultConfigDir  = "/etc/auto-ssl"
+	DefaultConfigFile = "config.yaml"
+	DefaultCertDir    = "/etc/ssl/auto-ssl"
+	DefaultDataDir    = "/var/lib/auto-ssl"
+	DefaultStepCAPath = "/opt/step-ca"
 )
 
 // Config represents the auto-ssl configuration
@@ -22,6 +29,11 @@ type Config struct {
 	Backup   BackupConfig   `yaml:"backup"`
 	Server   ServerConfig   `yaml:"server"`
 	
+	// Named CA contexts for operators who manage more than one CA. The
+	// top-level CA block is the implicit "default" context.
+	CurrentContext string    `yaml:"current_context,omitempty"`
+	Contexts       []Context `yaml:"contexts,omitempty"`
+
 	// Runtime fields (not saved)
 	path string `yaml:"-"`
 }
@@ -42,42 +54,98 @@ type DefaultsConfig struct {
 
 // BackupConfig holds backup configuration
 type BackupConfig struct {
-	Enabled       bool                `yaml:"enabled"`
-	Schedule      string              `yaml:"schedule"`
-	Retention     int                 `yaml:"retention"`
-	Passphrase    string              `yaml:"passphrase_file,omitempty"`
-	Destinations  []BackupDestination `yaml:"destinations"`
+	Enabled      bool                `yaml:"enabled"`
+	Schedule     string              `yaml:"schedule"`
+	OutputDir    string              `yaml:"output_dir,omitempty"`
+	Retention    int                 `yaml:"retention"` // keep the newest N backups
+	KeepDaily    int                 `yaml:"keep_daily,omitempty"`
+	KeepWeekly   int                 `yaml:"keep_weekly,omitempty"`
+	KeepMonthly  int                 `yaml:"keep_monthly,omitempty"`
+	Passphrase   string              `yaml:"passphrase_file,omitempty"`
+	Verify       bool                `yaml:"verify,omitempty"` // run verify after each backup
+	Destinations []BackupDestination `yaml:"destinations,omitempty"`
 }
 
 // BackupDestination represents a backup target
 type BackupDestination struct {
-	Type     string `yaml:"type"`              // local, rsync, s3
-	Path     string `yaml:"path,omitempty"`    // for local
-	Target   string `yaml:"target,omitempty"`  // for rsync
-	Bucket   string `yaml:"bucket,omitempty"`  // for s3
-	Endpoint string `yaml:"endpoint,omitempty"` // for s3 (Wasabi)
-	Prefix   string `yaml:"prefix,omitempty"`  // for s3
+	Type     string `yaml:"type"`               // local, rsync, s3
+	Path     string `yaml:"path,omitempty"`     // for local
+	Target   string `yaml:"target,omitempty"`   // for rsync
+	Bucket   string `yaml:"bucket,omitempty"`   // for s3
+	Endpoint string `yaml:"endpoint,omitempty"` // for s3 (Wasabi, MinIO, B2)
+	Region   string `yaml:"region,omitempty"`   // for s3, if the endpoint does not name it
+	Prefix   string `yaml:"prefix,omitempty"`   // for s3
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	CertPath string `yaml:"cert_path"`
-	KeyPath  string `yaml:"key_path"`
-	SANs     string `yaml:"sans"`
+	CertPath      string `yaml:"cert_path"`
+	KeyPath       string `yaml:"key_path"`
+	SANs          string `yaml:"sans"`
+	Suspended     bool   `yaml:"suspended,omitempty"`
+	SuspendReason string `yaml:"suspend_reason,omitempty"`
+	SuspendedAt   string `yaml:"suspended_at,omitempty"` // RFC 3339
+	ResumedAt     string `yaml:"resumed_at,omitempty"`   // RFC 3339
+
+	// RenewAt is the fraction of the certificate's lifetime after which the
+	// renewal agent renews it, e.g. "2/3" (the default) or "0.5".
+	RenewAt string `yaml:"renew_at,omitempty"`
+
+	// Reload runs after every renewal so the services using the
+	// certificate load it. The renewed certificate must then be served on
+	// VerifyPort (0 skips the check); AlertCommand reports when it is not.
+	Reload       []ReloadHook `yaml:"reload,omitempty"`
+	VerifyPort   int          `yaml:"verify_port,omitempty"`
+	AlertCommand string       `yaml:"alert_command,omitempty"`
+
+	// Enrollment is how the certificate is issued and renewed: "provisioner"
+	// (step ca certificate/renew) or "acme".
+	Enrollment string     `yaml:"enrollment,omitempty"`
+	ACME       ACMEConfig `yaml:"acme,omitempty"`
+
+	// Certificates are further certificates the server keeps renewed, e.g.
+	// one per service. The fields above are the implicit "default" one.
+	Certificates []Certificate `yaml:"certificates,omitempty"`
+}
+
+// ACMEConfig holds the settings `server enroll --acme` used, so renewals
+// can repeat them.
+type ACMEConfig struct {
+	Directory  string `yaml:"directory,omitempty"`
+	Challenge  string `yaml:"challenge,omitempty"` // http-01 or tls-alpn-01
+	Webroot    string `yaml:"webroot,omitempty"`   // http-01 through an existing web server
+	Port       int    `yaml:"port,omitempty"`      // standalone listener port
+	Email      string `yaml:"email,omitempty"`
+	Root       string `yaml:"root,omitempty"`        // CA certificates trusted for the directory
+	AccountKey string `yaml:"account_key,omitempty"` // default: acme-account.key in the config dir
+}
+
+// ReloadHook is one step of server.reload: a systemd service to reload, or
+// a shell command.
+type ReloadHook struct {
+	Service string `yaml:"service,omitempty"`
+	Command string `yaml:"command,omitempty"`
 }
 
 // Server represents an enrolled server in the inventory
 type Server struct {
-	Host            string    `yaml:"host"`
-	Name            string    `yaml:"name"`
-	User            string    `yaml:"user"`
-	Enrolled        bool      `yaml:"enrolled"`
-	Suspended       bool      `yaml:"suspended"`
-	SuspendedAt     time.Time `yaml:"suspended_at,omitempty"`
-	SuspendedReason string    `yaml:"suspended_reason,omitempty"`
-	EnrolledAt      time.Time `yaml:"enrolled_at,omitempty"`
-	LastSeen        time.Time `yaml:"last_seen,omitempty"`
-	CertExpires     time.Time `yaml:"cert_expires,omitempty"`
+	Host            string            `yaml:"host"`
+	Name            string            `yaml:"name"`
+	User            string            `yaml:"user"`
+	Port            int               `yaml:"port,omitempty"`
+	Identity        string            `yaml:"identity,omitempty"`   // SSH private key
+	ProxyJump       string            `yaml:"proxy_jump,omitempty"` // ssh -J syntax
+	Privilege       string            `yaml:"privilege,omitempty"`  // sudo (default) or root
+	HostKey         string            `yaml:"host_key,omitempty"`   // SSH host key fingerprint pinned at enrollment
+	Group           string            `yaml:"group,omitempty"`
+	Labels          map[string]string `yaml:"labels,omitempty"`
+	Enrolled        bool              `yaml:"enrolled"`
+	Suspended       bool              `yaml:"suspended"`
+	SuspendedAt     time.Time         `yaml:"suspended_at,omitempty"`
+	SuspendedReason string            `yaml:"suspended_reason,omitempty"`
+	EnrolledAt      time.Time         `yaml:"enrolled_at,omitempty"`
+	LastSeen        time.Time         `yaml:"last_seen,omitempty"`
+	CertExpires     time.Time         `yaml:"cert_expires,omitempty"`
 }
 
 // Inventory holds the list of enrolled servers
@@ -86,34 +154,60 @@ type Inventory struct {
 	path    string   `yaml:"-"`
 }
 
-// Load reads the configuration file or returns defaults
-func Load() *Config {
+// Load reads the configuration file at ConfigFile(). A missing file is not an error and
+// yields defaults; any other failure is returned as a *LoadError alongside
+// the defaults so callers can decide whether to carry on.
+func Load() (*Config, error) {
+	return LoadFile(ConfigFile())
+}
+
+// LoadFile reads the configuration from path. See Load.
+func LoadFile(path string) (*Config, error) {
+	return loadConfig(path, false)
+}
+
+// LoadFileStrict is LoadFile but also rejects keys the schema does not know,
+// which catches typos such as "fingerprnt" that would otherwise be ignored.
+func LoadFileStrict(path string) (*Config, error) {
+	return loadConfig(path, true)
+}
+
+func loadConfig(path string, strict bool) (*Config, error) {
 	cfg := &Config{
 		Defaults: DefaultsConfig{
 			CertDuration:    "168h",  // 7 days
 			MaxCertDuration: "720h",  // 30 days
 		},
 		CA: CAConfig{
-			StepPath: DefaultStepCAPath,
+			StepPath: StepCAPath(),
 		},
 		Server: ServerConfig{
-			CertPath: filepath.Join(DefaultCertDir, "server.crt"),
-			KeyPath:  filepath.Join(DefaultCertDir, "server.key"),
+			CertPath: filepath.Join(CertDir(), "server.crt"),
+			KeyPath:  filepath.Join(CertDir(), "server.key"),
 		},
-		path: filepath.Join(DefaultConfigDir, DefaultConfigFile),
+		path: path,
 	}
 	
-	// Try to load existing config
 	data, err := os.ReadFile(cfg.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
 	if err != nil {
-		return cfg
+		return cfg, &LoadError{File: path, Err: err}
 	}
 	
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return cfg
+	// Decode into a scratch copy so a file that fails halfway does not leave
+	// the caller with a mix of file values and defaults.
+	loaded := *cfg
+	if err := decodeYAML(data, path, &loaded, strict); err != nil {
+		return cfg, err
 	}
-	
-	return cfg
+	return &loaded, nil
+}
+
+// Path returns the file the configuration was loaded from
+func (c *Config) Path() string {
+	return c.path
 }
 
 // Save writes the configuration to disk
@@ -124,42 +218,79 @@ func (c *Config) Save() error {
 		return err
 	}
 	
-	data, err := yaml.Marshal(c)
+	data, err := c.Marshal()
 	if err != nil {
 		return err
 	}
 	
-	return os.WriteFile(c.path, data, 0600)
+	return atomicfile.Write(c.path, data, 0600)
+}
+
+// Marshal renders the configuration as YAML with the two-space indent the
+// Bash runtime writes.
+func (c *Config) Marshal() ([]byte, error) {
+	return marshalYAML(c)
+}
+
+func marshalYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadInventory reads the server inventory. Like Load, a missing file is an
+// empty inventory and other failures are returned as a *LoadError.
+func LoadInventory() (*Inventory, error) {
+	return LoadInventoryFile(InventoryFile())
 }
 
-// LoadInventory reads the server inventory
-func LoadInventory() *Inventory {
+// LoadInventoryFile reads the server inventory from path.
+func LoadInventoryFile(path string) (*Inventory, error) {
 	inv := &Inventory{
-		path: filepath.Join(DefaultConfigDir, "servers.yaml"),
+		path: path,
 	}
 	
 	data, err := os.ReadFile(inv.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return inv, nil
+	}
 	if err != nil {
-		return inv
+		return inv, &LoadError{File: path, Err: err}
 	}
 	
-	yaml.Unmarshal(data, inv)
-	return inv
+	loaded := &Inventory{path: path}
+	if err := decodeYAML(data, path, loaded, false); err != nil {
+		return inv, err
+	}
+	return loaded, nil
+}
+
+// Path returns the file the inventory was loaded from
+func (i *Inventory) Path() string {
+	return i.path
 }
 
-// Save writes the inventory to disk
+// Save writes the inventory to disk atomically. Use UpdateInventory for
+// read-modify-write changes so concurrent writers do not lose updates.
 func (i *Inventory) Save() error {
 	dir := filepath.Dir(i.path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 	
-	data, err := yaml.Marshal(i)
+	data, err := marshalYAML(i)
 	if err != nil {
 		return err
 	}
 	
-	return os.WriteFile(i.path, data, 0600)
+	return atomicfile.Write(i.path, data, 0600)
 }
 
 // AddServer adds or updates a server in the inventory
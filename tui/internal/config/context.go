@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// DefaultContext names the top-level ca: block.
+const DefaultContext = "default"
+
+// EnvContext selects a context for one invocation, overriding
+// current_context. The --context option sets it.
+const EnvContext = "AUTO_SSL_CONTEXT"
+
+// Context is a named CA an operator can switch between.
+type Context struct {
+	Name string   `yaml:"name"`
+	CA   CAConfig `yaml:"ca"`
+}
+
+var contextNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// FindContext returns the context called name, or nil.
+func (c *Config) FindContext(name string) *Context {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i]
+		}
+	}
+	return nil
+}
+
+// AddContext adds ctx, replacing an existing context of the same name only
+// when replace is set.
+func (c *Config) AddContext(ctx Context, replace bool) error {
+	if ctx.Name == DefaultContext {
+		return fmt.Errorf("%q is reserved for the top-level ca block", DefaultContext)
+	}
+	if !contextNamePattern.MatchString(ctx.Name) {
+		return fmt.Errorf("invalid context name %q (letters, digits, '.', '_' and '-')", ctx.Name)
+	}
+	if existing := c.FindContext(ctx.Name); existing != nil {
+		if !replace {
+			return fmt.Errorf("context %s already exists", ctx.Name)
+		}
+		*existing = ctx
+		return nil
+	}
+	c.Contexts = append(c.Contexts, ctx)
+	return nil
+}
+
+// RemoveContext deletes the named context. Removing the current context
+// switches back to the default one.
+func (c *Config) RemoveContext(name string) error {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.CurrentContext == name {
+				c.CurrentContext = ""
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown context: %s", name)
+}
+
+// UseContext makes name the current context.
+func (c *Config) UseContext(name string) error {
+	if name == DefaultContext {
+		c.CurrentContext = ""
+		return nil
+	}
+	if c.FindContext(name) == nil {
+		return fmt.Errorf("unknown context: %s", name)
+	}
+	c.CurrentContext = name
+	return nil
+}
+
+// ActiveContext resolves the context in effect: $AUTO_SSL_CONTEXT, then
+// current_context, then the default context.
+func (c *Config) ActiveContext() (string, CAConfig, error) {
+	name := os.Getenv(EnvContext)
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" || name == DefaultContext {
+		return DefaultContext, c.CA, nil
+	}
+	ctx := c.FindContext(name)
+	if ctx == nil {
+		return "", CAConfig{}, fmt.Errorf("unknown context: %s", name)
+	}
+	return ctx.Name, ctx.CA, nil
+}
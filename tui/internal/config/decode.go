@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadError reports why a config or inventory file could not be loaded.
+// Line, Column and Key are zero when the failure has no position, such as
+// a permission error.
+type LoadError struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	b.WriteString(": ")
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	syntaxLinePattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+)
+
+// decodeYAML decodes data into out field by field so that a failure can be
+// pinned to the key and position that caused it, which yaml.Unmarshal does
+// not report. In strict mode unknown keys are errors.
+func decodeYAML(data []byte, file string, out any, strict bool) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		loadErr := &LoadError{File: file, Err: err}
+		if m := syntaxLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			loadErr.Line, _ = strconv.Atoi(m[1])
+			loadErr.Err = errors.New(m[2])
+		}
+		return loadErr
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return decodeNode(doc.Content[0], reflect.ValueOf(out).Elem(), "", file, strict)
+}
+
+func decodeNode(node *yaml.Node, v reflect.Value, key, file string, strict bool) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	fail := func(format string, args ...any) error {
+		return &LoadError{File: file, Line: node.Line, Column: node.Column, Key: key, Err: fmt.Errorf(format, args...)}
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		if node.Kind != yaml.MappingNode {
+			return fail("expected a section of keys, got %s", describeNode(node))
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			path := joinKey(key, keyNode.Value)
+			field, ok := fieldByYAML(v, keyNode.Value)
+			if !ok {
+				if strict {
+					return &LoadError{File: file, Line: keyNode.Line, Column: keyNode.Column, Key: path, Err: errors.New("unknown key")}
+				}
+				continue
+			}
+			if err := decodeNode(valueNode, field, path, file, strict); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		if node.Kind != yaml.SequenceNode {
+			return fail("expected a list, got %s", describeNode(node))
+		}
+		items := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			if err := decodeNode(item, items.Index(i), joinKey(key, strconv.Itoa(i)), file, strict); err != nil {
+				return err
+			}
+		}
+		v.Set(items)
+		return nil
+	}
+
+	if err := node.Decode(v.Addr().Interface()); err != nil {
+		if node.Kind == yaml.ScalarNode {
+			return fail("cannot use %q as %s", node.Value, describeType(v.Type()))
+		}
+		return fail("expected %s, got %s", describeType(v.Type()), describeNode(node))
+	}
+	return nil
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a section of keys"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func describeType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "a timestamp (RFC 3339)"
+	case t.Kind() == reflect.Bool:
+		return "true or false"
+	case t.Kind() == reflect.Int:
+		return "a whole number"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() == reflect.Slice:
+		return "a list"
+	case t.Kind() == reflect.Map:
+		return "a section of key: value pairs"
+	default:
+		return t.String()
+	}
+}
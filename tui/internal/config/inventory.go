@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultSSHPort is used for servers without a recorded port.
+const DefaultSSHPort = 22
+
+// Privilege modes for running commands on an enrolled server.
+const (
+	PrivilegeSudo = "sudo" // log in as User and escalate with sudo
+	PrivilegeRoot = "root" // already root; commands run without sudo
+)
+
+// SSHPort returns the server's SSH port, or DefaultSSHPort when unset.
+func (s Server) SSHPort() int {
+	if s.Port == 0 {
+		return DefaultSSHPort
+	}
+	return s.Port
+}
+
+// PrivilegeMode returns the server's privilege mode, defaulting to sudo.
+func (s Server) PrivilegeMode() string {
+	if s.Privilege == "" {
+		return PrivilegeSudo
+	}
+	return s.Privilege
+}
+
+// ValidatePrivilege checks a privilege mode value.
+func ValidatePrivilege(mode string) error {
+	switch mode {
+	case "", PrivilegeSudo, PrivilegeRoot:
+		return nil
+	default:
+		return fmt.Errorf("must be %s or %s, got %q", PrivilegeSudo, PrivilegeRoot, mode)
+	}
+}
+
+// hostKeyPattern matches an SSH SHA256 fingerprint as ssh-keygen -l prints it.
+var hostKeyPattern = regexp.MustCompile(`^SHA256:[A-Za-z0-9+/]{43}$`)
+
+// ValidateHostKey checks a pinned host key fingerprint.
+func ValidateHostKey(fingerprint string) error {
+	if fingerprint == "" || hostKeyPattern.MatchString(fingerprint) {
+		return nil
+	}
+	return fmt.Errorf("must be a SHA256 fingerprint (SHA256: and 43 base64 characters), got %q", fingerprint)
+}
+
+// Validate checks every inventory entry and returns the violations found.
+func (i *Inventory) Validate() []FieldError {
+	var errs []FieldError
+	add := func(key, format string, args ...any) {
+		errs = append(errs, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	seen := map[string]bool{}
+	for idx, server := range i.Servers {
+		key := fmt.Sprintf("servers.%d", idx)
+		switch {
+		case server.Host == "":
+			add(key+".host", "is required")
+		case seen[server.Host]:
+			add(key+".host", "duplicate host %q", server.Host)
+		}
+		seen[server.Host] = true
+
+		if server.Port < 0 || server.Port > 65535 {
+			add(key+".port", "must be between 1 and 65535")
+		}
+		if err := ValidatePrivilege(server.Privilege); err != nil {
+			add(key+".privilege", "%v", err)
+		}
+		if err := ValidateHostKey(server.HostKey); err != nil {
+			add(key+".host_key", "%v", err)
+		}
+		for label, value := range server.Labels {
+			if !labelKeyPattern.MatchString(label) {
+				add(key+".labels."+label, "invalid label key")
+			}
+			if strings.Contains(value, ",") {
+				add(key+".labels."+label, "values may not contain commas")
+			}
+		}
+	}
+	return errs
+}
+
+// UpdateInventory runs change against the inventory at path while holding an
+// exclusive lock, then saves the result. Concurrent `remote enroll` runs
+// serialize here instead of overwriting each other's entries.
+func UpdateInventory(path string, change func(*Inventory) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	unlock, err := LockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	inv, err := LoadInventoryFile(path)
+	if err != nil {
+		return err
+	}
+	if err := change(inv); err != nil {
+		return err
+	}
+	return inv.Save()
+}
@@ -0,0 +1,875 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Inventory exchange formats for `tools inventory import|export`.
+const (
+	FormatCSV         = "csv"
+	FormatJSON        = "json"
+	FormatAnsibleINI  = "ansible-ini"
+	FormatAnsibleYAML = "ansible-yaml"
+)
+
+// InventoryFormats lists the exchange formats in the order help text shows them.
+var InventoryFormats = []string{FormatCSV, FormatJSON, FormatAnsibleINI, FormatAnsibleYAML}
+
+// csvColumns is the column order export writes. Import accepts them in any
+// order; only host is required.
+var csvColumns = []string{"host", "name", "user", "port", "identity", "proxy_jump", "privilege", "group", "labels"}
+
+// DetectInventoryFormat guesses the exchange format from a file name.
+func DetectInventoryFormat(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, true
+	case ".json":
+		return FormatJSON, true
+	case ".ini", ".cfg":
+		return FormatAnsibleINI, true
+	case ".yml", ".yaml":
+		return FormatAnsibleYAML, true
+	}
+	if filepath.Base(path) == "hosts" {
+		return FormatAnsibleINI, true
+	}
+	return "", false
+}
+
+// ParseServers reads servers from data in the given exchange format. Values
+// that cannot be represented in the inventory, such as Ansible variables that
+// are not valid labels, are skipped and reported in warnings.
+func ParseServers(format string, data []byte) (servers []Server, warnings []string, err error) {
+	switch format {
+	case FormatCSV:
+		servers, err = parseCSV(data)
+	case FormatJSON:
+		servers, err = parseJSON(data)
+	case FormatAnsibleINI:
+		var hosts []ansibleHost
+		hosts, err = parseAnsibleINI(data)
+		if err == nil {
+			servers, warnings = serversFromAnsible(hosts)
+		}
+	case FormatAnsibleYAML:
+		var hosts []ansibleHost
+		hosts, err = parseAnsibleYAML(data)
+		if err == nil {
+			servers, warnings = serversFromAnsible(hosts)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown format: %s (%s)", format, strings.Join(InventoryFormats, ", "))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	check := Inventory{Servers: servers}
+	if errs := check.Validate(); len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+	return servers, warnings, nil
+}
+
+// FormatServers renders servers in the given exchange format.
+func FormatServers(format string, servers []Server) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return formatCSV(servers)
+	case FormatJSON:
+		return formatJSON(servers)
+	case FormatAnsibleINI:
+		return formatAnsibleINI(servers), nil
+	case FormatAnsibleYAML:
+		return formatAnsibleYAML(servers)
+	default:
+		return nil, fmt.Errorf("unknown format: %s (%s)", format, strings.Join(InventoryFormats, ", "))
+	}
+}
+
+//--------------------------------------------------
+// Merge
+//--------------------------------------------------
+
+// Change actions reported by MergeServers.
+const (
+	ChangeAdd       = "add"
+	ChangeUpdate    = "update"
+	ChangeUnchanged = "unchanged"
+)
+
+// ServerChange describes what merging one imported server did.
+type ServerChange struct {
+	Host   string        `json:"host"`
+	Action string        `json:"action"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is one field an import changed. Old or New is empty when the
+// field was unset.
+type FieldChange struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// MergeServers merges imported servers into the inventory by host. Fields
+// the import sets overwrite the inventory's, labels are merged, and
+// enrollment state is left alone. New servers are added unenrolled.
+func (i *Inventory) MergeServers(servers []Server) []ServerChange {
+	changes := make([]ServerChange, 0, len(servers))
+	for _, imported := range servers {
+		existing := i.GetServer(imported.Host)
+		if existing == nil {
+			server := imported
+			server.Enrolled = false
+			if server.Name == "" {
+				server.Name = server.Host
+			}
+			i.AddServer(server)
+			changes = append(changes, ServerChange{
+				Host:   server.Host,
+				Action: ChangeAdd,
+				Fields: diffFields(Server{}, server),
+			})
+			continue
+		}
+
+		before := *existing
+		mergeServer(existing, imported)
+		change := ServerChange{Host: imported.Host, Action: ChangeUnchanged}
+		if fields := diffFields(before, *existing); len(fields) > 0 {
+			change.Action = ChangeUpdate
+			change.Fields = fields
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func mergeServer(dst *Server, src Server) {
+	setString := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	setString(&dst.Name, src.Name)
+	setString(&dst.User, src.User)
+	setString(&dst.Identity, src.Identity)
+	setString(&dst.ProxyJump, src.ProxyJump)
+	setString(&dst.Privilege, src.Privilege)
+	setString(&dst.Group, src.Group)
+	if src.Port != 0 {
+		dst.Port = src.Port
+	}
+	if len(src.Labels) > 0 {
+		labels := make(map[string]string, len(dst.Labels)+len(src.Labels))
+		for key, value := range dst.Labels {
+			labels[key] = value
+		}
+		for key, value := range src.Labels {
+			labels[key] = value
+		}
+		dst.Labels = labels
+	}
+}
+
+// exchangeFields flattens the fields the exchange formats carry.
+func exchangeFields(s Server) map[string]string {
+	fields := map[string]string{
+		"name":       s.Name,
+		"user":       s.User,
+		"identity":   s.Identity,
+		"proxy_jump": s.ProxyJump,
+		"privilege":  s.Privilege,
+		"group":      s.Group,
+	}
+	if s.Port != 0 {
+		fields["port"] = strconv.Itoa(s.Port)
+	}
+	for key, value := range s.Labels {
+		fields["labels."+key] = value
+	}
+	return fields
+}
+
+func diffFields(before, after Server) []FieldChange {
+	old, updated := exchangeFields(before), exchangeFields(after)
+	keys := map[string]bool{}
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range updated {
+		keys[key] = true
+	}
+
+	var changes []FieldChange
+	for key := range keys {
+		if old[key] != updated[key] {
+			changes = append(changes, FieldChange{Key: key, Old: old[key], New: updated[key]})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Key < changes[b].Key })
+	return changes
+}
+
+//--------------------------------------------------
+// CSV and JSON
+//--------------------------------------------------
+
+func parseCSV(data []byte) ([]Server, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+	columns := map[string]int{}
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, column := range csvColumns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, fmt.Errorf("csv: unknown column %q (expected %s)", name, strings.Join(csvColumns, ", "))
+		}
+		columns[name] = idx
+	}
+	if _, ok := columns["host"]; !ok {
+		return nil, fmt.Errorf("csv: a host column is required")
+	}
+
+	var servers []Server
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		get := func(column string) string {
+			idx, ok := columns[column]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		server := Server{
+			Host:      get("host"),
+			Name:      get("name"),
+			User:      get("user"),
+			Identity:  get("identity"),
+			ProxyJump: get("proxy_jump"),
+			Privilege: get("privilege"),
+			Group:     get("group"),
+		}
+		if server.Host == "" {
+			return nil, fmt.Errorf("csv: line %d: host is required", line)
+		}
+		if port := get("port"); port != "" {
+			if server.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("csv: line %d: invalid port %q", line, port)
+			}
+		}
+		if labels := get("labels"); labels != "" {
+			if server.Labels, err = ParseLabels(strings.Split(labels, ",")); err != nil {
+				return nil, fmt.Errorf("csv: line %d: %w", line, err)
+			}
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+func formatCSV(servers []Server) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	for _, s := range servers {
+		port := ""
+		if s.Port != 0 {
+			port = strconv.Itoa(s.Port)
+		}
+		record := []string{s.Host, s.Name, s.User, port, s.Identity, s.ProxyJump, s.Privilege, s.Group, FormatLabels(s.Labels)}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// parseJSON accepts either a list of servers, as `inventory list --json`
+// prints, or an object with a "servers" list, as servers.yaml holds.
+func parseJSON(data []byte) ([]Server, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if trimmed[0] == '[' {
+		var servers []Server
+		if err := decodeYAML(trimmed, "json", &servers, true); err != nil {
+			return nil, err
+		}
+		return servers, nil
+	}
+	var inv Inventory
+	if err := decodeYAML(trimmed, "json", &inv, true); err != nil {
+		return nil, err
+	}
+	return inv.Servers, nil
+}
+
+func formatJSON(servers []Server) ([]byte, error) {
+	if servers == nil {
+		servers = []Server{}
+	}
+	// Round-trip through YAML so keys and omitted fields match servers.yaml.
+	data, err := yaml.Marshal(servers)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+//--------------------------------------------------
+// Ansible
+//--------------------------------------------------
+
+// ansibleHost is one host of an Ansible inventory with its variables
+// resolved: group variables from the outermost group in, then host
+// variables. group is the first group that lists the host directly.
+type ansibleHost struct {
+	name  string
+	group string
+	vars  map[string]string
+}
+
+// ansibleInventory collects hosts and groups in file order before variables
+// are resolved.
+type ansibleInventory struct {
+	hosts     []string
+	hostVars  map[string]map[string]string
+	hostGroup map[string]string
+	memberOf  map[string][]string // host or group -> groups that contain it
+	groupVars map[string]map[string]string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	return &ansibleInventory{
+		hostVars:  map[string]map[string]string{},
+		hostGroup: map[string]string{},
+		memberOf:  map[string][]string{},
+		groupVars: map[string]map[string]string{},
+	}
+}
+
+func (a *ansibleInventory) addHost(name, group string, vars map[string]string) {
+	if _, ok := a.hostVars[name]; !ok {
+		a.hosts = append(a.hosts, name)
+		a.hostVars[name] = map[string]string{}
+	}
+	for key, value := range vars {
+		a.hostVars[name][key] = value
+	}
+	if group != "" && group != "all" && group != "ungrouped" {
+		if a.hostGroup[name] == "" {
+			a.hostGroup[name] = group
+		}
+		a.memberOf["host:"+name] = append(a.memberOf["host:"+name], group)
+	}
+}
+
+func (a *ansibleInventory) addChild(parent, child string) {
+	a.memberOf["group:"+child] = append(a.memberOf["group:"+child], parent)
+}
+
+func (a *ansibleInventory) setGroupVars(group string, vars map[string]string) {
+	if a.groupVars[group] == nil {
+		a.groupVars[group] = map[string]string{}
+	}
+	for key, value := range vars {
+		a.groupVars[group][key] = value
+	}
+}
+
+// resolve applies "all" variables, then each ancestor group's variables from
+// the outermost in, then the host's own.
+func (a *ansibleInventory) resolve() []ansibleHost {
+	hosts := make([]ansibleHost, 0, len(a.hosts))
+	for _, name := range a.hosts {
+		vars := map[string]string{}
+		for key, value := range a.groupVars["all"] {
+			vars[key] = value
+		}
+		for _, group := range a.ancestors("host:" + name) {
+			for key, value := range a.groupVars[group] {
+				vars[key] = value
+			}
+		}
+		for key, value := range a.hostVars[name] {
+			vars[key] = value
+		}
+		hosts = append(hosts, ansibleHost{name: name, group: a.hostGroup[name], vars: vars})
+	}
+	return hosts
+}
+
+// ancestors returns the groups containing node, outermost first.
+func (a *ansibleInventory) ancestors(node string) []string {
+	var order []string
+	seen := map[string]bool{}
+	var visit func(node string)
+	visit = func(node string) {
+		for _, group := range a.memberOf[node] {
+			if seen[group] {
+				continue
+			}
+			seen[group] = true
+			visit("group:" + group)
+			order = append(order, group)
+		}
+	}
+	visit(node)
+	return order
+}
+
+var (
+	ansibleSectionPattern = regexp.MustCompile(`^\[([^\]:]+)(?::(vars|children))?\]$`)
+	ansibleRangePattern   = regexp.MustCompile(`\[(\d+):(\d+)\]`)
+)
+
+func parseAnsibleINI(data []byte) ([]ansibleHost, error) {
+	inv := newAnsibleInventory()
+	group, kind := "ungrouped", ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if m := ansibleSectionPattern.FindStringSubmatch(line); m != nil {
+			group, kind = m[1], m[2]
+			continue
+		}
+
+		fields, err := splitAnsibleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ansible-ini: line %d: %w", lineNo, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("ansible-ini: line %d: expected key=value in [%s:vars]", lineNo, group)
+			}
+			inv.setGroupVars(group, map[string]string{strings.TrimSpace(key): unquoteAnsible(strings.TrimSpace(value))})
+		case "children":
+			inv.addChild(group, fields[0])
+		default:
+			vars := map[string]string{}
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return nil, fmt.Errorf("ansible-ini: line %d: expected key=value, got %q", lineNo, field)
+				}
+				vars[key] = value
+			}
+			names, err := expandAnsibleRange(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("ansible-ini: line %d: %w", lineNo, err)
+			}
+			for _, name := range names {
+				inv.addHost(name, group, vars)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return inv.resolve(), nil
+}
+
+// splitAnsibleLine splits a host line on whitespace, keeping quoted values
+// together and stripping the quotes.
+func splitAnsibleLine(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	quote := rune(0)
+	inField := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		case r == '#' && !inField:
+			return fields, nil
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+func unquoteAnsible(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// expandAnsibleRange expands numeric host patterns such as web[01:10].
+func expandAnsibleRange(pattern string) ([]string, error) {
+	m := ansibleRangePattern.FindStringSubmatchIndex(pattern)
+	if m == nil {
+		if strings.ContainsAny(pattern, "[]") {
+			return nil, fmt.Errorf("unsupported host pattern %q (only numeric ranges like web[01:10] are supported)", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	startText, endText := pattern[m[2]:m[3]], pattern[m[4]:m[5]]
+	start, _ := strconv.Atoi(startText)
+	end, _ := strconv.Atoi(endText)
+	if end < start {
+		return nil, fmt.Errorf("invalid host range %q", pattern)
+	}
+	width := 0
+	if len(startText) > 1 && startText[0] == '0' {
+		width = len(startText)
+	}
+
+	var names []string
+	for n := start; n <= end; n++ {
+		rest, err := expandAnsibleRange(pattern[m[1]:])
+		if err != nil {
+			return nil, err
+		}
+		for _, suffix := range rest {
+			names = append(names, fmt.Sprintf("%s%0*d%s", pattern[:m[0]], width, n, suffix))
+		}
+	}
+	return names, nil
+}
+
+// ansibleGroup is one group of a YAML inventory. Nodes keep file order.
+type ansibleGroup struct {
+	Hosts    yaml.Node `yaml:"hosts"`
+	Vars     yaml.Node `yaml:"vars"`
+	Children yaml.Node `yaml:"children"`
+}
+
+func parseAnsibleYAML(data []byte) ([]ansibleHost, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("ansible-yaml: %w", err)
+	}
+	inv := newAnsibleInventory()
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	if err := walkAnsibleGroups(inv, "", root.Content[0]); err != nil {
+		return nil, err
+	}
+	return inv.resolve(), nil
+}
+
+// walkAnsibleGroups reads a mapping of group name to group body.
+func walkAnsibleGroups(inv *ansibleInventory, parent string, groups *yaml.Node) error {
+	if groups.Kind == 0 || (groups.Kind == yaml.ScalarNode && groups.Tag == "!!null") {
+		return nil
+	}
+	if groups.Kind != yaml.MappingNode {
+		return fmt.Errorf("ansible-yaml: line %d: expected a mapping of groups", groups.Line)
+	}
+	for i := 0; i+1 < len(groups.Content); i += 2 {
+		name := groups.Content[i].Value
+		if parent != "" {
+			inv.addChild(parent, name)
+		}
+
+		var group ansibleGroup
+		if err := groups.Content[i+1].Decode(&group); err != nil {
+			return fmt.Errorf("ansible-yaml: group %s: %w", name, err)
+		}
+		vars, err := ansibleVars(&group.Vars)
+		if err != nil {
+			return fmt.Errorf("ansible-yaml: group %s: %w", name, err)
+		}
+		inv.setGroupVars(name, vars)
+
+		if group.Hosts.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(group.Hosts.Content); j += 2 {
+				hostVars, err := ansibleVars(group.Hosts.Content[j+1])
+				if err != nil {
+					return fmt.Errorf("ansible-yaml: host %s: %w", group.Hosts.Content[j].Value, err)
+				}
+				names, err := expandAnsibleRange(group.Hosts.Content[j].Value)
+				if err != nil {
+					return fmt.Errorf("ansible-yaml: line %d: %w", group.Hosts.Content[j].Line, err)
+				}
+				for _, host := range names {
+					inv.addHost(host, name, hostVars)
+				}
+			}
+		}
+		if err := walkAnsibleGroups(inv, name, &group.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ansibleVars flattens a variables mapping. Non-scalar values are kept as
+// their YAML text so they are reported, not silently lost.
+func ansibleVars(node *yaml.Node) (map[string]string, error) {
+	vars := map[string]string{}
+	if node.Kind == 0 || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return vars, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of variables", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		if value.Kind == yaml.ScalarNode {
+			vars[node.Content[i].Value] = value.Value
+			continue
+		}
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		vars[node.Content[i].Value] = strings.TrimSpace(string(data))
+	}
+	return vars, nil
+}
+
+var proxyJumpPattern = regexp.MustCompile(`(?:-J\s*|ProxyJump=)(\S+)`)
+
+// serversFromAnsible maps Ansible connection variables onto inventory fields.
+// Other variables become labels when they are valid labels.
+func serversFromAnsible(hosts []ansibleHost) ([]Server, []string) {
+	var servers []Server
+	var warnings []string
+	for _, host := range hosts {
+		server := Server{Host: host.name, Name: host.name, Group: host.group}
+		become := ""
+		for key, value := range host.vars {
+			switch key {
+			case "ansible_host", "ansible_ssh_host":
+				server.Host = value
+			case "ansible_user", "ansible_ssh_user":
+				server.User = value
+			case "ansible_port", "ansible_ssh_port":
+				port, err := strconv.Atoi(value)
+				if err != nil {
+					warnings = append(warnings, fmt.Sprintf("%s: ignoring invalid %s %q", host.name, key, value))
+					continue
+				}
+				server.Port = port
+			case "ansible_ssh_private_key_file":
+				server.Identity = value
+			case "ansible_ssh_common_args", "ansible_ssh_extra_args":
+				if m := proxyJumpPattern.FindStringSubmatch(value); m != nil {
+					server.ProxyJump = strings.Trim(m[1], `'"`)
+				}
+			case "ansible_become":
+				become = strings.ToLower(value)
+			default:
+				if strings.HasPrefix(key, "ansible_") {
+					continue
+				}
+				if !labelKeyPattern.MatchString(key) || strings.ContainsAny(value, ",\n") {
+					warnings = append(warnings, fmt.Sprintf("%s: variable %s is not a valid label, skipped", host.name, key))
+					continue
+				}
+				if server.Labels == nil {
+					server.Labels = map[string]string{}
+				}
+				server.Labels[key] = value
+			}
+		}
+		if server.User == "root" && become != "true" && become != "yes" {
+			server.Privilege = PrivilegeRoot
+		}
+		servers = append(servers, server)
+	}
+	sort.Strings(warnings)
+	return servers, warnings
+}
+
+// ansibleVarsFor renders a server as Ansible host variables.
+func ansibleVarsFor(s Server) (string, [][2]string) {
+	name := s.Name
+	if name == "" || strings.ContainsAny(name, " \t") {
+		name = s.Host
+	}
+	var vars [][2]string
+	if name != s.Host {
+		vars = append(vars, [2]string{"ansible_host", s.Host})
+	}
+	if s.User != "" {
+		vars = append(vars, [2]string{"ansible_user", s.User})
+	}
+	if s.Port != 0 {
+		vars = append(vars, [2]string{"ansible_port", strconv.Itoa(s.Port)})
+	}
+	if s.Identity != "" {
+		vars = append(vars, [2]string{"ansible_ssh_private_key_file", s.Identity})
+	}
+	if s.ProxyJump != "" {
+		vars = append(vars, [2]string{"ansible_ssh_common_args", "-o ProxyJump=" + s.ProxyJump})
+	}
+	if s.PrivilegeMode() == PrivilegeSudo && s.User != "root" {
+		vars = append(vars, [2]string{"ansible_become", "true"})
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for key := range s.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		vars = append(vars, [2]string{key, s.Labels[key]})
+	}
+	return name, vars
+}
+
+// groupServers buckets servers by group in first-seen order; servers without
+// a group go to Ansible's "ungrouped".
+func groupServers(servers []Server) ([]string, map[string][]Server) {
+	var order []string
+	groups := map[string][]Server{}
+	for _, s := range servers {
+		group := s.Group
+		if group == "" {
+			group = "ungrouped"
+		}
+		if _, ok := groups[group]; !ok {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], s)
+	}
+	return order, groups
+}
+
+func formatAnsibleINI(servers []Server) []byte {
+	var buf bytes.Buffer
+	order, groups := groupServers(servers)
+	for idx, group := range order {
+		if idx > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", group)
+		for _, s := range groups[group] {
+			name, vars := ansibleVarsFor(s)
+			buf.WriteString(name)
+			for _, kv := range vars {
+				value := kv[1]
+				switch {
+				case !strings.ContainsAny(value, " \t\"'#"):
+				case strings.Contains(value, "'"):
+					value = `"` + value + `"`
+				default:
+					value = "'" + value + "'"
+				}
+				fmt.Fprintf(&buf, " %s=%s", kv[0], value)
+			}
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func formatAnsibleYAML(servers []Server) ([]byte, error) {
+	mapping := func() *yaml.Node { return &yaml.Node{Kind: yaml.MappingNode} }
+	scalar := func(value string) *yaml.Node { return &yaml.Node{Kind: yaml.ScalarNode, Value: value} }
+
+	children := mapping()
+	order, groups := groupServers(servers)
+	for _, group := range order {
+		hosts := mapping()
+		for _, s := range groups[group] {
+			name, vars := ansibleVarsFor(s)
+			hostVars := mapping()
+			for _, kv := range vars {
+				value := scalar(kv[1])
+				switch kv[0] {
+				case "ansible_port":
+					value.Tag = "!!int"
+				case "ansible_become":
+					value.Tag = "!!bool"
+				}
+				hostVars.Content = append(hostVars.Content, scalar(kv[0]), value)
+			}
+			if len(hostVars.Content) == 0 {
+				hostVars = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+			}
+			hosts.Content = append(hosts.Content, scalar(name), hostVars)
+		}
+		body := mapping()
+		body.Content = append(body.Content, scalar("hosts"), hosts)
+		children.Content = append(children.Content, scalar(group), body)
+	}
+
+	all := mapping()
+	all.Content = append(all.Content, scalar("children"), children)
+	root := mapping()
+	root.Content = append(root.Content, scalar("all"), all)
+	return marshalYAML(root)
+}
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectInventoryFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"servers.csv", FormatCSV, true},
+		{"export.JSON", FormatJSON, true},
+		{"inventory.ini", FormatAnsibleINI, true},
+		{"ansible.cfg", FormatAnsibleINI, true},
+		{"/etc/ansible/hosts", FormatAnsibleINI, true},
+		{"inventory.yml", FormatAnsibleYAML, true},
+		{"inventory.yaml", FormatAnsibleYAML, true},
+		{"servers.txt", "", false},
+		{"hosts.bak", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DetectInventoryFormat(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("DetectInventoryFormat(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseServers(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		want     []Server
+		warnings []string
+		wantErr  string
+	}{
+		// CSV
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input: "# exported\n" +
+				"host,name,user,port,identity,proxy_jump,privilege,group,labels\n" +
+				"10.0.0.1,web1,deploy,2222,~/.ssh/web,ops@bastion,sudo,web,\"env=prod,role=web\"\n" +
+				"10.0.0.2,,root,,,,root,,\n",
+			want: []Server{
+				{Host: "10.0.0.1", Name: "web1", User: "deploy", Port: 2222, Identity: "~/.ssh/web", ProxyJump: "ops@bastion",
+					Privilege: "sudo", Group: "web", Labels: map[string]string{"env": "prod", "role": "web"}},
+				{Host: "10.0.0.2", User: "root", Privilege: "root"},
+			},
+		},
+		{
+			name:   "csv columns in any order and case",
+			format: FormatCSV,
+			input:  "Labels, Host\nenv=dev, 10.0.0.3\n",
+			want:   []Server{{Host: "10.0.0.3", Labels: map[string]string{"env": "dev"}}},
+		},
+		{name: "csv empty", format: FormatCSV, input: "", want: nil},
+		{name: "csv header only", format: FormatCSV, input: "host,user\n", want: nil},
+		{name: "csv unknown column", format: FormatCSV, input: "host,password\nweb1,x\n", wantErr: `unknown column "password"`},
+		{name: "csv without host column", format: FormatCSV, input: "name,user\nweb1,root\n", wantErr: "a host column is required"},
+		{name: "csv row without host", format: FormatCSV, input: "host,user\nweb1,root\n,deploy\n", wantErr: "line 3: host is required"},
+		{name: "csv invalid port", format: FormatCSV, input: "host,port\nweb1,ssh\n", wantErr: `line 2: invalid port "ssh"`},
+		{name: "csv port out of range", format: FormatCSV, input: "host,port\nweb1,70000\n", wantErr: "must be between 1 and 65535"},
+		{name: "csv invalid label", format: FormatCSV, input: "host,labels\nweb1,env\n", wantErr: "line 2: invalid label"},
+		{name: "csv invalid privilege", format: FormatCSV, input: "host,privilege\nweb1,admin\n", wantErr: "must be sudo or root"},
+		{name: "csv duplicate host", format: FormatCSV, input: "host\nweb1\nweb1\n", wantErr: `duplicate host "web1"`},
+		{name: "csv unterminated quote", format: FormatCSV, input: "host,name\nweb1,\"web\n", wantErr: "csv:"},
+		{name: "csv extra field", format: FormatCSV, input: "host\nweb1,extra\n", wantErr: "wrong number of fields"},
+
+		// JSON
+		{
+			name:   "json list",
+			format: FormatJSON,
+			input:  `[{"host": "10.0.0.1", "name": "web1", "port": 22, "labels": {"env": "prod"}}]`,
+			want:   []Server{{Host: "10.0.0.1", Name: "web1", Port: 22, Labels: map[string]string{"env": "prod"}}},
+		},
+		{
+			name:   "json inventory",
+			format: FormatJSON,
+			input:  `{"servers": [{"host": "10.0.0.1", "enrolled": true}]}`,
+			want:   []Server{{Host: "10.0.0.1", Enrolled: true}},
+		},
+		{name: "json empty", format: FormatJSON, input: "  \n", want: nil},
+		{name: "json unknown key", format: FormatJSON, input: `[{"host": "a", "hostname": "b"}]`, wantErr: "hostname"},
+		{name: "json malformed", format: FormatJSON, input: `[{"host": "a"`, wantErr: "json"},
+		{name: "json wrong type", format: FormatJSON, input: `[{"host": "a", "port": "ssh"}]`, wantErr: "port"},
+
+		// Ansible INI
+		{
+			name:   "ansible-ini",
+			format: FormatAnsibleINI,
+			input: `# comment
+bastion.example.com
+
+[web]
+web[01:02].example.com ansible_user=deploy env=prod
+lb ansible_host=10.0.0.5 ansible_port=2222 ansible_user=root # a comment
+
+[db]
+db1 ansible_ssh_common_args='-o ProxyJump=ops@bastion:2200' ansible_ssh_private_key_file=~/.ssh/db ansible_port=ssh
+
+[db:vars]
+env=prod
+role="database"
+ntp_servers=a,b
+
+[prod:children]
+web
+db
+
+[prod:vars]
+env=staging
+tier=1
+`,
+			want: []Server{
+				{Host: "bastion.example.com", Name: "bastion.example.com"},
+				{Host: "web01.example.com", Name: "web01.example.com", User: "deploy", Group: "web",
+					Labels: map[string]string{"env": "prod", "tier": "1"}},
+				{Host: "web02.example.com", Name: "web02.example.com", User: "deploy", Group: "web",
+					Labels: map[string]string{"env": "prod", "tier": "1"}},
+				{Host: "10.0.0.5", Name: "lb", User: "root", Port: 2222, Privilege: PrivilegeRoot, Group: "web",
+					Labels: map[string]string{"env": "staging", "tier": "1"}},
+				{Host: "db1", Name: "db1", Identity: "~/.ssh/db", ProxyJump: "ops@bastion:2200", Group: "db",
+					Labels: map[string]string{"env": "prod", "role": "database", "tier": "1"}},
+			},
+			warnings: []string{
+				`db1: ignoring invalid ansible_port "ssh"`,
+				"db1: variable ntp_servers is not a valid label, skipped",
+			},
+		},
+		{
+			name:   "ansible-ini host in several groups",
+			format: FormatAnsibleINI,
+			input:  "[web]\nweb1\n\n[monitoring]\nweb1 ansible_become=yes ansible_user=root\n",
+			want:   []Server{{Host: "web1", Name: "web1", User: "root", Group: "web"}},
+		},
+		{name: "ansible-ini unterminated quote", format: FormatAnsibleINI, input: "[web]\nweb1 env='prod\n", wantErr: "line 2: unterminated quote"},
+		{name: "ansible-ini variable without value", format: FormatAnsibleINI, input: "web1 env\n", wantErr: `line 1: expected key=value, got "env"`},
+		{name: "ansible-ini group variable without value", format: FormatAnsibleINI, input: "[web:vars]\nenv\n", wantErr: "line 2: expected key=value in [web:vars]"},
+		{name: "ansible-ini alphabetic range", format: FormatAnsibleINI, input: "web[a:c]\n", wantErr: "unsupported host pattern"},
+		{name: "ansible-ini reversed range", format: FormatAnsibleINI, input: "web[3:1]\n", wantErr: "invalid host range"},
+		{name: "ansible-ini invalid port", format: FormatAnsibleINI, input: "web1 ansible_port=99999\n", wantErr: "must be between 1 and 65535"},
+
+		// Ansible YAML
+		{
+			name:   "ansible-yaml",
+			format: FormatAnsibleYAML,
+			input: `all:
+  vars:
+    env: staging
+  children:
+    web:
+      hosts:
+        web[1:2]:
+          ansible_user: deploy
+      vars:
+        env: prod
+    db:
+      hosts:
+        db1:
+          ansible_host: 10.0.1.1
+          ansible_port: 2200
+          ansible_user: root
+          ansible_become: true
+          zones: [a, b]
+    ungrouped:
+      hosts:
+        spare:
+`,
+			want: []Server{
+				{Host: "web1", Name: "web1", User: "deploy", Group: "web", Labels: map[string]string{"env": "prod"}},
+				{Host: "web2", Name: "web2", User: "deploy", Group: "web", Labels: map[string]string{"env": "prod"}},
+				{Host: "10.0.1.1", Name: "db1", User: "root", Port: 2200, Group: "db", Labels: map[string]string{"env": "staging"}},
+				{Host: "spare", Name: "spare", Labels: map[string]string{"env": "staging"}},
+			},
+			warnings: []string{"db1: variable zones is not a valid label, skipped"},
+		},
+		{name: "ansible-yaml empty", format: FormatAnsibleYAML, input: "", want: nil},
+		{name: "ansible-yaml malformed", format: FormatAnsibleYAML, input: "all:\n  children: [\n", wantErr: "ansible-yaml"},
+		{name: "ansible-yaml not a mapping", format: FormatAnsibleYAML, input: "- web1\n", wantErr: "expected a mapping of groups"},
+		{name: "ansible-yaml host variables not a mapping", format: FormatAnsibleYAML, input: "web:\n  hosts:\n    web1: [a]\n", wantErr: "host web1"},
+		{name: "ansible-yaml bad range", format: FormatAnsibleYAML, input: "web:\n  hosts:\n    web[x]:\n", wantErr: "line 3: unsupported host pattern"},
+
+		{name: "unknown format", format: "xml", input: "", wantErr: "unknown format: xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers, warnings, err := ParseServers(tt.format, []byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(servers, tt.want) {
+				t.Errorf("servers =\n%+v\nwant\n%+v", servers, tt.want)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestFormatServersRoundTrip(t *testing.T) {
+	servers := []Server{
+		{Host: "10.0.0.1", Name: "web1", User: "deploy", Port: 2222, Identity: "/root/.ssh/id web", ProxyJump: "ops@bastion:22",
+			Group: "web", Labels: map[string]string{"env": "prod", "role": "web"}},
+		{Host: "10.0.0.2", Name: "10.0.0.2", User: "root", Privilege: PrivilegeRoot, Group: "db"},
+		{Host: "db2", Name: "db2", Group: "db", Labels: map[string]string{"note": "it's quoted"}},
+		{Host: "spare", Name: "spare"},
+	}
+	for _, format := range InventoryFormats {
+		t.Run(format, func(t *testing.T) {
+			data, err := FormatServers(format, servers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, warnings, err := ParseServers(format, data)
+			if err != nil {
+				t.Fatalf("parsing the export: %v\n%s", err, data)
+			}
+			if len(warnings) > 0 {
+				t.Errorf("warnings = %q", warnings)
+			}
+			if !reflect.DeepEqual(got, servers) {
+				t.Errorf("round trip =\n%+v\nwant\n%+v\nexport:\n%s", got, servers, data)
+			}
+		})
+	}
+}
+
+func TestMergeServers(t *testing.T) {
+	enrolledAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	inv := &Inventory{Servers: []Server{
+		{Host: "web1", Name: "web1", User: "deploy", Port: 22, Enrolled: true, EnrolledAt: enrolledAt,
+			HostKey: "SHA256:" + strings.Repeat("A", 43), Labels: map[string]string{"env": "prod", "role": "web"}},
+		{Host: "db1", Name: "db1", User: "root", Privilege: PrivilegeRoot},
+	}}
+
+	changes := inv.MergeServers([]Server{
+		// Empty fields leave the inventory's alone; labels are merged.
+		{Host: "web1", User: "ops", Port: 2222, Labels: map[string]string{"env": "staging", "tier": "1"}},
+		{Host: "db1", User: "root"},
+		// New servers are added unenrolled, named after their host.
+		{Host: "web2", User: "deploy", Enrolled: true, Group: "web"},
+	})
+
+	want := []ServerChange{
+		{Host: "web1", Action: ChangeUpdate, Fields: []FieldChange{
+			{Key: "labels.env", Old: "prod", New: "staging"},
+			{Key: "labels.tier", Old: "", New: "1"},
+			{Key: "port", Old: "22", New: "2222"},
+			{Key: "user", Old: "deploy", New: "ops"},
+		}},
+		{Host: "db1", Action: ChangeUnchanged},
+		{Host: "web2", Action: ChangeAdd, Fields: []FieldChange{
+			{Key: "group", Old: "", New: "web"},
+			{Key: "name", Old: "", New: "web2"},
+			{Key: "user", Old: "", New: "deploy"},
+		}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes =\n%+v\nwant\n%+v", changes, want)
+	}
+
+	web1 := inv.GetServer("web1")
+	if !web1.Enrolled || !web1.EnrolledAt.Equal(enrolledAt) || web1.HostKey == "" {
+		t.Errorf("web1 lost its enrollment state: %+v", web1)
+	}
+	if want := map[string]string{"env": "staging", "role": "web", "tier": "1"}; !reflect.DeepEqual(web1.Labels, want) {
+		t.Errorf("web1 labels = %v, want %v", web1.Labels, want)
+	}
+	if web2 := inv.GetServer("web2"); web2 == nil || web2.Enrolled || web2.Name != "web2" {
+		t.Errorf("web2 = %+v, want it added unenrolled", web2)
+	}
+	if len(inv.Servers) != 3 {
+		t.Errorf("inventory has %d servers, want 3", len(inv.Servers))
+	}
+}
+
+// Merging an import into itself changes nothing.
+func TestMergeServersIdempotent(t *testing.T) {
+	imported, _, err := ParseServers(FormatCSV, []byte("host,user,labels\nweb1,deploy,env=prod\nweb2,,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := &Inventory{}
+	inv.MergeServers(imported)
+	for _, change := range inv.MergeServers(imported) {
+		if change.Action != ChangeUnchanged {
+			t.Errorf("second merge of %s: %s %+v", change.Host, change.Action, change.Fields)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Keys are dotted paths that follow the yaml tags of Config, e.g. "ca.url"
+// or "backup.destinations.0.bucket". List entries are addressed by index, or
+// by name for entries that have one.
+
+// Get returns the value stored at key. Scalars are printed as-is; sections
+// and lists are rendered as YAML. ok is false when the value is unset.
+func (c *Config) Get(key string) (value string, ok bool, err error) {
+	v, err := lookup(reflect.ValueOf(c).Elem(), key, false)
+	if err != nil {
+		return "", false, err
+	}
+	if v.IsZero() {
+		return "", false, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		data, err := yaml.Marshal(v.Interface())
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(data), "\n"), true, nil
+	default:
+		return fmt.Sprint(v.Interface()), true, nil
+	}
+}
+
+// Set parses value into the field at key. A list index one past the end
+// appends a new entry.
+func (c *Config) Set(key, value string) error {
+	v, err := lookup(reflect.ValueOf(c).Elem(), key, true)
+	if err != nil {
+		return err
+	}
+	return setScalar(v, key, value)
+}
+
+// Unset clears the value at key. Unsetting a list entry removes it.
+func (c *Config) Unset(key string) error {
+	parent, last := splitKey(key)
+	if parent != "" {
+		container, err := lookup(reflect.ValueOf(c).Elem(), parent, false)
+		if err != nil {
+			return err
+		}
+		if container.Kind() == reflect.Slice {
+			idx, ok := listIndex(container, last)
+			if !ok || idx >= container.Len() {
+				return fmt.Errorf("%s: no such list entry", key)
+			}
+			reflect.Copy(container.Slice(idx, container.Len()), container.Slice(idx+1, container.Len()))
+			container.SetLen(container.Len() - 1)
+			return nil
+		}
+	}
+
+	v, err := lookup(reflect.ValueOf(c).Elem(), key, false)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.Zero(v.Type()))
+	return nil
+}
+
+// Keys lists every scalar key in the schema. List entries appear with a
+// "N" placeholder for the index.
+func Keys() []string {
+	var keys []string
+	collectKeys(reflect.TypeOf(Config{}), "", &keys)
+	return keys
+}
+
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlName(field)
+		if name == "" {
+			continue
+		}
+		key := joinKey(prefix, name)
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			collectKeys(field.Type, key, keys)
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.Struct {
+				collectKeys(field.Type.Elem(), key+".N", keys)
+			} else {
+				*keys = append(*keys, key)
+			}
+		default:
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+// lookup walks key through v. With grow set, an index one past the end of
+// a list appends a zero entry so Set can populate it.
+func lookup(v reflect.Value, key string, grow bool) (reflect.Value, error) {
+	if key == "" {
+		return reflect.Value{}, fmt.Errorf("empty key")
+	}
+
+	walked := ""
+	for _, part := range strings.Split(key, ".") {
+		walked = joinKey(walked, part)
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByYAML(v, part)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("unknown key: %s", walked)
+			}
+			v = field
+		case reflect.Slice:
+			if v.Type().Elem().Kind() != reflect.Struct {
+				return reflect.Value{}, fmt.Errorf("%s is a list value; set it as a comma-separated list", strings.TrimSuffix(walked, "."+part))
+			}
+			idx, ok := listIndex(v, part)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("%s: no such list entry", walked)
+			}
+			if idx == v.Len() && grow {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			}
+			if idx >= v.Len() {
+				return reflect.Value{}, fmt.Errorf("%s: no such list entry (have %d)", walked, v.Len())
+			}
+			v = v.Index(idx)
+		default:
+			return reflect.Value{}, fmt.Errorf("unknown key: %s", walked)
+		}
+	}
+	return v, nil
+}
+
+// listIndex resolves a list segment, either a numeric index or the value of
+// the entries' "name" key (so "contexts.lab.ca.url" works).
+func listIndex(list reflect.Value, part string) (int, bool) {
+	if idx, err := strconv.Atoi(part); err == nil {
+		return idx, idx >= 0
+	}
+	for i := 0; i < list.Len(); i++ {
+		name, ok := fieldByYAML(list.Index(i), "name")
+		if ok && name.Kind() == reflect.String && name.String() == part {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func setScalar(v reflect.Value, key, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false, got %q", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected a whole number, got %q", key, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s is a list; set its entries as %s.N.<field>", key, key)
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Struct:
+		return fmt.Errorf("%s is a section; set one of its keys instead", key)
+	default:
+		return fmt.Errorf("%s: unsupported value type %s", key, v.Type())
+	}
+	return nil
+}
+
+func fieldByYAML(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" || name == "" {
+		return ""
+	}
+	return name
+}
+
+func splitKey(key string) (parent, last string) {
+	idx := strings.LastIndex(key, ".")
+	if idx < 0 {
+		return "", key
+	}
+	return key[:idx], key[idx+1:]
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package config
+
+// LockFile is a no-op where flock is unavailable; writes are still atomic.
+func LockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Environment variables that override the default locations. The Bash
+// runtime reads the same names, so both halves resolve the same files.
+const (
+	EnvConfigDir  = "AUTO_SSL_CONFIG_DIR"
+	EnvConfigFile = "AUTO_SSL_CONFIG_FILE"
+	EnvCertDir    = "AUTO_SSL_CERT_DIR"
+	EnvDataDir    = "AUTO_SSL_DATA_DIR"
+	EnvStepCAPath = "STEP_CA_PATH"
+	EnvStepPath   = "STEPPATH"
+)
+
+// File names inside the config directory.
+const (
+	InventoryFileName  = "servers.yaml"
+	KnownHostsFileName = "known_hosts"
+)
+
+// ConfigDir returns $AUTO_SSL_CONFIG_DIR or DefaultConfigDir.
+func ConfigDir() string {
+	return envOr(EnvConfigDir, DefaultConfigDir)
+}
+
+// ConfigFile returns $AUTO_SSL_CONFIG_FILE, or config.yaml in ConfigDir.
+func ConfigFile() string {
+	return envOr(EnvConfigFile, filepath.Join(ConfigDir(), DefaultConfigFile))
+}
+
+// InventoryFile returns servers.yaml in ConfigDir.
+func InventoryFile() string {
+	return filepath.Join(ConfigDir(), InventoryFileName)
+}
+
+// KnownHostsFile returns the SSH known_hosts file auto-ssl keeps in
+// ConfigDir for the servers it manages.
+func KnownHostsFile() string {
+	return filepath.Join(ConfigDir(), KnownHostsFileName)
+}
+
+// CertDir returns $AUTO_SSL_CERT_DIR or DefaultCertDir.
+func CertDir() string {
+	return envOr(EnvCertDir, DefaultCertDir)
+}
+
+// DataDir returns $AUTO_SSL_DATA_DIR or DefaultDataDir.
+func DataDir() string {
+	return envOr(EnvDataDir, DefaultDataDir)
+}
+
+// StepCAPath returns the step-ca data directory: $STEP_CA_PATH, then
+// $STEPPATH, then DefaultStepCAPath.
+func StepCAPath() string {
+	return envOr(EnvStepCAPath, envOr(EnvStepPath, DefaultStepCAPath))
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
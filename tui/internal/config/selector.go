@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Selector filters inventory servers by label, kubectl-style:
+// "env=prod,role!=db" matches servers labelled env=prod whose role is not db.
+// A bare "key" requires the label to exist and "!key" requires it to be
+// absent. All terms must match.
+type Selector struct {
+	terms []selectorTerm
+}
+
+type selectorTerm struct {
+	key    string
+	value  string
+	op     string // "=", "!=", "exists", "!exists"
+	source string
+}
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// ParseSelector parses a comma-separated selector. An empty string selects
+// everything.
+func ParseSelector(raw string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		term := selectorTerm{source: part}
+		switch {
+		case strings.Contains(part, "!="):
+			term.key, term.value, _ = strings.Cut(part, "!=")
+			term.op = "!="
+		case strings.Contains(part, "=="):
+			term.key, term.value, _ = strings.Cut(part, "==")
+			term.op = "="
+		case strings.Contains(part, "="):
+			term.key, term.value, _ = strings.Cut(part, "=")
+			term.op = "="
+		case strings.HasPrefix(part, "!"):
+			term.key = strings.TrimPrefix(part, "!")
+			term.op = "!exists"
+		default:
+			term.key = part
+			term.op = "exists"
+		}
+
+		term.key = strings.TrimSpace(term.key)
+		term.value = strings.TrimSpace(term.value)
+		if !labelKeyPattern.MatchString(term.key) {
+			return Selector{}, fmt.Errorf("invalid selector term %q", part)
+		}
+		sel.terms = append(sel.terms, term)
+	}
+	return sel, nil
+}
+
+// Empty reports whether the selector matches everything.
+func (s Selector) Empty() bool {
+	return len(s.terms) == 0
+}
+
+// Matches reports whether labels satisfy every term.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, term := range s.terms {
+		value, ok := labels[term.key]
+		switch term.op {
+		case "=":
+			if !ok || value != term.value {
+				return false
+			}
+		case "!=":
+			if ok && value == term.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s.terms))
+	for _, term := range s.terms {
+		parts = append(parts, term.source)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseLabels parses "key=value" pairs into a map.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || !labelKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid label %q (expected key=value)", pair)
+		}
+		if strings.Contains(value, ",") {
+			return nil, fmt.Errorf("invalid label %q: values may not contain commas", pair)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// FormatLabels renders labels as sorted "key=value" pairs joined by commas.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+labels[key])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Select returns the servers in the given group (any group when empty) whose
+// labels match sel, in inventory order.
+func (i *Inventory) Select(sel Selector, group string) []Server {
+	var matched []Server
+	for _, server := range i.Servers {
+		if group != "" && server.Group != group {
+			continue
+		}
+		if !sel.Matches(server.Labels) {
+			continue
+		}
+		matched = append(matched, server)
+	}
+	return matched
+}
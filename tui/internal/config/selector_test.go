@@ -0,0 +1,181 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []selectorTerm
+		wantErr string
+	}{
+		{raw: "", want: nil},
+		{raw: " , ,", want: nil},
+		{raw: "env=prod", want: []selectorTerm{{key: "env", value: "prod", op: "=", source: "env=prod"}}},
+		{raw: "env==prod", want: []selectorTerm{{key: "env", value: "prod", op: "=", source: "env==prod"}}},
+		{raw: "role!=db", want: []selectorTerm{{key: "role", value: "db", op: "!=", source: "role!=db"}}},
+		{raw: "tier", want: []selectorTerm{{key: "tier", op: "exists", source: "tier"}}},
+		{raw: "!canary", want: []selectorTerm{{key: "canary", op: "!exists", source: "!canary"}}},
+		{raw: "env=", want: []selectorTerm{{key: "env", value: "", op: "=", source: "env="}}},
+		{
+			raw: " env = prod , role!=db,team/owner.name",
+			want: []selectorTerm{
+				{key: "env", value: "prod", op: "=", source: "env = prod"},
+				{key: "role", value: "db", op: "!=", source: "role!=db"},
+				{key: "team/owner.name", op: "exists", source: "team/owner.name"},
+			},
+		},
+		{raw: "=prod", wantErr: `invalid selector term "=prod"`},
+		{raw: "!=db", wantErr: `invalid selector term "!=db"`},
+		{raw: "!", wantErr: `invalid selector term "!"`},
+		{raw: "env=prod,-bad", wantErr: `invalid selector term "-bad"`},
+		{raw: "bad key=x", wantErr: `invalid selector term "bad key=x"`},
+		{raw: "env.=x", wantErr: `invalid selector term "env.=x"`},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSelector(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSelector(%q): %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(sel.terms, tt.want) {
+			t.Errorf("ParseSelector(%q) = %+v, want %+v", tt.raw, sel.terms, tt.want)
+		}
+		if sel.Empty() != (len(tt.want) == 0) {
+			t.Errorf("ParseSelector(%q).Empty() = %v", tt.raw, sel.Empty())
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "role": "web", "canary": ""}
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"role!=web", false},
+		{"missing!=x", true},
+		{"missing=", false},
+		{"canary=", true},
+		{"canary", true},
+		{"!canary", false},
+		{"region", false},
+		{"!region", true},
+		{"env=prod,role=web", true},
+		{"env=prod,role=db", false},
+		{"env=prod,!region,role!=db", true},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.raw)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q): %v", tt.raw, err)
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("%q matches %v = %v, want %v", tt.raw, labels, got, tt.want)
+		}
+	}
+	if sel, _ := ParseSelector("env=prod"); sel.Matches(nil) {
+		t.Error("env=prod matches a server without labels")
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	sel, err := ParseSelector(" env=prod ,, !canary ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sel.String(); got != "env=prod,!canary" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		pairs   []string
+		want    map[string]string
+		wantErr string
+	}{
+		{pairs: nil, want: map[string]string{}},
+		{pairs: []string{"env=prod", "role=web"}, want: map[string]string{"env": "prod", "role": "web"}},
+		{pairs: []string{"env=prod", "env=dev"}, want: map[string]string{"env": "dev"}},
+		{pairs: []string{"note=a=b"}, want: map[string]string{"note": "a=b"}},
+		{pairs: []string{"empty="}, want: map[string]string{"empty": ""}},
+		{pairs: []string{"env"}, wantErr: "expected key=value"},
+		{pairs: []string{"=prod"}, wantErr: "expected key=value"},
+		{pairs: []string{"bad key=x"}, wantErr: "expected key=value"},
+		{pairs: []string{"zones=a,b"}, wantErr: "values may not contain commas"},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabels(tt.pairs)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseLabels(%q) error = %v, want %q", tt.pairs, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLabels(%q): %v", tt.pairs, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLabels(%q) = %v, want %v", tt.pairs, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	if got := FormatLabels(map[string]string{"role": "web", "env": "prod"}); got != "env=prod,role=web" {
+		t.Errorf("FormatLabels = %q", got)
+	}
+	if got := FormatLabels(nil); got != "" {
+		t.Errorf("FormatLabels(nil) = %q", got)
+	}
+}
+
+func TestInventorySelect(t *testing.T) {
+	inv := &Inventory{Servers: []Server{
+		{Host: "web1", Group: "web", Labels: map[string]string{"env": "prod"}},
+		{Host: "db1", Group: "db", Labels: map[string]string{"env": "prod", "role": "db"}},
+		{Host: "web2", Group: "web", Labels: map[string]string{"env": "staging"}},
+		{Host: "bare"},
+	}}
+	tests := []struct {
+		raw   string
+		group string
+		want  []string
+	}{
+		{"", "", []string{"web1", "db1", "web2", "bare"}},
+		{"", "web", []string{"web1", "web2"}},
+		{"env=prod", "", []string{"web1", "db1"}},
+		{"env=prod", "web", []string{"web1"}},
+		{"role!=db", "", []string{"web1", "web2", "bare"}},
+		{"!env", "", []string{"bare"}},
+		{"env=prod", "none", nil},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, server := range inv.Select(sel, tt.group) {
+			got = append(got, server.Host)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%q, %q) = %q, want %q", tt.raw, tt.group, got, tt.want)
+		}
+	}
+}
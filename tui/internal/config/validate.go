@@ -0,0 +1,321 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a schema violation for a single key.
+type FieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// BackupSchedules are the values `ca backup-schedule --schedule` accepts.
+var BackupSchedules = []string{"daily", "weekly", "monthly"}
+
+// EnrollmentModes are the values server.enrollment accepts.
+var EnrollmentModes = []string{"provisioner", "acme"}
+
+// ACMEChallenges are the challenge types `server enroll --acme` can answer.
+var ACMEChallenges = []string{"http-01", "tls-alpn-01"}
+
+// Validate checks the configuration against the schema and returns every
+// violation found. Unset optional values are not errors.
+func (c *Config) Validate() []FieldError {
+	var errs []FieldError
+	add := func(key, format string, args ...any) {
+		errs = append(errs, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	validateCA := func(prefix string, ca CAConfig) {
+		if ca.URL != "" {
+			if err := ValidateCAURL(ca.URL); err != nil {
+				add(prefix+".url", "%v", err)
+			}
+		}
+		if ca.Fingerprint != "" && !fingerprintPattern.MatchString(ca.Fingerprint) {
+			add(prefix+".fingerprint", "must be 64 hexadecimal characters (SHA-256 of the root certificate)")
+		}
+		if ca.StepPath != "" && !filepath.IsAbs(ca.StepPath) {
+			add(prefix+".steppath", "must be an absolute path")
+		}
+	}
+	validateCA("ca", c.CA)
+
+	seen := map[string]bool{}
+	for i, ctx := range c.Contexts {
+		key := fmt.Sprintf("contexts.%d", i)
+		switch {
+		case ctx.Name == "":
+			add(key+".name", "is required")
+		case ctx.Name == DefaultContext:
+			add(key+".name", "%q is reserved for the top-level ca block", DefaultContext)
+		case !contextNamePattern.MatchString(ctx.Name):
+			add(key+".name", "may only contain letters, digits, '.', '_' and '-'")
+		case seen[ctx.Name]:
+			add(key+".name", "duplicate context %q", ctx.Name)
+		default:
+			key = "contexts." + ctx.Name
+		}
+		seen[ctx.Name] = true
+		if ctx.CA.URL == "" {
+			add(key+".ca.url", "is required")
+		}
+		validateCA(key+".ca", ctx.CA)
+	}
+	if c.CurrentContext != "" && c.FindContext(c.CurrentContext) == nil {
+		add("current_context", "unknown context %q", c.CurrentContext)
+	}
+
+	certDuration, certErr := ParseDuration(c.Defaults.CertDuration)
+	if c.Defaults.CertDuration != "" && certErr != nil {
+		add("defaults.cert_duration", "%v", certErr)
+	}
+	maxDuration, maxErr := ParseDuration(c.Defaults.MaxCertDuration)
+	if c.Defaults.MaxCertDuration != "" && maxErr != nil {
+		add("defaults.max_cert_duration", "%v", maxErr)
+	}
+	if c.Defaults.CertDuration != "" && c.Defaults.MaxCertDuration != "" && certErr == nil && maxErr == nil && certDuration > maxDuration {
+		add("defaults.cert_duration", "%s exceeds max_cert_duration %s", c.Defaults.CertDuration, c.Defaults.MaxCertDuration)
+	}
+
+	if c.Backup.Schedule != "" && !contains(BackupSchedules, c.Backup.Schedule) {
+		add("backup.schedule", "must be one of %s", strings.Join(BackupSchedules, ", "))
+	}
+	for _, keep := range []struct {
+		key   string
+		value int
+	}{
+		{"backup.retention", c.Backup.Retention},
+		{"backup.keep_daily", c.Backup.KeepDaily},
+		{"backup.keep_weekly", c.Backup.KeepWeekly},
+		{"backup.keep_monthly", c.Backup.KeepMonthly},
+	} {
+		if keep.value < 0 {
+			add(keep.key, "must not be negative")
+		}
+	}
+	for i, dest := range c.Backup.Destinations {
+		key := fmt.Sprintf("backup.destinations.%d", i)
+		switch dest.Type {
+		case "local":
+			if dest.Path == "" {
+				add(key+".path", "is required for local destinations")
+			}
+		case "rsync":
+			if dest.Target == "" {
+				add(key+".target", "is required for rsync destinations")
+			}
+		case "s3":
+			if dest.Bucket == "" {
+				add(key+".bucket", "is required for s3 destinations")
+			}
+			if dest.Endpoint != "" {
+				if err := ValidateS3Endpoint(dest.Endpoint); err != nil {
+					add(key+".endpoint", "%v", err)
+				}
+			}
+		case "":
+			add(key+".type", "is required (local, rsync or s3)")
+		default:
+			add(key+".type", "unknown destination type %q (local, rsync or s3)", dest.Type)
+		}
+	}
+
+	validateCertificate := func(prefix string, cert Certificate) {
+		if cert.CertPath != "" && !filepath.IsAbs(cert.CertPath) {
+			add(prefix+".cert_path", "must be an absolute path")
+		}
+		if cert.KeyPath != "" && !filepath.IsAbs(cert.KeyPath) {
+			add(prefix+".key_path", "must be an absolute path")
+		}
+		if cert.RenewAt != "" {
+			if _, err := ParseFraction(cert.RenewAt); err != nil {
+				add(prefix+".renew_at", "%v", err)
+			}
+		}
+		for i, hook := range cert.Reload {
+			if (hook.Service == "") == (hook.Command == "") {
+				add(fmt.Sprintf("%s.reload.%d", prefix, i), "needs either a service or a command")
+			}
+		}
+		if cert.VerifyPort < 0 || cert.VerifyPort > 65535 {
+			add(prefix+".verify_port", "must be a TCP port")
+		}
+		if cert.Enrollment != "" && !contains(EnrollmentModes, cert.Enrollment) {
+			add(prefix+".enrollment", "must be one of %s", strings.Join(EnrollmentModes, ", "))
+		}
+		acme := cert.ACME
+		if acme.Directory != "" {
+			if err := validateHTTPSURL(acme.Directory); err != nil {
+				add(prefix+".acme.directory", "%v", err)
+			}
+		}
+		if acme.Challenge != "" && !contains(ACMEChallenges, acme.Challenge) {
+			add(prefix+".acme.challenge", "must be one of %s", strings.Join(ACMEChallenges, ", "))
+		}
+		if acme.Port < 0 || acme.Port > 65535 {
+			add(prefix+".acme.port", "must be a TCP port")
+		}
+		for _, p := range []struct{ key, value string }{
+			{prefix + ".acme.webroot", acme.Webroot},
+			{prefix + ".acme.root", acme.Root},
+			{prefix + ".acme.account_key", acme.AccountKey},
+		} {
+			if p.value != "" && !filepath.IsAbs(p.value) {
+				add(p.key, "must be an absolute path")
+			}
+		}
+		if cert.Enrollment == "acme" && acme.Directory == "" {
+			add(prefix+".acme.directory", "is required for acme enrollment")
+		}
+	}
+	validateCertificate("server", Certificate{
+		CertPath:   c.Server.CertPath,
+		KeyPath:    c.Server.KeyPath,
+		RenewAt:    c.Server.RenewAt,
+		Reload:     c.Server.Reload,
+		VerifyPort: c.Server.VerifyPort,
+		Enrollment: c.Server.Enrollment,
+		ACME:       c.Server.ACME,
+	})
+	for _, ts := range []struct{ key, value string }{
+		{"server.suspended_at", c.Server.SuspendedAt},
+		{"server.resumed_at", c.Server.ResumedAt},
+	} {
+		if ts.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, ts.value); err != nil {
+			add(ts.key, "must be an RFC 3339 timestamp")
+		}
+	}
+
+	// Two certificates written to one file would replace each other
+	paths := map[string]string{}
+	if c.Server.SANs != "" {
+		paths[c.Server.Default().CertPath] = "server.cert_path"
+	}
+	seen = map[string]bool{}
+	for i, cert := range c.Server.Certificates {
+		key := fmt.Sprintf("server.certificates.%d", i)
+		switch {
+		case cert.Name == "":
+			add(key+".name", "is required")
+		case cert.Name == DefaultCertificate:
+			add(key+".name", "%q is reserved for the certificate in the server block", DefaultCertificate)
+		case !certificateNamePattern.MatchString(cert.Name):
+			add(key+".name", "may only contain letters, digits, '.', '_' and '-'")
+		case seen[cert.Name]:
+			add(key+".name", "duplicate certificate %q", cert.Name)
+		default:
+			key = "server.certificates." + cert.Name
+		}
+		seen[cert.Name] = true
+		validateCertificate(key, cert)
+		if cert.Name != "" {
+			path := cert.withDefaults(cert.Name).CertPath
+			if other, ok := paths[path]; ok {
+				add(key+".cert_path", "%s is also %s", path, other)
+			}
+			paths[path] = key + ".cert_path"
+		}
+	}
+
+	return errs
+}
+
+// ValidateCAURL checks that raw is an https URL with a host, which is what
+// step expects for --ca-url.
+func ValidateCAURL(raw string) error {
+	return validateHTTPSURL(raw)
+}
+
+// ValidateS3Endpoint checks an S3 endpoint URL. Plain http is only
+// accepted on the loopback interface, e.g. for a local MinIO.
+func ValidateS3Endpoint(raw string) error {
+	u, err := url.Parse(raw)
+	if err == nil && u.Scheme == "http" {
+		if u.Host == "" {
+			return fmt.Errorf("URL has no host")
+		}
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("must be an https:// URL (http:// only for localhost)")
+		}
+		return nil
+	}
+	return validateHTTPSURL(raw)
+}
+
+func validateHTTPSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("must be an https:// URL")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL has no host")
+	}
+	return nil
+}
+
+// ParseDuration parses a certificate duration the way step-ca does, so
+// "168h" is valid and "7d" is not.
+func ParseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 24h or 168h)", value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", value)
+	}
+	return d, nil
+}
+
+// ParseFraction parses a fraction of a certificate's lifetime, written as
+// "2/3" or "0.66". It must lie strictly between 0 and 1.
+func ParseFraction(value string) (float64, error) {
+	var f float64
+	if num, den, ok := strings.Cut(value, "/"); ok {
+		n, errN := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		d, errD := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if errN != nil || errD != nil || d == 0 {
+			return 0, fmt.Errorf("invalid fraction %q (use e.g. 2/3 or 0.66)", value)
+		}
+		f = n / d
+	} else {
+		var err error
+		if f, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return 0, fmt.Errorf("invalid fraction %q (use e.g. 2/3 or 0.66)", value)
+		}
+	}
+	if f <= 0 || f >= 1 {
+		return 0, fmt.Errorf("fraction %q must be between 0 and 1", value)
+	}
+	return f, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
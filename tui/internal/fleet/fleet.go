@@ -0,0 +1,209 @@
+// Package fleet runs one operation against many inventory servers with
+// bounded concurrency and per-host timeouts.
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// Host outcomes.
+const (
+	StatusOK          = "ok"
+	StatusFailed      = "failed"
+	StatusUnreachable = "unreachable"
+	StatusSkipped     = "skipped" // not attempted because a rollout stopped
+)
+
+// DefaultParallel and DefaultTimeout apply when a Runner leaves them unset.
+const (
+	DefaultParallel = 10
+	DefaultTimeout  = 2 * time.Minute
+)
+
+// ErrUnreachable marks a task error as a connection failure rather than a
+// failed operation. Use Unreachable to wrap a task's error with it.
+var ErrUnreachable = errors.New("host unreachable")
+
+type unreachableError struct{ err error }
+
+func (e unreachableError) Error() string        { return e.err.Error() }
+func (e unreachableError) Unwrap() error        { return e.err }
+func (e unreachableError) Is(target error) bool { return target == ErrUnreachable }
+
+// Unreachable marks err as a connection failure, keeping its message.
+func Unreachable(err error) error {
+	return unreachableError{err: err}
+}
+
+// Task performs the operation on one server. It must return when ctx is done.
+type Task func(ctx context.Context, server config.Server) (output string, err error)
+
+// Result is the outcome for one server.
+type Result struct {
+	Host     string        `json:"host"`
+	Name     string        `json:"name,omitempty"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+	Error    string        `json:"error,omitempty"`
+	Output   string        `json:"output,omitempty"`
+
+	// RolledBack is set when a stopped rollout undid this host's change.
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+// Reporter is told when each host starts and finishes. Calls are serialized.
+type Reporter interface {
+	Start(servers []config.Server)
+	HostStarted(server config.Server)
+	HostDone(result Result)
+	Finish()
+}
+
+// Runner executes a Task across servers.
+type Runner struct {
+	Parallel int
+	Timeout  time.Duration
+	Reporter Reporter
+
+	mu sync.Mutex // serializes Reporter calls
+}
+
+// Run executes task for every server and returns the results in inventory
+// order. Cancelling ctx stops hosts that have not started; they are reported
+// as failed.
+func (r *Runner) Run(ctx context.Context, servers []config.Server, task Task) []Result {
+	r.report(func(rep Reporter) { rep.Start(servers) })
+	results := r.run(ctx, servers, task)
+	r.report(func(rep Reporter) { rep.Finish() })
+	return results
+}
+
+func (r *Runner) report(fn func(Reporter)) {
+	if r.Reporter == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.Reporter)
+}
+
+// run executes task for servers without starting or finishing the report.
+func (r *Runner) run(ctx context.Context, servers []config.Server, task Task) []Result {
+	parallel := r.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	report := r.report
+
+	results := make([]Result, len(servers))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for idx, server := range servers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			results[idx] = Result{Host: server.Host, Name: server.Name, Status: StatusFailed, Error: "cancelled"}
+			report(func(rep Reporter) { rep.HostDone(results[idx]) })
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, server config.Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			report(func(rep Reporter) { rep.HostStarted(server) })
+			results[idx] = runOne(ctx, server, task, timeout)
+			report(func(rep Reporter) { rep.HostDone(results[idx]) })
+		}(idx, server)
+	}
+	wg.Wait()
+	return results
+}
+
+func runOne(ctx context.Context, server config.Server, task Task, timeout time.Duration) Result {
+	hostCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	output, err := task(hostCtx, server)
+	elapsed := time.Since(started)
+
+	result := Result{
+		Host:     server.Host,
+		Name:     server.Name,
+		Status:   StatusOK,
+		Duration: elapsed,
+		Seconds:  elapsed.Round(time.Millisecond).Seconds(),
+		Output:   output,
+	}
+	switch {
+	case err == nil:
+	case errors.Is(hostCtx.Err(), context.DeadlineExceeded):
+		result.Status = StatusFailed
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	case errors.Is(err, ErrUnreachable):
+		result.Status = StatusUnreachable
+		result.Error = err.Error()
+	default:
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Total       int `json:"total"`
+	OK          int `json:"ok"`
+	Failed      int `json:"failed"`
+	Unreachable int `json:"unreachable"`
+	Skipped     int `json:"skipped,omitempty"`
+	RolledBack  int `json:"rolled_back,omitempty"`
+}
+
+// Summarize counts results by status.
+func Summarize(results []Result) Summary {
+	summary := Summary{Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case StatusOK:
+			summary.OK++
+		case StatusUnreachable:
+			summary.Unreachable++
+		case StatusSkipped:
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+		if result.RolledBack {
+			summary.RolledBack++
+		}
+	}
+	return summary
+}
+
+// Err returns an error when any host did not succeed.
+func (s Summary) Err() error {
+	if s.Failed == 0 && s.Unreachable == 0 && s.Skipped == 0 {
+		return nil
+	}
+	if s.Skipped > 0 {
+		return fmt.Errorf("rollout stopped: %d of %d host(s) failed, %d unreachable, %d not attempted",
+			s.Failed, s.Total, s.Unreachable, s.Skipped)
+	}
+	return fmt.Errorf("%d of %d host(s) failed, %d unreachable", s.Failed, s.Total, s.Unreachable)
+}
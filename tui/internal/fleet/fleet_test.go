@@ -0,0 +1,248 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+func servers(hosts ...string) []config.Server {
+	var s []config.Server
+	for _, host := range hosts {
+		s = append(s, config.Server{Host: host, Name: host + "-name"})
+	}
+	return s
+}
+
+func hostsOf(results []Result) []string {
+	var hosts []string
+	for _, result := range results {
+		hosts = append(hosts, result.Host)
+	}
+	return hosts
+}
+
+// recorder is a Reporter that records the calls it gets.
+type recorder struct {
+	mu      sync.Mutex
+	calls   []string
+	started atomic.Int32
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) Start(servers []config.Server) { r.record(fmt.Sprintf("start %d", len(servers))) }
+func (r *recorder) HostStarted(config.Server)     { r.started.Add(1) }
+func (r *recorder) HostDone(result Result)        { r.record(result.Host + " " + result.Status) }
+func (r *recorder) Finish()                       { r.record("finish") }
+
+func TestRunClassifiesResults(t *testing.T) {
+	tasks := map[string]Task{
+		"ok": func(ctx context.Context, server config.Server) (string, error) {
+			return "renewed\n", nil
+		},
+		"failed": func(ctx context.Context, server config.Server) (string, error) {
+			return "step: exit 1\n", errors.New("exit status 1")
+		},
+		"unreachable": func(ctx context.Context, server config.Server) (string, error) {
+			return "", Unreachable(errors.New("dial tcp: connection refused"))
+		},
+		"slow": func(ctx context.Context, server config.Server) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+		// A connection that times out is a timeout, not an unreachable host.
+		"slow-dial": func(ctx context.Context, server config.Server) (string, error) {
+			<-ctx.Done()
+			return "", Unreachable(ctx.Err())
+		},
+	}
+	task := func(ctx context.Context, server config.Server) (string, error) {
+		return tasks[server.Host](ctx, server)
+	}
+
+	runner := &Runner{Parallel: 5, Timeout: 50 * time.Millisecond}
+	results := runner.Run(context.Background(), servers("ok", "failed", "unreachable", "slow", "slow-dial"), task)
+
+	want := []struct {
+		status, err, output string
+	}{
+		{StatusOK, "", "renewed\n"},
+		{StatusFailed, "exit status 1", "step: exit 1\n"},
+		{StatusUnreachable, "dial tcp: connection refused", ""},
+		{StatusFailed, "timed out after 50ms", ""},
+		{StatusFailed, "timed out after 50ms", ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Status != w.status || got.Error != w.err || got.Output != w.output {
+			t.Errorf("%s: status %q, error %q, output %q; want %q, %q, %q",
+				got.Host, got.Status, got.Error, got.Output, w.status, w.err, w.output)
+		}
+		if got.Name != got.Host+"-name" {
+			t.Errorf("%s: name %q", got.Host, got.Name)
+		}
+	}
+	if d := results[3].Duration; d < 50*time.Millisecond || d > 5*time.Second {
+		t.Errorf("timed-out host took %v", d)
+	}
+}
+
+func TestRunBoundsConcurrency(t *testing.T) {
+	tests := []struct {
+		parallel int
+		hosts    int
+		want     int
+	}{
+		{parallel: 1, hosts: 5, want: 1},
+		{parallel: 3, hosts: 12, want: 3},
+		{parallel: 8, hosts: 4, want: 4},
+		{parallel: 0, hosts: 25, want: DefaultParallel},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("parallel %d", tt.parallel), func(t *testing.T) {
+			var running, peak atomic.Int32
+			task := func(ctx context.Context, server config.Server) (string, error) {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				return "", nil
+			}
+			var hosts []string
+			for i := 0; i < tt.hosts; i++ {
+				hosts = append(hosts, fmt.Sprintf("host%02d", i))
+			}
+
+			runner := &Runner{Parallel: tt.parallel}
+			results := runner.Run(context.Background(), servers(hosts...), task)
+			if got := int(peak.Load()); got != tt.want {
+				t.Errorf("peak concurrency %d, want %d", got, tt.want)
+			}
+			if !reflect.DeepEqual(hostsOf(results), hosts) {
+				t.Errorf("results in order %v, want inventory order", hostsOf(results))
+			}
+		})
+	}
+}
+
+// Results stay in inventory order however the hosts finish.
+func TestRunKeepsInventoryOrder(t *testing.T) {
+	delays := map[string]time.Duration{"a": 60 * time.Millisecond, "b": 0, "c": 30 * time.Millisecond, "d": 10 * time.Millisecond}
+	task := func(ctx context.Context, server config.Server) (string, error) {
+		time.Sleep(delays[server.Host])
+		return server.Host, nil
+	}
+	rec := &recorder{}
+	runner := &Runner{Parallel: 4, Reporter: rec}
+	results := runner.Run(context.Background(), servers("a", "b", "c", "d"), task)
+
+	if got := hostsOf(results); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("results in order %v", got)
+	}
+	for _, result := range results {
+		if result.Output != result.Host {
+			t.Errorf("%s got the output of %s", result.Host, result.Output)
+		}
+	}
+	// The reporter sees hosts as they finish, between Start and Finish.
+	want := []string{"start 4", "b ok", "d ok", "c ok", "a ok", "finish"}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("reporter calls %v, want %v", rec.calls, want)
+	}
+	if n := rec.started.Load(); n != 4 {
+		t.Errorf("%d hosts reported started, want 4", n)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	task := func(ctx context.Context, server config.Server) (string, error) {
+		if calls.Add(1) == 1 {
+			cancel()
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	runner := &Runner{Parallel: 1}
+	results := runner.Run(ctx, servers("a", "b", "c"), task)
+
+	if calls.Load() != 1 {
+		t.Errorf("task ran %d times after the cancel", calls.Load())
+	}
+	for _, result := range results[1:] {
+		if result.Status != StatusFailed || result.Error != "cancelled" {
+			t.Errorf("%s: %s %q, want failed as cancelled", result.Host, result.Status, result.Error)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     Summary
+		wantErr  string
+	}{
+		{
+			name: "none",
+			want: Summary{},
+		},
+		{
+			name:     "all ok",
+			statuses: []string{StatusOK, StatusOK},
+			want:     Summary{Total: 2, OK: 2},
+		},
+		{
+			name:     "mixed",
+			statuses: []string{StatusOK, StatusFailed, StatusUnreachable, StatusUnreachable, StatusOK},
+			want:     Summary{Total: 5, OK: 2, Failed: 1, Unreachable: 2},
+			wantErr:  "1 of 5 host(s) failed, 2 unreachable",
+		},
+		{
+			name:     "only unreachable",
+			statuses: []string{StatusUnreachable},
+			want:     Summary{Total: 1, Unreachable: 1},
+			wantErr:  "0 of 1 host(s) failed, 1 unreachable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []Result
+			for _, status := range tt.statuses {
+				results = append(results, Result{Status: status})
+			}
+			got := Summarize(results)
+			if got != tt.want {
+				t.Errorf("Summarize = %+v, want %+v", got, tt.want)
+			}
+			err := got.Err()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Err() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Err() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
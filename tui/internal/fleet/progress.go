@@ -0,0 +1,112 @@
+package fleet
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// Progress prints one row per finished host. In live mode it also keeps a
+// status line at the bottom with the counts and the hosts still running,
+// which is redrawn in place and so should only be used on a terminal.
+type Progress struct {
+	w    io.Writer
+	live bool
+
+	total   int
+	done    int
+	width   int
+	started time.Time
+	running map[string]time.Time
+	counts  map[string]int
+}
+
+// NewProgress returns a Reporter that writes to w.
+func NewProgress(w io.Writer, live bool) *Progress {
+	return &Progress{w: w, live: live}
+}
+
+func (p *Progress) Start(servers []config.Server) {
+	p.total = len(servers)
+	p.started = time.Now()
+	p.running = map[string]time.Time{}
+	p.counts = map[string]int{}
+	p.width = len("HOST")
+	for _, server := range servers {
+		if len(server.Host) > p.width {
+			p.width = len(server.Host)
+		}
+	}
+	fmt.Fprintf(p.w, "%-*s  %-11s  %7s  %s\n", p.width, "HOST", "STATUS", "TIME", "DETAIL")
+	p.drawStatus()
+}
+
+func (p *Progress) HostStarted(server config.Server) {
+	p.running[server.Host] = time.Now()
+	p.drawStatus()
+}
+
+func (p *Progress) HostDone(result Result) {
+	delete(p.running, result.Host)
+	p.done++
+	p.counts[result.Status]++
+
+	p.clearStatus()
+	detail := result.Error
+	if result.Status == StatusOK {
+		detail = LastLine(result.Output)
+	}
+	fmt.Fprintf(p.w, "%-*s  %-11s  %6.1fs  %s\n", p.width, result.Host, result.Status, result.Duration.Seconds(), detail)
+	p.drawStatus()
+}
+
+func (p *Progress) Wave(n, total int, servers []config.Server) {
+	p.clearStatus()
+	fmt.Fprintf(p.w, "-- wave %d/%d: %d server(s)\n", n, total, len(servers))
+	p.drawStatus()
+}
+
+func (p *Progress) Finish() {
+	p.clearStatus()
+}
+
+func (p *Progress) drawStatus() {
+	if !p.live {
+		return
+	}
+	hosts := make([]string, 0, len(p.running))
+	for host := range p.running {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if len(hosts) > 3 {
+		hosts = append(hosts[:3], "...")
+	}
+
+	line := fmt.Sprintf("[%d/%d] %d ok, %d failed, %d unreachable, %d running %s (%s)",
+		p.done, p.total, p.counts[StatusOK], p.counts[StatusFailed], p.counts[StatusUnreachable],
+		len(p.running), strings.Join(hosts, " "), time.Since(p.started).Round(time.Second))
+	fmt.Fprint(p.w, "\r\033[K"+line)
+}
+
+func (p *Progress) clearStatus() {
+	if p.live {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+// LastLine returns the last non-empty line of output, which is where the
+// Bash runtime reports the outcome.
+func LastLine(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// Rollout runs an operation in waves: Canary servers first, then batches of
+// BatchSize. It stops before the next wave when any canary fails or when
+// more than MaxFailures servers have failed in total.
+type Rollout struct {
+	Canary      int
+	BatchSize   int // defaults to the Runner's Parallel
+	MaxFailures int
+}
+
+// WaveReporter is implemented by Reporters that show wave boundaries.
+type WaveReporter interface {
+	Wave(n, total int, servers []config.Server)
+}
+
+// Waves splits servers into the rollout's waves, in inventory order.
+func (ro Rollout) Waves(servers []config.Server, parallel int) [][]config.Server {
+	batch := ro.BatchSize
+	if batch <= 0 {
+		batch = parallel
+	}
+	if batch <= 0 {
+		batch = DefaultParallel
+	}
+
+	var waves [][]config.Server
+	rest := servers
+	if ro.Canary > 0 && len(rest) > 0 {
+		n := min(ro.Canary, len(rest))
+		waves = append(waves, rest[:n])
+		rest = rest[n:]
+	}
+	for len(rest) > 0 {
+		n := min(batch, len(rest))
+		waves = append(waves, rest[:n])
+		rest = rest[n:]
+	}
+	return waves
+}
+
+// RunRollout executes task wave by wave and returns the results in
+// inventory order. When the rollout stops, the servers it did not reach are
+// StatusSkipped and stopped explains why.
+func (r *Runner) RunRollout(ctx context.Context, servers []config.Server, task Task, ro Rollout) (results []Result, stopped string) {
+	waves := ro.Waves(servers, r.Parallel)
+	r.report(func(rep Reporter) { rep.Start(servers) })
+
+	failures := 0
+	for n, wave := range waves {
+		if stopped != "" {
+			for _, server := range wave {
+				result := Result{Host: server.Host, Name: server.Name, Status: StatusSkipped, Error: "not attempted"}
+				results = append(results, result)
+				r.report(func(rep Reporter) { rep.HostDone(result) })
+			}
+			continue
+		}
+
+		r.report(func(rep Reporter) {
+			if w, ok := rep.(WaveReporter); ok {
+				w.Wave(n+1, len(waves), wave)
+			}
+		})
+		waveResults := r.run(ctx, wave, task)
+		results = append(results, waveResults...)
+
+		waveFailures := 0
+		for _, result := range waveResults {
+			if result.Status != StatusOK {
+				waveFailures++
+			}
+		}
+		failures += waveFailures
+
+		switch {
+		case n == 0 && ro.Canary > 0 && waveFailures > 0:
+			stopped = "the canary failed"
+		case failures > ro.MaxFailures:
+			stopped = fmt.Sprintf("%d failure(s) exceeded --max-failures %d", failures, ro.MaxFailures)
+		case ctx.Err() != nil:
+			stopped = "interrupted"
+		}
+	}
+
+	r.report(func(rep Reporter) { rep.Finish() })
+	return results, stopped
+}
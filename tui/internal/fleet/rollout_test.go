@@ -0,0 +1,261 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+func TestRolloutWaves(t *testing.T) {
+	tests := []struct {
+		name     string
+		rollout  Rollout
+		parallel int
+		hosts    []string
+		want     [][]string
+	}{
+		{
+			name:    "canary then batches",
+			rollout: Rollout{Canary: 1, BatchSize: 2},
+			hosts:   []string{"a", "b", "c", "d", "e"},
+			want:    [][]string{{"a"}, {"b", "c"}, {"d", "e"}},
+		},
+		{
+			name:    "short last batch",
+			rollout: Rollout{Canary: 2, BatchSize: 2},
+			hosts:   []string{"a", "b", "c", "d", "e"},
+			want:    [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name:    "no canary",
+			rollout: Rollout{BatchSize: 3},
+			hosts:   []string{"a", "b", "c", "d"},
+			want:    [][]string{{"a", "b", "c"}, {"d"}},
+		},
+		{
+			name:    "canary larger than the fleet",
+			rollout: Rollout{Canary: 5, BatchSize: 2},
+			hosts:   []string{"a", "b", "c"},
+			want:    [][]string{{"a", "b", "c"}},
+		},
+		{
+			name:     "batch size 0 falls back to parallel",
+			rollout:  Rollout{Canary: 1},
+			parallel: 2,
+			hosts:    []string{"a", "b", "c", "d", "e"},
+			want:     [][]string{{"a"}, {"b", "c"}, {"d", "e"}},
+		},
+		{
+			name:    "batch size and parallel 0 fall back to the default",
+			rollout: Rollout{},
+			hosts:   strings.Split(strings.Repeat("h,", DefaultParallel+1)+"h", ","),
+			want: [][]string{
+				strings.Split(strings.Repeat("h,", DefaultParallel-1)+"h", ","),
+				{"h", "h"},
+			},
+		},
+		{
+			name:    "no servers",
+			rollout: Rollout{Canary: 1, BatchSize: 2},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, wave := range tt.rollout.Waves(servers(tt.hosts...), tt.parallel) {
+				var hosts []string
+				for _, server := range wave {
+					hosts = append(hosts, server.Host)
+				}
+				got = append(got, hosts)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("waves %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// waveRecorder is a recorder that also records wave boundaries.
+type waveRecorder struct {
+	recorder
+}
+
+func (r *waveRecorder) Wave(n, total int, servers []config.Server) {
+	var hosts []string
+	for _, server := range servers {
+		hosts = append(hosts, server.Host)
+	}
+	r.record(fmt.Sprintf("wave %d/%d %s", n, total, strings.Join(hosts, ",")))
+}
+
+func TestRunRollout(t *testing.T) {
+	tests := []struct {
+		name        string
+		rollout     Rollout
+		hosts       []string
+		fail        string // hosts whose task fails
+		unreachable string // hosts whose task cannot connect
+		wantStatus  string // per host: o ok, f failed, u unreachable, s skipped
+		wantStopped string
+	}{
+		{
+			name:       "all succeed",
+			rollout:    Rollout{Canary: 1, BatchSize: 2},
+			hosts:      []string{"a", "b", "c", "d"},
+			wantStatus: "oooo",
+		},
+		{
+			name:        "failed canary stops the rollout",
+			rollout:     Rollout{Canary: 1, BatchSize: 2, MaxFailures: 10},
+			hosts:       []string{"a", "b", "c", "d"},
+			fail:        "a",
+			wantStatus:  "fsss",
+			wantStopped: "the canary failed",
+		},
+		{
+			name:        "unreachable canary stops the rollout",
+			rollout:     Rollout{Canary: 2, BatchSize: 2, MaxFailures: 10},
+			hosts:       []string{"a", "b", "c", "d"},
+			unreachable: "b",
+			wantStatus:  "ouss",
+			wantStopped: "the canary failed",
+		},
+		{
+			name:        "threshold exceeded mid-rollout",
+			rollout:     Rollout{Canary: 1, BatchSize: 2, MaxFailures: 1},
+			hosts:       []string{"a", "b", "c", "d", "e", "f", "g"},
+			fail:        "c,d",
+			wantStatus:  "ooffoss",
+			wantStopped: "2 failure(s) exceeded --max-failures 1",
+		},
+		{
+			name:        "failures at the threshold carry on",
+			rollout:     Rollout{Canary: 1, BatchSize: 2, MaxFailures: 2},
+			hosts:       []string{"a", "b", "c", "d", "e"},
+			fail:        "c",
+			unreachable: "e",
+			wantStatus:  "oofou",
+			wantStopped: "",
+		},
+		{
+			name:        "max failures 0 stops on the first failure",
+			rollout:     Rollout{BatchSize: 2},
+			hosts:       []string{"a", "b", "c", "d", "e"},
+			fail:        "b",
+			wantStatus:  "ofsss",
+			wantStopped: "1 failure(s) exceeded --max-failures 0",
+		},
+		{
+			name:       "canary larger than the fleet",
+			rollout:    Rollout{Canary: 5, BatchSize: 1},
+			hosts:      []string{"a", "b", "c"},
+			fail:       "c",
+			wantStatus: "oof",
+			// Nothing is left to skip, but --rollback still undoes a and b.
+			wantStopped: "the canary failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ran []string
+			task := func(ctx context.Context, server config.Server) (string, error) {
+				mu.Lock()
+				ran = append(ran, server.Host)
+				mu.Unlock()
+				switch {
+				case strings.Contains(","+tt.fail+",", ","+server.Host+","):
+					return "", errors.New("failed")
+				case strings.Contains(","+tt.unreachable+",", ","+server.Host+","):
+					return "", Unreachable(errors.New("connection refused"))
+				}
+				return "", nil
+			}
+
+			runner := &Runner{Parallel: 4}
+			results, stopped := runner.RunRollout(context.Background(), servers(tt.hosts...), task, tt.rollout)
+
+			if stopped != tt.wantStopped {
+				t.Errorf("stopped %q, want %q", stopped, tt.wantStopped)
+			}
+			if got := hostsOf(results); !reflect.DeepEqual(got, tt.hosts) {
+				t.Fatalf("results for %v, want inventory order %v", got, tt.hosts)
+			}
+			codes := map[string]byte{StatusOK: 'o', StatusFailed: 'f', StatusUnreachable: 'u', StatusSkipped: 's'}
+			var status []byte
+			for _, result := range results {
+				status = append(status, codes[result.Status])
+			}
+			if string(status) != tt.wantStatus {
+				t.Errorf("statuses %s, want %s", status, tt.wantStatus)
+			}
+			if skipped := strings.Count(tt.wantStatus, "s"); len(ran) != len(tt.hosts)-skipped {
+				t.Errorf("task ran on %v, want every host but the %d skipped", ran, skipped)
+			}
+
+			err := Summarize(results).Err()
+			switch {
+			case tt.wantStatus == strings.Repeat("o", len(tt.hosts)):
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+			case strings.Contains(tt.wantStatus, "s"):
+				if err == nil || !strings.HasPrefix(err.Error(), "rollout stopped:") {
+					t.Errorf("Err() = %v, want the rollout reported stopped", err)
+				}
+			default:
+				if err == nil {
+					t.Error("Err() = nil with failed hosts")
+				}
+			}
+		})
+	}
+}
+
+func TestRunRolloutReportsWaves(t *testing.T) {
+	rec := &waveRecorder{}
+	runner := &Runner{Parallel: 2, Reporter: rec}
+	task := func(ctx context.Context, server config.Server) (string, error) {
+		if server.Host == "b" {
+			return "", errors.New("failed")
+		}
+		return "", nil
+	}
+	_, stopped := runner.RunRollout(context.Background(), servers("a", "b", "c", "d"), task, Rollout{Canary: 1, BatchSize: 1})
+
+	want := []string{"start 4", "wave 1/4 a", "a ok", "wave 2/4 b", "b failed", "c skipped", "d skipped", "finish"}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("reporter calls %v, want %v", rec.calls, want)
+	}
+	if stopped == "" {
+		t.Error("rollout did not stop")
+	}
+}
+
+func TestRunRolloutInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	task := func(ctx context.Context, server config.Server) (string, error) {
+		if server.Host == "b" {
+			cancel()
+		}
+		return "", nil
+	}
+	runner := &Runner{Parallel: 1}
+	results, stopped := runner.RunRollout(ctx, servers("a", "b", "c"), task, Rollout{BatchSize: 2, MaxFailures: 5})
+
+	if stopped != "interrupted" {
+		t.Errorf("stopped %q, want interrupted", stopped)
+	}
+	if results[2].Status != StatusSkipped {
+		t.Errorf("c is %s after the interrupt, want skipped", results[2].Status)
+	}
+}
@@ -0,0 +1,145 @@
+// Package hooks makes the services using the server certificate load it
+// after a renewal, checks that the renewed certificate is what they now
+// serve, and raises an alert when it is not.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// DefaultVerifyTimeout is how long Verify waits for a reloaded service to
+// serve the new certificate; graceful reloads finish in the background.
+const DefaultVerifyTimeout = 15 * time.Second
+
+// Hook reloads one service, or runs one shell command.
+type Hook struct {
+	Service string // systemd unit, reloaded (or restarted if it cannot reload)
+	Command string // run with /bin/sh -c
+}
+
+func (h Hook) String() string {
+	if h.Service != "" {
+		return "reload " + h.Service
+	}
+	return h.Command
+}
+
+// Run runs every hook, even after one fails, and returns the failures.
+func Run(ctx context.Context, hooks []Hook, log io.Writer) error {
+	var errs []error
+	for _, hook := range hooks {
+		fmt.Fprintf(logWriter(log), "Running %s\n", hook)
+		var cmd *exec.Cmd
+		if hook.Service != "" {
+			cmd = exec.CommandContext(ctx, "systemctl", "reload-or-restart", hook.Service)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", "-c", hook.Command)
+		}
+		cmd.Stdout = log
+		cmd.Stderr = log
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Verify checks that want is the certificate served on port. It connects
+// to localhost and then to each IP address in want, using its first DNS
+// name as the server name, and keeps trying until timeout passes.
+func Verify(ctx context.Context, want *x509.Certificate, port int, timeout time.Duration) error {
+	hosts := []string{"localhost"}
+	for _, ip := range want.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	serverName := ""
+	if len(want.DNSNames) > 0 {
+		serverName = want.DNSNames[0]
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var got *x509.Certificate
+		var addr string
+		var err error
+		for _, host := range hosts {
+			addr = net.JoinHostPort(host, strconv.Itoa(port))
+			if got, err = served(ctx, addr, serverName); err == nil {
+				break
+			}
+		}
+		if err == nil && bytes.Equal(got.Raw, want.Raw) {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s serves certificate %s (expires %s), but the certificate file holds %s",
+				addr, got.SerialNumber.Text(16), got.NotAfter.Local().Format(time.RFC1123), want.SerialNumber.Text(16))
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// served returns the leaf certificate presented at addr.
+func served(ctx context.Context, addr, serverName string) (*x509.Certificate, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 5 * time.Second},
+		Config: &tls.Config{
+			ServerName: serverName,
+			// Only the certificate's identity is compared, not its trust.
+			InsecureSkipVerify: true,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s presented no certificate", addr)
+	}
+	return certs[0], nil
+}
+
+// Alert runs command to report problem. The command finds the message in
+// $AUTO_SSL_ALERT, e.g. `mail -s "auto-ssl on $(hostname)" ops@example.com
+// <<< "$AUTO_SSL_ALERT"`.
+func Alert(ctx context.Context, command, problem string, log io.Writer) error {
+	fmt.Fprintf(logWriter(log), "ALERT: %s\n", problem)
+	if command == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), "AUTO_SSL_ALERT="+problem)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("alert command: %w", err)
+	}
+	return nil
+}
+
+func logWriter(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
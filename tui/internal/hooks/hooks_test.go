@@ -0,0 +1,156 @@
+package hooks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCert returns a self-signed certificate for 127.0.0.1 with the given
+// serial number.
+func newCert(t *testing.T, serial int64) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// serve starts a TLS server presenting whatever serving holds, and returns
+// its port.
+func serve(t *testing.T, serving *atomic.Pointer[tls.Certificate]) int {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// Verify hangs up after the handshake, which the server would log.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return serving.Load(), nil
+		},
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestVerifyServesFile(t *testing.T) {
+	cert := newCert(t, 0x1001)
+	var serving atomic.Pointer[tls.Certificate]
+	serving.Store(&cert)
+	port := serve(t, &serving)
+
+	if err := Verify(context.Background(), cert.Leaf, port, 0); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+}
+
+func TestVerifyServedCertificateDiffers(t *testing.T) {
+	old, renewed := newCert(t, 0xaaa1), newCert(t, 0xbbb2)
+	var serving atomic.Pointer[tls.Certificate]
+	serving.Store(&old)
+	port := serve(t, &serving)
+
+	start := time.Now()
+	err := Verify(context.Background(), renewed.Leaf, port, 1500*time.Millisecond)
+	if err == nil {
+		t.Fatal("Verify = nil while the old certificate is served")
+	}
+	// Retried until the deadline, then the last mismatch is reported.
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("gave up after %v, want just past the 1.5s timeout", elapsed)
+	}
+	for _, want := range []string{"serves certificate aaa1", "the certificate file holds bbb2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not say %q", err, want)
+		}
+	}
+}
+
+// A service that takes a while to pick up the renewed certificate passes
+// once it does.
+func TestVerifyRetriesUntilServed(t *testing.T) {
+	old, renewed := newCert(t, 0xaaa1), newCert(t, 0xbbb2)
+	var serving atomic.Pointer[tls.Certificate]
+	serving.Store(&old)
+	port := serve(t, &serving)
+	reloaded := time.AfterFunc(1200*time.Millisecond, func() { serving.Store(&renewed) })
+	defer reloaded.Stop()
+
+	start := time.Now()
+	if err := Verify(context.Background(), renewed.Leaf, port, 10*time.Second); err != nil {
+		t.Fatalf("Verify = %v, want nil once the service reloads", err)
+	}
+	if elapsed := time.Since(start); elapsed < 1200*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("passed after %v, want soon after the 1.2s reload", elapsed)
+	}
+}
+
+func TestVerifyNothingListening(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cert := newCert(t, 1)
+	if err := Verify(context.Background(), cert.Leaf, port, 0); err == nil {
+		t.Error("Verify = nil with nothing listening")
+	}
+}
+
+func TestVerifyCancelled(t *testing.T) {
+	old, renewed := newCert(t, 1), newCert(t, 2)
+	var serving atomic.Pointer[tls.Certificate]
+	serving.Store(&old)
+	port := serve(t, &serving)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Verify(ctx, renewed.Leaf, port, time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("Verify = %v, want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned %v after the context ended", elapsed)
+	}
+}
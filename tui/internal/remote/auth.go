@@ -0,0 +1,90 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// defaultIdentities are tried, in order, when a target names no identity,
+// matching ssh's own defaults.
+var defaultIdentities = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// defaultAuth offers the identity file (or the default key files) followed
+// by the keys in ssh-agent, as ssh does. The returned closer, if any, closes
+// the agent connection once the client is done with it.
+func defaultAuth(identity string) (ssh.AuthMethod, io.Closer, error) {
+	var signers []ssh.Signer
+	if identity != "" {
+		signer, err := loadIdentity(expandHome(identity))
+		if err != nil {
+			return nil, nil, err
+		}
+		signers = append(signers, signer)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultIdentities {
+			// Missing and passphrase-protected default keys are skipped;
+			// the agent may hold the latter.
+			if signer, err := loadIdentity(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	var closer io.Closer
+	var agentClient agent.ExtendedAgent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closer = conn
+			agentClient = agent.NewClient(conn)
+		}
+	}
+	if len(signers) == 0 && agentClient == nil {
+		return nil, nil, errors.New("no SSH keys found: start ssh-agent or pass --identity")
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		all := signers
+		if agentClient != nil {
+			agentSigners, err := agentClient.Signers()
+			if err == nil {
+				all = append(all[:len(all):len(all)], agentSigners...)
+			}
+		}
+		return all, nil
+	}), closer, nil
+}
+
+func loadIdentity(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("identity %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("identity %s is passphrase protected; add it to ssh-agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("identity %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path[1:], "/"))
+}
@@ -0,0 +1,253 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/Brightblade42/auto-ssl/internal/atomicfile"
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// HostKeyError reports a server whose host key does not match the one
+// recorded for it, which is what a man-in-the-middle looks like.
+type HostKeyError struct {
+	Host        string
+	Fingerprint string // SHA256 fingerprint the server presented
+	Expected    string // the pinned fingerprint, or where the known key is
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("host key for %s has changed (server sent %s, expected %s)",
+		e.Host, e.Fingerprint, e.Expected)
+}
+
+// hostKeyChecker checks host keys against a known_hosts file, recording the
+// key of a host seen for the first time.
+type hostKeyChecker struct {
+	path  string
+	known ssh.HostKeyCallback
+	mu    sync.Mutex // guards known
+}
+
+func newHostKeyChecker(path string) (*hostKeyChecker, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	known, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &hostKeyChecker{path: path, known: known}, nil
+}
+
+// callback returns the ssh.HostKeyCallback for one hop. A non-empty pin is
+// the fingerprint the server must present, and takes precedence over
+// known_hosts. With acceptNew, a changed key is accepted and replaces the
+// recorded one. The presented key is stored in *seen either way.
+func (h *hostKeyChecker) callback(pin string, acceptNew bool, seen *ssh.PublicKey) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		*seen = key
+		fingerprint := ssh.FingerprintSHA256(key)
+		if pin != "" {
+			if fingerprint == pin {
+				// Keep known_hosts in step so Algorithms asks for this key.
+				if h.check(hostname, remote, key) != nil {
+					return h.replace(hostname, key)
+				}
+				return nil
+			}
+			if !acceptNew {
+				return &HostKeyError{
+					Host:        knownhosts.Normalize(hostname),
+					Fingerprint: fingerprint,
+					Expected:    pin + " pinned in the inventory",
+				}
+			}
+			return h.replace(hostname, key)
+		}
+
+		err := h.check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) == 0 {
+			return h.trustNew(hostname, remote, key, acceptNew)
+		}
+		if !acceptNew {
+			return changedKeyError(hostname, key, keyErr)
+		}
+		return h.replace(hostname, key)
+	}
+}
+
+func changedKeyError(hostname string, key ssh.PublicKey, keyErr *knownhosts.KeyError) error {
+	want := keyErr.Want[0]
+	return &HostKeyError{
+		Host:        knownhosts.Normalize(hostname),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Expected:    fmt.Sprintf("the key at %s:%d", want.Filename, want.Line),
+	}
+}
+
+// check looks key up in known_hosts as last read.
+func (h *hostKeyChecker) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.known(hostname, remote, key)
+}
+
+// trustNew records the key of a host not seen before. known_hosts is read
+// again under the lock first: another run may have recorded a key for the
+// host since, and that key must match unless acceptNew.
+func (h *hostKeyChecker) trustNew(hostname string, remote net.Addr, key ssh.PublicKey, acceptNew bool) error {
+	return h.update(hostname, key, func() error {
+		err := h.known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		switch {
+		case err == nil:
+			return errAlreadyKnown
+		case !errors.As(err, &keyErr):
+			return err
+		case len(keyErr.Want) > 0 && !acceptNew:
+			return changedKeyError(hostname, key, keyErr)
+		}
+		return nil
+	})
+}
+
+// replace records key as the only known key for hostname.
+func (h *hostKeyChecker) replace(hostname string, key ssh.PublicKey) error {
+	return h.update(hostname, key, nil)
+}
+
+var errAlreadyKnown = errors.New("host key already known")
+
+// update rewrites known_hosts with key as the only key for hostname. It
+// holds the same kind of lock as the inventory, so runs in other processes
+// do not lose each other's keys, and re-reads the file under it; recheck,
+// when set, runs against the fresh copy and can veto the write.
+func (h *hostKeyChecker) update(hostname string, key ssh.PublicKey, recheck func() error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	unlock, err := config.LockFile(h.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := h.reload(); err != nil {
+		return err
+	}
+	if recheck != nil {
+		if err := recheck(); err != nil {
+			if err == errAlreadyKnown {
+				return nil
+			}
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	host := knownhosts.Normalize(hostname)
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			hosts := strings.Split(fields[0], ",")
+			if len(hosts) == 1 && hosts[0] == host {
+				continue
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	out.WriteString(knownhosts.Line([]string{host}, key) + "\n")
+
+	if err := atomicfile.Write(h.path, out.Bytes(), 0600); err != nil {
+		return err
+	}
+	return h.reload()
+}
+
+// reload reads known_hosts again. The caller holds h.mu.
+func (h *hostKeyChecker) reload() error {
+	known, err := knownhosts.New(h.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", h.path, err)
+	}
+	h.known = known
+	return nil
+}
+
+// TrustHostKey records key as the only known key for target in the
+// known_hosts file at path, or config.KnownHostsFile() when path is empty.
+func TrustHostKey(path string, target Target, key ssh.PublicKey) error {
+	if path == "" {
+		path = config.KnownHostsFile()
+	}
+	h, err := newHostKeyChecker(path)
+	if err != nil {
+		return err
+	}
+	return h.replace(target.Address(), key)
+}
+
+// Algorithms returns the host key algorithms recorded for addr, so the
+// handshake asks for a key we can check rather than the server's preferred
+// type. It is nil for hosts not seen before.
+func (h *hostKeyChecker) Algorithms(addr string) []string {
+	err := h.check(addr, &net.TCPAddr{}, probeKey)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		switch want.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, want.Key.Type())
+		}
+	}
+	return algorithms
+}
+
+// probeKey matches no recorded key, so looking it up lists the keys that are
+// recorded for a host.
+var probeKey = func() ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
@@ -0,0 +1,358 @@
+// Package remote runs commands on managed servers over SSH without the ssh
+// binary: it dials with golang.org/x/crypto/ssh, authenticates with the
+// agent and key files, and pins host keys in auto-ssl's own known_hosts.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+// DefaultConnectTimeout bounds dialling and the SSH handshake, per hop.
+const DefaultConnectTimeout = 10 * time.Second
+
+// Target is one server to connect to. Zero values use the defaults: the
+// local user name, port 22, the agent and default key files, and no jump
+// host.
+type Target struct {
+	Host      string
+	User      string
+	Port      int
+	Identity  string // private key file
+	ProxyJump string // ssh -J syntax: [user@]host[:port][,...]
+}
+
+// TargetFor returns the connection settings recorded for server.
+func TargetFor(server config.Server) Target {
+	return Target{
+		Host:      server.Host,
+		User:      server.User,
+		Port:      server.Port,
+		Identity:  server.Identity,
+		ProxyJump: server.ProxyJump,
+	}
+}
+
+// Address returns host:port.
+func (t Target) Address() string {
+	port := t.Port
+	if port == 0 {
+		port = config.DefaultSSHPort
+	}
+	return net.JoinHostPort(t.Host, strconv.Itoa(port))
+}
+
+func (t Target) String() string {
+	addr := t.Host
+	if t.Port != 0 && t.Port != config.DefaultSSHPort {
+		addr = t.Address()
+	}
+	if t.User == "" {
+		return addr
+	}
+	return t.User + "@" + addr
+}
+
+// Options tune how Dial connects. The zero value is ready to use.
+type Options struct {
+	// KnownHosts is the known_hosts file; default config.KnownHostsFile().
+	KnownHosts string
+	// ConnectTimeout defaults to DefaultConnectTimeout.
+	ConnectTimeout time.Duration
+	// Auth replaces the agent and key-file methods, e.g. for tests against
+	// an in-process server.
+	Auth []ssh.AuthMethod
+	// HostKey is the SHA256 fingerprint the target must present, as pinned
+	// in the inventory. Empty means check known_hosts only.
+	HostKey string
+	// AcceptNewHostKey accepts a changed host key and records it in
+	// known_hosts in place of the old one.
+	AcceptNewHostKey bool
+}
+
+// ConnectError reports that a server could not be reached or refused the
+// connection, as opposed to a command failing once connected.
+type ConnectError struct {
+	Target string
+	Err    error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("cannot connect to %s: %v", e.Target, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// Client is an open connection to one server, possibly through jump hosts.
+type Client struct {
+	client  *ssh.Client
+	hostKey ssh.PublicKey
+	closers []io.Closer
+}
+
+// HostKey returns the key the server presented.
+func (c *Client) HostKey() ssh.PublicKey {
+	return c.hostKey
+}
+
+// Dial connects to target through its jump hosts, if any. Failures are
+// returned as *ConnectError; a host key mismatch wraps *HostKeyError.
+func Dial(ctx context.Context, target Target, opts Options) (*Client, error) {
+	client, err := dial(ctx, target, opts, false)
+	if err != nil {
+		return nil, &ConnectError{Target: target.String(), Err: err}
+	}
+	return client, nil
+}
+
+// ScanHostKey returns the host key target presents, without checking it or
+// logging in. Jump hosts are still checked and logged in to.
+func ScanHostKey(ctx context.Context, target Target, opts Options) (ssh.PublicKey, error) {
+	_, err := dial(ctx, target, opts, true)
+	var scanned scannedKey
+	if errors.As(err, &scanned) {
+		return scanned.key, nil
+	}
+	if err == nil {
+		err = errors.New("server did not present a host key")
+	}
+	return nil, &ConnectError{Target: target.String(), Err: err}
+}
+
+// scannedKey stops a handshake once the host key has been seen.
+type scannedKey struct{ key ssh.PublicKey }
+
+func (scannedKey) Error() string { return "host key scanned" }
+
+func dial(ctx context.Context, target Target, opts Options, scan bool) (*Client, error) {
+	if target.Host == "" {
+		return nil, errors.New("no host given")
+	}
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	knownHosts := opts.KnownHosts
+	if knownHosts == "" {
+		knownHosts = config.KnownHostsFile()
+	}
+	hostKeys, err := newHostKeyChecker(knownHosts)
+	if err != nil {
+		return nil, err
+	}
+	jumps, err := ParseProxyJump(target.ProxyJump)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{}
+	auth := opts.Auth
+	if auth == nil {
+		method, closer, err := defaultAuth(target.Identity)
+		switch {
+		case err == nil:
+			auth = []ssh.AuthMethod{method}
+		case scan && len(jumps) == 0:
+			// Scanning stops before authentication.
+		default:
+			return nil, err
+		}
+		if closer != nil {
+			c.closers = append(c.closers, closer)
+		}
+	}
+
+	var via *ssh.Client
+	hops := append(jumps, target)
+	for i, hop := range hops {
+		last := i == len(hops)-1
+		hop.User = defaultUser(hop.User)
+
+		var seen ssh.PublicKey
+		checkHostKey := hostKeys.callback("", opts.AcceptNewHostKey, &seen)
+		if last {
+			checkHostKey = hostKeys.callback(opts.HostKey, opts.AcceptNewHostKey, &seen)
+		}
+		if last && scan {
+			checkHostKey = func(_ string, _ net.Addr, key ssh.PublicKey) error {
+				return scannedKey{key: key}
+			}
+		}
+
+		// Ask for the recorded key type, unless the key is allowed to change.
+		var algorithms []string
+		if !opts.AcceptNewHostKey && !(last && scan) {
+			algorithms = hostKeys.Algorithms(hop.Address())
+		}
+
+		hopCtx, cancel := context.WithTimeout(ctx, timeout)
+		client, err := dialHop(hopCtx, via, hop, &ssh.ClientConfig{
+			User:              hop.User,
+			Auth:              auth,
+			HostKeyCallback:   checkHostKey,
+			HostKeyAlgorithms: algorithms,
+			Timeout:           timeout,
+		})
+		cancel()
+		if err != nil {
+			c.Close()
+			if !last {
+				return nil, fmt.Errorf("jump host %s: %w", hop, err)
+			}
+			return nil, err
+		}
+		c.closers = append(c.closers, client)
+		c.hostKey = seen
+		via = client
+	}
+	c.client = via
+	return c, nil
+}
+
+// dialHop opens a TCP connection to hop, directly or through via, and runs
+// the SSH handshake. Cancelling ctx aborts a handshake in progress.
+func dialHop(ctx context.Context, via *ssh.Client, hop Target, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	addr := hop.Address()
+	var conn net.Conn
+	var err error
+	if via == nil {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = via.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	close(done)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, errors.New("timed out during the SSH handshake")
+		}
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// ParseProxyJump parses ssh -J syntax, [user@]host[:port] separated by
+// commas, into the hops in dialling order.
+func ParseProxyJump(spec string) ([]Target, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return nil, nil
+	}
+	var hops []Target
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		var hop Target
+		if at := strings.LastIndex(part, "@"); at >= 0 {
+			hop.User, part = part[:at], part[at+1:]
+		}
+		hop.Host = part
+		if host, port, err := net.SplitHostPort(part); err == nil {
+			n, err := strconv.Atoi(port)
+			if err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("invalid jump host port in %q", spec)
+			}
+			hop.Host, hop.Port = host, n
+		}
+		if hop.Host == "" {
+			return nil, fmt.Errorf("invalid jump host %q", spec)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+func defaultUser(name string) string {
+	if name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Close closes the connection and any jump host connections.
+func (c *Client) Close() error {
+	var first error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	c.closers = nil
+	return first
+}
+
+// Run runs command through the remote user's shell and waits for it. A
+// non-zero exit status is returned as *ssh.ExitError. If ctx is cancelled
+// the command is killed and ctx.Err() is returned.
+func (c *Client) Run(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Start(command); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	}
+}
+
+// Upload writes r to path on the server with the given mode. The data is
+// streamed to `cat`, so the server needs no SFTP subsystem.
+func (c *Client) Upload(ctx context.Context, r io.Reader, path string, mode os.FileMode) error {
+	quoted := Quote(path)
+	command := fmt.Sprintf("umask 077 && cat > %s && chmod %04o %s", quoted, mode.Perm(), quoted)
+	var stderr bytes.Buffer
+	if err := c.Run(ctx, command, r, io.Discard, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("upload %s: %s", path, msg)
+		}
+		return fmt.Errorf("upload %s: %w", path, err)
+	}
+	return nil
+}
+
+// Quote quotes s for a POSIX shell.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
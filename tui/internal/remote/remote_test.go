@@ -0,0 +1,476 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an in-process SSH server that accepts the password
+// "secret", answers exec requests by echoing the command, and forwards
+// direct-tcpip channels, so it can serve as a jump host.
+type testServer struct {
+	target Target
+	signer ssh.Signer
+
+	mu       sync.Mutex
+	forwards []string // addresses of direct-tcpip channels opened through it
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return startTestServer(t, newSigner(t))
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func startTestServer(t *testing.T, signer ssh.Signer) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	s := &testServer{
+		target: Target{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port},
+		signer: signer,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, cfg)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go serveSession(newChannel)
+		case "direct-tcpip":
+			go s.forward(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func serveSession(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		ssh.Unmarshal(req.Payload, &exec)
+		req.Reply(true, nil)
+		io.WriteString(channel, exec.Command)
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		return
+	}
+}
+
+func (s *testServer) forward(newChannel ssh.NewChannel) {
+	var dest struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &dest); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	addr := net.JoinHostPort(dest.Host, strconv.Itoa(int(dest.Port)))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	s.mu.Lock()
+	s.forwards = append(s.forwards, addr)
+	s.mu.Unlock()
+
+	go func() {
+		io.Copy(conn, channel)
+		conn.(*net.TCPConn).CloseWrite()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+	conn.Close()
+}
+
+func (s *testServer) forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.forwards...)
+}
+
+func (s *testServer) fingerprint() string {
+	return ssh.FingerprintSHA256(s.signer.PublicKey())
+}
+
+func testOptions(t *testing.T) Options {
+	t.Helper()
+	return Options{
+		KnownHosts:     filepath.Join(t.TempDir(), "known_hosts"),
+		ConnectTimeout: 5 * time.Second,
+		Auth:           []ssh.AuthMethod{ssh.Password("secret")},
+	}
+}
+
+// run dials target and runs a command, returning its output.
+func run(t *testing.T, target Target, opts Options) (string, error) {
+	t.Helper()
+	ctx := context.Background()
+	client, err := Dial(ctx, target, opts)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	var stdout bytes.Buffer
+	if err := client.Run(ctx, "hostname", nil, &stdout, io.Discard); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return stdout.String(), nil
+}
+
+func knownHostsLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// trustHostKey records key for target in known_hosts, as an earlier
+// connection would have.
+func trustHostKey(t *testing.T, path string, target Target, key ssh.PublicKey) {
+	t.Helper()
+	line := knownhosts.Line([]string{knownhosts.Normalize(target.Address())}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialRecordsNewHostKey(t *testing.T) {
+	server := newTestServer(t)
+	opts := testOptions(t)
+
+	out, err := run(t, server.target, opts)
+	if err != nil {
+		t.Fatalf("first connection: %v", err)
+	}
+	if out != "hostname" {
+		t.Errorf("output = %q, want %q", out, "hostname")
+	}
+
+	lines := knownHostsLines(t, opts.KnownHosts)
+	if len(lines) != 1 {
+		t.Fatalf("known_hosts has %d lines, want 1: %q", len(lines), lines)
+	}
+	host := "[127.0.0.1]:" + strconv.Itoa(server.target.Port)
+	if !strings.HasPrefix(lines[0], host+" ssh-ed25519 ") {
+		t.Errorf("known_hosts line = %q, want one for %s", lines[0], host)
+	}
+	if info, err := os.Stat(opts.KnownHosts); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("known_hosts mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// The recorded key is checked from then on.
+	if _, err := run(t, server.target, opts); err != nil {
+		t.Fatalf("second connection: %v", err)
+	}
+	if lines := knownHostsLines(t, opts.KnownHosts); len(lines) != 1 {
+		t.Errorf("known_hosts grew to %d lines on reconnecting", len(lines))
+	}
+}
+
+// Concurrent first connections, each with its own checker as in separate
+// processes, must not lose each other's keys.
+func TestDialConcurrentNewHostKeys(t *testing.T) {
+	opts := testOptions(t)
+	servers := make([]*testServer, 8)
+	for i := range servers {
+		servers[i] = newTestServer(t)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			client, err := Dial(context.Background(), target, opts)
+			if err != nil {
+				errs <- err
+				return
+			}
+			client.Close()
+		}(server.target)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if lines := knownHostsLines(t, opts.KnownHosts); len(lines) != len(servers) {
+		t.Errorf("known_hosts has %d lines, want %d: %q", len(lines), len(servers), lines)
+	}
+}
+
+func TestDialRejectsChangedHostKey(t *testing.T) {
+	server := newTestServer(t)
+	opts := testOptions(t)
+	// The key recorded before the server was rebuilt.
+	trustHostKey(t, opts.KnownHosts, server.target, newSigner(t).PublicKey())
+
+	_, err := run(t, server.target, opts)
+	var keyErr *HostKeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("err = %v, want a *HostKeyError", err)
+	}
+	var connErr *ConnectError
+	if !errors.As(err, &connErr) {
+		t.Errorf("err = %T, want it wrapped in *ConnectError", err)
+	}
+	if keyErr.Fingerprint != server.fingerprint() {
+		t.Errorf("Fingerprint = %s, want %s", keyErr.Fingerprint, server.fingerprint())
+	}
+
+	// --accept-new-hostkey replaces the recorded key.
+	opts.AcceptNewHostKey = true
+	if _, err := run(t, server.target, opts); err != nil {
+		t.Fatalf("with AcceptNewHostKey: %v", err)
+	}
+	opts.AcceptNewHostKey = false
+	if _, err := run(t, server.target, opts); err != nil {
+		t.Fatalf("after accepting the new key: %v", err)
+	}
+	lines := knownHostsLines(t, opts.KnownHosts)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], authorizedKey(server.signer.PublicKey())) {
+		t.Errorf("known_hosts = %q, want only the new key", lines)
+	}
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestDialPinnedHostKey(t *testing.T) {
+	server := newTestServer(t)
+	other := newSigner(t)
+
+	tests := []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{"matching pin", server.fingerprint(), false},
+		{"mismatched pin", ssh.FingerprintSHA256(other.PublicKey()), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t)
+			opts.HostKey = tt.pin
+			_, err := run(t, server.target, opts)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Dial: %v", err)
+				}
+				// The pinned key is recorded so later handshakes ask for it.
+				if lines := knownHostsLines(t, opts.KnownHosts); len(lines) != 1 {
+					t.Errorf("known_hosts has %d lines, want 1", len(lines))
+				}
+				return
+			}
+			var keyErr *HostKeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("err = %v, want a *HostKeyError", err)
+			}
+			if !strings.Contains(keyErr.Expected, tt.pin) {
+				t.Errorf("Expected = %q, want it to name the pin %s", keyErr.Expected, tt.pin)
+			}
+			if data, _ := os.ReadFile(opts.KnownHosts); len(data) != 0 {
+				t.Errorf("known_hosts = %q, want nothing recorded on a mismatch", data)
+			}
+		})
+	}
+}
+
+// A pin takes precedence over a different key in known_hosts.
+func TestDialPinOverridesKnownHosts(t *testing.T) {
+	server := newTestServer(t)
+	opts := testOptions(t)
+	stale := newSigner(t)
+	trustHostKey(t, opts.KnownHosts, server.target, stale.PublicKey())
+
+	opts.HostKey = server.fingerprint()
+	if _, err := run(t, server.target, opts); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	lines := knownHostsLines(t, opts.KnownHosts)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], authorizedKey(server.signer.PublicKey())) {
+		t.Errorf("known_hosts = %q, want only the pinned key", lines)
+	}
+}
+
+func TestDialThroughJumpHosts(t *testing.T) {
+	jump1 := newTestServer(t)
+	jump2 := newTestServer(t)
+	target := newTestServer(t)
+	opts := testOptions(t)
+
+	target.target.ProxyJump = "127.0.0.1:" + strconv.Itoa(jump1.target.Port) + ",127.0.0.1:" + strconv.Itoa(jump2.target.Port)
+	out, err := run(t, target.target, opts)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if out != "hostname" {
+		t.Errorf("output = %q, want %q", out, "hostname")
+	}
+
+	if got, want := jump1.forwarded(), []string{jump2.target.Address()}; !equal(got, want) {
+		t.Errorf("first jump host forwarded to %q, want %q", got, want)
+	}
+	if got, want := jump2.forwarded(), []string{target.target.Address()}; !equal(got, want) {
+		t.Errorf("second jump host forwarded to %q, want %q", got, want)
+	}
+	// Every hop's key is recorded.
+	if lines := knownHostsLines(t, opts.KnownHosts); len(lines) != 3 {
+		t.Errorf("known_hosts has %d lines, want 3: %q", len(lines), lines)
+	}
+}
+
+func TestDialJumpHostKeyMismatch(t *testing.T) {
+	jump := newTestServer(t)
+	target := newTestServer(t)
+	opts := testOptions(t)
+	trustHostKey(t, opts.KnownHosts, jump.target, newSigner(t).PublicKey())
+
+	target.target.ProxyJump = jump.target.Address()
+	_, err := run(t, target.target, opts)
+	var keyErr *HostKeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("err = %v, want a *HostKeyError", err)
+	}
+	if !strings.Contains(err.Error(), "jump host") {
+		t.Errorf("err = %v, want it to name the jump host", err)
+	}
+	if forwards := jump.forwarded(); len(forwards) != 0 {
+		t.Errorf("jump host forwarded %q after a host key mismatch", forwards)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Target
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "none", want: nil},
+		{spec: "bastion", want: []Target{{Host: "bastion"}}},
+		{spec: "ops@bastion:2222", want: []Target{{Host: "bastion", User: "ops", Port: 2222}}},
+		{spec: "a, b@c:22", want: []Target{{Host: "a"}, {Host: "c", User: "b", Port: 22}}},
+		{spec: "[fd00::1]:2200", want: []Target{{Host: "fd00::1", Port: 2200}}},
+		{spec: "bastion:0", wantErr: true},
+		{spec: "bastion:ssh", wantErr: true},
+		{spec: "a,,b", wantErr: true},
+		{spec: "user@", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProxyJump(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseProxyJump(%q) = %v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseProxyJump(%q): %v", tt.spec, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseProxyJump(%q) = %v, want %v", tt.spec, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseProxyJump(%q)[%d] = %+v, want %+v", tt.spec, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
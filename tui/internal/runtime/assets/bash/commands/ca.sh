@@ -20,6 +20,7 @@ SUBCOMMANDS
     restore         Restore CA from backup
     reset           Remove CA and local auto-ssl state (start over)
     backup-schedule Configure automatic backups
+    token           Mint a one-time enrollment token
 
 EXAMPLES
     # Initialize CA with default settings
@@ -127,12 +128,15 @@ cmd_ca_reset() {
         systemctl disable step-ca 2>/dev/null || true
         systemctl stop auto-ssl-renew.timer 2>/dev/null || true
         systemctl disable auto-ssl-renew.timer 2>/dev/null || true
+        systemctl stop auto-ssl-agent.service 2>/dev/null || true
+        systemctl disable auto-ssl-agent.service 2>/dev/null || true
     fi
 
     log_step "Removing service units..."
     rm -f /etc/systemd/system/step-ca.service
     rm -f /etc/systemd/system/auto-ssl-renew.service
     rm -f /etc/systemd/system/auto-ssl-renew.timer
+    rm -f /etc/systemd/system/auto-ssl-agent.service
     command -v systemctl &>/dev/null && systemctl daemon-reload 2>/dev/null || true
 
     log_step "Removing CA and auto-ssl local state..."
@@ -342,7 +346,7 @@ cmd_ca_init() {
     
     # Save configuration
     log_step "Saving configuration..."
-    cat > "${AUTO_SSL_CONFIG_DIR}/config.yaml" << EOF
+    cat > "${AUTO_SSL_CONFIG_FILE}" << EOF
 ca:
   url: https://${address}
   fingerprint: ${fingerprint}
@@ -353,7 +357,7 @@ defaults:
   cert_duration: ${cert_duration}
   max_cert_duration: ${max_duration}
 EOF
-    chmod 600 "${AUTO_SSL_CONFIG_DIR}/config.yaml"
+    chmod 600 "${AUTO_SSL_CONFIG_FILE}"
 
     # Start the service
     log_step "Starting step-ca service..."
@@ -399,7 +403,7 @@ cmd_ca_status() {
     log_header "CA Status"
 
     # Recover config if CA exists but config.yaml is missing
-    if [[ ! -f "${AUTO_SSL_CONFIG_DIR}/config.yaml" ]] && [[ -f "${STEP_CA_CONFIG}" ]] && [[ -f "${STEP_CA_PATH}/certs/root_ca.crt" ]]; then
+    if [[ ! -f "${AUTO_SSL_CONFIG_FILE}" ]] && [[ -f "${STEP_CA_CONFIG}" ]] && [[ -f "${STEP_CA_PATH}/certs/root_ca.crt" ]]; then
         log_warning "Config file missing; attempting to recover from existing CA state..."
 
         local recovered_address=""
@@ -425,7 +429,7 @@ cmd_ca_status() {
         fi
 
         mkdir -p "${AUTO_SSL_CONFIG_DIR}"
-        cat > "${AUTO_SSL_CONFIG_DIR}/config.yaml" << EOF
+        cat > "${AUTO_SSL_CONFIG_FILE}" << EOF
 ca:
   url: ${recovered_url}
   fingerprint: ${recovered_fingerprint}
@@ -436,12 +440,12 @@ defaults:
   cert_duration: 168h
   max_cert_duration: 720h
 EOF
-        chmod 600 "${AUTO_SSL_CONFIG_DIR}/config.yaml"
-        log_success "Recovered CA config at ${AUTO_SSL_CONFIG_DIR}/config.yaml"
+        chmod 600 "${AUTO_SSL_CONFIG_FILE}"
+        log_success "Recovered CA config at ${AUTO_SSL_CONFIG_FILE}"
     fi
     
     # Check if CA is configured
-    if [[ ! -f "${AUTO_SSL_CONFIG_DIR}/config.yaml" ]]; then
+    if [[ ! -f "${AUTO_SSL_CONFIG_FILE}" ]]; then
         log_warning "CA not configured on this machine"
         echo "Run 'auto-ssl ca init' to initialize a CA"
         return 1
@@ -480,7 +484,11 @@ EOF
     if [[ -f "${STEP_CA_PATH}/certs/root_ca.crt" ]]; then
         echo ""
         echo "Root CA Certificate:"
-        step certificate inspect "${STEP_CA_PATH}/certs/root_ca.crt" --short 2>/dev/null | sed 's/^/  /'
+        if has_companion; then
+            "$AUTO_SSL_BIN" tools cert inspect "${STEP_CA_PATH}/certs/root_ca.crt" 2>/dev/null | sed 's/^/  /'
+        else
+            step certificate inspect "${STEP_CA_PATH}/certs/root_ca.crt" --short 2>/dev/null | sed 's/^/  /'
+        fi
     fi
     
     # Show provisioners
@@ -510,12 +518,18 @@ OPTIONS
     --s3-bucket BUCKET    S3/Wasabi bucket name
     --s3-endpoint URL     S3 endpoint URL (for Wasabi: https://s3.wasabisys.com)
     --s3-prefix PREFIX    S3 key prefix (default: auto-ssl/)
+    --s3-region REGION    S3 region, if the endpoint does not name it
+    --all-destinations    Also upload to every destination in backup.destinations
+    --verify              Verify the backup before storing it
     -h, --help            Show this help
 
 EXAMPLES
     # Local backup
     sudo auto-ssl ca backup --output /backup/ca-backup.enc
 
+    # Local backup, checked to be restorable first
+    sudo auto-ssl ca backup --output /backup/ca-backup.enc --verify
+
     # Backup to rsync target
     sudo auto-ssl ca backup \
         --output ca-backup.enc \
@@ -529,6 +543,27 @@ EXAMPLES
         --s3-bucket my-backups \
         --s3-endpoint https://s3.wasabisys.com
 
+    # Local backup, also uploaded to every configured destination
+    sudo auto-ssl ca backup \
+        --output /var/backups/auto-ssl/ca-backup-$(date +%Y%m%d-%H%M%S).enc \
+        --all-destinations
+
+    # Backup to a local MinIO
+    sudo auto-ssl ca backup \
+        --output ca-backup.enc \
+        --dest-type s3 \
+        --s3-bucket ca-backups \
+        --s3-endpoint http://127.0.0.1:9000
+
+S3 uploads are signed by the auto-ssl binary and do not need the AWS CLI.
+Credentials come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or from
+~/.aws/credentials (profile AWS_PROFILE, default "default").
+
+With --all-destinations the backup is encrypted once, saved at --output and
+uploaded to every destination in backup.destinations. A destination that
+fails does not stop the others; the command then reports which failed and
+exits non-zero.
+
 HELP
 }
 
@@ -540,6 +575,9 @@ cmd_ca_backup() {
     local s3_bucket=""
     local s3_endpoint=""
     local s3_prefix="auto-ssl/"
+    local s3_region=""
+    local all_destinations=false
+    local verify=false
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
@@ -572,6 +610,18 @@ cmd_ca_backup() {
                 s3_prefix="$2"
                 shift 2
                 ;;
+            --s3-region)
+                s3_region="$2"
+                shift 2
+                ;;
+            --all-destinations)
+                all_destinations=true
+                shift
+                ;;
+            --verify)
+                verify=true
+                shift
+                ;;
             -h|--help)
                 cmd_ca_backup_help
                 return 0
@@ -586,6 +636,12 @@ cmd_ca_backup() {
     
     [[ -z "$output" ]] && die "Output file required. Use --output FILE"
     require_dir "${STEP_CA_PATH}" "CA directory"
+    [[ "$verify" == true ]] && require_companion "backup verification"
+    [[ "$dest_type" == "s3" ]] && require_companion "S3 uploads"
+    if [[ "$all_destinations" == true ]]; then
+        [[ "$dest_type" != "local" ]] && die "Use --dest-type or --all-destinations, not both"
+        require_companion "uploads to every destination"
+    fi
     
     log_header "Creating CA Backup"
     
@@ -613,9 +669,21 @@ cmd_ca_backup() {
     log_step "Stopping CA for consistent backup..."
     systemctl stop step-ca || true
     
-    # Create metadata
-    log_step "Creating backup metadata..."
-    cat > "${tmp_dir}/metadata.json" << EOF
+    # The passphrase goes over a pipe, never on a command line
+    if has_companion; then
+        log_step "Creating encrypted backup..."
+        if ! "$AUTO_SSL_BIN" tools backup create --output "${tmp_dir}/backup.enc" \
+            --passphrase-fd 3 3< <(printf '%s' "$passphrase") | sed 's/^/  /'; then
+            systemctl start step-ca
+            die "Backup failed"
+        fi
+        
+        log_step "Restarting CA..."
+        systemctl start step-ca
+    else
+        # Legacy format: tar + openssl, without integrity checks
+        log_step "Creating backup metadata..."
+        cat > "${tmp_dir}/metadata.json" << EOF
 {
     "version": "${AUTO_SSL_VERSION}",
     "timestamp": "$(date -u +"%Y-%m-%dT%H:%M:%SZ")",
@@ -625,27 +693,33 @@ cmd_ca_backup() {
     "ip_address": "$(get_primary_ip || echo 'unknown')"
 }
 EOF
+        
+        log_step "Copying CA data..."
+        cp -a "${STEP_CA_PATH}" "${tmp_dir}/step-ca"
+        cp -a "${AUTO_SSL_CONFIG_DIR}" "${tmp_dir}/config"
+        
+        log_step "Restarting CA..."
+        systemctl start step-ca
+        
+        log_step "Creating archive..."
+        local archive="${tmp_dir}/backup.tar.gz"
+        tar -czf "$archive" -C "$tmp_dir" metadata.json step-ca config
+        
+        log_step "Encrypting backup..."
+        log_warning "The auto-ssl binary is not installed; writing a legacy backup without integrity checks"
+        openssl enc -aes-256-cbc -salt -pbkdf2 \
+            -in "$archive" \
+            -out "${tmp_dir}/backup.enc" \
+            -pass fd:3 3< <(printf '%s' "$passphrase")
+    fi
     
-    # Copy CA data
-    log_step "Copying CA data..."
-    cp -a "${STEP_CA_PATH}" "${tmp_dir}/step-ca"
-    cp -a "${AUTO_SSL_CONFIG_DIR}" "${tmp_dir}/config"
-    
-    # Restart CA
-    log_step "Restarting CA..."
-    systemctl start step-ca
-    
-    # Create archive
-    log_step "Creating archive..."
-    local archive="${tmp_dir}/backup.tar.gz"
-    tar -czf "$archive" -C "$tmp_dir" metadata.json step-ca config
-    
-    # Encrypt archive
-    log_step "Encrypting backup..."
-    openssl enc -aes-256-cbc -salt -pbkdf2 \
-        -in "$archive" \
-        -out "${tmp_dir}/backup.enc" \
-        -pass "pass:${passphrase}"
+    # Check the backup could be restored before it replaces anything
+    if [[ "$verify" == true ]]; then
+        log_step "Verifying backup..."
+        "$AUTO_SSL_BIN" tools backup verify "${tmp_dir}/backup.enc" --name "${output##*/}" \
+            --passphrase-fd 3 3< <(printf '%s' "$passphrase") | sed 's/^/  /' \
+            || die "Backup verification failed; the backup was not stored"
+    fi
 
     local size
     size=$(du -h "${tmp_dir}/backup.enc" | cut -f1)
@@ -667,21 +741,39 @@ EOF
             ;;
         s3)
             [[ -z "$s3_bucket" ]] && die "S3 bucket required. Use --s3-bucket"
-            require_command "aws" "Install AWS CLI: pip install awscli"
             log_step "Uploading to S3 bucket ${s3_bucket}..."
-            local aws_args=()
-            [[ -n "$s3_endpoint" ]] && aws_args+=(--endpoint-url "$s3_endpoint")
-            aws "${aws_args[@]}" s3 cp "${tmp_dir}/backup.enc" "s3://${s3_bucket}/${s3_prefix}${output##*/}"
+            local s3_args=(--s3-bucket "$s3_bucket" --s3-prefix "$s3_prefix")
+            [[ -n "$s3_endpoint" ]] && s3_args+=(--s3-endpoint "$s3_endpoint")
+            [[ -n "$s3_region" ]] && s3_args+=(--s3-region "$s3_region")
+            "$AUTO_SSL_BIN" tools backup upload "${tmp_dir}/backup.enc" --name "${output##*/}" \
+                --dest-type s3 "${s3_args[@]}" | sed 's/^/  /' \
+                || die "S3 upload failed"
             ;;
         *)
             die "Unknown destination type: $dest_type"
             ;;
     esac
     
+    # One encrypted archive, uploaded everywhere at once
+    local upload_failed=false
+    if [[ "$all_destinations" == true ]]; then
+        log_step "Uploading to every destination in backup.destinations..."
+        "$AUTO_SSL_BIN" tools backup upload "$output" --all | sed 's/^/  /' || upload_failed=true
+    fi
+    
+    if [[ "$upload_failed" == true ]]; then
+        echo ""
+        die "Backup saved to ${output}, but not stored at every destination (see above)"
+    fi
+    
     echo ""
     log_success "Backup created successfully!"
     echo ""
-    echo "  Destination: ${dest_type}"
+    if [[ "$all_destinations" == true ]]; then
+        echo "  Destination: ${dest_type} and backup.destinations"
+    else
+        echo "  Destination: ${dest_type}"
+    fi
     echo "  Output:      ${output}"
     echo "  Size:        ${size}"
     echo ""
@@ -703,12 +795,16 @@ OPTIONS
     --input FILE          Input backup file (required)
     --passphrase-file F   Read decryption passphrase from file
     --new-address ADDR    Use new address (if CA IP changed)
+    --dry-run             Verify the backup without restoring it
     -h, --help            Show this help
 
 EXAMPLES
     # Restore from local backup
     sudo auto-ssl ca restore --input /backup/ca-backup.enc
 
+    # Check a backup could be restored, leaving the CA untouched
+    sudo auto-ssl ca restore --input /backup/ca-backup.enc --dry-run
+
     # Restore with new IP address
     sudo auto-ssl ca restore \
         --input /backup/ca-backup.enc \
@@ -721,6 +817,7 @@ cmd_ca_restore() {
     local input=""
     local passphrase_file=""
     local new_address=""
+    local dry_run=false
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
@@ -737,6 +834,10 @@ cmd_ca_restore() {
                 new_address="$2"
                 shift 2
                 ;;
+            --dry-run)
+                dry_run=true
+                shift
+                ;;
             -h|--help)
                 cmd_ca_restore_help
                 return 0
@@ -763,6 +864,20 @@ cmd_ca_restore() {
         passphrase=$(ui_password "Enter backup passphrase")
     fi
     
+    if [[ "$dry_run" == true ]]; then
+        if [[ "$(head -c 8 "$input")" == "Salted__" ]]; then
+            die "Legacy backups cannot be verified. Restore this one, then take a new backup."
+        fi
+        require_companion "backup verification"
+        log_step "Verifying backup..."
+        "$AUTO_SSL_BIN" tools backup verify "$input" \
+            --passphrase-fd 3 3< <(printf '%s' "$passphrase") | sed 's/^/  /' \
+            || die "Backup verification failed"
+        echo ""
+        log_success "Backup verified. Nothing was restored (dry run)."
+        return 0
+    fi
+    
     # Check for existing CA
     if [[ -d "${STEP_CA_PATH}" ]]; then
         log_warning "Existing CA found at ${STEP_CA_PATH}"
@@ -781,18 +896,26 @@ cmd_ca_restore() {
     cleanup_add "rm -rf '$tmp_dir'"
     
     # Decrypt backup
-    log_step "Decrypting backup..."
-    if ! openssl enc -d -aes-256-cbc -pbkdf2 \
-        -in "$input" \
-        -out "${tmp_dir}/backup.tar.gz" \
-        -pass "pass:${passphrase}" 2>/dev/null; then
-        die "Failed to decrypt backup. Wrong passphrase?"
+    if [[ "$(head -c 8 "$input")" == "Salted__" ]]; then
+        # Legacy openssl backup, from before auto-ssl wrote its own format
+        log_step "Decrypting legacy backup..."
+        if ! openssl enc -d -aes-256-cbc -pbkdf2 \
+            -in "$input" \
+            -out "${tmp_dir}/backup.tar.gz" \
+            -pass fd:3 3< <(printf '%s' "$passphrase") 2>/dev/null; then
+            die "Failed to decrypt backup. Wrong passphrase?"
+        fi
+        
+        log_step "Extracting archive..."
+        tar -xzf "${tmp_dir}/backup.tar.gz" -C "$tmp_dir"
+    else
+        require_companion "encrypted backups"
+        log_step "Decrypting and verifying backup..."
+        "$AUTO_SSL_BIN" tools backup extract "$input" --output "$tmp_dir" \
+            --passphrase-fd 3 3< <(printf '%s' "$passphrase") >/dev/null \
+            || die "Failed to restore ${input}"
     fi
     
-    # Extract archive
-    log_step "Extracting archive..."
-    tar -xzf "${tmp_dir}/backup.tar.gz" -C "$tmp_dir"
-    
     # Show metadata
     if [[ -f "${tmp_dir}/metadata.json" ]]; then
         echo ""
@@ -899,22 +1022,38 @@ OPTIONS
     --disable             Disable scheduled backups
     --schedule SCHEDULE   Backup schedule: daily, weekly, monthly (default: weekly)
     --output DIR          Backup output directory (default: /var/backups/auto-ssl)
-    --retention NUM       Number of backups to keep (default: 4)
+    --retention NUM       Number of newest backups to keep (default: 4)
+    --keep-daily NUM      Also keep the newest backup of each of the last NUM days
+    --keep-weekly NUM     Also keep the newest backup of each of the last NUM weeks
+    --keep-monthly NUM    Also keep the newest backup of each of the last NUM months
     --passphrase-file F   Passphrase file for encryption
+    --verify              Verify each backup before storing it
     -h, --help            Show this help
 
 EXAMPLES
     # Enable weekly backups
     sudo auto-ssl ca backup-schedule --enable --schedule weekly
 
+    # Enable weekly backups, each checked to be restorable
+    sudo auto-ssl ca backup-schedule --enable --verify
+
     # Enable daily backups with passphrase
     sudo auto-ssl ca backup-schedule --enable \
         --schedule daily \
         --passphrase-file /etc/auto-ssl/backup-passphrase
 
+    # Daily backups: the last 7 days, 4 weeks and 12 months
+    sudo auto-ssl ca backup-schedule --enable --schedule daily \
+        --retention 7 --keep-weekly 4 --keep-monthly 12
+
     # Disable backups
     sudo auto-ssl ca backup-schedule --disable
 
+Each backup is written to the output directory and, with the auto-ssl
+binary, uploaded to every destination in backup.destinations. The
+retention policy applies to all of them. List what each one holds with:
+    auto-ssl tools backup list
+
 HELP
 }
 
@@ -924,7 +1063,11 @@ cmd_ca_backup_schedule() {
     local schedule="weekly"
     local output_dir="/var/backups/auto-ssl"
     local retention=4
+    local keep_daily=0
+    local keep_weekly=0
+    local keep_monthly=0
     local passphrase_file=""
+    local verify=false
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
@@ -949,10 +1092,26 @@ cmd_ca_backup_schedule() {
                 retention="$2"
                 shift 2
                 ;;
+            --keep-daily)
+                keep_daily="$2"
+                shift 2
+                ;;
+            --keep-weekly)
+                keep_weekly="$2"
+                shift 2
+                ;;
+            --keep-monthly)
+                keep_monthly="$2"
+                shift 2
+                ;;
             --passphrase-file)
                 passphrase_file="$2"
                 shift 2
                 ;;
+            --verify)
+                verify=true
+                shift
+                ;;
             -h|--help)
                 cmd_ca_backup_schedule_help
                 return 0
@@ -978,6 +1137,15 @@ cmd_ca_backup_schedule() {
     
     # Enable backups
     log_header "Configuring Automatic Backups"
+    [[ "$verify" == true ]] && require_companion "backup verification"
+    
+    local keep
+    for keep in "$retention" "$keep_daily" "$keep_weekly" "$keep_monthly"; do
+        [[ "$keep" =~ ^[0-9]+$ ]] || die "Retention counts must be whole numbers, got: $keep"
+    done
+    if [[ "$keep_daily" != 0 || "$keep_weekly" != 0 || "$keep_monthly" != 0 ]]; then
+        require_companion "daily, weekly and monthly retention"
+    fi
     
     # Validate schedule
     local calendar_spec
@@ -1027,20 +1195,45 @@ set -euo pipefail
 OUTPUT_DIR="__OUTPUT_DIR__"
 RETENTION=__RETENTION__
 PASSPHRASE_FILE="__PASSPHRASE_FILE__"
+VERIFY=__VERIFY__
+
+# Look for the auto-ssl binary on every run, not when the schedule was set
+# up, so installing or removing it later takes effect. Either auto-ssl is
+# the binary itself, or an ejected runtime has auto-ssl-tui next to it.
+if /usr/local/bin/auto-ssl tools --help >/dev/null 2>&1; then
+    TOOLS_BIN=/usr/local/bin/auto-ssl
+else
+    TOOLS_BIN=$(command -v auto-ssl-tui 2>/dev/null || true)
+fi
 
 # Generate filename with timestamp
 FILENAME="ca-backup-$(date +%Y%m%d-%H%M%S).enc"
 OUTPUT="${OUTPUT_DIR}/${FILENAME}"
 
-# Run backup
-/usr/local/bin/auto-ssl ca backup \
-    --output "$OUTPUT" \
-    --passphrase-file "$PASSPHRASE_FILE"
-
-# Rotate old backups
-cd "$OUTPUT_DIR"
-ls -t ca-backup-*.enc 2>/dev/null | tail -n +$((RETENTION + 1)) | xargs -r rm -f
-
+# Run backup, uploaded to every destination in backup.destinations when
+# the auto-ssl binary is there; a backup that fails verification is not
+# stored anywhere
+BACKUP_ARGS=(--output "$OUTPUT" --passphrase-file "$PASSPHRASE_FILE")
+[[ "$VERIFY" == true ]] && BACKUP_ARGS+=(--verify)
+[[ -n "$TOOLS_BIN" ]] && BACKUP_ARGS+=(--all-destinations)
+STATUS=0
+/usr/local/bin/auto-ssl ca backup "${BACKUP_ARGS[@]}" || STATUS=$?
+[[ -f "$OUTPUT" ]] || exit "$STATUS"
+
+# Apply the retention policy (backup.retention, backup.keep_*) to every
+# destination, or rotate the output directory without the auto-ssl binary.
+# A destination that failed is still pruned at the others.
+if [[ -n "$TOOLS_BIN" ]]; then
+    "$TOOLS_BIN" tools backup prune || STATUS=$?
+else
+    cd "$OUTPUT_DIR"
+    ls -t ca-backup-*.enc 2>/dev/null | tail -n +$((RETENTION + 1)) | xargs -r rm -f
+fi
+
+if [[ "$STATUS" -ne 0 ]]; then
+    echo "Backup saved to ${OUTPUT}, but a destination failed (see above)" >&2
+    exit "$STATUS"
+fi
 echo "Backup complete: ${OUTPUT}"
 SCRIPT
     
@@ -1048,6 +1241,7 @@ SCRIPT
     sed -i "s|__OUTPUT_DIR__|${output_dir}|g" "$backup_script"
     sed -i "s|__RETENTION__|${retention}|g" "$backup_script"
     sed -i "s|__PASSPHRASE_FILE__|${passphrase_file}|g" "$backup_script"
+    sed -i "s|__VERIFY__|${verify}|g" "$backup_script"
     chmod 755 "$backup_script"
     
     # Create systemd service
@@ -1091,15 +1285,21 @@ EOF
     config_set "backup.schedule" "$schedule"
     config_set "backup.output_dir" "$output_dir"
     config_set "backup.retention" "$retention"
+    config_set "backup.keep_daily" "$keep_daily"
+    config_set "backup.keep_weekly" "$keep_weekly"
+    config_set "backup.keep_monthly" "$keep_monthly"
     config_set "backup.passphrase_file" "$passphrase_file"
+    config_set "backup.verify" "$verify"
     
     echo ""
     log_success "Automatic backups configured!"
     echo ""
     echo "  Schedule:      ${schedule} (${calendar_spec})"
     echo "  Output:        ${output_dir}"
-    echo "  Retention:     ${retention} backups"
+    _backup_destinations | sed 's/^/  Upload:        /'
+    echo "  Retention:     last ${retention}, daily ${keep_daily}, weekly ${keep_weekly}, monthly ${keep_monthly}"
     echo "  Passphrase:    ${passphrase_file}"
+    echo "  Verify:        ${verify}"
     echo ""
     echo "Next backup:"
     systemctl list-timers auto-ssl-backup.timer --no-pager | tail -2
@@ -1133,13 +1333,19 @@ _show_backup_schedule_status() {
         echo ""
         echo "  Schedule:      $(config_get 'backup.schedule' 'weekly')"
         echo "  Output:        $(config_get 'backup.output_dir' '/var/backups/auto-ssl')"
-        echo "  Retention:     $(config_get 'backup.retention' '4') backups"
+        echo "  Retention:     last $(config_get 'backup.retention' '4'), daily $(config_get 'backup.keep_daily' '0'), weekly $(config_get 'backup.keep_weekly' '0'), monthly $(config_get 'backup.keep_monthly' '0')"
+        _backup_destinations | sed 's/^/  Upload:        /'
+        echo "  Verify:        $(config_get 'backup.verify' 'false')"
         echo ""
         echo "Timer Status:"
         systemctl status auto-ssl-backup.timer --no-pager 2>/dev/null | head -5 | sed 's/^/  /'
         echo ""
         echo "Next backup:"
         systemctl list-timers auto-ssl-backup.timer --no-pager 2>/dev/null | tail -2 | sed 's/^/  /'
+        if has_companion; then
+            echo ""
+            echo "List backups at every destination with: auto-ssl tools backup list"
+        fi
     else
         log_warning "Automatic backups: Disabled"
         echo ""
@@ -1147,6 +1353,168 @@ _show_backup_schedule_status() {
     fi
 }
 
+# Print each destination in backup.destinations, e.g. "s3 s3://bucket/ca".
+# Only the auto-ssl binary reads them, and only it uploads.
+_backup_destinations() {
+    has_companion || return 0
+    
+    local i=0 dest_type
+    while dest_type=$("$AUTO_SSL_BIN" tools config get "backup.destinations.${i}.type" --config "${AUTO_SSL_CONFIG_FILE}" 2>/dev/null); do
+        local key="backup.destinations.${i}"
+        case "$dest_type" in
+            local) echo "local $(config_get "${key}.path" "")" ;;
+            rsync) echo "rsync $(config_get "${key}.target" "")" ;;
+            s3)
+                local prefix
+                prefix=$(config_get "${key}.prefix" "")
+                echo "s3 s3://$(config_get "${key}.bucket" "")${prefix:+/${prefix}}"
+                ;;
+            *) echo "$dest_type" ;;
+        esac
+        i=$(( i + 1 ))
+    done
+    return 0
+}
+
+#--------------------------------------------------
+# CA Token
+#--------------------------------------------------
+
+cmd_ca_token_help() {
+    cat << 'HELP'
+auto-ssl ca token - Mint a one-time enrollment token
+
+The token lets one server get one certificate for the given SANs without
+knowing the provisioner password. step-ca accepts each token only once.
+
+USAGE
+    auto-ssl ca token --san NAME [options]
+
+OPTIONS
+    --san NAME            SAN the certificate may have (can repeat; the first
+                          is the certificate subject)
+    --ttl DURATION        How long the token is valid, e.g. 30m or 24h
+                          (default: step's own, 5 minutes)
+    --provisioner NAME    Provisioner name (default: admin)
+    -h, --help            Show this help
+
+    The token is printed on stdout and its expiry on stderr. Pass it to the
+    server with 'auto-ssl server enroll --token-file FILE' (or --token TOKEN).
+
+    --ttl sets the lifetime of the token, not of the certificate it gets.
+    Only step CLI versions whose 'step ca token' has --cert-not-after can
+    set it; older ones always mint 5-minute tokens. The expiry of the token
+    step returns is checked, and a token shorter-lived than --ttl is
+    refused rather than printed.
+
+EXAMPLES
+    # Token for one server, used right away
+    sudo auto-ssl ca token --san web1.internal --san 192.168.1.50
+
+    # Token to hand over, valid for a day
+    sudo auto-ssl ca token --san 192.168.1.50 --ttl 24h > token
+
+    # Enroll a server with it
+    sudo auto-ssl ca token --san 192.168.1.50 > token
+    sudo auto-ssl server enroll --token-file token
+
+HELP
+}
+
+cmd_ca_token() {
+    local sans=()
+    local ttl=""
+    local provisioner="admin"
+    
+    # Parse arguments
+    while [[ $# -gt 0 ]]; do
+        case "$1" in
+            --san)
+                sans+=("$2")
+                shift 2
+                ;;
+            --ttl)
+                ttl="$2"
+                shift 2
+                ;;
+            --provisioner)
+                provisioner="$2"
+                shift 2
+                ;;
+            -h|--help)
+                cmd_ca_token_help
+                return 0
+                ;;
+            *)
+                die_with_help "Unknown option: $1" "ca token"
+                ;;
+        esac
+    done
+    
+    [[ ${#sans[@]} -eq 0 ]] && die "At least one SAN required. Use --san NAME"
+    if [[ -n "$ttl" ]]; then
+        duration_to_seconds "$ttl" >/dev/null || die "Invalid --ttl: ${ttl} (use e.g. 30m or 24h)"
+    fi
+    
+    if ! is_ca_server; then
+        die "This command must be run from the CA server"
+    fi
+    
+    _ca_token "$ttl" "$provisioner" "${sans[@]}"
+}
+
+# _ca_token TTL PROVISIONER SAN...
+# Prints a one-time token for the SANs, signed with the provisioner
+# password kept on the CA server. The password never leaves this machine.
+# An empty TTL takes step's default lifetime.
+_ca_token() {
+    local ttl="$1"
+    local provisioner="$2"
+    shift 2
+    
+    local ca_url
+    ca_url=$(config_get "ca.url" "")
+    [[ -z "$ca_url" ]] && die "CA URL not configured"
+    
+    local pw_file="${AUTO_SSL_CONFIG_DIR}/ca-password"
+    [[ -r "$pw_file" ]] || die "Cannot read the provisioner password: ${pw_file} (run as root)"
+    
+    local san_args=()
+    local san
+    for san in "$@"; do
+        san_args+=(--san "$san")
+    done
+    
+    # step ca token --not-after is the token's expiry only in versions that
+    # also have --cert-not-after; older ones apply it to the certificate
+    local ttl_args=()
+    if [[ -n "$ttl" ]] && step ca token --help 2>/dev/null | grep -q -- '--cert-not-after'; then
+        ttl_args=(--not-after "$ttl")
+    fi
+    
+    local token
+    token=$(step ca token "$1" "${san_args[@]}" \
+        --provisioner "$provisioner" \
+        --password-file "$pw_file" \
+        --ca-url "$ca_url" \
+        --root "${STEP_CA_PATH}/certs/root_ca.crt" \
+        "${ttl_args[@]}") || die "Cannot create a token for: $*"
+    
+    # Report the lifetime the token has, not the one asked for
+    local expires
+    expires=$(token_expiry "$token") || die "step returned a token without an expiry"
+    if [[ -n "$ttl" ]]; then
+        local wanted
+        wanted=$(duration_to_seconds "$ttl") || die "Invalid --ttl: ${ttl}"
+        # A minute of slack for the time step took
+        if (( expires - $(date +%s) < wanted - 60 )); then
+            die "step minted a token that expires at $(date -u -d "@${expires}" +"%Y-%m-%dT%H:%M:%SZ"), sooner than --ttl ${ttl}. This step CLI cannot set token lifetimes; upgrade it or use a shorter --ttl."
+        fi
+    fi
+    log_info "Token valid until $(date -u -d "@${expires}" +"%Y-%m-%dT%H:%M:%SZ")" >&2
+    echo "$token"
+}
+
 #--------------------------------------------------
 # Helper functions
 #--------------------------------------------------
@@ -18,12 +18,31 @@ USAGE
 SUBCOMMANDS
     enroll          Enroll a remote server via SSH
     status          Check remote server certificate status
+    renew           Renew certificates on remote servers
     update-ca-url   Update CA URL on enrolled servers (after CA migration)
     list            List enrolled servers
 
+SELECTING SERVERS
+    Servers in the inventory can carry a group and labels (set at enrollment
+    or with 'auto-ssl tools inventory label'). status, update-ca-url and list
+    accept these to operate on a slice of the fleet:
+
+    --group GROUP         Only servers in GROUP
+    --selector SELECTOR   Only servers whose labels match SELECTOR, e.g.
+                          env=prod,role!=db (KEY, !KEY, KEY=VALUE, KEY!=VALUE;
+                          all terms must match)
+
+    Operations on several servers run in parallel and end with a summary of
+    succeeded, failed and unreachable hosts; the exit status is non-zero when
+    any host did not succeed:
+
+    --parallel N          Hosts to work on at once (default: 10)
+    --timeout DURATION    Give up on a host after DURATION (default: 2m)
+    --log-dir DIR         Save each host's full output to DIR/HOST.log
+
 PREREQUISITES
     - SSH key-based authentication to target servers
-    - sudo access on target servers
+    - sudo access on target servers, or root login (--privilege root)
     - Network access from CA to target servers
 
 EXAMPLES
@@ -33,6 +52,9 @@ EXAMPLES
     # Check status of remote server
     auto-ssl remote status --host 192.168.1.50 --user ryan
 
+    # Check every production server outside the database tier
+    auto-ssl remote status --selector env=prod,role!=db
+
     # Update CA URL on all servers after migration
     auto-ssl remote update-ca-url --new-url https://192.168.1.200:9000
 
@@ -49,56 +71,156 @@ cmd_remote() {
 
 INVENTORY_FILE="${AUTO_SSL_CONFIG_DIR}/servers.yaml"
 
+# The inventory is only written through the Go companion, which locks the
+# file and saves atomically so concurrent enrollments cannot corrupt it.
+_inventory() {
+    require_companion "the server inventory"
+    "$AUTO_SSL_BIN" tools inventory "$@"
+}
+
 _inventory_add() {
     local host="$1"
     local name="$2"
     local user="$3"
+    shift 3
     
-    ensure_dirs
+    _inventory add "$host" --name "$name" --user "$user" "$@"
+}
+
+_inventory_list() {
+    _inventory list "$@"
+}
+
+# Prints one tab-separated line per server matching the selection arguments
+# (--group/--selector, passed through to the inventory):
+#   host user name port identity proxy_jump privilege host_key
+# Empty values are "-".
+_inventory_targets() {
+    _inventory list --format tsv "$@"
+}
+
+# _inventory_load HOST
+# Sets INV_USER, INV_PORT, INV_IDENTITY, INV_PROXY_JUMP, INV_PRIVILEGE and
+# INV_HOST_KEY from the inventory entry for HOST. They are empty when the host
+# is not enrolled.
+_inventory_load() {
+    local host="$1"
+    local line=""
     
-    # Simple YAML append/update (proper YAML library would be better)
-    if [[ ! -f "$INVENTORY_FILE" ]]; then
-        echo "servers:" > "$INVENTORY_FILE"
+    INV_USER="" INV_PORT="" INV_IDENTITY="" INV_PROXY_JUMP="" INV_PRIVILEGE="" INV_HOST_KEY=""
+    if [[ -f "$INVENTORY_FILE" ]] && has_companion; then
+        line=$(_inventory_targets | awk -F'\t' -v host="$host" '$1 == host')
     fi
+    [[ -z "$line" ]] && return 0
+    
+    local _h _name
+    IFS=$'\t' read -r _h INV_USER _name INV_PORT INV_IDENTITY INV_PROXY_JUMP INV_PRIVILEGE INV_HOST_KEY <<< "$line"
+    [[ "$INV_USER" == "-" ]] && INV_USER=""
+    [[ "$INV_IDENTITY" == "-" ]] && INV_IDENTITY=""
+    [[ "$INV_PROXY_JUMP" == "-" ]] && INV_PROXY_JUMP=""
+    [[ "$INV_HOST_KEY" == "-" ]] && INV_HOST_KEY=""
+    return 0
+}
 
-    # Remove existing block for this host if present
-    if grep -q "host: ${host}$" "$INVENTORY_FILE" 2>/dev/null; then
-        log_info "Server ${host} already in inventory, updating..."
-        local tmp_inventory
-        tmp_inventory=$(mktemp)
-        awk -v host="$host" '
-            /^  - host: / {
-                skip = ($0 ~ "^  - host: " host "$")
-            }
-            skip && /^  - host: / && $0 !~ "^  - host: " host "$" {
-                skip = 0
-            }
-            !skip { print }
-        ' "$INVENTORY_FILE" > "$tmp_inventory"
-        mv "$tmp_inventory" "$INVENTORY_FILE"
-    fi
+# Runs a remote operation across the selected inventory servers in parallel
+# (see `auto-ssl tools fleet`). Each host runs the single-host form of the
+# command, `auto-ssl remote OPERATION --host HOST`.
+_fleet() {
+    require_companion "operations on several servers"
+    "$AUTO_SSL_BIN" tools fleet "$@"
+}
+
+#--------------------------------------------------
+# Connection helpers
+#--------------------------------------------------
+
+# Single-host commands exit with this ("network error") when SSH cannot
+# connect, so the fleet runner can report the host as unreachable.
+REMOTE_UNREACHABLE=5
+
+# Per-host connection settings, filled in by _remote_connect.
+REMOTE_CONN_ARGS=()
+REMOTE_SUDO="sudo "
+
+# Set by --accept-new-hostkey: connect to a server whose host key no longer
+# matches the one pinned in the inventory, and pin the new key.
+REMOTE_ACCEPT_NEW_HOSTKEY=false
+
+# _remote_connect PORT IDENTITY PROXY_JUMP PRIVILEGE
+# Builds the connection options and the sudo prefix for one host. Empty or
+# "-" values use the defaults: port 22, the ssh-agent and default keys, no
+# jump host and sudo. Connections go through `auto-ssl tools ssh`, which
+# checks host keys against the key pinned in the inventory, or else
+# ${AUTO_SSL_CONFIG_DIR}/known_hosts.
+_remote_connect() {
+    local port="${1:--}"
+    local identity="${2:--}"
+    local proxy_jump="${3:--}"
+    local privilege="${4:--}"
     
-    cat >> "$INVENTORY_FILE" << EOF
-  - host: ${host}
-    name: ${name}
-    user: ${user}
-    enrolled: true
-    enrolled_at: $(date -u +"%Y-%m-%dT%H:%M:%SZ")
-EOF
+    require_companion "remote commands over SSH"
     
-    chmod 600 "$INVENTORY_FILE"
+    [[ "$port" == "-" ]] && port="22"
+    REMOTE_CONN_ARGS=(--port "$port")
+    [[ "$identity" != "-" ]] && REMOTE_CONN_ARGS+=(--identity "$identity")
+    [[ "$proxy_jump" != "-" ]] && REMOTE_CONN_ARGS+=(--proxy-jump "$proxy_jump")
+    [[ "$REMOTE_ACCEPT_NEW_HOSTKEY" == true ]] && REMOTE_CONN_ARGS+=(--accept-new-hostkey)
+    
+    case "$privilege" in
+        sudo|-) REMOTE_SUDO="sudo " ;;
+        root)   REMOTE_SUDO="" ;;
+        *)      die "Unknown privilege mode: ${privilege} (expected sudo or root)" ;;
+    esac
 }
 
-_inventory_list() {
-    if [[ ! -f "$INVENTORY_FILE" ]]; then
-        echo "No servers enrolled"
-        return
+# _remote_check TARGET [TIMEOUT]
+# Prints the host key fingerprint TARGET (user@host) presented. Fails with
+# REMOTE_UNREACHABLE, after logging why, when it does not accept an SSH
+# connection within TIMEOUT (default 10s) or its host key does not match.
+_remote_check() {
+    local target="$1"
+    local timeout="${2:-10s}"
+    local out line
+    
+    if ! out=$("$AUTO_SSL_BIN" tools ssh check "${REMOTE_CONN_ARGS[@]}" --connect-timeout "$timeout" "$target" 2>&1); then
+        while IFS= read -r line; do
+            log_error "${line#auto-ssl: }"
+        done <<< "$out"
+        return "$REMOTE_UNREACHABLE"
     fi
+    # The fingerprint is the last line; anything before it is a notice
+    while IFS= read -r line; do
+        [[ "$line" == auto-ssl:* ]] && log_warning "${line#auto-ssl: }"
+    done <<< "$out"
+    echo "${out##*$'\n'}"
+}
+
+# _remote_keyscan TARGET
+# Prints the host key fingerprint TARGET presents, without trusting it.
+_remote_keyscan() {
+    local out
     
-    # Simple parsing (proper YAML library would be better)
-    grep -E "^\s+-\s*host:|^\s+name:|^\s+enrolled:|^\s+user:" "$INVENTORY_FILE" | \
-    paste - - - - | \
-    sed 's/host:/\nHost:/g; s/name:/Name:/g; s/enrolled:/Enrolled:/g; s/user:/User:/g'
+    if ! out=$("$AUTO_SSL_BIN" tools ssh keyscan "${REMOTE_CONN_ARGS[@]}" "$1" 2>&1); then
+        log_error "${out#auto-ssl: }"
+        return "$REMOTE_UNREACHABLE"
+    fi
+    echo "$out"
+}
+
+# _remote_ssh TARGET COMMAND
+# Runs COMMAND on TARGET and returns its exit status. Local stdin is not
+# forwarded; use _remote_ssh_stdin for that.
+_remote_ssh() {
+    "$AUTO_SSL_BIN" tools ssh exec "${REMOTE_CONN_ARGS[@]}" "$1" -- "$2"
+}
+
+_remote_ssh_stdin() {
+    "$AUTO_SSL_BIN" tools ssh exec "${REMOTE_CONN_ARGS[@]}" --stdin "$1" -- "$2"
+}
+
+# _remote_upload TARGET LOCAL REMOTE
+_remote_upload() {
+    "$AUTO_SSL_BIN" tools ssh upload "${REMOTE_CONN_ARGS[@]}" "$1" "$2" "$3"
 }
 
 #--------------------------------------------------
@@ -117,14 +239,39 @@ OPTIONS
     --user USER           SSH username (required)
     --name NAME           Friendly name for the server (default: hostname)
     --port PORT           SSH port (default: 22)
-    --san NAME            Additional SAN for the certificate (can repeat)
-    --identity FILE       SSH identity file (default: ~/.ssh/id_rsa)
+    --san NAME            SAN for the certificate (can repeat, default: HOST)
+    --identity FILE       SSH identity file (default: ssh-agent and
+                          ~/.ssh/id_ed25519, id_ecdsa, id_rsa)
+    --proxy-jump HOST     Reach the server through a jump host (ssh -J syntax)
+    --privilege MODE      sudo (log in as USER, escalate with sudo; default) or
+                          root (USER is root, no sudo)
+    --host-key FP         Expected SSH host key fingerprint (SHA256:...),
+                          checked on first contact instead of asking; one
+                          that is not the pinned key is refused
+    --accept-new-hostkey  Accept and pin a host key that differs from the
+                          one recorded at enrollment (rebuilt server)
+    --group GROUP         Inventory group for the server
+    --label KEY=VALUE     Inventory label for the server (can repeat)
     -h, --help            Show this help
 
+    The server's host key fingerprint is pinned in the inventory on first
+    enrollment. Later connections refuse a different key.
+
+    The server gets a one-time token for its SANs (see 'auto-ssl ca token'),
+    so the provisioner password never leaves the CA.
+
 EXAMPLES
     # Basic enrollment
     auto-ssl remote enroll --host 192.168.1.50 --user ryan
 
+    # Through a bastion, logging in as root
+    auto-ssl remote enroll --host 10.0.5.20 --user root \
+        --proxy-jump admin@bastion.example.com --privilege root
+
+    # Enroll into a group with labels for later selection
+    auto-ssl remote enroll --host 192.168.1.50 --user ryan \
+        --group edge --label env=prod --label site=rack3
+
     # With custom name and additional SANs
     auto-ssl remote enroll \
         --host 192.168.1.50 \
@@ -139,9 +286,13 @@ cmd_remote_enroll() {
     local host=""
     local user=""
     local name=""
-    local port="22"
+    local port=""
     local sans=()
     local identity=""
+    local proxy_jump=""
+    local privilege=""
+    local host_key=""
+    local inventory_args=()
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
@@ -170,6 +321,26 @@ cmd_remote_enroll() {
                 identity="$2"
                 shift 2
                 ;;
+            --proxy-jump)
+                proxy_jump="$2"
+                shift 2
+                ;;
+            --privilege)
+                privilege="$2"
+                shift 2
+                ;;
+            --host-key)
+                host_key="$2"
+                shift 2
+                ;;
+            --accept-new-hostkey)
+                REMOTE_ACCEPT_NEW_HOSTKEY=true
+                shift
+                ;;
+            --group|--label)
+                inventory_args+=("$1" "$2")
+                shift 2
+                ;;
             -h|--help)
                 cmd_remote_enroll_help
                 return 0
@@ -182,13 +353,23 @@ cmd_remote_enroll() {
     
     # Validate
     [[ -z "$host" ]] && die "Host required. Use --host HOST"
-    [[ -z "$user" ]] && die "User required. Use --user USER"
-    [[ -z "$name" ]] && name="$host"
     
     # Check we're on the CA server
     if ! is_ca_server; then
         die "This command must be run from the CA server"
     fi
+    require_companion "the server inventory"
+    
+    # Re-enrolling a known host reuses its saved connection settings
+    _inventory_load "$host"
+    user="${user:-$INV_USER}"
+    port="${port:-${INV_PORT:-22}}"
+    identity="${identity:-$INV_IDENTITY}"
+    proxy_jump="${proxy_jump:-$INV_PROXY_JUMP}"
+    privilege="${privilege:-${INV_PRIVILEGE:-sudo}}"
+    [[ -z "$user" ]] && die "User required. Use --user USER"
+    [[ -z "$name" ]] && name="$host"
+    _remote_connect "$port" "$identity" "$proxy_jump" "$privilege"
     
     # Get CA info
     local ca_url
@@ -201,38 +382,28 @@ cmd_remote_enroll() {
     
     log_header "Remote Enrollment: ${host}"
     
-    # Build SSH/SCP options
-    local control_path="/tmp/auto-ssl-ssh-${user}@${host}-${port}"
-    local ssh_opts=(
-        -o "StrictHostKeyChecking=accept-new"
-        -o "ControlMaster=auto"
-        -o "ControlPersist=5m"
-        -o "ControlPath=${control_path}"
-        -p "$port"
-    )
-    local scp_opts=(
-        -o "StrictHostKeyChecking=accept-new"
-        -o "ControlMaster=auto"
-        -o "ControlPersist=5m"
-        -o "ControlPath=${control_path}"
-        -P "$port"
-    )
-    [[ -n "$identity" ]] && ssh_opts+=(-i "$identity")
-    [[ -n "$identity" ]] && scp_opts+=(-i "$identity")
-    
     local ssh_target="${user}@${host}"
     
+    # First enrollment: show the host key before trusting it
+    if [[ -z "$host_key" && -z "$INV_HOST_KEY" ]]; then
+        log_step "Fetching host key..."
+        host_key=$(_remote_keyscan "$ssh_target") || exit "$REMOTE_UNREACHABLE"
+        log_info "Host key for ${host}: ${host_key}"
+        if [[ -t 0 ]] && ! ui_confirm "Trust this host key?"; then
+            die "Host key not trusted. Compare it with 'ssh-keygen -lf /etc/ssh/ssh_host_ed25519_key.pub' on the server and pass --host-key"
+        fi
+    fi
+    [[ -n "$host_key" ]] && REMOTE_CONN_ARGS+=(--host-key "$host_key")
+    
     # Test SSH connection
     log_step "Testing SSH connection..."
-    if ! ssh "${ssh_opts[@]}" "$ssh_target" "echo 'SSH OK'" &>/dev/null; then
-        die "Cannot connect to ${ssh_target} via SSH"
-    fi
+    host_key=$(_remote_check "$ssh_target") || exit "$REMOTE_UNREACHABLE"
     log_success "SSH connection successful"
     
     # Detect remote OS
     log_step "Detecting remote OS..."
     local remote_os
-    remote_os=$(ssh "${ssh_opts[@]}" "$ssh_target" "cat /etc/os-release 2>/dev/null | grep ^ID= | cut -d= -f2 | tr -d '\"'" || echo "unknown")
+    remote_os=$(_remote_ssh "$ssh_target" "cat /etc/os-release 2>/dev/null | grep ^ID= | cut -d= -f2 | tr -d '\"'" || echo "unknown")
     log_info "Remote OS: ${remote_os}"
     
     # Copy auto-ssl runtime to remote host
@@ -255,51 +426,49 @@ cmd_remote_enroll() {
     cleanup_add "rm -f '$tmp_tar'"
     tar -C "$bundle_dir" -czf "$tmp_tar" .
 
-    scp "${scp_opts[@]}" "$tmp_tar" "${ssh_target}:/tmp/auto-ssl-runtime.tgz"
-    ssh "${ssh_opts[@]}" "$ssh_target" "rm -rf /tmp/auto-ssl-runtime && mkdir -p /tmp/auto-ssl-runtime && tar -xzf /tmp/auto-ssl-runtime.tgz -C /tmp/auto-ssl-runtime && chmod +x /tmp/auto-ssl-runtime/auto-ssl"
+    _remote_upload "$ssh_target" "$tmp_tar" /tmp/auto-ssl-runtime.tgz
+    _remote_ssh "$ssh_target" "rm -rf /tmp/auto-ssl-runtime && mkdir -p /tmp/auto-ssl-runtime && tar -xzf /tmp/auto-ssl-runtime.tgz -C /tmp/auto-ssl-runtime && chmod +x /tmp/auto-ssl-runtime/auto-ssl"
     
-    # Build SAN arguments
-    local san_args=""
+    # Mint a one-time token for the server's SANs
+    log_step "Creating enrollment token..."
+    [[ ${#sans[@]} -eq 0 ]] && sans=("$host")
+    local token_args=()
     for san in "${sans[@]}"; do
-        san_args+=" --san $san"
+        token_args+=(--san "$san")
     done
-    
-    # Get provisioner password
-    local pw_file="${AUTO_SSL_CONFIG_DIR}/ca-password"
-    local password=""
-    if [[ -f "$pw_file" ]]; then
-        password=$(cat "$pw_file")
-    else
-        password=$(ui_password "Enter provisioner password")
-    fi
+    local token
+    token=$("${script_dir}/../auto-ssl" ca token "${token_args[@]}") || die "Cannot create an enrollment token"
     
     # Run enrollment on remote server
     log_step "Running enrollment on remote server..."
     
-    # More secure: pass password via stdin to enrollment command
-    # Create enrollment command that reads password from stdin
-    local enroll_cmd="sudo /tmp/auto-ssl-runtime/auto-ssl server enroll \
+    # The token is written to stdin so it never appears on a command line
+    local enroll_cmd="${REMOTE_SUDO}/tmp/auto-ssl-runtime/auto-ssl server enroll \
         --ca-url '${ca_url}' \
         --fingerprint '${fingerprint}' \
-        --password-file /dev/stdin \
-        --non-interactive \
-        ${san_args}"
+        --token-file /dev/stdin \
+        --non-interactive"
     
-    # Pass password securely through stdin
-    if echo "$password" | ssh "${ssh_opts[@]}" "$ssh_target" "$enroll_cmd"; then
+    if printf '%s\n' "$token" | _remote_ssh_stdin "$ssh_target" "$enroll_cmd"; then
         log_success "Remote enrollment successful"
     else
-        ssh "${ssh_opts[@]}" "$ssh_target" "rm -rf /tmp/auto-ssl-runtime /tmp/auto-ssl-runtime.tgz" 2>/dev/null || true
+        _remote_ssh "$ssh_target" "rm -rf /tmp/auto-ssl-runtime /tmp/auto-ssl-runtime.tgz" 2>/dev/null || true
         die "Remote enrollment failed"
     fi
     
     # Install runtime to permanent location
     log_step "Installing auto-ssl on remote server..."
-    ssh "${ssh_opts[@]}" "$ssh_target" "sudo install -d /usr/local/bin/auto-ssl-lib /usr/local/bin/auto-ssl-commands && sudo install -m 755 /tmp/auto-ssl-runtime/auto-ssl /usr/local/bin/auto-ssl && sudo install -m 644 /tmp/auto-ssl-runtime/auto-ssl-lib/*.sh /usr/local/bin/auto-ssl-lib/ && sudo install -m 644 /tmp/auto-ssl-runtime/auto-ssl-commands/*.sh /usr/local/bin/auto-ssl-commands/ && rm -rf /tmp/auto-ssl-runtime /tmp/auto-ssl-runtime.tgz"
+    _remote_ssh "$ssh_target" "${REMOTE_SUDO}install -d /usr/local/bin/auto-ssl-lib /usr/local/bin/auto-ssl-commands && ${REMOTE_SUDO}install -m 755 /tmp/auto-ssl-runtime/auto-ssl /usr/local/bin/auto-ssl && ${REMOTE_SUDO}install -m 644 /tmp/auto-ssl-runtime/auto-ssl-lib/*.sh /usr/local/bin/auto-ssl-lib/ && ${REMOTE_SUDO}install -m 644 /tmp/auto-ssl-runtime/auto-ssl-commands/*.sh /usr/local/bin/auto-ssl-commands/ && rm -rf /tmp/auto-ssl-runtime /tmp/auto-ssl-runtime.tgz"
     
     # Add to inventory
     log_step "Adding to inventory..."
-    _inventory_add "$host" "$name" "$user"
+    _inventory_add "$host" "$name" "$user" \
+        --port "$port" \
+        --identity "$identity" \
+        --proxy-jump "$proxy_jump" \
+        --privilege "$privilege" \
+        --host-key "$host_key" \
+        "${inventory_args[@]}"
     
     echo ""
     log_success "Server ${host} enrolled successfully!"
@@ -307,11 +476,10 @@ cmd_remote_enroll() {
     echo "  Name:     ${name}"
     echo "  Host:     ${host}"
     echo "  User:     ${user}"
+    echo "  Port:     ${port}"
+    [[ -n "$proxy_jump" ]] && echo "  Via:      ${proxy_jump}"
     echo ""
     echo "The server now has valid certificates and automatic renewal configured."
-
-    # Close multiplexed SSH master connection if present
-    ssh "${ssh_opts[@]}" -O exit "$ssh_target" >/dev/null 2>&1 || true
 }
 
 #--------------------------------------------------
@@ -327,11 +495,20 @@ USAGE
 
 OPTIONS
     --host HOST           Target server (required unless --all)
-    --user USER           SSH username (required unless --all)
+    --user USER           SSH username (default: from the inventory)
     --all                 Check all enrolled servers
-    --port PORT           SSH port (default: 22)
+    --group GROUP         Check enrolled servers in GROUP
+    --selector SELECTOR   Check enrolled servers whose labels match
+    --port PORT           SSH port (default: from the inventory, else 22)
+    --parallel N          Servers to check at once (default: 10)
+    --timeout DURATION    Per-server timeout (default: 2m)
+    --log-dir DIR         Save each server's output to DIR/HOST.log
+    --accept-new-hostkey  Accept and pin changed SSH host keys
     -h, --help            Show this help
 
+    Enrolled servers are reached with the port, identity, jump host and
+    privilege mode recorded in the inventory.
+
 EXAMPLES
     # Check single server
     auto-ssl remote status --host 192.168.1.50 --user ryan
@@ -339,6 +516,9 @@ EXAMPLES
     # Check all enrolled servers
     auto-ssl remote status --all
 
+    # Check the edge group's production servers
+    auto-ssl remote status --group edge --selector env=prod
+
 HELP
 }
 
@@ -346,7 +526,9 @@ cmd_remote_status() {
     local host=""
     local user=""
     local all=false
-    local port="22"
+    local port=""
+    local select_args=()
+    local fleet_args=()
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
@@ -363,10 +545,24 @@ cmd_remote_status() {
                 all=true
                 shift
                 ;;
+            --group|--selector)
+                select_args+=("$1" "$2")
+                all=true
+                shift 2
+                ;;
             --port)
                 port="$2"
                 shift 2
                 ;;
+            --parallel|--timeout|--log-dir)
+                fleet_args+=("$1" "$2")
+                shift 2
+                ;;
+            --accept-new-hostkey)
+                REMOTE_ACCEPT_NEW_HOSTKEY=true
+                fleet_args+=("$1")
+                shift
+                ;;
             -h|--help)
                 cmd_remote_status_help
                 return 0
@@ -378,53 +574,47 @@ cmd_remote_status() {
     done
     
     if [[ "$all" == true ]]; then
-        log_header "All Enrolled Servers"
+        if [[ ${#select_args[@]} -gt 0 ]]; then
+            log_header "Selected Servers"
+        else
+            log_header "All Enrolled Servers"
+            select_args=(--all)
+        fi
         
         if [[ ! -f "$INVENTORY_FILE" ]]; then
             echo "No servers enrolled"
             return
         fi
         
-        # Parse inventory and check each server
-        # (simplified - proper YAML parsing would be better)
-        while IFS= read -r line; do
-            if [[ "$line" =~ host:\ *(.+) ]]; then
-                local h="${BASH_REMATCH[1]}"
-            elif [[ "$line" =~ user:\ *(.+) ]]; then
-                local u="${BASH_REMATCH[1]}"
-                if [[ -n "$h" ]] && [[ -n "$u" ]]; then
-                    echo ""
-                    echo "Checking ${h}..."
-                    _check_remote_status "$h" "$u" "$port" || true
-                fi
-            fi
-        done < "$INVENTORY_FILE"
+        [[ -n "$port" ]] && fleet_args+=(-- --port "$port")
+        _fleet status "${select_args[@]}" "${fleet_args[@]}"
     else
         [[ -z "$host" ]] && die "Host required. Use --host HOST or --all"
+        _inventory_load "$host"
+        user="${user:-$INV_USER}"
         [[ -z "$user" ]] && die "User required. Use --user USER"
         
         log_header "Remote Status: ${host}"
-        _check_remote_status "$host" "$user" "$port"
+        _remote_connect "${port:-$INV_PORT}" "$INV_IDENTITY" "$INV_PROXY_JUMP" "$INV_PRIVILEGE"
+        _check_remote_status "$host" "$user"
     fi
 }
 
+# _check_remote_status HOST USER
+# Uses the connection settings from the last _remote_connect call.
 _check_remote_status() {
     local host="$1"
     local user="$2"
-    local port="$3"
     
-    local ssh_opts=(-o "ConnectTimeout=5" -o "StrictHostKeyChecking=accept-new" -p "$port")
     local ssh_target="${user}@${host}"
     
-    if ! ssh "${ssh_opts[@]}" "$ssh_target" "true" &>/dev/null; then
-        log_error "Cannot connect to ${host}"
-        return 1
-    fi
+    _remote_check "$ssh_target" 5s >/dev/null || return
     
-    # Check certificate
+    # Check certificate (parsed locally by the Go companion)
     local cert_info
-    cert_info=$(ssh "${ssh_opts[@]}" "$ssh_target" \
-        "sudo cat /etc/ssl/auto-ssl/server.crt 2>/dev/null | openssl x509 -noout -subject -enddate 2>/dev/null" || echo "")
+    cert_info=$(_remote_ssh "$ssh_target" \
+        "${REMOTE_SUDO}cat /etc/ssl/auto-ssl/server.crt 2>/dev/null" | \
+        "$AUTO_SSL_BIN" tools cert inspect - 2>/dev/null || echo "")
     
     if [[ -z "$cert_info" ]]; then
         log_warning "No certificate found on ${host}"
@@ -433,9 +623,17 @@ _check_remote_status() {
     
     echo "$cert_info" | sed 's/^/  /'
     
-    # Check renewal timer
+    # Check the renewal agent, or the timer on servers enrolled without it
+    local agent_status
+    agent_status=$(_remote_ssh "$ssh_target" \
+        "systemctl is-active auto-ssl-agent.service 2>/dev/null" || echo "inactive")
+    if [[ "$agent_status" == "active" ]]; then
+        log_success "  Renewal agent: active"
+        return 0
+    fi
+    
     local timer_status
-    timer_status=$(ssh "${ssh_opts[@]}" "$ssh_target" \
+    timer_status=$(_remote_ssh "$ssh_target" \
         "systemctl is-active auto-ssl-renew.timer 2>/dev/null" || echo "inactive")
     
     if [[ "$timer_status" == "active" ]]; then
@@ -455,25 +653,64 @@ auto-ssl remote update-ca-url - Update CA URL on enrolled servers
 
 Use this after migrating the CA to a new IP address.
 
+Each server saves its current CA settings, bootstraps against the new URL
+and then passes a health gate: the new CA must answer /health and a
+'step ca renew --dry-run' of each of the server's certificates must
+succeed. No certificates are issued. Where step has no --dry-run, each
+certificate is only verified against the new root, with a warning. A
+server that fails the gate is put back on its previous settings.
+
+Servers are updated in waves: one canary server first, then batches of
+--parallel. The rollout stops before the next wave if the canary fails or
+more than --max-failures servers have failed.
+
 USAGE
     auto-ssl remote update-ca-url [options]
 
 OPTIONS
-    --new-url URL         New CA URL (required)
+    --new-url URL         New CA URL (required unless --host and --rollback)
     --host HOST           Update single host (default: all enrolled)
-    --user USER           SSH username (required if --host)
+    --user USER           SSH username (default: from the inventory)
+    --group GROUP         Only update enrolled servers in GROUP
+    --selector SELECTOR   Only update enrolled servers whose labels match
+    --fingerprint FP      Root fingerprint of the new CA (default: fetched
+                          from the new URL)
+    --parallel N          Servers to update at once (default: 10)
+    --timeout DURATION    Per-server timeout (default: 2m)
+    --log-dir DIR         Save each server's output to DIR/HOST.log
+    --canary N            Servers in the first wave (default: 1)
+    --batch-size N        Servers in each later wave (default: --parallel)
+    --max-failures N      Failures tolerated before stopping (default: 0)
+    --rollback            With --host, restore the server's previous CA
+                          settings; otherwise restore every updated server
+                          if the rollout stops
+    --no-health-check     Skip the health gate after bootstrapping
+    --accept-new-hostkey  Accept and pin changed SSH host keys
+    -y, --yes             Do not ask for confirmation
     -h, --help            Show this help
 
 EXAMPLES
     # Update all servers
     auto-ssl remote update-ca-url --new-url https://192.168.1.200:9000
 
+    # Two canaries, then 25 at a time; undo everything if 3 servers fail
+    auto-ssl remote update-ca-url --new-url https://192.168.1.200:9000 \
+        --canary 2 --batch-size 25 --max-failures 2 --rollback
+
+    # Put one server back on its previous CA
+    auto-ssl remote update-ca-url --host 192.168.1.50 --rollback
+
     # Update single server
     auto-ssl remote update-ca-url \
         --new-url https://192.168.1.200:9000 \
         --host 192.168.1.50 \
         --user ryan
 
+    # Update the staging servers only
+    auto-ssl remote update-ca-url \
+        --new-url https://192.168.1.200:9000 \
+        --selector env=staging
+
 HELP
 }
 
@@ -481,6 +718,13 @@ cmd_remote_update_ca_url() {
     local new_url=""
     local host=""
     local user=""
+    local new_fp=""
+    local assume_yes=false
+    local rollback=false
+    local health_check=true
+    local select_args=()
+    local fleet_args=()
+    local host_args=()
     
     while [[ $# -gt 0 ]]; do
         case "$1" in
@@ -496,6 +740,36 @@ cmd_remote_update_ca_url() {
                 user="$2"
                 shift 2
                 ;;
+            --group|--selector)
+                select_args+=("$1" "$2")
+                shift 2
+                ;;
+            --fingerprint)
+                new_fp="$2"
+                shift 2
+                ;;
+            --parallel|--timeout|--log-dir|--canary|--batch-size|--max-failures)
+                fleet_args+=("$1" "$2")
+                shift 2
+                ;;
+            --rollback)
+                rollback=true
+                shift
+                ;;
+            --no-health-check)
+                health_check=false
+                host_args+=("$1")
+                shift
+                ;;
+            --accept-new-hostkey)
+                REMOTE_ACCEPT_NEW_HOSTKEY=true
+                fleet_args+=("$1")
+                shift
+                ;;
+            -y|--yes)
+                assume_yes=true
+                shift
+                ;;
             -h|--help)
                 cmd_remote_update_ca_url_help
                 return 0
@@ -506,91 +780,364 @@ cmd_remote_update_ca_url() {
         esac
     done
     
+    if [[ -n "$host" && ${#select_args[@]} -gt 0 ]]; then
+        die "--host cannot be combined with --group or --selector"
+    fi
+    
+    if [[ -n "$host" && "$rollback" == true ]]; then
+        _inventory_load "$host"
+        user="${user:-$INV_USER}"
+        [[ -z "$user" ]] && die "User required when specifying host"
+        _remote_connect "$INV_PORT" "$INV_IDENTITY" "$INV_PROXY_JUMP" "$INV_PRIVILEGE"
+        _restore_server_ca_url "$host" "$user"
+        return
+    fi
+    
     [[ -z "$new_url" ]] && die "New URL required. Use --new-url URL"
     
     log_header "Updating CA URL on Servers"
     log_info "New CA URL: ${new_url}"
     
-    # Get new fingerprint
-    local new_fp
-    log_step "Fetching new CA fingerprint..."
-    local tmp_cert
-    tmp_cert=$(mktemp)
-    cleanup_add "rm -f '$tmp_cert'"
-    
-    if curl -sk "${new_url}/roots.pem" -o "$tmp_cert"; then
-        new_fp=$(step certificate fingerprint "$tmp_cert" 2>/dev/null || \
-                 openssl x509 -in "$tmp_cert" -noout -fingerprint -sha256 | cut -d= -f2 | tr -d ':')
-        log_success "New fingerprint: ${new_fp}"
-    else
-        die "Cannot fetch root CA from ${new_url}"
+    # Get new fingerprint (once, not per server)
+    if [[ -z "$new_fp" ]]; then
+        log_step "Fetching new CA fingerprint..."
+        local tmp_cert
+        tmp_cert=$(mktemp)
+        cleanup_add "rm -f '$tmp_cert'"
+        
+        if curl -sk "${new_url}/roots.pem" -o "$tmp_cert"; then
+            new_fp=$(step certificate fingerprint "$tmp_cert" 2>/dev/null || \
+                     openssl x509 -in "$tmp_cert" -noout -fingerprint -sha256 | cut -d= -f2 | tr -d ':')
+            log_success "New fingerprint: ${new_fp}"
+        else
+            die "Cannot fetch root CA from ${new_url}"
+        fi
     fi
     
     if [[ -n "$host" ]]; then
+        _inventory_load "$host"
+        user="${user:-$INV_USER}"
         [[ -z "$user" ]] && die "User required when specifying host"
-        _update_server_ca_url "$host" "$user" "$new_url" "$new_fp"
+        _remote_connect "$INV_PORT" "$INV_IDENTITY" "$INV_PROXY_JUMP" "$INV_PRIVILEGE"
+        _update_server_ca_url "$host" "$user" "$new_url" "$new_fp" "$health_check" || return
     else
         # Update all enrolled servers
         if [[ ! -f "$INVENTORY_FILE" ]]; then
             die "No servers enrolled"
         fi
         
-        log_warning "This will update CA URL on ALL enrolled servers"
-        if ! ui_confirm "Continue?"; then
+        local targets
+        targets=$(_inventory_targets "${select_args[@]}") || die "Cannot read the server inventory"
+        [[ -z "$targets" ]] && die "No servers match"
+        
+        local count
+        count=$(grep -c . <<< "$targets")
+        if [[ ${#select_args[@]} -gt 0 ]]; then
+            log_warning "This will update CA URL on ${count} selected server(s)"
+        else
+            log_warning "This will update CA URL on ALL ${count} enrolled server(s)"
+        fi
+        if [[ "$assume_yes" != true ]] && ! ui_confirm "Continue?"; then
             log_info "Cancelled"
             return 1
         fi
         
-        # Parse inventory
-        while IFS= read -r line; do
-            if [[ "$line" =~ host:\ *(.+) ]]; then
-                local h="${BASH_REMATCH[1]}"
-            elif [[ "$line" =~ user:\ *(.+) ]]; then
-                local u="${BASH_REMATCH[1]}"
-                if [[ -n "$h" ]] && [[ -n "$u" ]]; then
-                    echo ""
-                    _update_server_ca_url "$h" "$u" "$new_url" "$new_fp" || true
-                fi
-            fi
-        done < "$INVENTORY_FILE"
+        [[ ${#select_args[@]} -eq 0 ]] && select_args=(--all)
+        [[ "$rollback" == true ]] && fleet_args+=(--rollback)
+        echo ""
+        _fleet update-ca-url "${select_args[@]}" "${fleet_args[@]}" \
+            -- --new-url "$new_url" --fingerprint "$new_fp" "${host_args[@]}"
     fi
     
     echo ""
     log_success "CA URL update complete"
 }
 
+# Remote scripts for update-ca-url. They run under sh as the user that
+# enrolled the server, so `step path` is the step directory in use.
+# The previous bootstrap is kept in auto-ssl-previous for rollback.
+_CA_URL_SAVE='d=$(step path) && mkdir -p "$d/auto-ssl-previous" && cp "$d/config/defaults.json" "$d/certs/root_ca.crt" "$d/auto-ssl-previous/"'
+_CA_URL_RESTORE='d=$(step path) && [ -f "$d/auto-ssl-previous/defaults.json" ] && cp "$d/auto-ssl-previous/defaults.json" "$d/config/defaults.json" && cp "$d/auto-ssl-previous/root_ca.crt" "$d/certs/root_ca.crt"'
+# The health gate, run by sh on the server: the CA answers /health and each
+# certificate the server has enrolled still renews against it. The paths
+# come from the server's own config; a runtime too old for `server status
+# --paths` has only the default certificate, which the gate warns about.
+#
+# `step ca renew --dry-run` checks a renewal without issuing anything. Older
+# step CLIs cannot test a renewal without getting a real certificate from
+# the CA, so on those the gate only checks that each certificate verifies
+# against the new root, and warns that it did.
+_ca_url_gate_script() {
+    cat << 'GATE'
+step ca health >/dev/null || { echo "error: the CA does not answer its health check" >&2; exit 1; }
+root="$(step path)/certs/root_ca.crt"
+
+if ! paths=$(auto-ssl server status --paths 2>/dev/null); then
+    echo "warning: auto-ssl here cannot list its certificates; only /etc/ssl/auto-ssl/server.crt was checked" >&2
+    paths=$(printf 'default\t/etc/ssl/auto-ssl/server.crt\t/etc/ssl/auto-ssl/server.key')
+fi
+
+dry_run=false
+if step ca renew --help 2>/dev/null | grep -q -- '--dry-run'; then
+    dry_run=true
+else
+    echo "warning: step has no 'ca renew --dry-run'; certificates were verified against the new root, not test-renewed" >&2
+fi
+
+tab=$(printf '\t')
+rc=0
+while IFS="$tab" read -r name cert key; do
+    if [ "$dry_run" = true ]; then
+        step ca renew --dry-run "$cert" "$key" >/dev/null 2>&1 && continue
+        echo "error: test renewal of certificate $name ($cert) failed" >&2
+    else
+        step certificate verify "$cert" --roots "$root" >/dev/null 2>&1 && continue
+        echo "error: certificate $name ($cert) does not verify against the new root" >&2
+    fi
+    rc=1
+done << EOF
+$paths
+EOF
+exit $rc
+GATE
+}
+
+# _report_gate HOST OUTPUT
+# Logs the gate's warning: and error: lines against HOST.
+_report_gate() {
+    local host="$1"
+    local line
+    while IFS= read -r line; do
+        case "$line" in
+            warning:*) log_warning "${host}: ${line#warning: }" ;;
+            error:*)   log_error "${host}: ${line#error: }" ;;
+        esac
+    done <<< "$2"
+}
+
+# _update_server_ca_url HOST USER NEW_URL NEW_FP [HEALTH_CHECK]
+# Uses the connection settings from the last _remote_connect call.
 _update_server_ca_url() {
     local host="$1"
     local user="$2"
     local new_url="$3"
     local new_fp="$4"
+    local health_check="${5:-true}"
     
     log_step "Updating ${host}..."
     
-    local ssh_opts=(-o "ConnectTimeout=10" -o "StrictHostKeyChecking=accept-new")
     local ssh_target="${user}@${host}"
     
-    if ! ssh "${ssh_opts[@]}" "$ssh_target" "true" &>/dev/null; then
-        log_error "Cannot connect to ${host}"
+    _remote_check "$ssh_target" >/dev/null || return
+    
+    if ! _remote_ssh "$ssh_target" "${REMOTE_SUDO}sh -c '${_CA_URL_SAVE}'" &>/dev/null; then
+        log_error "Cannot save the current CA settings on ${host}"
         return 1
     fi
     
-    # Re-bootstrap with new CA
-    local cmd="step ca bootstrap --ca-url '${new_url}' --fingerprint '${new_fp}' --force"
+    # Re-bootstrap with new CA, as the same user that enrolled the server
+    local cmd="${REMOTE_SUDO}step ca bootstrap --ca-url '${new_url}' --fingerprint '${new_fp}' --force"
     
-    if ssh "${ssh_opts[@]}" "$ssh_target" "$cmd" &>/dev/null; then
-        log_success "Updated ${host}"
-    else
+    if ! _remote_ssh "$ssh_target" "$cmd" &>/dev/null; then
         log_error "Failed to update ${host}"
         return 1
     fi
+    
+    if [[ "$health_check" == true ]]; then
+        local gate_out gate_ok=true
+        gate_out=$(_ca_url_gate_script | _remote_ssh_stdin "$ssh_target" "${REMOTE_SUDO}sh -s" 2>&1) || gate_ok=false
+        _report_gate "$host" "$gate_out"
+        if [[ "$gate_ok" != true ]]; then
+            if _remote_ssh "$ssh_target" "${REMOTE_SUDO}sh -c '${_CA_URL_RESTORE}'" &>/dev/null; then
+                log_error "Health check failed on ${host}; restored its previous CA settings"
+            else
+                log_error "Health check failed on ${host} and its previous CA settings could not be restored"
+            fi
+            return 1
+        fi
+    fi
+    log_success "Updated ${host}"
+}
+
+# _restore_server_ca_url HOST USER
+# Puts a server back on the CA settings saved by its last update.
+_restore_server_ca_url() {
+    local host="$1"
+    local user="$2"
+    local ssh_target="${user}@${host}"
+    
+    log_step "Rolling back ${host}..."
+    _remote_check "$ssh_target" >/dev/null || return
+    
+    if _remote_ssh "$ssh_target" "${REMOTE_SUDO}sh -c '${_CA_URL_RESTORE}'" &>/dev/null; then
+        log_success "Restored the previous CA settings on ${host}"
+    else
+        log_error "No previous CA settings to restore on ${host}"
+        return 1
+    fi
+}
+
+#--------------------------------------------------
+# Remote Renew
+#--------------------------------------------------
+
+cmd_remote_renew_help() {
+    cat << 'HELP'
+auto-ssl remote renew - Renew certificates on remote servers
+
+Runs 'auto-ssl server renew' on each server over SSH.
+
+USAGE
+    auto-ssl remote renew [options]
+
+OPTIONS
+    --host HOST           Renew a single server
+    --user USER           SSH username (default: from the inventory)
+    --all                 Renew all enrolled servers
+    --group GROUP         Renew enrolled servers in GROUP
+    --selector SELECTOR   Renew enrolled servers whose labels match
+    --force               Renew even if the certificate is not due
+    --parallel N          Servers to renew at once (default: 10)
+    --timeout DURATION    Per-server timeout (default: 2m)
+    --log-dir DIR         Save each server's output to DIR/HOST.log
+    --accept-new-hostkey  Accept and pin changed SSH host keys
+    -h, --help            Show this help
+
+EXAMPLES
+    # Renew one server now
+    auto-ssl remote renew --host 192.168.1.50 --force
+
+    # Renew the edge group, 20 servers at a time
+    auto-ssl remote renew --group edge --parallel 20
+
+HELP
+}
+
+cmd_remote_renew() {
+    local host=""
+    local user=""
+    local all=false
+    local force=false
+    local select_args=()
+    local fleet_args=()
+    
+    while [[ $# -gt 0 ]]; do
+        case "$1" in
+            --host)
+                host="$2"
+                shift 2
+                ;;
+            --user)
+                user="$2"
+                shift 2
+                ;;
+            --all)
+                all=true
+                shift
+                ;;
+            --group|--selector)
+                select_args+=("$1" "$2")
+                all=true
+                shift 2
+                ;;
+            --force)
+                force=true
+                shift
+                ;;
+            --parallel|--timeout|--log-dir)
+                fleet_args+=("$1" "$2")
+                shift 2
+                ;;
+            --accept-new-hostkey)
+                REMOTE_ACCEPT_NEW_HOSTKEY=true
+                fleet_args+=("$1")
+                shift
+                ;;
+            -h|--help)
+                cmd_remote_renew_help
+                return 0
+                ;;
+            *)
+                die_with_help "Unknown option: $1" "remote renew"
+                ;;
+        esac
+    done
+    
+    if [[ "$all" == true ]]; then
+        [[ ${#select_args[@]} -eq 0 ]] && select_args=(--all)
+        [[ "$force" == true ]] && fleet_args+=(-- --force)
+        _fleet renew "${select_args[@]}" "${fleet_args[@]}"
+        return
+    fi
+    
+    [[ -z "$host" ]] && die "Host required. Use --host HOST, --all, --group or --selector"
+    _inventory_load "$host"
+    user="${user:-$INV_USER}"
+    [[ -z "$user" ]] && die "User required. Use --user USER"
+    _remote_connect "$INV_PORT" "$INV_IDENTITY" "$INV_PROXY_JUMP" "$INV_PRIVILEGE"
+    
+    local ssh_target="${user}@${host}"
+    
+    log_step "Renewing ${host}..."
+    _remote_check "$ssh_target" >/dev/null || exit "$REMOTE_UNREACHABLE"
+    
+    local renew_cmd="${REMOTE_SUDO}auto-ssl server renew"
+    [[ "$force" == true ]] && renew_cmd+=" --force"
+    if _remote_ssh "$ssh_target" "$renew_cmd"; then
+        log_success "Renewed ${host}"
+    else
+        die "Renewal failed on ${host}"
+    fi
 }
 
 #--------------------------------------------------
 # Remote List
 #--------------------------------------------------
 
+cmd_remote_list_help() {
+    cat << 'HELP'
+auto-ssl remote list - List enrolled servers
+
+USAGE
+    auto-ssl remote list [options]
+
+OPTIONS
+    --group GROUP         Only servers in GROUP
+    --selector SELECTOR   Only servers whose labels match SELECTOR
+    --json                Print the servers as JSON
+    -h, --help            Show this help
+
+EXAMPLES
+    auto-ssl remote list
+    auto-ssl remote list --group edge
+    auto-ssl remote list --selector 'env=prod,!decommissioned'
+
+HELP
+}
+
 cmd_remote_list() {
+    local list_args=()
+    
+    while [[ $# -gt 0 ]]; do
+        case "$1" in
+            --group|--selector)
+                list_args+=("$1" "$2")
+                shift 2
+                ;;
+            --json)
+                list_args+=("$1")
+                shift
+                ;;
+            -h|--help)
+                cmd_remote_list_help
+                return 0
+                ;;
+            *)
+                die_with_help "Unknown option: $1" "remote list"
+                ;;
+        esac
+    done
+    
     log_header "Enrolled Servers"
     
     if [[ ! -f "$INVENTORY_FILE" ]]; then
@@ -601,5 +1148,5 @@ cmd_remote_list() {
         return
     fi
     
-    _inventory_list
+    _inventory_list "${list_args[@]}"
 }
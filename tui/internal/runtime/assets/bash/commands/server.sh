@@ -55,12 +55,52 @@ USAGE
 OPTIONS
     --ca-url URL          CA server URL (required)
     --fingerprint FP      CA root fingerprint (required)
+    --name NAME           Enroll a further, named certificate (e.g. one per
+                          service) with its own renewal and reload hooks;
+                          enrolling a name again replaces it
     --san NAME            Subject Alternative Name (can repeat, default: primary IP)
+    --token TOKEN         One-time token from 'auto-ssl ca token' instead of
+                          the provisioner password; it sets the SANs
+    --token-file FILE     Read the token from FILE (/dev/stdin for stdin)
     --duration DUR        Certificate duration (default: from CA)
-    --cert-path PATH      Where to store certificate (default: /etc/ssl/auto-ssl/server.crt)
-    --key-path PATH       Where to store private key (default: /etc/ssl/auto-ssl/server.key)
+    --cert-path PATH      Where to store certificate (default:
+                          /etc/ssl/auto-ssl/server.crt, or NAME.crt)
+    --key-path PATH       Where to store private key (default:
+                          /etc/ssl/auto-ssl/server.key, or NAME.key)
     --provisioner NAME    Provisioner name (default: admin)
     --password-file FILE  Provisioner password file (or prompt)
+    --bundle FILE         Enroll offline from a bundle made on the CA with
+                          'auto-ssl tools bundle create' (tarball or
+                          unpacked directory); supplies the CA URL,
+                          fingerprint, SANs, a one-time token and, if
+                          included, the step CLI
+    --acme                Get and renew the certificate over ACME; the
+                          server holds no provisioner credentials (needs
+                          the auto-ssl binary)
+    --acme-directory URL  ACME directory (default: the CA's acme
+                          provisioner, CA_URL/acme/acme/directory); with
+                          it, --ca-url and --fingerprint are optional
+    --acme-root FILE      CA certificates trusted for the directory
+                          (default: the CA root from --fingerprint)
+    --challenge TYPE      ACME challenge: http-01 (default) or tls-alpn-01
+    --webroot DIR         Answer http-01 through an existing web server's
+                          document root instead of listening on port 80
+    --challenge-port N    Listen for the challenge on port N (default: 80
+                          for http-01, 443 for tls-alpn-01)
+    --email ADDR          ACME account contact
+    --renew-at FRACTION   Renew once this fraction of the certificate's
+                          lifetime has passed (default: 2/3)
+    --reload-service NAME Reload this systemd service after each renewal
+                          (can repeat; default without --name: the running
+                          nginx, caddy, apache2/httpd, haproxy and envoy
+                          services)
+    --reload-cmd CMD      Run this command after each renewal (can repeat)
+    --no-reload           Don't reload any services after renewal
+    --verify-port N       After reloading, check that port N serves the
+                          renewed certificate (default without --name: 443
+                          when services are reloaded; 0 turns the check off)
+    --alert-cmd CMD       Run this command when a reload or the check
+                          fails; it finds the message in $AUTO_SSL_ALERT
     --no-renewal          Don't set up automatic renewal
     --non-interactive     Don't prompt for input
     -h, --help            Show this help
@@ -85,18 +125,69 @@ EXAMPLES
         --password-file /etc/step/password \
         --non-interactive
 
+    # With a one-time token minted on the CA (no password on the server)
+    sudo auto-ssl server enroll \
+        --ca-url https://192.168.1.100:9000 \
+        --fingerprint abc123 \
+        --token-file /root/token
+
+    # From an offline bundle (no internet access needed)
+    tar -xzf auto-ssl-bundle-web1.tgz
+    sudo ./auto-ssl-bundle/runtime/auto-ssl server enroll \
+        --bundle auto-ssl-bundle-web1.tgz
+
+    # Over ACME, answering http-01 through nginx's document root
+    sudo auto-ssl server enroll \
+        --ca-url https://192.168.1.100:9000 \
+        --fingerprint abc123 \
+        --san web1.internal \
+        --acme --webroot /var/www/html
+
+    # A second certificate for postgres, alongside the web server's
+    sudo auto-ssl server enroll \
+        --ca-url https://192.168.1.100:9000 \
+        --fingerprint abc123 \
+        --name db \
+        --san db.internal \
+        --reload-service postgresql
+
+    # Reload haproxy and a custom service, and mail when that fails
+    sudo auto-ssl server enroll \
+        --ca-url https://192.168.1.100:9000 \
+        --fingerprint abc123 \
+        --reload-service haproxy \
+        --reload-cmd "systemctl restart myapp" \
+        --alert-cmd 'mail -s "auto-ssl on $(hostname)" ops@example.com <<< "$AUTO_SSL_ALERT"'
+
 HELP
 }
 
 cmd_server_enroll() {
     local ca_url=""
     local fingerprint=""
+    local name=""
     local sans=()
     local duration=""
-    local cert_path="${AUTO_SSL_CERT_DIR}/server.crt"
-    local key_path="${AUTO_SSL_CERT_DIR}/server.key"
+    local cert_path=""
+    local key_path=""
     local provisioner="admin"
     local password_file=""
+    local bundle=""
+    local token=""
+    local token_file=""
+    local acme=false
+    local acme_directory=""
+    local acme_root=""
+    local challenge="http-01"
+    local webroot=""
+    local challenge_port=""
+    local email=""
+    local renew_at=""
+    local reload_services=()
+    local reload_cmds=()
+    local detect_reload=true
+    local verify_port=""
+    local alert_cmd=""
     local setup_renewal=true
     local non_interactive=false
     
@@ -111,6 +202,10 @@ cmd_server_enroll() {
                 fingerprint="$2"
                 shift 2
                 ;;
+            --name)
+                name="$2"
+                shift 2
+                ;;
             --san)
                 sans+=("$2")
                 shift 2
@@ -135,6 +230,70 @@ cmd_server_enroll() {
                 password_file="$2"
                 shift 2
                 ;;
+            --bundle)
+                bundle="$2"
+                shift 2
+                ;;
+            --token)
+                token="$2"
+                shift 2
+                ;;
+            --token-file)
+                token_file="$2"
+                shift 2
+                ;;
+            --acme)
+                acme=true
+                shift
+                ;;
+            --acme-directory)
+                acme_directory="$2"
+                shift 2
+                ;;
+            --acme-root)
+                acme_root="$2"
+                shift 2
+                ;;
+            --challenge)
+                challenge="$2"
+                shift 2
+                ;;
+            --webroot)
+                webroot="$2"
+                shift 2
+                ;;
+            --challenge-port)
+                challenge_port="$2"
+                shift 2
+                ;;
+            --email)
+                email="$2"
+                shift 2
+                ;;
+            --renew-at)
+                renew_at="$2"
+                shift 2
+                ;;
+            --reload-service)
+                reload_services+=("$2")
+                shift 2
+                ;;
+            --reload-cmd)
+                reload_cmds+=("$2")
+                shift 2
+                ;;
+            --no-reload)
+                detect_reload=false
+                shift
+                ;;
+            --verify-port)
+                verify_port="$2"
+                shift 2
+                ;;
+            --alert-cmd)
+                alert_cmd="$2"
+                shift 2
+                ;;
             --no-renewal)
                 setup_renewal=false
                 shift
@@ -155,9 +314,82 @@ cmd_server_enroll() {
     
     require_root
     
-    # Validate required arguments
-    [[ -z "$ca_url" ]] && die "CA URL required. Use --ca-url URL"
-    [[ -z "$fingerprint" ]] && die "Fingerprint required. Use --fingerprint FP"
+    # Named certificates live in a list only the typed config can edit
+    [[ "$name" == "default" ]] && name=""
+    if [[ -n "$name" ]]; then
+        require_companion "named certificates"
+        [[ "$name" =~ ^[A-Za-z0-9][A-Za-z0-9._-]*$ ]] || die "--name may only contain letters, digits, '.', '_' and '-'"
+    fi
+    cert_path="${cert_path:-${AUTO_SSL_CERT_DIR}/${name:-server}.crt}"
+    key_path="${key_path:-${AUTO_SSL_CERT_DIR}/${name:-server}.key}"
+    
+    # The renewal agent schedules from the certificate's lifetime
+    if [[ -n "$renew_at" ]]; then
+        require_companion "the renewal agent"
+        [[ "$renew_at" =~ ^(0?\.[0-9]+|[0-9]+/[0-9]+)$ ]] || die "--renew-at must be a fraction such as 2/3 or 0.75"
+    fi
+    
+    # The agent checks the reload worked; the plain timer can only run it
+    if [[ -n "$verify_port" || -n "$alert_cmd" ]]; then
+        require_companion "reload checks and alerts"
+        if [[ -n "$verify_port" ]] && ! [[ "$verify_port" =~ ^[0-9]+$ && "$verify_port" -le 65535 ]]; then
+            die "--verify-port must be a port number (0 turns the check off)"
+        fi
+    fi
+    if [[ "$detect_reload" == false ]] && (( ${#reload_services[@]} + ${#reload_cmds[@]} > 0 )); then
+        die "--no-reload cannot be combined with --reload-service or --reload-cmd"
+    fi
+    
+    # ACME proves control of the names instead of using provisioner credentials
+    if [[ "$acme" == true ]]; then
+        require_companion "ACME enrollment"
+        if [[ -n "$bundle" || -n "$token" || -n "$token_file" || -n "$password_file" ]]; then
+            die "--acme cannot be combined with --bundle, --token or --password-file"
+        fi
+        [[ -n "$duration" ]] && die "--duration is not supported with --acme; the ACME provisioner sets the certificate lifetime"
+        case "$challenge" in
+            http-01|tls-alpn-01) ;;
+            *) die "Unknown challenge: ${challenge} (http-01 or tls-alpn-01)" ;;
+        esac
+        [[ -n "$webroot" && "$challenge" != "http-01" ]] && die "--webroot only works with the http-01 challenge"
+        [[ -n "$webroot" ]] && webroot=$(readlink -f "$webroot")
+        [[ -n "$acme_root" ]] && acme_root=$(readlink -f "$acme_root")
+    elif [[ -n "$acme_directory$acme_root$webroot$challenge_port$email" || "$challenge" != "http-01" ]]; then
+        die_with_help "ACME options need --acme" "server enroll"
+    fi
+    
+    # The bundle's token is bound to its SANs, so they cannot be changed
+    if [[ -n "$bundle" ]]; then
+        [[ ${#sans[@]} -gt 0 ]] && die "--san cannot be combined with --bundle; create a bundle with the SANs you need"
+        _bundle_open "$bundle"
+        ca_url="${ca_url:-$BUNDLE_CA_URL}"
+        fingerprint="${fingerprint:-$BUNDLE_FINGERPRINT}"
+        token_file="${BUNDLE_DIR}/token"
+    fi
+    if [[ -n "$token_file" ]]; then
+        if [[ "$token_file" == "/dev/stdin" ]]; then
+            token=$(cat)
+        else
+            require_file "$token_file" "Token file"
+            token=$(cat "$token_file")
+        fi
+        [[ -z "$token" ]] && die "Token file is empty: ${token_file}"
+    fi
+    
+    # A token is bound to its SANs, so they come from the token
+    if [[ -n "$token" ]]; then
+        [[ ${#sans[@]} -gt 0 ]] && die "--san cannot be combined with a token; mint a token with the SANs you need"
+        local token_sans
+        token_sans=$(_token_sans "$token") || die "Not a valid enrollment token"
+        mapfile -t sans <<< "$token_sans"
+    fi
+    
+    # Validate required arguments (an explicit ACME directory needs no step-ca)
+    if [[ -z "$acme_directory" || -n "$ca_url" ]]; then
+        [[ -z "$ca_url" ]] && die "CA URL required. Use --ca-url URL"
+        [[ -z "$fingerprint" ]] && die "Fingerprint required. Use --fingerprint FP"
+    fi
+    [[ "$acme" == true && -z "$acme_directory" ]] && acme_directory="${ca_url%/}/acme/acme/directory"
     
     log_header "Enrolling Server"
     
@@ -169,9 +401,26 @@ cmd_server_enroll() {
         log_info "Using primary IP as SAN: ${primary_ip}"
     fi
     
+    # Default reload hooks to the web servers running here (a named
+    # certificate is usually for something else)
+    if [[ "$detect_reload" == true && -z "$name" ]] && (( ${#reload_services[@]} + ${#reload_cmds[@]} == 0 )); then
+        mapfile -t reload_services < <(detect_web_servers)
+        if [[ ${#reload_services[@]} -gt 0 ]]; then
+            log_info "Reloading after renewal: ${reload_services[*]} (change with --reload-service)"
+        fi
+    fi
+    if [[ -z "$verify_port" ]]; then
+        verify_port=0
+        [[ -z "$name" ]] && (( ${#reload_services[@]} + ${#reload_cmds[@]} > 0 )) && verify_port=443
+    fi
+    
     # Get password if needed
     local password=""
-    if [[ -n "$password_file" ]]; then
+    if [[ "$acme" == true ]]; then
+        log_info "Using ACME (${challenge}); no provisioner credentials are needed"
+    elif [[ -n "$token" ]]; then
+        log_info "Using a one-time token instead of the provisioner password"
+    elif [[ -n "$password_file" ]]; then
         if [[ "$password_file" == "/dev/stdin" ]]; then
             password=$(cat)
         else
@@ -186,9 +435,13 @@ cmd_server_enroll() {
     fi
     
     # Install step CLI if needed
-    if ! has_step_cli; then
+    if [[ -n "$ca_url" ]] && ! has_step_cli; then
         log_step "Installing step CLI..."
-        _install_step_cli
+        if [[ -n "$bundle" ]]; then
+            _bundle_install_step
+        else
+            _install_step_cli
+        fi
     fi
     
     # Create certificate directory
@@ -197,12 +450,15 @@ cmd_server_enroll() {
     chmod 755 "$(dirname "$cert_path")"
     
     # Bootstrap trust
-    log_step "Bootstrapping trust to CA..."
-    step ca bootstrap \
-        --ca-url "$ca_url" \
-        --fingerprint "$fingerprint" \
-        --install \
-        --force
+    if [[ -n "$ca_url" ]]; then
+        log_step "Bootstrapping trust to CA..."
+        step ca bootstrap \
+            --ca-url "$ca_url" \
+            --fingerprint "$fingerprint" \
+            --install \
+            --force
+        [[ -z "$acme_root" ]] && acme_root="$(step path)/certs/root_ca.crt"
+    fi
     
     # Build SAN arguments
     local san_args=()
@@ -210,21 +466,29 @@ cmd_server_enroll() {
         san_args+=(--san "$san")
     done
     
-    # Build certificate arguments
-    local cert_args=(
-        "${sans[0]}"
-        "$cert_path"
-        "$key_path"
-        "${san_args[@]}"
-        --provisioner "$provisioner"
-        --force
-    )
+    # Build certificate arguments (a token carries its own SANs and provisioner)
+    local cert_args=("${sans[0]}" "$cert_path" "$key_path" --force)
+    if [[ -n "$token" ]]; then
+        cert_args+=(--token "$token")
+    else
+        cert_args+=("${san_args[@]}" --provisioner "$provisioner")
+    fi
     
     [[ -n "$duration" ]] && cert_args+=(--not-after "$duration")
     
     # Request certificate
     log_step "Requesting certificate..."
-    if [[ -n "$password" ]]; then
+    if [[ "$acme" == true ]]; then
+        local acme_args=(--directory "$acme_directory" --challenge "$challenge" \
+            --cert-path "$cert_path" --key-path "$key_path" "${san_args[@]}")
+        [[ -n "$acme_root" ]] && acme_args+=(--root "$acme_root")
+        [[ -n "$webroot" ]] && acme_args+=(--webroot "$webroot")
+        [[ -n "$challenge_port" ]] && acme_args+=(--port "$challenge_port")
+        [[ -n "$email" ]] && acme_args+=(--email "$email")
+        "$AUTO_SSL_BIN" tools acme obtain "${acme_args[@]}" || die "ACME enrollment failed"
+    elif [[ -n "$token" ]]; then
+        step ca certificate "${cert_args[@]}"
+    elif [[ -n "$password" ]]; then
         # Use password
         local tmp_pw
         tmp_pw=$(mktemp)
@@ -243,41 +507,106 @@ cmd_server_enroll() {
     chmod 644 "$cert_path"
     chmod 600 "$key_path"
     
-    # Verify certificate
-    log_step "Verifying certificate..."
-    if ! step certificate verify "$cert_path" --roots "$(step path)/certs/root_ca.crt" &>/dev/null; then
-        die "Certificate verification failed"
+    # Verify certificate (a foreign ACME server's chain is not ours to check)
+    if [[ -n "$ca_url" ]]; then
+        log_step "Verifying certificate..."
+        if ! step certificate verify "$cert_path" --roots "$(step path)/certs/root_ca.crt" &>/dev/null; then
+            die "Certificate verification failed"
+        fi
+    fi
+    
+    # Save configuration
+    log_step "Saving configuration..."
+    if [[ -n "$ca_url" ]]; then
+        config_set "ca.url" "$ca_url"
+        config_set "ca.fingerprint" "$fingerprint"
+    fi
+    if [[ -n "$name" ]] && ! _cert_names | grep -qxF "$name"; then
+        config_set "server.certificates.$(_cert_names | grep -cvx default).name" "$name"
+    fi
+    config_set "$(_cert_key "$name" cert_path)" "$cert_path"
+    config_set "$(_cert_key "$name" key_path)" "$key_path"
+    config_set "$(_cert_key "$name" sans)" "$(IFS=,; echo "${sans[*]}")"
+    if [[ "$acme" == true ]]; then
+        # Renewal repeats these, so settings from an earlier enrollment go
+        "$AUTO_SSL_BIN" tools config unset "$(_cert_key "$name" acme)" --config "${AUTO_SSL_CONFIG_FILE}"
+        config_set "$(_cert_key "$name" acme.directory)" "$acme_directory"
+        config_set "$(_cert_key "$name" acme.challenge)" "$challenge"
+        [[ -n "$acme_root" ]] && config_set "$(_cert_key "$name" acme.root)" "$acme_root"
+        [[ -n "$webroot" ]] && config_set "$(_cert_key "$name" acme.webroot)" "$webroot"
+        [[ -n "$challenge_port" ]] && config_set "$(_cert_key "$name" acme.port)" "$challenge_port"
+        [[ -n "$email" ]] && config_set "$(_cert_key "$name" acme.email)" "$email"
+        config_set "$(_cert_key "$name" enrollment)" "acme"
+    else
+        config_set "$(_cert_key "$name" enrollment)" "provisioner"
+    fi
+    [[ -n "$renew_at" ]] && config_set "$(_cert_key "$name" renew_at)" "$renew_at"
+    if has_companion; then
+        # Hooks from an earlier enrollment are replaced, not added to
+        local key
+        for key in reload verify_port alert_command; do
+            "$AUTO_SSL_BIN" tools config unset "$(_cert_key "$name" "$key")" --config "${AUTO_SSL_CONFIG_FILE}"
+        done
+        local i=0 hook
+        for hook in "${reload_services[@]}"; do
+            config_set "$(_cert_key "$name" "reload.${i}.service")" "$hook"
+            i=$(( i + 1 ))
+        done
+        for hook in "${reload_cmds[@]}"; do
+            config_set "$(_cert_key "$name" "reload.${i}.command")" "$hook"
+            i=$(( i + 1 ))
+        done
+        [[ "$verify_port" != 0 ]] && config_set "$(_cert_key "$name" verify_port)" "$verify_port"
+        [[ -n "$alert_cmd" ]] && config_set "$(_cert_key "$name" alert_command)" "$alert_cmd"
     fi
     
-    # Set up automatic renewal
+    # Set up automatic renewal (after saving: the agent reads the config)
+    local renewal="Manual"
     if [[ "$setup_renewal" == true ]]; then
         log_step "Setting up automatic renewal..."
-        _setup_renewal_timer "$cert_path" "$key_path"
+        if has_companion; then
+            _setup_renewal_agent
+            renewal="Automatic (renewal agent)"
+        else
+            local hook timer_hooks=()
+            for hook in "${reload_services[@]}"; do timer_hooks+=("service=${hook}"); done
+            for hook in "${reload_cmds[@]}"; do timer_hooks+=("command=${hook}"); done
+            _setup_renewal_timer "$cert_path" "$key_path" "${timer_hooks[@]}"
+            renewal="Automatic (systemd timer)"
+        fi
     fi
     
-    # Save configuration
-    log_step "Saving configuration..."
-    config_set "ca.url" "$ca_url"
-    config_set "ca.fingerprint" "$fingerprint"
-    config_set "server.cert_path" "$cert_path"
-    config_set "server.key_path" "$key_path"
-    config_set "server.sans" "$(IFS=,; echo "${sans[*]}")"
+    # Keep the bundle's runtime for renewals and status checks
+    if [[ -n "$bundle" ]]; then
+        log_step "Installing auto-ssl..."
+        _bundle_install_runtime
+    fi
     
     # Get certificate info
     local expiry
-    expiry=$(step certificate inspect "$cert_path" --format json 2>/dev/null | \
-             grep -o '"not_after": "[^"]*"' | cut -d'"' -f4 || echo "unknown")
+    expiry=$(_cert_expiry "$cert_path")
+    
+    local reload="None"
+    if (( ${#reload_services[@]} + ${#reload_cmds[@]} > 0 )); then
+        reload=$(printf '%s, ' "${reload_services[@]}" "${reload_cmds[@]}")
+        reload="${reload%, }"
+        has_companion && [[ "$verify_port" != 0 ]] && reload+=" (checked on port ${verify_port})"
+    fi
+    
+    local name_line=""
+    [[ -n "$name" ]] && name_line="Name:        ${name}"$'\n'
     
     echo ""
     log_success "Server enrolled successfully!"
     echo ""
     ui_box "Certificate Information" "$(cat << INFO
-Certificate: ${cert_path}
+${name_line}Certificate: ${cert_path}
 Private Key: ${key_path}
 SANs:        ${sans[*]}
 Expires:     ${expiry}
 
-Renewal:     $(if [[ "$setup_renewal" == true ]]; then echo "Automatic (systemd timer)"; else echo "Manual"; fi)
+Renewal:     ${renewal}$(if [[ "$acme" == true ]]; then echo " over ACME (${challenge})"; fi)
+Reload:      ${reload}
 
 Use these paths in your web server configuration:
   ssl_certificate     ${cert_path}
@@ -290,66 +619,93 @@ INFO
 # Server Status
 #--------------------------------------------------
 
+cmd_server_status_help() {
+    cat << 'HELP'
+auto-ssl server status - Show certificate status and expiration
+
+USAGE
+    auto-ssl server status [options]
+
+OPTIONS
+    --name NAME     Show only the named certificate (default: all of them)
+    --paths         Print each certificate's name, certificate path and key
+                    path, tab-separated, and nothing else
+    -h, --help      Show this help
+
+HELP
+}
+
 cmd_server_status() {
-    log_header "Server Certificate Status"
+    local only=""
+    local paths=false
     
-    local cert_path
-    cert_path=$(config_get "server.cert_path" "${AUTO_SSL_CERT_DIR}/server.crt")
-    local key_path
-    key_path=$(config_get "server.key_path" "${AUTO_SSL_CERT_DIR}/server.key")
+    while [[ $# -gt 0 ]]; do
+        case "$1" in
+            --name) only="$2"; shift 2 ;;
+            --paths) paths=true; shift ;;
+            -h|--help)
+                cmd_server_status_help
+                return 0
+                ;;
+            *) die_with_help "Unknown option: $1" "server status" ;;
+        esac
+    done
     
-    # Check if enrolled
-    if [[ ! -f "$cert_path" ]]; then
-        log_warning "No certificate found at ${cert_path}"
-        echo ""
-        echo "This server is not enrolled. Run:"
-        echo "  sudo auto-ssl server enroll --ca-url <URL> --fingerprint <FP>"
-        return 1
+    local names=()
+    if [[ -n "$only" ]]; then
+        names=("$only")
+    else
+        mapfile -t names < <(_cert_names)
+        # Enrolled before the SANs were saved
+        [[ ${#names[@]} -eq 0 ]] && names=(default)
     fi
     
-    echo "Certificate Files:"
-    echo "  Certificate: ${cert_path}"
-    echo "  Private Key: ${key_path}"
-    echo ""
-    
-    # Certificate details
-    echo "Certificate Details:"
-    if has_step_cli; then
-        step certificate inspect "$cert_path" --short 2>/dev/null | sed 's/^/  /'
-    else
-        openssl x509 -in "$cert_path" -noout -subject -dates -issuer 2>/dev/null | sed 's/^/  /'
+    # For scripts, such as the update-ca-url health gate
+    if [[ "$paths" == true ]]; then
+        local name base
+        for name in "${names[@]}"; do
+            base="$name"
+            [[ "$name" == "default" ]] && base="server"
+            printf '%s\t%s\t%s\n' "$name" \
+                "$(config_get "$(_cert_key "$name" cert_path)" "${AUTO_SSL_CERT_DIR}/${base}.crt")" \
+                "$(config_get "$(_cert_key "$name" key_path)" "${AUTO_SSL_CERT_DIR}/${base}.key")"
+        done
+        return 0
     fi
     
-    # Check expiration
-    echo ""
-    echo "Validity:"
-    local end_date
-    end_date=$(openssl x509 -in "$cert_path" -noout -enddate 2>/dev/null | cut -d= -f2)
-    local end_epoch
-    end_epoch=$(date -d "$end_date" +%s 2>/dev/null || date -j -f "%b %d %T %Y %Z" "$end_date" +%s 2>/dev/null)
-    local now_epoch
-    now_epoch=$(date +%s)
-    local days_left=$(( (end_epoch - now_epoch) / 86400 ))
+    log_header "Server Certificate Status"
     
-    if (( days_left < 0 )); then
-        log_error "  Certificate EXPIRED ${days_left#-} days ago"
-    elif (( days_left < 1 )); then
-        log_warning "  Certificate expires TODAY"
-    elif (( days_left < 3 )); then
-        log_warning "  Certificate expires in ${days_left} days"
-    else
-        log_success "  Certificate valid for ${days_left} more days"
+    local name status=0
+    for name in "${names[@]}"; do
+        if [[ ${#names[@]} -gt 1 || "$name" != "default" ]]; then
+            echo -e "${BOLD}Certificate \"${name}\"${RESET}"
+            echo ""
+        fi
+        _server_cert_status "$name" || status=1
+        echo ""
+    done
+    if [[ "$status" -ne 0 && ${#names[@]} -eq 1 ]]; then
+        return 1
     fi
     
-    # Renewal timer status
-    echo ""
-    echo "Renewal Timer:"
-    if systemctl is-active auto-ssl-renew.timer &>/dev/null; then
-        log_success "  Timer is active"
-        systemctl list-timers auto-ssl-renew.timer --no-pager 2>/dev/null | tail -2 | sed 's/^/  /'
+    # Renewal agent, or the timer it replaces
+    if [[ -f /etc/systemd/system/auto-ssl-agent.service ]] && has_companion; then
+        echo "Renewal Agent:"
+        if systemctl is-active auto-ssl-agent.service &>/dev/null; then
+            log_success "  Agent is active"
+        else
+            log_warning "  Agent is not active (see: journalctl -u auto-ssl-agent)"
+        fi
+        "$AUTO_SSL_BIN" agent status ${only:+--name "$only"} 2>&1 | sed 's/^/  /'
     else
-        log_warning "  Timer is not active"
-        echo "  Set up renewal with: sudo auto-ssl server enroll ..."
+        echo "Renewal Timer:"
+        if systemctl is-active auto-ssl-renew.timer &>/dev/null; then
+            log_success "  Timer is active"
+            systemctl list-timers auto-ssl-renew.timer --no-pager 2>/dev/null | tail -2 | sed 's/^/  /'
+        else
+            log_warning "  Timer is not active"
+            echo "  Set up renewal with: sudo auto-ssl server enroll ..."
+        fi
     fi
     
     # CA connection
@@ -374,33 +730,44 @@ cmd_server_status() {
 
 cmd_server_renew_help() {
     cat << 'HELP'
-auto-ssl server renew - Renew the server certificate
+auto-ssl server renew - Renew the server certificates
 
 USAGE
     auto-ssl server renew [options]
 
 OPTIONS
+    --name NAME     Renew only the named certificate (default: all of them)
     --force         Force renewal even if certificate is still valid
-    --exec CMD      Command to run after successful renewal (e.g., reload nginx)
+                    (servers enrolled with --acme always renew)
+    --exec CMD      Command to run after successful renewal, in addition
+                    to the reload hooks saved by 'server enroll'
     -h, --help      Show this help
 
 EXAMPLES
     # Force immediate renewal
     sudo auto-ssl server renew --force
 
-    # Renew and reload nginx
-    sudo auto-ssl server renew --force --exec "systemctl reload nginx"
+    # Renew only the certificate enrolled with --name db
+    sudo auto-ssl server renew --force --name db
+
+    # Renew and also restart a service that is not in the reload hooks
+    sudo auto-ssl server renew --force --exec "systemctl restart myapp"
 
 HELP
 }
 
 cmd_server_renew() {
+    local only=""
     local force=false
     local exec_cmd=""
     
     # Parse arguments
     while [[ $# -gt 0 ]]; do
         case "$1" in
+            --name)
+                only="$2"
+                shift 2
+                ;;
             --force)
                 force=true
                 shift
@@ -421,54 +788,21 @@ cmd_server_renew() {
     
     require_root
     
-    local cert_path
-    cert_path=$(config_get "server.cert_path" "${AUTO_SSL_CERT_DIR}/server.crt")
-    local key_path
-    key_path=$(config_get "server.key_path" "${AUTO_SSL_CERT_DIR}/server.key")
-    
-    require_file "$cert_path" "Certificate"
-    require_file "$key_path" "Private key"
-    
-    log_header "Renewing Certificate"
-    
-    # Backup existing certificate before renewal
-    log_step "Backing up current certificate..."
-    local backup_dir="/var/lib/auto-ssl/cert-backups"
-    mkdir -p "$backup_dir"
-    local timestamp=$(date +%Y%m%d-%H%M%S)
-    cp "$cert_path" "${backup_dir}/server-${timestamp}.crt" 2>/dev/null || true
-    cp "$key_path" "${backup_dir}/server-${timestamp}.key" 2>/dev/null || true
-    chmod 600 "${backup_dir}/server-${timestamp}.key" 2>/dev/null || true
-    
-    # Clean up old backups (keep last 5)
-    (cd "$backup_dir" && ls -t server-*.crt 2>/dev/null | tail -n +6 | xargs -r rm -f) || true
-    (cd "$backup_dir" && ls -t server-*.key 2>/dev/null | tail -n +6 | xargs -r rm -f) || true
-    
-    # Use step ca renew (uses existing cert to authenticate)
-    log_step "Requesting renewal..."
+    local names=()
+    if [[ -n "$only" ]]; then
+        names=("$only")
+    else
+        mapfile -t names < <(_cert_names)
+        [[ ${#names[@]} -eq 0 ]] && names=(default)
+    fi
     
-    local renew_args=("$cert_path" "$key_path")
-    [[ "$force" == true ]] && renew_args+=(--force)
+    local name failed=()
+    for name in "${names[@]}"; do
+        _server_cert_renew "$name" "$force" "$exec_cmd" || failed+=("$name")
+    done
     
-    if step ca renew "${renew_args[@]}"; then
-        log_success "Certificate renewed successfully"
-        
-        # Show new expiration
-        local expiry
-        expiry=$(step certificate inspect "$cert_path" --format json 2>/dev/null | \
-                 grep -o '"not_after": "[^"]*"' | cut -d'"' -f4 || echo "unknown")
-        echo "  New expiration: ${expiry}"
-        
-        # Run post-renewal command
-        if [[ -n "$exec_cmd" ]]; then
-            log_step "Running post-renewal command..."
-            if eval "$exec_cmd"; then
-                log_success "Post-renewal command completed"
-            else
-                log_warning "Post-renewal command failed (exit code: $?)"
-            fi
-        fi
-    else
+    if [[ ${#failed[@]} -gt 0 ]]; then
+        [[ ${#names[@]} -gt 1 ]] && die "Certificate renewal failed for: ${failed[*]}"
         die "Certificate renewal failed"
     fi
 }
@@ -512,14 +846,19 @@ cmd_server_suspend() {
     
     log_header "Suspending Certificate Renewal"
     
-    # Stop and disable the renewal timer
-    if systemctl is-active auto-ssl-renew.timer &>/dev/null; then
+    # Stop and disable the renewal agent or timer
+    if systemctl is-active auto-ssl-agent.service &>/dev/null; then
+        log_step "Stopping renewal agent..."
+        systemctl stop auto-ssl-agent.service
+        systemctl disable auto-ssl-agent.service
+        log_success "Renewal agent stopped"
+    elif systemctl is-active auto-ssl-renew.timer &>/dev/null; then
         log_step "Disabling renewal timer..."
         systemctl stop auto-ssl-renew.timer
         systemctl disable auto-ssl-renew.timer
         log_success "Renewal timer disabled"
     else
-        log_info "Renewal timer was not active"
+        log_info "Automatic renewal was not active"
     fi
     
     # Mark as suspended in config
@@ -574,27 +913,38 @@ cmd_server_resume() {
         return 0
     fi
     
-    # Re-enable the renewal timer
-    if [[ -f /etc/systemd/system/auto-ssl-renew.timer ]]; then
+    # Clear suspended status first; the agent exits while it is set
+    config_set "server.suspended" "false"
+    config_set "server.resumed_at" "$(date -u +"%Y-%m-%dT%H:%M:%SZ")"
+    
+    # Re-enable the renewal agent or timer
+    if [[ -f /etc/systemd/system/auto-ssl-agent.service ]]; then
+        log_step "Restarting renewal agent..."
+        systemctl daemon-reload
+        systemctl enable auto-ssl-agent.service
+        systemctl start auto-ssl-agent.service
+        log_success "Renewal agent started"
+    elif [[ -f /etc/systemd/system/auto-ssl-renew.timer ]]; then
         log_step "Re-enabling renewal timer..."
         systemctl daemon-reload
         systemctl enable auto-ssl-renew.timer
         systemctl start auto-ssl-renew.timer
         log_success "Renewal timer enabled"
     else
-        log_error "Renewal timer not found. Server may need re-enrollment."
+        config_set "server.suspended" "true"
+        log_error "Neither the renewal agent nor the renewal timer is installed. Server may need re-enrollment."
         return 1
     fi
     
-    # Clear suspended status
-    config_set "server.suspended" "false"
-    config_set "server.resumed_at" "$(date -u +"%Y-%m-%dT%H:%M:%SZ")"
-    
     echo ""
     log_success "Certificate renewal resumed"
     
     # Show next renewal time
-    systemctl list-timers auto-ssl-renew.timer --no-pager | tail -2
+    if [[ -f /etc/systemd/system/auto-ssl-agent.service ]]; then
+        echo "  Check the agent with: auto-ssl agent status"
+    else
+        systemctl list-timers auto-ssl-renew.timer --no-pager | tail -2
+    fi
 }
 
 cmd_server_revoke_help() {
@@ -605,6 +955,7 @@ USAGE
     auto-ssl server revoke [options]
 
 OPTIONS
+    --name NAME     Revoke the named certificate instead of the default one
     --reason TEXT   Reason for revocation
     --serial NUM    Certificate serial number (if not current cert)
     -h, --help      Show this help
@@ -617,11 +968,13 @@ HELP
 }
 
 cmd_server_revoke() {
+    local name="default"
     local reason=""
     local serial=""
     
     while [[ $# -gt 0 ]]; do
         case "$1" in
+            --name) name="$2"; shift 2 ;;
             --reason) reason="$2"; shift 2 ;;
             --serial) serial="$2"; shift 2 ;;
             -h|--help)
@@ -634,10 +987,12 @@ cmd_server_revoke() {
     
     require_root
     
+    local base="$name"
+    [[ "$name" == "default" ]] && base="server"
     local cert_path
-    cert_path=$(config_get "server.cert_path" "${AUTO_SSL_CERT_DIR}/server.crt")
+    cert_path=$(config_get "$(_cert_key "$name" cert_path)" "${AUTO_SSL_CERT_DIR}/${base}.crt")
     local key_path
-    key_path=$(config_get "server.key_path" "${AUTO_SSL_CERT_DIR}/server.key")
+    key_path=$(config_get "$(_cert_key "$name" key_path)" "${AUTO_SSL_CERT_DIR}/${base}.key")
     
     log_header "Revoking Certificate"
     
@@ -671,6 +1026,8 @@ USAGE
     auto-ssl server remove [options]
 
 OPTIONS
+    --name NAME     Revoke and remove only the named certificate, keeping
+                    the rest of the enrollment
     --reason TEXT   Reason for removal
     --keep-certs    Don't delete certificate files
     -h, --help      Show this help
@@ -679,6 +1036,9 @@ EXAMPLES
     # Complete removal
     sudo auto-ssl server remove --reason "Server decommissioned"
     
+    # Stop renewing the certificate enrolled with --name db
+    sudo auto-ssl server remove --name db
+    
     # Remove but keep certificates
     sudo auto-ssl server remove --keep-certs
 
@@ -686,11 +1046,13 @@ HELP
 }
 
 cmd_server_remove() {
+    local name=""
     local reason=""
     local keep_certs=false
     
     while [[ $# -gt 0 ]]; do
         case "$1" in
+            --name) name="$2"; shift 2 ;;
             --reason) reason="$2"; shift 2 ;;
             --keep-certs) keep_certs=true; shift ;;
             -h|--help)
@@ -703,29 +1065,41 @@ cmd_server_remove() {
     
     require_root
     
-    log_header "Removing Server Enrollment"
+    if [[ -n "$name" && "$name" != "default" ]]; then
+        _server_cert_remove "$name" "$reason" "$keep_certs"
+        return
+    fi
     
-    local cert_path
-    cert_path=$(config_get "server.cert_path" "${AUTO_SSL_CERT_DIR}/server.crt")
-    local key_path
-    key_path=$(config_get "server.key_path" "${AUTO_SSL_CERT_DIR}/server.key")
+    log_header "Removing Server Enrollment"
     
-    if ! ui_confirm "This will revoke the certificate and remove auto-ssl. Continue?"; then
+    if ! ui_confirm "This will revoke the certificates and remove auto-ssl. Continue?"; then
         log_info "Cancelled"
         return 1
     fi
     
-    # Revoke certificate if it exists
-    if [[ -f "$cert_path" ]] && [[ -f "$key_path" ]]; then
-        log_step "Revoking certificate..."
-        if step ca revoke --cert "$cert_path" --key "$key_path" ${reason:+--reason "$reason"} 2>/dev/null; then
-            log_success "Certificate revoked"
-        else
-            log_warning "Certificate revocation failed (may already be revoked or expired)"
+    # Revoke every certificate that exists
+    local cert_name cert_path key_path named_files=()
+    while read -r cert_name; do
+        cert_path=$(config_get "$(_cert_key "$cert_name" cert_path)" "${AUTO_SSL_CERT_DIR}/server.crt")
+        key_path=$(config_get "$(_cert_key "$cert_name" key_path)" "${AUTO_SSL_CERT_DIR}/server.key")
+        [[ "$cert_name" != "default" ]] && named_files+=("$cert_path" "$key_path")
+        if [[ -f "$cert_path" ]] && [[ -f "$key_path" ]]; then
+            log_step "Revoking ${cert_path}..."
+            if step ca revoke --cert "$cert_path" --key "$key_path" ${reason:+--reason "$reason"} 2>/dev/null; then
+                log_success "Certificate revoked"
+            else
+                log_warning "Certificate revocation failed (may already be revoked or expired)"
+            fi
         fi
+    done < <(_cert_names; [[ -n "$(config_get "server.sans" "")" ]] || echo default)
+    
+    # Stop and disable the renewal agent and timer
+    if systemctl is-active auto-ssl-agent.service &>/dev/null; then
+        log_step "Stopping renewal agent..."
+        systemctl stop auto-ssl-agent.service
+        systemctl disable auto-ssl-agent.service
+        log_success "Renewal agent stopped"
     fi
-    
-    # Stop and disable renewal timer
     if systemctl is-active auto-ssl-renew.timer &>/dev/null; then
         log_step "Stopping renewal timer..."
         systemctl stop auto-ssl-renew.timer
@@ -734,19 +1108,22 @@ cmd_server_remove() {
     fi
     
     # Remove systemd files
-    if [[ -f /etc/systemd/system/auto-ssl-renew.service ]]; then
+    if [[ -f /etc/systemd/system/auto-ssl-renew.service || -f /etc/systemd/system/auto-ssl-agent.service ]]; then
         log_step "Removing systemd units..."
         rm -f /etc/systemd/system/auto-ssl-renew.service
         rm -f /etc/systemd/system/auto-ssl-renew.timer
+        rm -f /etc/systemd/system/auto-ssl-agent.service
+        rm -f "${AUTO_SSL_DATA_DIR}"/agent.json "${AUTO_SSL_DATA_DIR}"/agent-*.json
         systemctl daemon-reload
         log_success "Systemd units removed"
     fi
     
     # Remove certificates unless --keep-certs
     if [[ "$keep_certs" == false ]]; then
-        if [[ -d "${AUTO_SSL_CERT_DIR}" ]]; then
+        if [[ -d "${AUTO_SSL_CERT_DIR}" || ${#named_files[@]} -gt 0 ]]; then
             log_step "Removing certificates..."
             rm -rf "${AUTO_SSL_CERT_DIR}"
+            rm -f "${named_files[@]}"
             log_success "Certificates removed"
         fi
     else
@@ -789,6 +1166,258 @@ cmd_server_remove() {
 # Helper functions
 #--------------------------------------------------
 
+# Print the certificate expiry, preferring the Go companion's parser
+_cert_expiry() {
+    local cert_path="$1"
+    
+    if cert_field "$cert_path" not_after; then
+        return 0
+    fi
+    
+    step certificate inspect "$cert_path" --format json 2>/dev/null | \
+        grep -o '"not_after": "[^"]*"' | cut -d'"' -f4 || echo "unknown"
+}
+
+# _server_cert_renew NAME FORCE EXEC_CMD
+# Renew one certificate, then reload the services using it.
+_server_cert_renew() {
+    local name="$1"
+    local force="$2"
+    local exec_cmd="$3"
+    local base="$name"
+    [[ "$name" == "default" ]] && base="server"
+    
+    local cert_path
+    cert_path=$(config_get "$(_cert_key "$name" cert_path)" "${AUTO_SSL_CERT_DIR}/${base}.crt")
+    local key_path
+    key_path=$(config_get "$(_cert_key "$name" key_path)" "${AUTO_SSL_CERT_DIR}/${base}.key")
+    
+    require_file "$cert_path" "Certificate"
+    require_file "$key_path" "Private key"
+    
+    if [[ "$name" == "default" ]]; then
+        log_header "Renewing Certificate"
+    else
+        log_header "Renewing Certificate \"${name}\""
+    fi
+    
+    # Backup existing certificate before renewal
+    log_step "Backing up current certificate..."
+    local backup_dir="/var/lib/auto-ssl/cert-backups"
+    mkdir -p "$backup_dir"
+    local timestamp=$(date +%Y%m%d-%H%M%S)
+    cp "$cert_path" "${backup_dir}/${base}-${timestamp}.crt" 2>/dev/null || true
+    cp "$key_path" "${backup_dir}/${base}-${timestamp}.key" 2>/dev/null || true
+    chmod 600 "${backup_dir}/${base}-${timestamp}.key" 2>/dev/null || true
+    
+    # Clean up old backups (keep last 5)
+    (cd "$backup_dir" && ls -t "${base}"-*.crt 2>/dev/null | tail -n +6 | xargs -r rm -f) || true
+    (cd "$backup_dir" && ls -t "${base}"-*.key 2>/dev/null | tail -n +6 | xargs -r rm -f) || true
+    
+    # Use step ca renew (uses existing cert to authenticate), or a new ACME
+    # order for servers enrolled with --acme
+    log_step "Requesting renewal..."
+    
+    local renew_cmd
+    if [[ "$(config_get "$(_cert_key "$name" enrollment)" "provisioner")" == "acme" ]]; then
+        require_companion "ACME renewal"
+        renew_cmd=("$AUTO_SSL_BIN" tools acme renew --force --name "$name")
+    else
+        renew_cmd=(step ca renew "$cert_path" "$key_path")
+        [[ "$force" == true ]] && renew_cmd+=(--force)
+    fi
+    
+    if ! "${renew_cmd[@]}"; then
+        log_error "Renewal of ${cert_path} failed"
+        return 1
+    fi
+    log_success "Certificate renewed successfully"
+    
+    # Show new expiration
+    local expiry
+    expiry=$(_cert_expiry "$cert_path")
+    echo "  New expiration: ${expiry}"
+    
+    # Reload the services using the certificate and check they serve it
+    if has_companion; then
+        log_step "Reloading services..."
+        local reload_cmd=("$AUTO_SSL_BIN" tools reload --name "$name")
+        [[ -n "$exec_cmd" ]] && reload_cmd+=(--exec "$exec_cmd")
+        if "${reload_cmd[@]}"; then
+            log_success "Services reloaded"
+        else
+            log_warning "Reloading services failed; the renewed certificate may not be in use yet"
+        fi
+    elif [[ -n "$exec_cmd" ]]; then
+        log_step "Running post-renewal command..."
+        if eval "$exec_cmd"; then
+            log_success "Post-renewal command completed"
+        else
+            log_warning "Post-renewal command failed (exit code: $?)"
+        fi
+    fi
+}
+
+# _server_cert_remove NAME REASON KEEP_CERTS
+# Revoke one named certificate and stop renewing it.
+_server_cert_remove() {
+    local name="$1"
+    local reason="$2"
+    local keep_certs="$3"
+    
+    _cert_names | grep -qxF "$name" || die "Unknown certificate: ${name}"
+    
+    log_header "Removing Certificate \"${name}\""
+    
+    local cert_path
+    cert_path=$(config_get "$(_cert_key "$name" cert_path)" "${AUTO_SSL_CERT_DIR}/${name}.crt")
+    local key_path
+    key_path=$(config_get "$(_cert_key "$name" key_path)" "${AUTO_SSL_CERT_DIR}/${name}.key")
+    
+    if ! ui_confirm "This will revoke ${cert_path} and stop renewing it. Continue?"; then
+        log_info "Cancelled"
+        return 1
+    fi
+    
+    if [[ -f "$cert_path" ]] && [[ -f "$key_path" ]]; then
+        log_step "Revoking certificate..."
+        if step ca revoke --cert "$cert_path" --key "$key_path" ${reason:+--reason "$reason"} 2>/dev/null; then
+            log_success "Certificate revoked"
+        else
+            log_warning "Certificate revocation failed (may already be revoked or expired)"
+        fi
+    fi
+    
+    log_step "Removing it from the configuration..."
+    "$AUTO_SSL_BIN" tools config unset "server.certificates.${name}" --config "${AUTO_SSL_CONFIG_FILE}"
+    rm -f "${AUTO_SSL_DATA_DIR}/agent-${name}.json"
+    if systemctl is-active auto-ssl-agent.service &>/dev/null; then
+        systemctl restart auto-ssl-agent.service
+    fi
+    
+    if [[ "$keep_certs" == false ]]; then
+        rm -f "$cert_path" "$key_path"
+        log_success "Certificate removed"
+    else
+        log_success "Certificate removed; the files are kept at ${cert_path}"
+    fi
+}
+
+# _server_cert_status NAME
+# Print the files, details, validity and reload hooks of one certificate.
+_server_cert_status() {
+    local name="$1"
+    local base="$name"
+    [[ "$name" == "default" ]] && base="server"
+    
+    local cert_path
+    cert_path=$(config_get "$(_cert_key "$name" cert_path)" "${AUTO_SSL_CERT_DIR}/${base}.crt")
+    local key_path
+    key_path=$(config_get "$(_cert_key "$name" key_path)" "${AUTO_SSL_CERT_DIR}/${base}.key")
+    
+    # Check if enrolled
+    if [[ ! -f "$cert_path" ]]; then
+        log_warning "No certificate found at ${cert_path}"
+        echo ""
+        echo "This server is not enrolled. Run:"
+        echo "  sudo auto-ssl server enroll --ca-url <URL> --fingerprint <FP>$([[ "$name" != default ]] && echo " --name ${name}")"
+        return 1
+    fi
+    
+    echo "Certificate Files:"
+    echo "  Certificate: ${cert_path}"
+    echo "  Private Key: ${key_path}"
+    if [[ "$(config_get "$(_cert_key "$name" enrollment)" "provisioner")" == "acme" ]]; then
+        echo "  Enrollment:  ACME ($(config_get "$(_cert_key "$name" acme.challenge)" "http-01"))"
+    fi
+    echo ""
+    
+    # Certificate details
+    echo "Certificate Details:"
+    if has_companion; then
+        "$AUTO_SSL_BIN" tools cert inspect "$cert_path" 2>/dev/null | sed 's/^/  /'
+    elif has_step_cli; then
+        step certificate inspect "$cert_path" --short 2>/dev/null | sed 's/^/  /'
+    else
+        openssl x509 -in "$cert_path" -noout -subject -dates -issuer 2>/dev/null | sed 's/^/  /'
+    fi
+    
+    # Check expiration
+    echo ""
+    echo "Validity:"
+    local remaining_seconds
+    if ! remaining_seconds=$(cert_field "$cert_path" remaining_seconds); then
+        local end_date
+        end_date=$(openssl x509 -in "$cert_path" -noout -enddate 2>/dev/null | cut -d= -f2)
+        local end_epoch
+        end_epoch=$(date -d "$end_date" +%s 2>/dev/null || date -j -f "%b %d %T %Y %Z" "$end_date" +%s 2>/dev/null)
+        remaining_seconds=$(( end_epoch - $(date +%s) ))
+    fi
+    local days_left=$(( remaining_seconds / 86400 ))
+    
+    if (( days_left < 0 )); then
+        log_error "  Certificate EXPIRED ${days_left#-} days ago"
+    elif (( days_left < 1 )); then
+        log_warning "  Certificate expires TODAY"
+    elif (( days_left < 3 )); then
+        log_warning "  Certificate expires in ${days_left} days"
+    else
+        log_success "  Certificate valid for ${days_left} more days"
+    fi
+    
+    # What runs after a renewal, and whether it took effect
+    if has_companion; then
+        echo ""
+        echo "Reload:"
+        local reload_hooks
+        reload_hooks=$("$AUTO_SSL_BIN" tools config get "$(_cert_key "$name" reload)" --config "${AUTO_SSL_CONFIG_FILE}" 2>/dev/null)
+        if [[ -n "$reload_hooks" ]]; then
+            echo "$reload_hooks" | sed 's/^- /  /; s/^    /  /'
+        else
+            echo "  No services are reloaded after renewal"
+        fi
+        local verify_port
+        verify_port=$(config_get "$(_cert_key "$name" verify_port)" "")
+        if [[ -n "$verify_port" ]]; then
+            local served
+            if served=$("$AUTO_SSL_BIN" tools reload --check --name "$name" 2>&1); then
+                log_success "  ${served}"
+            else
+                served="${served#tools failed: }"
+                log_warning "  ${served#"${name}": }"
+                echo "  Reload the services with: sudo auto-ssl tools reload --name ${name}"
+            fi
+        fi
+    fi
+}
+
+# _cert_key NAME FIELD
+# Config key of FIELD for the certificate called NAME; "" and "default" are
+# the certificate described by the server block itself.
+_cert_key() {
+    local name="$1"
+    local field="$2"
+    
+    if [[ -z "$name" || "$name" == "default" ]]; then
+        echo "server.${field}"
+    else
+        echo "server.certificates.${name}.${field}"
+    fi
+}
+
+# Print the names of the enrolled certificates, "default" first
+_cert_names() {
+    [[ -n "$(config_get "server.sans" "")" ]] && echo "default"
+    has_companion || return 0
+    
+    local i=0 name
+    while name=$("$AUTO_SSL_BIN" tools config get "server.certificates.${i}.name" --config "${AUTO_SSL_CONFIG_FILE}" 2>/dev/null); do
+        echo "$name"
+        i=$(( i + 1 ))
+    done
+    return 0
+}
+
 _install_step_cli() {
     local distro
     distro=$(detect_distro)
@@ -822,9 +1451,185 @@ _install_step_cli() {
     log_success "step CLI installed"
 }
 
+# _token_sans TOKEN
+# Prints the token's subject and SANs, one per line, subject first. The
+# token is a JWT; its claims are read without checking the signature,
+# which step-ca does.
+_token_sans() {
+    local claims
+    claims=$(token_claims "$1") || return 1
+    
+    local subject
+    subject=$(grep -o '"sub":"[^"]*"' <<< "$claims" | cut -d'"' -f4)
+    [[ -z "$subject" ]] && return 1
+    echo "$subject"
+    grep -o '"sans":\[[^]]*\]' <<< "$claims" | grep -o '"[^"]*"' | tr -d '"' | \
+        grep -v -x -F -e sans -e "$subject" || true
+}
+
+# _bundle_open FILE|DIR
+# Unpacks (if needed) and checks an enrollment bundle, and sets BUNDLE_DIR,
+# BUNDLE_CA_URL, BUNDLE_FINGERPRINT, BUNDLE_SANS and BUNDLE_STEP_ARCHES
+# from its bundle.conf.
+_bundle_open() {
+    local src="$1"
+    
+    if [[ -d "$src" ]]; then
+        BUNDLE_DIR="$src"
+        [[ -f "${BUNDLE_DIR}/bundle.conf" ]] || BUNDLE_DIR="${src}/auto-ssl-bundle"
+    else
+        require_file "$src" "Bundle"
+        local tmp
+        tmp=$(mktemp -d)
+        cleanup_add "rm -rf '$tmp'"
+        tar -xzf "$src" -C "$tmp" || die "Cannot unpack bundle: ${src}"
+        BUNDLE_DIR="${tmp}/auto-ssl-bundle"
+    fi
+    [[ -f "${BUNDLE_DIR}/bundle.conf" ]] || die "Not an enrollment bundle: ${src}"
+    
+    if command -v sha256sum &>/dev/null; then
+        (cd "$BUNDLE_DIR" && sha256sum --quiet -c CHECKSUMS.txt) &>/dev/null || \
+            die "Bundle is corrupt (checksum mismatch): ${src}"
+    fi
+    
+    BUNDLE_CA_URL=""
+    BUNDLE_FINGERPRINT=""
+    BUNDLE_SANS=()
+    BUNDLE_STEP_ARCHES=()
+    local key value token_expires=""
+    while IFS='=' read -r key value; do
+        case "$key" in
+            ca_url)        BUNDLE_CA_URL="$value" ;;
+            fingerprint)   BUNDLE_FINGERPRINT="$value" ;;
+            san)           BUNDLE_SANS+=("$value") ;;
+            step)          BUNDLE_STEP_ARCHES+=("$value") ;;
+            token_expires) token_expires="$value" ;;
+        esac
+    done < "${BUNDLE_DIR}/bundle.conf"
+    [[ ${#BUNDLE_SANS[@]} -eq 0 ]] && die "Bundle names no SANs: ${src}"
+    
+    # The token's own expiry wins over the manifest's
+    local expires_at
+    if expires_at=$(token_expiry "$(cat "${BUNDLE_DIR}/token")"); then
+        token_expires=$(date -u -d "@${expires_at}" +%Y-%m-%dT%H:%M:%SZ)
+    else
+        expires_at=$(date -u -d "$token_expires" +%s 2>/dev/null) || expires_at=""
+    fi
+    if [[ -n "$expires_at" && "$expires_at" -le $(date -u +%s) ]]; then
+        die "The bundle's token expired at ${token_expires}. Create a new bundle on the CA."
+    fi
+    
+    # The root must match the fingerprint before it is trusted
+    if command -v openssl &>/dev/null; then
+        local root_fp
+        root_fp=$(openssl x509 -in "${BUNDLE_DIR}/root_ca.crt" -noout -fingerprint -sha256 | cut -d= -f2 | tr -d ':' | tr 'A-F' 'a-f')
+        [[ "$root_fp" == "${BUNDLE_FINGERPRINT,,}" ]] || die "Bundle root certificate does not match its fingerprint"
+    fi
+    
+    log_info "Bundle for: ${BUNDLE_SANS[*]} (token valid until ${token_expires})"
+}
+
+# Installs the bundle's step binary for this machine's architecture
+_bundle_install_step() {
+    local arch
+    arch=$(detect_arch)
+    local binary="${BUNDLE_DIR}/bin/step-${arch}"
+    
+    if [[ ! -f "$binary" ]]; then
+        die "step CLI is not installed and the bundle has no step binary for ${arch}. Recreate it with --step-binary."
+    fi
+    install -m 755 "$binary" /usr/bin/step
+    
+    if ! has_step_cli; then
+        die "Failed to install step CLI"
+    fi
+    log_success "step CLI installed from the bundle"
+}
+
+# Installs the bundle's runtime where remote enroll puts it
+_bundle_install_runtime() {
+    local src="${BUNDLE_DIR}/runtime"
+    install -d /usr/local/bin/auto-ssl-lib /usr/local/bin/auto-ssl-commands
+    install -m 755 "${src}/auto-ssl" /usr/local/bin/auto-ssl
+    install -m 644 "${src}/lib/"*.sh /usr/local/bin/auto-ssl-lib/
+    install -m 644 "${src}/commands/"*.sh /usr/local/bin/auto-ssl-commands/
+}
+
+# Runs `auto-ssl agent` as a service. It replaces the fixed renewal timer:
+# renewals are scheduled from the certificate's own lifetime and retried
+# with backoff while the CA is down.
+_setup_renewal_agent() {
+    # A timer from an earlier enrollment would renew on its own schedule
+    if [[ -f /etc/systemd/system/auto-ssl-renew.timer ]]; then
+        systemctl stop auto-ssl-renew.timer 2>/dev/null || true
+        systemctl disable auto-ssl-renew.timer 2>/dev/null || true
+        rm -f /etc/systemd/system/auto-ssl-renew.service
+        rm -f /etc/systemd/system/auto-ssl-renew.timer
+    fi
+    
+    # The unit runs /usr/local/bin/auto-ssl, not the binary running now,
+    # which may be a download in /tmp. Either that is the binary itself, or
+    # an ejected runtime that finds auto-ssl-tui when the agent starts.
+    local agent_bin=/usr/local/bin/auto-ssl
+    if ! env -u AUTO_SSL_BIN "$agent_bin" tools --help >/dev/null 2>&1; then
+        local target="$agent_bin"
+        [[ -e "$agent_bin" ]] && target=/usr/local/bin/auto-ssl-tui
+        if [[ ! "$AUTO_SSL_BIN" -ef "$target" ]]; then
+            log_info "Installing ${AUTO_SSL_BIN} as ${target} for the renewal agent"
+            install -m 755 "$AUTO_SSL_BIN" "$target" || die "Failed to install ${target}"
+        fi
+    fi
+    
+    cat > /etc/systemd/system/auto-ssl-agent.service << EOF
+[Unit]
+Description=auto-ssl certificate renewal agent
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+Environment=AUTO_SSL_CONFIG_FILE=${AUTO_SSL_CONFIG_FILE}
+Environment=AUTO_SSL_DATA_DIR=${AUTO_SSL_DATA_DIR}
+ExecStart=${agent_bin} agent run
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=multi-user.target
+EOF
+    
+    systemctl daemon-reload
+    systemctl enable auto-ssl-agent.service
+    systemctl restart auto-ssl-agent.service
+    
+    log_success "Renewal agent started (renews after $(config_get "server.renew_at" "2/3") of the certificate lifetime)"
+}
+
+# _setup_renewal_timer CERT KEY [service=NAME | command=CMD]...
+# Fallback without the auto-ssl binary: step ca renew on a fixed calendar,
+# then the reload hooks (unchecked).
 _setup_renewal_timer() {
     local cert_path="$1"
     local key_path="$2"
+    shift 2
+    
+    local reload_lines="" hook
+    for hook in "$@"; do
+        if [[ "$hook" == command=* ]]; then
+            # systemd unquotes and expands % and $ itself
+            hook="${hook#command=}"
+            hook="${hook//\\/\\\\}"
+            hook="${hook//\"/\\\"}"
+            hook="${hook//%/%%}"
+            hook="${hook//\$/\$\$}"
+            reload_lines+="ExecStartPost=/bin/sh -c \"${hook}\""$'\n'
+        else
+            reload_lines+="ExecStartPost=/usr/bin/systemctl reload-or-restart ${hook#service=}"$'\n'
+        fi
+    done
+    [[ -n "$reload_lines" ]] || reload_lines="# Add a line like this to reload your web server:
+# ExecStartPost=/usr/bin/systemctl reload-or-restart nginx
+"
     
     # Create renewal service
     cat > /etc/systemd/system/auto-ssl-renew.service << EOF
@@ -836,10 +1641,7 @@ Wants=network-online.target
 [Service]
 Type=oneshot
 ExecStart=/usr/bin/step ca renew --force ${cert_path} ${key_path}
-# Uncomment and modify to reload your web server:
-# ExecStartPost=/usr/bin/systemctl reload nginx
-# ExecStartPost=/usr/bin/systemctl reload caddy
-EOF
+${reload_lines}EOF
     
     # Create renewal timer (every 5 days for 7-day certs)
     cat > /etc/systemd/system/auto-ssl-renew.timer << EOF
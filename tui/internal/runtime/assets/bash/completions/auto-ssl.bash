@@ -4,11 +4,12 @@ _auto_ssl() {
     local cur prev words cword
     _init_completion || return
 
-    local commands="ca server remote client info version help"
-    local ca_commands="init status backup restore backup-schedule"
+    local commands="ca server remote client agent info version help"
+    local ca_commands="init status backup restore backup-schedule token"
     local server_commands="enroll status renew suspend resume revoke remove"
-    local remote_commands="enroll status update-ca-url list"
+    local remote_commands="enroll status renew update-ca-url list"
     local client_commands="trust status"
+    local agent_commands="run status"
 
     case ${cword} in
         1)
@@ -28,6 +29,9 @@ _auto_ssl() {
                 client)
                     COMPREPLY=($(compgen -W "${client_commands}" -- "${cur}"))
                     ;;
+                agent)
+                    COMPREPLY=($(compgen -W "${agent_commands}" -- "${cur}"))
+                    ;;
             esac
             ;;
         *)
@@ -39,45 +43,57 @@ _auto_ssl() {
                             COMPREPLY=($(compgen -W "--name --address --cert-duration --max-duration --password-file --non-interactive --help" -- "${cur}"))
                             ;;
                         backup)
-                            COMPREPLY=($(compgen -W "--output --passphrase-file --dest-type --rsync-target --s3-bucket --s3-endpoint --s3-prefix --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--output --passphrase-file --dest-type --rsync-target --s3-bucket --s3-endpoint --s3-prefix --s3-region --all-destinations --verify --help" -- "${cur}"))
                             ;;
                         restore)
-                            COMPREPLY=($(compgen -W "--input --passphrase-file --new-address --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--input --passphrase-file --new-address --dry-run --help" -- "${cur}"))
                             ;;
                         backup-schedule)
-                            COMPREPLY=($(compgen -W "--enable --disable --schedule --output --retention --passphrase-file --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--enable --disable --schedule --output --retention --keep-daily --keep-weekly --keep-monthly --passphrase-file --verify --help" -- "${cur}"))
+                            ;;
+                        token)
+                            COMPREPLY=($(compgen -W "--san --ttl --provisioner --help" -- "${cur}"))
                             ;;
                     esac
                     ;;
                 server)
                     case ${words[2]} in
                         enroll)
-                            COMPREPLY=($(compgen -W "--ca-url --fingerprint --san --duration --cert-path --key-path --provisioner --password-file --no-renewal --non-interactive --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--name --ca-url --fingerprint --san --duration --cert-path --key-path --provisioner --password-file --token --token-file --bundle --acme --acme-directory --acme-root --challenge --webroot --challenge-port --email --renew-at --reload-service --reload-cmd --no-reload --verify-port --alert-cmd --no-renewal --non-interactive --help" -- "${cur}"))
+                            ;;
+                        status)
+                            COMPREPLY=($(compgen -W "--name --help" -- "${cur}"))
                             ;;
                         renew)
-                            COMPREPLY=($(compgen -W "--force --exec --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--name --force --exec --help" -- "${cur}"))
                             ;;
                         suspend)
                             COMPREPLY=($(compgen -W "--reason --help" -- "${cur}"))
                             ;;
                         revoke)
-                            COMPREPLY=($(compgen -W "--reason --serial --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--name --reason --serial --help" -- "${cur}"))
                             ;;
                         remove)
-                            COMPREPLY=($(compgen -W "--reason --keep-certs --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--name --reason --keep-certs --help" -- "${cur}"))
                             ;;
                     esac
                     ;;
                 remote)
                     case ${words[2]} in
                         enroll)
-                            COMPREPLY=($(compgen -W "--host --user --name --port --san --identity --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--host --user --name --port --san --identity --proxy-jump --privilege --group --label --help" -- "${cur}"))
                             ;;
                         status)
-                            COMPREPLY=($(compgen -W "--host --user --all --port --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--host --user --all --group --selector --port --parallel --timeout --log-dir --help" -- "${cur}"))
                             ;;
                         update-ca-url)
-                            COMPREPLY=($(compgen -W "--new-url --host --user --help" -- "${cur}"))
+                            COMPREPLY=($(compgen -W "--new-url --host --user --group --selector --fingerprint --parallel --timeout --log-dir --yes --help" -- "${cur}"))
+                            ;;
+                        renew)
+                            COMPREPLY=($(compgen -W "--host --user --all --group --selector --force --parallel --timeout --log-dir --help" -- "${cur}"))
+                            ;;
+                        list)
+                            COMPREPLY=($(compgen -W "--group --selector --json --help" -- "${cur}"))
                             ;;
                     esac
                     ;;
@@ -88,6 +104,16 @@ _auto_ssl() {
                             ;;
                     esac
                     ;;
+                agent)
+                    case ${words[2]} in
+                        run)
+                            COMPREPLY=($(compgen -W "--name --renew-at --jitter --backoff --max-backoff --exec --status-dir" -- "${cur}"))
+                            ;;
+                        status)
+                            COMPREPLY=($(compgen -W "--name --json --status-dir" -- "${cur}"))
+                            ;;
+                    esac
+                    ;;
             esac
             ;;
     esac
@@ -199,6 +199,45 @@ duration_to_hours() {
     esac
 }
 
+# Parse a Go-style duration ("90s", "15m", "24h", "1h30m") to seconds
+duration_to_seconds() {
+    local duration="$1"
+    local rest="$duration"
+    local total=0
+    
+    [[ -z "$rest" ]] && return 1
+    while [[ -n "$rest" ]]; do
+        [[ "$rest" =~ ^([0-9]+)([hms])(.*)$ ]] || return 1
+        case "${BASH_REMATCH[2]}" in
+            h) total=$((total + BASH_REMATCH[1] * 3600)) ;;
+            m) total=$((total + BASH_REMATCH[1] * 60)) ;;
+            s) total=$((total + BASH_REMATCH[1])) ;;
+        esac
+        rest="${BASH_REMATCH[3]}"
+    done
+    echo "$total"
+}
+
+# token_claims TOKEN
+# Prints the JSON claims of a JWT, without checking its signature
+token_claims() {
+    local payload
+    payload=$(cut -d. -f2 <<< "$1" | tr '_-' '/+')
+    while (( ${#payload} % 4 )); do
+        payload+="="
+    done
+    base64 -d <<< "$payload" 2>/dev/null
+}
+
+# token_expiry TOKEN
+# Prints when a JWT expires (its exp claim), in seconds since the epoch
+token_expiry() {
+    local exp
+    exp=$(token_claims "$1" | grep -o '"exp":[0-9]*' | cut -d: -f2) || return 1
+    [[ -n "$exp" ]] || return 1
+    echo "$exp"
+}
+
 # Format hours to human-readable duration
 hours_to_human() {
     local hours="$1"
@@ -245,18 +284,21 @@ confirm() {
 # Path and config helpers
 #--------------------------------------------------
 
-# Standard paths
+# Standard paths (the Go companion resolves the same variables)
 AUTO_SSL_CONFIG_DIR="${AUTO_SSL_CONFIG_DIR:-/etc/auto-ssl}"
+AUTO_SSL_CONFIG_FILE="${AUTO_SSL_CONFIG_FILE:-${AUTO_SSL_CONFIG_DIR}/config.yaml}"
 AUTO_SSL_DATA_DIR="${AUTO_SSL_DATA_DIR:-/var/lib/auto-ssl}"
 AUTO_SSL_CERT_DIR="${AUTO_SSL_CERT_DIR:-/etc/ssl/auto-ssl}"
 AUTO_SSL_LOG_DIR="${AUTO_SSL_LOG_DIR:-/var/log/auto-ssl}"
 
-STEP_CA_PATH="${STEP_CA_PATH:-/opt/step-ca}"
+STEP_CA_PATH="${STEP_CA_PATH:-${STEPPATH:-/opt/step-ca}}"
 STEP_CA_CONFIG="${STEP_CA_PATH}/config/ca.json"
 
+export AUTO_SSL_CONFIG_DIR AUTO_SSL_CONFIG_FILE AUTO_SSL_CERT_DIR STEP_CA_PATH
+
 # Ensure directories exist
 ensure_dirs() {
-    local dirs=("$AUTO_SSL_CONFIG_DIR" "$AUTO_SSL_DATA_DIR" "$AUTO_SSL_CERT_DIR")
+    local dirs=("$AUTO_SSL_CONFIG_DIR" "$(dirname "$AUTO_SSL_CONFIG_FILE")" "$AUTO_SSL_DATA_DIR" "$AUTO_SSL_CERT_DIR")
     for dir in "${dirs[@]}"; do
         if [[ ! -d "$dir" ]]; then
             mkdir -p "$dir"
@@ -265,15 +307,82 @@ ensure_dirs() {
     done
 }
 
+#--------------------------------------------------
+# Go companion helpers
+#--------------------------------------------------
+
+# The Go binary exports AUTO_SSL_BIN when it runs the embedded runtime.
+# Ejected runtimes fall back to an installed auto-ssl-tui.
+AUTO_SSL_BIN="${AUTO_SSL_BIN:-$(command -v auto-ssl-tui 2>/dev/null || true)}"
+
+# Check whether the Go companion is available
+has_companion() {
+    [[ -n "${AUTO_SSL_BIN}" ]] && [[ -x "${AUTO_SSL_BIN}" ]]
+}
+
+# Fail unless the Go companion is available for a feature that needs it
+require_companion() {
+    local feature="$1"
+    has_companion || die "Managing ${feature} requires the auto-ssl binary (set AUTO_SSL_BIN or install auto-ssl-tui)"
+}
+
+# Read one top-level field from `auto-ssl tools cert inspect --json`
+# Usage: cert_field <file|host:port|-> <field>
+cert_field() {
+    local target="$1"
+    local field="$2"
+
+    has_companion || return 1
+
+    local value
+    value=$("$AUTO_SSL_BIN" tools cert inspect --json "$target" 2>/dev/null | \
+        sed -n "s/^  \"${field}\": \"\{0,1\}\([^\",]*\)\"\{0,1\},\{0,1\}\$/\1/p" | head -1)
+
+    [[ -n "$value" ]] || return 1
+    echo "$value"
+}
+
 #--------------------------------------------------
 # Config file helpers
 #--------------------------------------------------
 
+# Name of the variable carrying a ca.* key for the active context.
+# The Go binary exports these when a context other than "default" is active.
+_context_ca_var() {
+    [[ -n "${AUTO_SSL_CONTEXT:-}" && "${AUTO_SSL_CONTEXT}" != "default" ]] || return 1
+    case "$1" in
+        ca.url)         echo "AUTO_SSL_CA_URL" ;;
+        ca.fingerprint) echo "AUTO_SSL_CA_FINGERPRINT" ;;
+        ca.name)        echo "AUTO_SSL_CA_NAME" ;;
+        *)              return 1 ;;
+    esac
+}
+
 # Read a value from the config file
+# Delegates to `auto-ssl tools config get` when the Go companion is
+# available; the awk reader below only handles flat and one-level keys.
+# A config file that fails to load is reported on stderr and the default
+# is used, rather than silently looking like an unconfigured machine.
 config_get() {
     local key="$1"
     local default="${2:-}"
-    local config_file="${AUTO_SSL_CONFIG_DIR}/config.yaml"
+    local config_file="${AUTO_SSL_CONFIG_FILE}"
+    
+    local context_var
+    if context_var=$(_context_ca_var "$key"); then
+        echo "${!context_var:-$default}"
+        return 0
+    fi
+    
+    if has_companion; then
+        local value
+        if value=$("$AUTO_SSL_BIN" tools config get "$key" --config "$config_file") && [[ -n "$value" ]]; then
+            echo "$value"
+        else
+            echo "$default"
+        fi
+        return 0
+    fi
     
     if [[ -f "$config_file" ]]; then
         local value=""
@@ -311,14 +420,28 @@ config_get() {
 }
 
 # Write a value to the config file
+# With the Go companion the value is checked against the config schema
+# and rejected (non-zero exit) if invalid.
 config_set() {
     local key="$1"
     local value="$2"
-    local config_file="${AUTO_SSL_CONFIG_DIR}/config.yaml"
+    local config_file="${AUTO_SSL_CONFIG_FILE}"
     local tmp_file
     
     ensure_dirs
     
+    if has_companion; then
+        # CA settings belong to the active context, not the top-level block
+        local context_var
+        if context_var=$(_context_ca_var "$key"); then
+            "$AUTO_SSL_BIN" tools config set "contexts.${AUTO_SSL_CONTEXT}.${key}" "$value" --config "$config_file" || return
+            export "${context_var}=${value}"
+            return 0
+        fi
+        "$AUTO_SSL_BIN" tools config set "$key" "$value" --config "$config_file"
+        return
+    fi
+    
     if [[ "$key" == *"."* ]]; then
         local parent="${key%%.*}"
         local child="${key#*.}"
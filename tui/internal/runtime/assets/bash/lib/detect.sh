@@ -188,6 +188,25 @@ get_step_ca_version() {
     fi
 }
 
+#--------------------------------------------------
+# Web Server Detection
+#--------------------------------------------------
+
+# Services that commonly serve the server certificate
+WEB_SERVER_UNITS=(nginx caddy apache2 httpd haproxy envoy)
+
+# Print the running web server units, one per line
+detect_web_servers() {
+    [[ "$(detect_service_manager)" == "systemd" ]] || return 0
+    
+    local unit
+    for unit in "${WEB_SERVER_UNITS[@]}"; do
+        if systemctl is-active --quiet "$unit" 2>/dev/null; then
+            echo "$unit"
+        fi
+    done
+}
+
 #--------------------------------------------------
 # Environment Detection (CA/Server/Client role)
 #--------------------------------------------------
@@ -256,6 +275,7 @@ print_detection_summary() {
     echo "  step-ca:       $(get_step_ca_version)"
     echo "  gum:           $(has_gum && echo 'yes' || echo 'no')"
     echo "  jq:            $(has_jq && echo 'yes' || echo 'no')"
+    echo "  Web servers:   $(detect_web_servers | paste -sd ' ' - | sed 's/^$/none/')"
     echo ""
     echo "Detected Mode:   $(detect_mode)"
     echo "  Is CA Server:  $(is_ca_server && echo 'yes' || echo 'no')"
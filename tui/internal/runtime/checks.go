@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
+)
+
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// CheckStatus is the outcome of one doctor check beyond dependency lookup.
+type CheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	File   string `json:"file,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Checks inspects the local config and inventory files. A file that does not
+// parse fails; unknown keys and schema problems are reported separately so a
+// typo is not mistaken for an unconfigured machine.
+func Checks() []CheckStatus {
+	return []CheckStatus{
+		configCheck(config.ConfigFile()),
+		inventoryCheck(config.InventoryFile()),
+	}
+}
+
+// FailedChecks returns the checks with status fail.
+func FailedChecks(checks []CheckStatus) []CheckStatus {
+	failed := make([]CheckStatus, 0)
+	for _, check := range checks {
+		if check.Status == CheckFail {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+func configCheck(path string) CheckStatus {
+	check := CheckStatus{Name: "config", File: path}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		check.Status = CheckOK
+		check.Detail = "not present, defaults in use"
+		return check
+	}
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	if fieldErrs := cfg.Validate(); len(fieldErrs) > 0 {
+		check.Status = CheckFail
+		check.Detail = fieldErrs[0].Error()
+		if len(fieldErrs) > 1 {
+			check.Detail += fmt.Sprintf(" (and %d more; run auto-ssl tools config validate)", len(fieldErrs)-1)
+		}
+		return check
+	}
+	if _, err := config.LoadFileStrict(path); err != nil {
+		check.Status = CheckWarn
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Status = CheckOK
+	check.Detail = "parsed and valid"
+	return check
+}
+
+func inventoryCheck(path string) CheckStatus {
+	check := CheckStatus{Name: "inventory", File: path}
+
+	inv, err := config.LoadInventoryFile(path)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+
+	if fieldErrs := inv.Validate(); len(fieldErrs) > 0 {
+		check.Status = CheckFail
+		check.Detail = fieldErrs[0].Error()
+		if len(fieldErrs) > 1 {
+			check.Detail += fmt.Sprintf(" (and %d more)", len(fieldErrs)-1)
+		}
+		return check
+	}
+
+	check.Status = CheckOK
+	check.Detail = fmt.Sprintf("%d server(s)", len(inv.Servers))
+	return check
+}
@@ -22,7 +22,6 @@ func Doctor() []DependencyStatus {
 		{Name: "step", Required: true, Purpose: "certificate issuance and renewal"},
 		{Name: "step-ca", Required: true, Purpose: "CA server operations"},
 		{Name: "curl", Required: true, Purpose: "CA health and certificate downloads"},
-		{Name: "ssh", Required: false, Purpose: "remote enrollment workflows"},
 		{Name: "systemctl", Required: false, Purpose: "service and renewal timers"},
 	}
 
@@ -37,8 +36,14 @@ func Doctor() []DependencyStatus {
 	return deps
 }
 
+// DoctorReport is the machine-readable form of `doctor --json`.
+type DoctorReport struct {
+	Dependencies []DependencyStatus `json:"dependencies"`
+	Checks       []CheckStatus      `json:"checks"`
+}
+
 func DoctorJSON() (string, error) {
-	data, err := json.MarshalIndent(Doctor(), "", "  ")
+	data, err := json.MarshalIndent(DoctorReport{Dependencies: Doctor(), Checks: Checks()}, "", "  ")
 	if err != nil {
 		return "", err
 	}
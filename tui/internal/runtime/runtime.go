@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"crypto/sha256"
 	"embed"
 	"errors"
@@ -14,6 +15,9 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/Brightblade42/auto-ssl/internal/config"
 )
 
 //go:embed assets/bash/**
@@ -41,23 +45,91 @@ func (m *Manager) AutoSSLPath() (string, error) {
 	return filepath.Join(dir, "auto-ssl"), nil
 }
 
-func (m *Manager) Exec(args ...string) *exec.Cmd {
+// Command prepares the embedded auto-ssl script with the companion
+// environment, so the Bash runtime can call back into this binary.
+func (m *Manager) Command(args ...string) (*exec.Cmd, error) {
+	return m.CommandContext(context.Background(), args...)
+}
+
+// CommandContext is Command, but the script is killed when ctx is done.
+func (m *Manager) CommandContext(ctx context.Context, args ...string) (*exec.Cmd, error) {
 	path, err := m.AutoSSLPath()
+	if err != nil {
+		return nil, err
+	}
+	ctxEnv, err := ContextEnv()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = append(os.Environ(), companionEnv()...)
+	cmd.Env = append(cmd.Env, ctxEnv...)
+	// Remote helpers can outlive a killed script and hold its output open.
+	cmd.WaitDelay = 5 * time.Second
+	return cmd, nil
+}
+
+func (m *Manager) Exec(args ...string) *exec.Cmd {
+	cmd, err := m.Command(args...)
 	if err != nil {
 		return exec.Command("/usr/bin/env", "false")
 	}
-	return exec.Command(path, args...)
+	return cmd
 }
 
 func (m *Manager) RunCombinedOutput(args ...string) ([]byte, error) {
-	path, err := m.AutoSSLPath()
+	cmd, err := m.Command(args...)
 	if err != nil {
 		return nil, err
 	}
-	cmd := exec.Command(path, args...)
 	return cmd.CombinedOutput()
 }
 
+// companionEnv exports the path of the running binary as AUTO_SSL_BIN.
+func companionEnv() []string {
+	self, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(self); err == nil {
+		self = resolved
+	}
+	return []string{"AUTO_SSL_BIN=" + self}
+}
+
+// ContextEnv exports the active CA context so the Bash runtime uses its CA
+// instead of the top-level ca block. The default context adds nothing.
+func ContextEnv() ([]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		// Without an explicit --context the runtime reports the broken file
+		// itself; with one, there is no way to honour the request.
+		if os.Getenv(config.EnvContext) != "" {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	name, ca, err := cfg.ActiveContext()
+	if err != nil {
+		return nil, err
+	}
+	if name == config.DefaultContext {
+		return nil, nil
+	}
+
+	env := []string{
+		config.EnvContext + "=" + name,
+		"AUTO_SSL_CA_URL=" + ca.URL,
+		"AUTO_SSL_CA_FINGERPRINT=" + ca.Fingerprint,
+		"AUTO_SSL_CA_NAME=" + ca.Name,
+	}
+	if ca.StepPath != "" {
+		env = append(env, config.EnvStepCAPath+"="+ca.StepPath)
+	}
+	return env, nil
+}
+
 func (m *Manager) DumpBash(outputDir string, force bool) (string, error) {
 	if strings.TrimSpace(outputDir) == "" {
 		outputDir = "./auto-ssl-bash"
@@ -0,0 +1,131 @@
+package s3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Credentials are an access key pair, with a session token for temporary
+// credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// LoadCredentials finds credentials where the aws CLI does: in
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, then in the profile
+// AWS_PROFILE (default "default") of the shared credentials file,
+// AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials. Existing `aws
+// configure` setups keep working.
+func LoadCredentials() (Credentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = filepath.Join(homeDir(), ".aws", "credentials")
+	}
+	profile := profileName()
+	section, err := readProfile(path, profile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Credentials{}, fmt.Errorf("no S3 credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or add them to %s", path)
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds := Credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("%s: profile %q has no aws_access_key_id and aws_secret_access_key", path, profile)
+	}
+	return creds, nil
+}
+
+// configRegion returns the region of the AWS_PROFILE profile in the shared
+// config file, AWS_CONFIG_FILE or ~/.aws/config, if it sets one.
+func configRegion() string {
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		path = filepath.Join(homeDir(), ".aws", "config")
+	}
+	profile := profileName()
+	// The config file names profiles other than the default "profile NAME".
+	if profile != "default" {
+		profile = "profile " + profile
+	}
+	section, err := readProfile(path, profile)
+	if err != nil {
+		return ""
+	}
+	return section["region"]
+}
+
+func profileName() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// homeDir falls back to the user database, since systemd does not set HOME
+// for root's services.
+func homeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	if u, err := user.Current(); err == nil {
+		return u.HomeDir
+	}
+	return ""
+}
+
+var sectionLine = regexp.MustCompile(`^\[\s*(.+?)\s*\]$`)
+
+// readProfile returns the keys of one [section] of an AWS ini file.
+func readProfile(path, name string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var section map[string]string
+	scanner := bufio.NewScanner(f)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if m := sectionLine.FindStringSubmatch(line); m != nil {
+			inSection = m[1] == name
+			if inSection && section == nil {
+				section = map[string]string{}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if inSection && ok {
+			section[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if section == nil {
+		return nil, fmt.Errorf("%s: no profile %q", path, name)
+	}
+	return section, nil
+}
@@ -0,0 +1,356 @@
+// Package s3 is a small client for the S3 API: enough to upload, list and
+// delete backups in AWS S3 and in S3-compatible services such as Wasabi,
+// MinIO and Backblaze B2, with requests signed by AWS Signature Version 4.
+// Large uploads go up in parts, so no single request carries the whole
+// archive.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPartSize is the size of each part of a multipart upload, and
+	// the size above which uploads are multipart.
+	DefaultPartSize = 16 << 20
+	// MinPartSize is the smallest part S3 accepts, but for the last one.
+	MinPartSize = 5 << 20
+	maxParts    = 10000
+
+	maxAttempts = 3
+)
+
+// retryDelay is the wait before the first retry; it doubles after that.
+var retryDelay = time.Second
+
+// Client talks to one bucket.
+type Client struct {
+	Bucket      string
+	Region      string
+	Endpoint    *url.URL
+	PathStyle   bool // bucket in the path rather than in the host name
+	Credentials Credentials
+	PartSize    int64
+	HTTPClient  *http.Client
+}
+
+// New returns a client for bucket at endpoint, e.g. https://s3.wasabisys.com
+// or http://127.0.0.1:9000 for a local MinIO; AWS when endpoint is "".
+// An empty region is taken from the endpoint's host name when it names one
+// (s3.eu-central-1.wasabisys.com, s3.us-west-004.backblazeb2.com), then
+// from AWS_REGION, AWS_DEFAULT_REGION and the shared config file, and is
+// us-east-1 otherwise. Credentials are loaded with LoadCredentials.
+func New(bucket, endpoint, region string) (*Client, error) {
+	if bucket == "" {
+		return nil, errors.New("s3: no bucket")
+	}
+	creds, err := LoadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	var u *url.URL
+	if endpoint != "" {
+		if u, err = url.Parse(endpoint); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("s3: endpoint must be an http(s) URL, got %q", endpoint)
+		}
+	}
+	if region == "" {
+		region = resolveRegion(u)
+	}
+	c := &Client{
+		Bucket:      bucket,
+		Region:      region,
+		Endpoint:    u,
+		Credentials: creds,
+		PartSize:    DefaultPartSize,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Minute},
+	}
+	if c.Endpoint == nil {
+		// Bucket names with dots do not match the wildcard certificate.
+		c.Endpoint = &url.URL{Scheme: "https", Host: "s3." + region + ".amazonaws.com"}
+		c.PathStyle = strings.Contains(bucket, ".")
+	} else {
+		// MinIO and most self-hosted services only serve path-style
+		// requests; Wasabi and B2 serve both.
+		c.PathStyle = true
+	}
+	return c, nil
+}
+
+var regionHost = regexp.MustCompile(`^s3[.-]([a-z0-9-]+)\.(amazonaws\.com|wasabisys\.com|backblazeb2\.com)$`)
+
+func resolveRegion(endpoint *url.URL) string {
+	if endpoint != nil {
+		host := endpoint.Hostname()
+		if m := regionHost.FindStringSubmatch(host); m != nil {
+			return m[1]
+		}
+		if host == "s3.wasabisys.com" {
+			return "us-east-1"
+		}
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+	if region := configRegion(); region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+func (c *Client) String() string {
+	return "s3://" + c.Bucket
+}
+
+// Error is an error response from the service.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Code != "" && e.Message != "":
+		return fmt.Sprintf("s3: %s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+	case e.Code != "":
+		return fmt.Sprintf("s3: %s (HTTP %d)", e.Code, e.StatusCode)
+	default:
+		return fmt.Sprintf("s3: HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+}
+
+func parseError(status int, body []byte) *Error {
+	var e struct {
+		Code    string
+		Message string
+	}
+	xml.Unmarshal(body, &e)
+	return &Error{StatusCode: status, Code: e.Code, Message: e.Message}
+}
+
+// url returns the URL of key, or of the bucket when key is "", in the
+// canonical form the signature needs.
+func (c *Client) url(key string, query url.Values) *url.URL {
+	host := c.Endpoint.Host
+	path := strings.TrimSuffix(c.Endpoint.Path, "/")
+	if c.PathStyle {
+		path += "/" + c.Bucket
+		if key != "" {
+			path += "/" + key
+		}
+	} else {
+		host = c.Bucket + "." + host
+		path += "/" + key
+	}
+	return &url.URL{
+		Scheme:   c.Endpoint.Scheme,
+		Host:     host,
+		Path:     path,
+		RawPath:  uriEncode(path, true),
+		RawQuery: canonicalQuery(query),
+	}
+}
+
+// do sends a signed request and returns the response of the first attempt
+// that is not a server error, or the error. Server errors, throttling and
+// network errors are retried, with the same body.
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(1<<(attempt-2)) * retryDelay):
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.url(key, query).String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		sign(req, c.Credentials, c.Region, payloadHash, time.Now())
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		lastErr = parseError(resp.StatusCode, data)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// call is do for requests whose XML response, if any, is decoded into out.
+func (c *Client) call(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte, out any) (http.Header, error) {
+	resp, err := c.do(ctx, method, key, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// CompleteMultipartUpload can fail after its 200 OK.
+	if bytes.Contains(data, []byte("<Error>")) {
+		return nil, parseError(resp.StatusCode, data)
+	}
+	if out != nil {
+		if err := xml.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("s3: %s %s: %w", method, key, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// Object is an object in a listing.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+}
+
+// List returns every object whose key starts with prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		var result struct {
+			Contents              []Object `xml:"Contents"`
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if _, err := c.call(ctx, http.MethodGet, "", query, nil, nil, &result); err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete deletes key. Deleting a key that does not exist succeeds.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.call(ctx, http.MethodDelete, key, nil, nil, nil, nil)
+	return err
+}
+
+// Put uploads size bytes from r as key: in one request up to the part size,
+// as a multipart upload above it. A failed multipart upload is aborted, so
+// its parts are not left behind to be billed.
+func (c *Client) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	partSize := c.PartSize
+	if partSize < MinPartSize {
+		partSize = MinPartSize
+	}
+	// Very large archives need larger parts to stay within 10000 of them.
+	if least := (size + maxParts - 1) / maxParts; partSize < least {
+		partSize = least
+	}
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if size <= partSize {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		_, err := c.call(ctx, http.MethodPut, key, nil, header, data, nil)
+		return err
+	}
+
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if _, err := c.call(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil, &initiated); err != nil {
+		return err
+	}
+	if initiated.UploadID == "" {
+		return fmt.Errorf("s3: no upload ID for %s", key)
+	}
+	if err := c.putParts(ctx, key, initiated.UploadID, r, size, partSize); err != nil {
+		// Abort even when ctx was canceled.
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		c.call(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, nil, nil)
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (c *Client) putParts(ctx context.Context, key, uploadID string, r io.Reader, size, partSize int64) error {
+	var parts []completedPart
+	buf := make([]byte, partSize)
+	for number, left := 1, size; left > 0; number++ {
+		n := partSize
+		if left < n {
+			n = left
+		}
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		header, err := c.call(ctx, http.MethodPut, key, query, nil, buf[:n], nil)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", number, err)
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+		left -= n
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	_, err = c.call(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, http.Header{"Content-Type": {"application/xml"}}, body, nil)
+	return err
+}
@@ -0,0 +1,458 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryDelay = time.Millisecond
+}
+
+// fakeS3 is an in-memory bucket served path-style. It checks that each
+// request is signed and that its payload hash matches the body, and keeps
+// multipart uploads until they are completed or aborted.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	aborted  []string
+	requests []string // "METHOD key?query" of each request
+	pageSize int
+
+	// intercept, when set, can answer a request instead of the bucket.
+	intercept func(w http.ResponseWriter, r *http.Request) bool
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *Client) {
+	t.Helper()
+	f := &fakeS3{
+		objects:  map[string][]byte{},
+		uploads:  map[string]map[int][]byte{},
+		pageSize: 1000,
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	endpoint, _ := url.Parse(srv.URL)
+	return f, &Client{
+		Bucket:      "backups",
+		Region:      "us-east-1",
+		Endpoint:    endpoint,
+		PathStyle:   true,
+		Credentials: Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+		PartSize:    MinPartSize,
+		HTTPClient:  srv.Client(),
+	}
+}
+
+func (f *fakeS3) log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s from the fake</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/backups"), "/")
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+key+"?"+r.URL.RawQuery)
+	f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+		writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+		return
+	}
+	if f.intercept != nil && f.intercept(w, r) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.list(w, query)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := "upload-" + strconv.Itoa(len(f.uploads)+len(f.aborted)+1)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.complete(w, key, query.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		f.aborted = append(f.aborted, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(strings.TrimPrefix(token, "page/"))
+	}
+	end := start + f.pageSize
+	if end > len(keys) {
+		end = len(keys)
+	}
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys[start:end] {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2024-03-01T02:03:04.000Z</LastModified><ETag>&quot;x&quot;</ETag></Contents>",
+			key, len(f.objects[key]))
+	}
+	if end < len(keys) {
+		// A token with characters that need encoding in the query.
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>page/%d</NextContinuationToken>", end)
+	} else {
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated>")
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, key, id string, body []byte) {
+	parts, ok := f.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var req struct {
+		Parts []completedPart `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var object []byte
+	for i, part := range req.Parts {
+		if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, part.PartNumber) {
+			writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		object = append(object, parts[part.PartNumber]...)
+	}
+	delete(f.uploads, id)
+	f.objects[key] = object
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestPutSingleRequest(t *testing.T) {
+	f, c := newFakeS3(t)
+	data := []byte("small archive")
+	if err := c.Put(context.Background(), "auto-ssl/ca.tar.gz.enc", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.objects["auto-ssl/ca.tar.gz.enc"]; !bytes.Equal(got, data) {
+		t.Errorf("object = %q, want %q", got, data)
+	}
+	if got := f.log(); len(got) != 1 || !strings.HasPrefix(got[0], "PUT ") {
+		t.Errorf("requests = %q, want one PUT", got)
+	}
+}
+
+func TestPutMultipart(t *testing.T) {
+	f, c := newFakeS3(t)
+	data := testData(2*MinPartSize + 1234)
+	if err := c.Put(context.Background(), "ca.tar.gz.enc", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.objects["ca.tar.gz.enc"]; !bytes.Equal(got, data) {
+		t.Errorf("object is %d bytes, want the %d uploaded", len(got), len(data))
+	}
+
+	want := []string{
+		"POST ca.tar.gz.enc?uploads=",
+		"PUT ca.tar.gz.enc?partNumber=1&uploadId=upload-1",
+		"PUT ca.tar.gz.enc?partNumber=2&uploadId=upload-1",
+		"PUT ca.tar.gz.enc?partNumber=3&uploadId=upload-1",
+		"POST ca.tar.gz.enc?uploadId=upload-1",
+	}
+	if got := f.log(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d uploads left open", len(f.uploads))
+	}
+}
+
+func TestPutMultipartAbortsOnFailure(t *testing.T) {
+	f, c := newFakeS3(t)
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("partNumber") == "2" {
+			writeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	data := testData(2*MinPartSize + 1)
+	err := c.Put(context.Background(), "ca.tar.gz.enc", bytes.NewReader(data), int64(len(data)))
+	var s3Err *Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "AccessDenied" {
+		t.Fatalf("err = %v, want AccessDenied", err)
+	}
+	if !strings.Contains(err.Error(), "part 2") {
+		t.Errorf("err = %v, want it to name the part", err)
+	}
+	if len(f.aborted) != 1 || len(f.uploads) != 0 {
+		t.Errorf("aborted %q with %d uploads open, want the upload aborted", f.aborted, len(f.uploads))
+	}
+	if _, ok := f.objects["ca.tar.gz.enc"]; ok {
+		t.Error("object was created by a failed upload")
+	}
+}
+
+func TestPutMultipartErrorAfterOK(t *testing.T) {
+	f, c := newFakeS3(t)
+	// CompleteMultipartUpload can answer 200 OK and fail in the body.
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPost && r.URL.Query().Has("uploadId") {
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				"<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>"))
+			return true
+		}
+		return false
+	}
+	data := testData(MinPartSize + 1)
+	err := c.Put(context.Background(), "ca.tar.gz.enc", bytes.NewReader(data), int64(len(data)))
+	var s3Err *Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "InternalError" || s3Err.StatusCode != http.StatusOK {
+		t.Fatalf("err = %#v, want InternalError with HTTP 200", err)
+	}
+	if len(f.aborted) != 1 {
+		t.Errorf("aborted %q, want the upload aborted", f.aborted)
+	}
+}
+
+func TestPutShortReader(t *testing.T) {
+	_, c := newFakeS3(t)
+	err := c.Put(context.Background(), "k", strings.NewReader("short"), 10)
+	if err == nil || !strings.Contains(err.Error(), "reading k") {
+		t.Errorf("err = %v, want a read error", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // answered in turn before the bucket answers
+		wantErr  int   // status of the error returned, 0 for success
+		attempts int
+	}{
+		{"server error", []int{http.StatusServiceUnavailable}, 0, 2},
+		{"throttled", []int{http.StatusTooManyRequests, http.StatusInternalServerError}, 0, 3},
+		{"persistent server error", []int{500, 502, 503, 504}, http.StatusServiceUnavailable, maxAttempts},
+		{"client error", []int{http.StatusForbidden}, http.StatusForbidden, 1},
+		{"not found", []int{http.StatusNotFound}, http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeS3(t)
+			attempts := 0
+			f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				attempts++
+				if attempts <= len(tt.statuses) {
+					writeError(w, tt.statuses[attempts-1], "Injected")
+					return true
+				}
+				return false
+			}
+			err := c.Put(context.Background(), "k", strings.NewReader("data"), 4)
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if tt.wantErr == 0 {
+				if err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+				if string(f.objects["k"]) != "data" {
+					t.Errorf("object = %q, want the body resent on retry", f.objects["k"])
+				}
+				return
+			}
+			var s3Err *Error
+			if !errors.As(err, &s3Err) || s3Err.StatusCode != tt.wantErr {
+				t.Errorf("err = %v, want HTTP %d", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	f, c := newFakeS3(t)
+	retryDelay = time.Minute
+	defer func() { retryDelay = time.Millisecond }()
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		writeError(w, http.StatusServiceUnavailable, "SlowDown")
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Delete(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's error", err)
+	}
+}
+
+func TestListContinuation(t *testing.T) {
+	f, c := newFakeS3(t)
+	f.pageSize = 2
+	for _, key := range []string{"auto-ssl/a", "auto-ssl/b", "auto-ssl/c", "auto-ssl/d", "auto-ssl/e", "other/x"} {
+		f.objects[key] = []byte(key)
+	}
+
+	objects, err := c.List(context.Background(), "auto-ssl/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+		if o.Size != int64(len(o.Key)) || o.LastModified.IsZero() {
+			t.Errorf("object %+v, want its size and modification time", o)
+		}
+	}
+	if want := "auto-ssl/a auto-ssl/b auto-ssl/c auto-ssl/d auto-ssl/e"; strings.Join(keys, " ") != want {
+		t.Errorf("keys = %q, want %s", keys, want)
+	}
+
+	want := []string{
+		"GET ?list-type=2&prefix=auto-ssl%2F",
+		"GET ?continuation-token=page%2F2&list-type=2&prefix=auto-ssl%2F",
+		"GET ?continuation-token=page%2F4&list-type=2&prefix=auto-ssl%2F",
+	}
+	if got := f.log(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestListError(t *testing.T) {
+	f, c := newFakeS3(t)
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return true
+	}
+	_, err := c.List(context.Background(), "")
+	var s3Err *Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "NoSuchBucket" {
+		t.Fatalf("err = %v, want NoSuchBucket", err)
+	}
+	if want := "s3: NoSuchBucket: NoSuchBucket from the fake (HTTP 404)"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f, c := newFakeS3(t)
+	f.objects["old.tar.gz.enc"] = []byte("x")
+	if err := c.Delete(context.Background(), "old.tar.gz.enc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.objects["old.tar.gz.enc"]; ok {
+		t.Error("object still exists")
+	}
+	// S3 reports success for keys that do not exist.
+	if err := c.Delete(context.Background(), "missing"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		endpoint  string
+		pathStyle bool
+		key       string
+		want      string
+	}{
+		{"https://s3.us-east-1.amazonaws.com", false, "a/b c", "https://backups.s3.us-east-1.amazonaws.com/a/b%20c"},
+		{"https://s3.wasabisys.com", true, "a/b", "https://s3.wasabisys.com/backups/a/b"},
+		{"http://127.0.0.1:9000/minio/", true, "", "http://127.0.0.1:9000/minio/backups"},
+	}
+	for _, tt := range tests {
+		endpoint, _ := url.Parse(tt.endpoint)
+		c := &Client{Bucket: "backups", Endpoint: endpoint, PathStyle: tt.pathStyle}
+		if got := c.url(tt.key, nil).String(); got != tt.want {
+			t.Errorf("url(%q) at %s = %s, want %s", tt.key, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/none")
+
+	tests := []struct {
+		endpoint string
+		env      string
+		want     string
+	}{
+		{"https://s3.eu-central-1.wasabisys.com", "", "eu-central-1"},
+		{"https://s3.us-west-004.backblazeb2.com", "", "us-west-004"},
+		{"https://s3-eu-west-1.amazonaws.com", "", "eu-west-1"},
+		{"https://s3.wasabisys.com", "ap-south-1", "us-east-1"},
+		{"http://127.0.0.1:9000", "ap-south-1", "ap-south-1"},
+		{"", "", "us-east-1"},
+	}
+	for _, tt := range tests {
+		t.Setenv("AWS_REGION", tt.env)
+		var endpoint *url.URL
+		if tt.endpoint != "" {
+			endpoint, _ = url.Parse(tt.endpoint)
+		}
+		if got := resolveRegion(endpoint); got != tt.want {
+			t.Errorf("resolveRegion(%s) with AWS_REGION=%q = %s, want %s", tt.endpoint, tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package tui
+
+import "strings"
+
+// Field is a single form input that maps onto an auto-ssl command-line flag.
+type Field struct {
+	Label       string
+	Flag        string
+	Placeholder string
+	Default     string
+	Required    bool
+	// Repeat splits a comma-separated value into one flag per entry (e.g. --san).
+	Repeat bool
+	// Switch treats the value as yes/no and emits the bare flag on yes.
+	Switch bool
+}
+
+// Action is a menu entry that runs one auto-ssl workflow.
+type Action struct {
+	Title       string
+	Description string
+	Args        []string
+	Extra       []string
+	Fields      []Field
+	// Root marks workflows that the Bash runtime refuses to run unprivileged.
+	Root bool
+	// Interactive hands the terminal to the command instead of streaming it,
+	// for workflows that ask for confirmation or secrets.
+	Interactive bool
+	// Builtin names an in-process handler instead of a runtime command.
+	Builtin string
+}
+
+// Category groups related actions on the main menu.
+type Category struct {
+	Title       string
+	Description string
+	Actions     []Action
+}
+
+const (
+	builtinDoctor      = "doctor"
+	builtinInstallDeps = "install-deps"
+)
+
+func categories() []Category {
+	return []Category{
+		{
+			Title:       "Certificate Authority",
+			Description: "Initialize, inspect and back up the CA on this machine",
+			Actions: []Action{
+				{Title: "Status", Description: "Show CA health and configuration", Args: []string{"ca", "status"}},
+				{
+					Title:       "Initialize",
+					Description: "Initialize this machine as the CA server",
+					Args:        []string{"ca", "init"},
+					Extra:       []string{"--non-interactive"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "CA name", Flag: "--name", Default: "Internal CA"},
+						{Label: "Listen address", Flag: "--address", Placeholder: "auto-detected <ip>:9000"},
+						{Label: "Default duration", Flag: "--cert-duration", Default: "168h"},
+						{Label: "Maximum duration", Flag: "--max-duration", Default: "720h"},
+						{Label: "Password file", Flag: "--password-file", Placeholder: "/root/ca-password", Required: true},
+					},
+				},
+				{
+					Title:       "Backup",
+					Description: "Create an encrypted backup of the CA",
+					Args:        []string{"ca", "backup"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "Output file", Flag: "--output", Placeholder: "/var/backups/auto-ssl/ca-backup.enc", Required: true},
+						{Label: "Passphrase file", Flag: "--passphrase-file", Default: "/etc/auto-ssl/backup-passphrase", Required: true},
+					},
+				},
+				{
+					Title:       "Restore",
+					Description: "Restore the CA from an encrypted backup",
+					Args:        []string{"ca", "restore"},
+					Root:        true,
+					Interactive: true,
+					Fields: []Field{
+						{Label: "Backup file", Flag: "--input", Required: true},
+						{Label: "Passphrase file", Flag: "--passphrase-file"},
+						{Label: "New address", Flag: "--new-address", Placeholder: "only if the CA IP changed"},
+					},
+				},
+				{Title: "Backup schedule", Description: "Show automatic backup status", Args: []string{"ca", "backup-schedule"}, Root: true},
+				{
+					Title:       "Enrollment token",
+					Description: "Mint a one-time token for a server",
+					Args:        []string{"ca", "token"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "SANs", Flag: "--san", Placeholder: "comma separated, first is the subject", Required: true, Repeat: true},
+						{Label: "Valid for", Flag: "--ttl", Placeholder: "default 5m; e.g. 30m or 24h"},
+					},
+				},
+				{Title: "Reset", Description: "Remove the CA and local auto-ssl state", Args: []string{"ca", "reset"}, Root: true, Interactive: true},
+			},
+		},
+		{
+			Title:       "Server",
+			Description: "Enroll this server and manage its certificate",
+			Actions: []Action{
+				{Title: "Status", Description: "Show certificate status and expiration", Args: []string{"server", "status"}},
+				{Title: "Renewal agent", Description: "Show the next renewal, last renewal and errors", Args: []string{"agent", "status"}},
+				{
+					Title:       "Enroll",
+					Description: "Get a certificate and set up renewal",
+					Args:        []string{"server", "enroll"},
+					Extra:       []string{"--non-interactive"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "CA URL", Flag: "--ca-url", Placeholder: "https://192.168.1.100:9000", Required: true},
+						{Label: "Fingerprint", Flag: "--fingerprint", Required: true},
+						{Label: "SANs", Flag: "--san", Placeholder: "comma separated, default: primary IP", Repeat: true},
+						{Label: "Name", Flag: "--name", Placeholder: "for a further certificate, e.g. db"},
+						{Label: "Duration", Flag: "--duration", Placeholder: "default from CA"},
+						{Label: "Password file", Flag: "--password-file", Placeholder: "or use a token file"},
+						{Label: "Token file", Flag: "--token-file", Placeholder: "from auto-ssl ca token; sets the SANs"},
+						{Label: "Renew at", Flag: "--renew-at", Placeholder: "fraction of the lifetime, default 2/3"},
+						{Label: "Reload service", Flag: "--reload-service", Placeholder: "comma separated, default: running web servers", Repeat: true},
+						{Label: "Alert command", Flag: "--alert-cmd", Placeholder: "run when the renewed certificate is not served"},
+					},
+				},
+				{
+					Title:       "Enroll (ACME)",
+					Description: "Get a certificate over ACME; no provisioner credentials",
+					Args:        []string{"server", "enroll"},
+					Extra:       []string{"--acme", "--non-interactive"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "CA URL", Flag: "--ca-url", Placeholder: "https://192.168.1.100:9000", Required: true},
+						{Label: "Fingerprint", Flag: "--fingerprint", Required: true},
+						{Label: "SANs", Flag: "--san", Placeholder: "comma separated, default: primary IP", Repeat: true},
+						{Label: "Name", Flag: "--name", Placeholder: "for a further certificate, e.g. db"},
+						{Label: "Challenge", Flag: "--challenge", Default: "http-01", Placeholder: "http-01 or tls-alpn-01"},
+						{Label: "Webroot", Flag: "--webroot", Placeholder: "answer http-01 through this document root"},
+						{Label: "Email", Flag: "--email", Placeholder: "ACME account contact"},
+						{Label: "Renew at", Flag: "--renew-at", Placeholder: "fraction of the lifetime, default 2/3"},
+						{Label: "Reload service", Flag: "--reload-service", Placeholder: "comma separated, default: running web servers", Repeat: true},
+						{Label: "Alert command", Flag: "--alert-cmd", Placeholder: "run when the renewed certificate is not served"},
+					},
+				},
+				{
+					Title:       "Renew",
+					Description: "Force immediate certificate renewal",
+					Args:        []string{"server", "renew"},
+					Extra:       []string{"--force"},
+					Root:        true,
+					Fields: []Field{
+						{Label: "Name", Flag: "--name", Placeholder: "default: every certificate"},
+						{Label: "Post-renewal command", Flag: "--exec", Placeholder: "systemctl reload nginx"},
+					},
+				},
+				{
+					Title:       "Suspend",
+					Description: "Temporarily block certificate renewals",
+					Args:        []string{"server", "suspend"},
+					Root:        true,
+					Fields:      []Field{{Label: "Reason", Flag: "--reason"}},
+				},
+				{Title: "Resume", Description: "Re-enable certificate renewals", Args: []string{"server", "resume"}, Root: true},
+				{
+					Title:       "Revoke",
+					Description: "Revoke the current certificate",
+					Args:        []string{"server", "revoke"},
+					Root:        true,
+					Interactive: true,
+					Fields:      []Field{{Label: "Reason", Flag: "--reason"}},
+				},
+				{
+					Title:       "Remove",
+					Description: "Revoke and remove auto-ssl from this server",
+					Args:        []string{"server", "remove"},
+					Root:        true,
+					Interactive: true,
+					Fields: []Field{
+						{Label: "Reason", Flag: "--reason"},
+						{Label: "Keep certificates (y/n)", Flag: "--keep-certs", Default: "n", Switch: true},
+					},
+				},
+			},
+		},
+		{
+			Title:       "Remote",
+			Description: "Manage enrolled servers over SSH from the CA",
+			Actions: []Action{
+				{Title: "List", Description: "List enrolled servers", Args: []string{"remote", "list"}},
+				{Title: "Status (all)", Description: "Check every enrolled server", Args: []string{"remote", "status", "--all"}},
+				{
+					Title:       "Status (selection)",
+					Description: "Check enrolled servers by group or label",
+					Args:        []string{"remote", "status", "--all"},
+					Fields: []Field{
+						{Label: "Group", Flag: "--group"},
+						{Label: "Selector", Flag: "--selector", Placeholder: "env=prod,role!=db"},
+						{Label: "Parallel", Flag: "--parallel", Default: "10"},
+					},
+				},
+				{
+					Title:       "Renew (selection)",
+					Description: "Renew certificates on enrolled servers by group or label",
+					Args:        []string{"remote", "renew", "--all"},
+					Fields: []Field{
+						{Label: "Group", Flag: "--group"},
+						{Label: "Selector", Flag: "--selector", Placeholder: "env=prod,role!=db"},
+						{Label: "Force (y/n)", Flag: "--force", Default: "n", Switch: true},
+						{Label: "Parallel", Flag: "--parallel", Default: "10"},
+					},
+				},
+				{
+					Title:       "Status (host)",
+					Description: "Check a single remote server",
+					Args:        []string{"remote", "status"},
+					Fields: []Field{
+						{Label: "Host", Flag: "--host", Required: true},
+						{Label: "SSH user", Flag: "--user", Placeholder: "default: from inventory"},
+						{Label: "SSH port", Flag: "--port", Placeholder: "default: from inventory, else 22"},
+					},
+				},
+				{
+					Title:       "Enroll",
+					Description: "Enroll a remote server via SSH",
+					Args:        []string{"remote", "enroll"},
+					Fields: []Field{
+						{Label: "Host", Flag: "--host", Required: true},
+						{Label: "SSH user", Flag: "--user", Required: true},
+						{Label: "Name", Flag: "--name", Placeholder: "default: host"},
+						{Label: "SSH port", Flag: "--port", Default: "22"},
+						{Label: "SANs", Flag: "--san", Placeholder: "comma separated", Repeat: true},
+						{Label: "Identity file", Flag: "--identity", Placeholder: "~/.ssh/id_ed25519"},
+						{Label: "Jump host", Flag: "--proxy-jump", Placeholder: "user@bastion"},
+						{Label: "Privilege", Flag: "--privilege", Default: "sudo"},
+						{Label: "Host key", Flag: "--host-key", Placeholder: "SHA256:... (verify on the server)"},
+						{Label: "Group", Flag: "--group"},
+						{Label: "Labels", Flag: "--label", Placeholder: "env=prod,site=rack3", Repeat: true},
+					},
+				},
+				{
+					Title:       "Update CA URL",
+					Description: "Point enrolled servers at a migrated CA",
+					Args:        []string{"remote", "update-ca-url"},
+					Interactive: true,
+					Fields: []Field{
+						{Label: "New CA URL", Flag: "--new-url", Required: true},
+						{Label: "Host", Flag: "--host", Placeholder: "default: all enrolled servers"},
+						{Label: "SSH user", Flag: "--user"},
+						{Label: "Group", Flag: "--group"},
+						{Label: "Selector", Flag: "--selector", Placeholder: "env=prod,role!=db"},
+						{Label: "Canary servers", Flag: "--canary", Placeholder: "default: 1"},
+						{Label: "Batch size", Flag: "--batch-size", Placeholder: "default: 10"},
+						{Label: "Max failures", Flag: "--max-failures", Placeholder: "default: 0"},
+						{Label: "Roll back on stop (y/n)", Flag: "--rollback", Default: "n", Switch: true},
+					},
+				},
+			},
+		},
+		{
+			Title:       "Client",
+			Description: "Trust the CA on this machine",
+			Actions: []Action{
+				{Title: "Status", Description: "Verify the root CA is trusted", Args: []string{"client", "status"}},
+				{
+					Title:       "Trust",
+					Description: "Install the root CA into the system trust store",
+					Args:        []string{"client", "trust"},
+					Fields: []Field{
+						{Label: "CA URL", Flag: "--ca-url", Placeholder: "https://192.168.1.100:9000"},
+						{Label: "Fingerprint", Flag: "--fingerprint"},
+						{Label: "Root cert file", Flag: "--cert-file", Placeholder: "instead of downloading"},
+					},
+				},
+			},
+		},
+		{
+			Title:       "Tools",
+			Description: "Dependencies and environment",
+			Actions: []Action{
+				{Title: "Doctor", Description: "Check runtime dependencies", Builtin: builtinDoctor},
+				{Title: "Install dependencies", Description: "Install missing step/step-ca/curl", Builtin: builtinInstallDeps, Interactive: true},
+				{Title: "Environment info", Description: "Show detected environment and configuration", Args: []string{"info"}},
+			},
+		},
+	}
+}
+
+// commandArgs builds the auto-ssl argument list for an action from the
+// submitted form values, which are indexed like a.Fields.
+func (a Action) commandArgs(values []string) []string {
+	args := append([]string{}, a.Args...)
+	for i, field := range a.Fields {
+		value := strings.TrimSpace(values[i])
+		if value == "" {
+			continue
+		}
+		switch {
+		case field.Switch:
+			if isYes(value) {
+				args = append(args, field.Flag)
+			}
+		case field.Repeat:
+			for _, part := range strings.Split(value, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					args = append(args, field.Flag, part)
+				}
+			}
+		default:
+			args = append(args, field.Flag, value)
+		}
+	}
+	return append(args, a.Extra...)
+}
+
+func isYes(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "y", "yes", "true", "1":
+		return true
+	}
+	return false
+}
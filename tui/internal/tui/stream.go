@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type outputLineMsg string
+
+type commandDoneMsg struct {
+	err error
+}
+
+// stream runs a command with stdout and stderr merged and delivers its
+// output to the program one line at a time.
+type stream struct {
+	cmd   *exec.Cmd
+	lines chan string
+	done  chan error
+}
+
+func startStream(cmd *exec.Cmd) (*stream, error) {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	cmd.Stdin = nil
+
+	if err := cmd.Start(); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	s := &stream{
+		cmd:   cmd,
+		lines: make(chan string, 128),
+		done:  make(chan error, 1),
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			s.lines <- scanner.Text()
+		}
+		close(s.lines)
+	}()
+
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		s.done <- err
+	}()
+
+	return s, nil
+}
+
+// next waits for the next line of output, or for the exit status once the
+// output is drained.
+func (s *stream) next() tea.Cmd {
+	return func() tea.Msg {
+		if line, ok := <-s.lines; ok {
+			return outputLineMsg(line)
+		}
+		return commandDoneMsg{err: <-s.done}
+	}
+}
+
+func (s *stream) kill() {
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,29 @@
+package tui
+
+import "github.com/charmbracelet/lipgloss"
+
+var (
+	colorAmber = lipgloss.Color("214")
+	colorGreen = lipgloss.Color("82")
+	colorRed   = lipgloss.Color("196")
+	colorDim   = lipgloss.Color("244")
+	colorPanel = lipgloss.Color("236")
+
+	headerStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("16")).
+			Background(colorAmber).
+			Padding(0, 1)
+
+	subtleStyle  = lipgloss.NewStyle().Foreground(colorDim)
+	labelStyle   = lipgloss.NewStyle().Foreground(colorAmber).Bold(true)
+	okStyle      = lipgloss.NewStyle().Foreground(colorGreen).Bold(true)
+	errorStyle   = lipgloss.NewStyle().Foreground(colorRed).Bold(true)
+	commandStyle = lipgloss.NewStyle().Foreground(colorGreen)
+
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder()).
+			BorderForeground(colorPanel)
+
+	footerStyle = lipgloss.NewStyle().Foreground(colorDim).Padding(0, 1)
+)
@@ -0,0 +1,491 @@
+// Package tui implements the full-screen operator console for auto-ssl.
+//
+// Every workflow is executed through the embedded Bash runtime managed by
+// runtime.Manager; the console only collects arguments and streams output.
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/Brightblade42/auto-ssl/internal/runtime"
+)
+
+type screen int
+
+const (
+	screenCategories screen = iota
+	screenActions
+	screenForm
+	screenOutput
+)
+
+type menuItem struct {
+	title       string
+	description string
+}
+
+func (i menuItem) Title() string       { return i.title }
+func (i menuItem) Description() string { return i.description }
+func (i menuItem) FilterValue() string { return i.title }
+
+type model struct {
+	manager *runtime.Manager
+	version string
+
+	categories []Category
+	category   int
+	action     Action
+
+	screen screen
+	width  int
+	height int
+
+	menu list.Model
+
+	inputs    []textinput.Model
+	focus     int
+	formError string
+
+	output   viewport.Model
+	lines    []string
+	spinner  spinner.Model
+	running  *stream
+	busy     bool
+	exitLine string
+}
+
+// Run opens the console and blocks until the operator quits.
+func Run(manager *runtime.Manager, version string) error {
+	m := newModel(manager, version)
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+func newModel(manager *runtime.Manager, version string) *model {
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.Foreground(colorAmber).BorderForeground(colorAmber)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.Foreground(colorDim).BorderForeground(colorAmber)
+
+	menu := list.New(nil, delegate, 0, 0)
+	menu.SetShowStatusBar(false)
+	menu.SetFilteringEnabled(false)
+	menu.Styles.Title = labelStyle
+
+	spin := spinner.New()
+	spin.Spinner = spinner.Line
+	spin.Style = labelStyle
+
+	m := &model{
+		manager:    manager,
+		version:    version,
+		categories: categories(),
+		menu:       menu,
+		output:     viewport.New(0, 0),
+		spinner:    spin,
+	}
+	m.showCategories()
+	return m
+}
+
+func (m *model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		return m, nil
+	case outputLineMsg:
+		m.appendOutput(string(msg))
+		if m.running != nil {
+			return m, m.running.next()
+		}
+		return m, nil
+	case commandDoneMsg:
+		m.finish(msg.err)
+		return m, nil
+	case spinner.TickMsg:
+		if !m.busy {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			if m.running != nil {
+				m.running.kill()
+				return m, nil
+			}
+			return m, tea.Quit
+		}
+	}
+
+	switch m.screen {
+	case screenCategories, screenActions:
+		return m.updateMenu(msg)
+	case screenForm:
+		return m.updateForm(msg)
+	default:
+		return m.updateOutput(msg)
+	}
+}
+
+func (m *model) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "q":
+			return m, tea.Quit
+		case "esc", "backspace", "left", "h":
+			if m.screen == screenActions {
+				m.showCategories()
+				return m, nil
+			}
+			if key.String() == "esc" {
+				return m, tea.Quit
+			}
+		case "enter", "right", "l":
+			index := m.menu.Index()
+			if m.screen == screenCategories {
+				m.showActions(index)
+				return m, nil
+			}
+			return m, m.selectAction(m.categories[m.category].Actions[index])
+		}
+	}
+
+	var cmd tea.Cmd
+	m.menu, cmd = m.menu.Update(msg)
+	return m, cmd
+}
+
+func (m *model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "esc":
+			m.showActions(m.category)
+			return m, nil
+		case "tab", "down":
+			m.focusInput(m.focus + 1)
+			return m, nil
+		case "shift+tab", "up":
+			m.focusInput(m.focus - 1)
+			return m, nil
+		case "ctrl+s":
+			return m, m.submitForm()
+		case "enter":
+			if m.focus < len(m.inputs)-1 {
+				m.focusInput(m.focus + 1)
+				return m, nil
+			}
+			return m, m.submitForm()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+func (m *model) updateOutput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && !m.busy {
+		switch key.String() {
+		case "esc", "q", "backspace":
+			m.showActions(m.category)
+			return m, nil
+		case "r":
+			return m, m.selectAction(m.action)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.output, cmd = m.output.Update(msg)
+	return m, cmd
+}
+
+func (m *model) showCategories() {
+	items := make([]list.Item, 0, len(m.categories))
+	for _, c := range m.categories {
+		items = append(items, menuItem{title: c.Title, description: c.Description})
+	}
+	m.menu.Title = "Workflows"
+	m.menu.SetItems(items)
+	m.menu.Select(m.category)
+	m.screen = screenCategories
+}
+
+func (m *model) showActions(index int) {
+	m.category = index
+	category := m.categories[index]
+	items := make([]list.Item, 0, len(category.Actions))
+	for _, a := range category.Actions {
+		desc := a.Description
+		if a.Root {
+			desc += " [root]"
+		}
+		items = append(items, menuItem{title: a.Title, description: desc})
+	}
+	m.menu.Title = category.Title
+	m.menu.SetItems(items)
+	m.menu.Select(0)
+	m.screen = screenActions
+}
+
+func (m *model) selectAction(a Action) tea.Cmd {
+	m.action = a
+	if len(a.Fields) == 0 {
+		return m.launch(nil)
+	}
+
+	m.inputs = make([]textinput.Model, len(a.Fields))
+	for i, field := range a.Fields {
+		input := textinput.New()
+		input.Prompt = ""
+		input.Placeholder = field.Placeholder
+		input.SetValue(field.Default)
+		input.CharLimit = 512
+		input.Width = max(20, m.width-32)
+		m.inputs[i] = input
+	}
+	m.formError = ""
+	m.focusInput(0)
+	m.screen = screenForm
+	return textinput.Blink
+}
+
+func (m *model) focusInput(index int) {
+	if len(m.inputs) == 0 {
+		return
+	}
+	if index < 0 {
+		index = len(m.inputs) - 1
+	}
+	if index >= len(m.inputs) {
+		index = 0
+	}
+	for i := range m.inputs {
+		m.inputs[i].Blur()
+	}
+	m.focus = index
+	m.inputs[index].Focus()
+}
+
+func (m *model) submitForm() tea.Cmd {
+	values := make([]string, len(m.inputs))
+	for i, input := range m.inputs {
+		values[i] = input.Value()
+		if m.action.Fields[i].Required && strings.TrimSpace(values[i]) == "" {
+			m.formError = m.action.Fields[i].Label + " is required"
+			m.focusInput(i)
+			return nil
+		}
+	}
+	return m.launch(values)
+}
+
+// launch starts the selected action and switches to the output pane.
+func (m *model) launch(values []string) tea.Cmd {
+	a := m.action
+	m.screen = screenOutput
+	m.lines = nil
+	m.exitLine = ""
+	m.output.SetContent("")
+
+	if a.Root {
+		if err := runtime.RequireRoot(a.Title); err != nil {
+			m.appendOutput(err.Error())
+			m.finish(err)
+			return nil
+		}
+	}
+
+	switch a.Builtin {
+	case builtinDoctor:
+		m.appendOutput("$ auto-ssl tools doctor")
+		for _, line := range doctorLines() {
+			m.appendOutput(line)
+		}
+		m.finish(nil)
+		return nil
+	case builtinInstallDeps:
+		self, err := os.Executable()
+		if err != nil {
+			m.finish(err)
+			return nil
+		}
+		return m.execInteractive(exec.Command(self, "tools", "install-deps"), "auto-ssl tools install-deps")
+	}
+
+	args := a.commandArgs(values)
+	cmd := m.manager.Exec(args...)
+	display := "auto-ssl " + strings.Join(args, " ")
+
+	if a.Interactive {
+		return m.execInteractive(cmd, display)
+	}
+
+	m.appendOutput("$ " + display)
+	running, err := startStream(cmd)
+	if err != nil {
+		m.finish(err)
+		return nil
+	}
+	m.running = running
+	m.busy = true
+	return tea.Batch(m.spinner.Tick, running.next())
+}
+
+// execInteractive suspends the console and gives the command the terminal,
+// for workflows that prompt for confirmation or secrets.
+func (m *model) execInteractive(cmd *exec.Cmd, display string) tea.Cmd {
+	m.appendOutput("$ " + display)
+	m.appendOutput("(interactive session, output shown in the terminal)")
+	m.busy = true
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return commandDoneMsg{err: err}
+	})
+}
+
+func (m *model) finish(err error) {
+	m.running = nil
+	m.busy = false
+	if err != nil {
+		m.exitLine = errorStyle.Render("✗ " + err.Error())
+	} else {
+		m.exitLine = okStyle.Render("✓ completed")
+	}
+	m.output.GotoBottom()
+}
+
+func (m *model) appendOutput(line string) {
+	follow := m.output.AtBottom() || len(m.lines) == 0
+	m.lines = append(m.lines, line)
+	m.output.SetContent(strings.Join(m.lines, "\n"))
+	if follow {
+		m.output.GotoBottom()
+	}
+}
+
+func (m *model) resize() {
+	bodyHeight := max(3, m.height-2)
+	m.menu.SetSize(m.width, bodyHeight)
+	m.output.Width = max(10, m.width-2)
+	m.output.Height = max(1, bodyHeight-3)
+	for i := range m.inputs {
+		m.inputs[i].Width = max(20, m.width-32)
+	}
+}
+
+func (m *model) View() string {
+	var body, keys string
+	switch m.screen {
+	case screenCategories:
+		body = m.menu.View()
+		keys = "enter select • q quit"
+	case screenActions:
+		body = m.menu.View()
+		keys = "enter run • esc back • q quit"
+	case screenForm:
+		body = m.formView()
+		keys = "tab next • enter run on last field • ctrl+s run • esc back"
+	default:
+		body = m.outputView()
+		if m.busy {
+			keys = "pgup/pgdn scroll • ctrl+c stop"
+		} else {
+			keys = "pgup/pgdn scroll • r rerun • esc back"
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, m.headerView(), body, footerStyle.Render(keys))
+}
+
+func (m *model) headerView() string {
+	title := headerStyle.Render("AUTO-SSL // OPS CONSOLE")
+	role := "operator"
+	if runtime.IsRoot() {
+		role = "root"
+	}
+	info := subtleStyle.Render(fmt.Sprintf(" %s • %s • %s", m.version, runtime.PlatformLabel(), role))
+	return title + info
+}
+
+func (m *model) formView() string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render(m.action.Title))
+	b.WriteString(subtleStyle.Render("  " + m.action.Description))
+	b.WriteString("\n\n")
+	for i, field := range m.action.Fields {
+		label := field.Label
+		if field.Required {
+			label += " *"
+		}
+		marker := "  "
+		if i == m.focus {
+			marker = labelStyle.Render("› ")
+		}
+		b.WriteString(fmt.Sprintf("%s%-26s %s\n", marker, label, m.inputs[i].View()))
+	}
+	if m.formError != "" {
+		b.WriteString("\n" + errorStyle.Render(m.formError) + "\n")
+	}
+	if m.action.Root && !runtime.IsRoot() {
+		b.WriteString("\n" + errorStyle.Render("requires root: rerun with sudo auto-ssl-tui") + "\n")
+	}
+	return lipgloss.NewStyle().Padding(1, 2).Render(b.String())
+}
+
+func (m *model) outputView() string {
+	status := m.exitLine
+	if m.busy {
+		status = m.spinner.View() + " running"
+	}
+	title := labelStyle.Render(m.categories[m.category].Title + " › " + m.action.Title)
+	pane := paneStyle.Width(max(10, m.width-2)).Render(m.output.View())
+	return lipgloss.JoinVertical(lipgloss.Left, title, pane, status)
+}
+
+func doctorLines() []string {
+	var lines []string
+	for _, dep := range runtime.Doctor() {
+		status := errorStyle.Render("missing")
+		if dep.Found {
+			status = okStyle.Render("ok     ")
+		}
+		required := "optional"
+		if dep.Required {
+			required = "required"
+		}
+		detail := dep.Purpose
+		if dep.Path != "" {
+			detail = commandStyle.Render(dep.Path)
+		}
+		lines = append(lines, fmt.Sprintf("%-10s  %s  %-8s  %s", dep.Name, status, required, detail))
+	}
+	lines = append(lines, "")
+	for _, check := range runtime.Checks() {
+		var status string
+		switch check.Status {
+		case runtime.CheckOK:
+			status = okStyle.Render("ok     ")
+		case runtime.CheckWarn:
+			status = labelStyle.Render("warn   ")
+		default:
+			status = errorStyle.Render("fail   ")
+		}
+		lines = append(lines, fmt.Sprintf("%-10s  %s  %s", check.Name, status, check.Detail))
+	}
+	return lines
+}